@@ -3,9 +3,14 @@ package adapter
 import (
 	"context"
 	"net"
+	"time"
 
 	"github.com/sagernet/sing-box/common/urltest"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/atomic"
 	N "github.com/sagernet/sing/common/network"
+
+	mdns "github.com/miekg/dns"
 )
 
 type ClashServer interface {
@@ -14,20 +19,67 @@ type ClashServer interface {
 	Mode() string
 	StoreSelected() bool
 	StoreFakeIP() bool
+	StoreDNS() bool
+	StoreUsers() bool
+	StoreQuota() bool
 	CacheFile() ClashCacheFile
 	HistoryStorage() *urltest.HistoryStorage
 	RoutedConnection(ctx context.Context, conn net.Conn, metadata InboundContext, matchedRule Rule) (net.Conn, Tracker)
 	RoutedPacketConnection(ctx context.Context, conn N.PacketConn, metadata InboundContext, matchedRule Rule) (N.PacketConn, Tracker)
+	TrafficSnapshot() TrafficSnapshot
+	SetReloader(reloader Reloader)
+}
+
+// TrafficSnapshot is the total traffic and active connections tracked by the Clash API.
+type TrafficSnapshot struct {
+	UploadTotal   int64
+	DownloadTotal int64
+	Connections   []ConnectionSnapshot
+}
+
+type ConnectionSnapshot struct {
+	Network  string
+	Inbound  string
+	Outbound string
+}
+
+type Reloader interface {
+	Reload(options option.Options) error
 }
 
 type ClashCacheFile interface {
 	LoadSelected(group string) string
 	StoreSelected(group string, selected string) error
 	FakeIPStorage
+	DNSCacheStorage
+	InboundUserStorage
+	UserQuotaStorage
+}
+
+// InboundUserStorage records users added or removed at runtime. A user stored
+// with empty content has been removed.
+type InboundUserStorage interface {
+	LoadInboundUsers(inbound string) map[string][]byte
+	StoreInboundUser(inbound string, name string, content []byte) error
+}
+
+// UserQuotaStorage records the used quota of limited users, and when it was last reset.
+type UserQuotaStorage interface {
+	LoadUserQuota(inbound string, user string) (used int64, resetAt time.Time, loaded bool)
+	StoreUserQuota(inbound string, user string, used int64, resetAt time.Time) error
+}
+
+type DNSCacheStorage interface {
+	LoadDNSCache(question mdns.Question) (message *mdns.Msg, expireAt time.Time, loaded bool)
+	StoreDNSCache(question mdns.Question, message *mdns.Msg, expireAt time.Time) error
+	DNSCachePurge(purgeExpired bool) error
+	DNSCacheReset() error
 }
 
+// Tracker is notified when a routed connection is finished, with the error
+// returned by the outbound.
 type Tracker interface {
-	Leave()
+	Leave(err error)
 }
 
 type OutboundGroup interface {
@@ -47,6 +99,38 @@ func OutboundTag(detour Outbound) string {
 	return detour.Tag()
 }
 
+// ResolveOutboundChain returns the outbounds selected for a connection, starting from the
+// routed one. Hops of a chain outbound are listed in reverse dial order, so the
+// reversed chain reads in dial order. Resolving stops at an outbound which depends on itself.
+func ResolveOutboundChain(router Router, next string) []string {
+	return resolveOutboundChain(router, next, make(map[string]bool))
+}
+
+func resolveOutboundChain(router Router, next string, resolving map[string]bool) []string {
+	var chain []string
+	for !resolving[next] {
+		resolving[next] = true
+		defer delete(resolving, next)
+		chain = append(chain, next)
+		detour, loaded := router.Outbound(next)
+		if !loaded {
+			break
+		}
+		if group, isGroup := detour.(OutboundGroup); isGroup {
+			next = group.Now()
+			continue
+		}
+		if outboundChain, isChain := detour.(OutboundChain); isChain {
+			hops := outboundChain.Chain()
+			for i := len(hops) - 1; i >= 0; i-- {
+				chain = append(chain, resolveOutboundChain(router, hops[i], resolving)...)
+			}
+		}
+		break
+	}
+	return chain
+}
+
 type V2RayServer interface {
 	Service
 	StatsService() V2RayStatsService
@@ -55,4 +139,19 @@ type V2RayServer interface {
 type V2RayStatsService interface {
 	RoutedConnection(inbound string, outbound string, user string, conn net.Conn) net.Conn
 	RoutedPacketConnection(inbound string, outbound string, user string, conn N.PacketConn) N.PacketConn
+	Counter(name string) *atomic.Int64
+	Counters() map[string]int64
+	UpdateRules(rules []Rule, dnsRules []DNSRule)
+}
+
+// AccessLogger records one entry for each routed connection once it is finished.
+type AccessLogger interface {
+	Service
+	RoutedConnection(ctx context.Context, conn net.Conn, metadata InboundContext, matchedRule Rule, outbound Outbound) (net.Conn, Tracker)
+	RoutedPacketConnection(ctx context.Context, conn N.PacketConn, metadata InboundContext, matchedRule Rule, outbound Outbound) (N.PacketConn, Tracker)
+}
+
+type MetricsServer interface {
+	Service
+	DNSQuery(server string, elapsed time.Duration, err error)
 }
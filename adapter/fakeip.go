@@ -12,6 +12,16 @@ type FakeIPStore interface {
 	Create(domain string, strategy dns.DomainStrategy) (netip.Addr, error)
 	Lookup(address netip.Addr) (string, bool)
 	Reset() error
+	Usage() FakeIPUsage
+}
+
+// FakeIPUsage is the number of addresses allocated from the ranges and the size
+// of the ranges. Addresses are reused once a range is exhausted.
+type FakeIPUsage struct {
+	Inet4Allocated float64
+	Inet4Size      float64
+	Inet6Allocated float64
+	Inet6Size      float64
 }
 
 type FakeIPStorage interface {
@@ -4,8 +4,10 @@ import (
 	"context"
 	"net"
 	"net/netip"
+	"time"
 
 	"github.com/sagernet/sing-box/common/process"
+	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
 	M "github.com/sagernet/sing/common/metadata"
 	N "github.com/sagernet/sing/common/network"
@@ -17,6 +19,28 @@ type Inbound interface {
 	Tag() string
 }
 
+type UserManagedInbound interface {
+	Inbound
+	Users() []UserInfo
+	AddUsers(content []byte) error
+	RemoveUsers(names []string) error
+}
+
+// UserInfo is a user of a managed inbound as listed through the API, without its credentials.
+type UserInfo struct {
+	Name string `json:"name,omitempty"`
+	option.UserLimitOptions
+	Usage *UserUsage `json:"usage,omitempty"`
+}
+
+// UserUsage is the usage of a user with limits. ResetAt is nil if the quota is never reset.
+type UserUsage struct {
+	Used        int64      `json:"used"`
+	Quota       int64      `json:"quota,omitempty"`
+	ResetAt     *time.Time `json:"reset_at,omitempty"`
+	Connections int32      `json:"connections"`
+}
+
 type InjectableInbound interface {
 	Inbound
 	Network() []string
@@ -35,6 +59,15 @@ type InboundContext struct {
 	Protocol    string
 	User        string
 	Outbound    string
+	Rule        string
+
+	// sniffed client information
+
+	ALPN              []string
+	TLSVersion        uint16
+	ClientFingerprint string
+	Client            string
+	UserAgent         string
 
 	// cache
 
@@ -52,6 +85,36 @@ type InboundContext struct {
 	QueryType uint16
 }
 
+// LogFields returns the fields of structured log entries of the connection.
+func (c *InboundContext) LogFields() []log.Field {
+	var fields []log.Field
+	if c.Inbound != "" {
+		fields = append(fields, log.Field{Key: "inbound", Value: c.Inbound})
+	}
+	if c.Network != "" {
+		fields = append(fields, log.Field{Key: "network", Value: c.Network})
+	}
+	if c.Source.IsValid() {
+		fields = append(fields, log.Field{Key: "source", Value: c.Source.String()})
+	}
+	if c.Destination.IsValid() {
+		fields = append(fields, log.Field{Key: "destination", Value: c.Destination.String()})
+	}
+	if c.Domain != "" {
+		fields = append(fields, log.Field{Key: "domain", Value: c.Domain})
+	}
+	if c.User != "" {
+		fields = append(fields, log.Field{Key: "user", Value: c.User})
+	}
+	if c.Outbound != "" {
+		fields = append(fields, log.Field{Key: "outbound", Value: c.Outbound})
+	}
+	if c.Rule != "" {
+		fields = append(fields, log.Field{Key: "rule", Value: c.Rule})
+	}
+	return fields
+}
+
 type inboundContextKey struct{}
 
 func WithContext(ctx context.Context, inboundContext *InboundContext) context.Context {
@@ -19,6 +19,11 @@ type Outbound interface {
 	NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata InboundContext) error
 }
 
+// OutboundChain is implemented by outbounds that dial through other outbounds in order.
+type OutboundChain interface {
+	Chain() []string
+}
+
 type IPOutbound interface {
 	Outbound
 	NewIPConnection(ctx context.Context, conn tun.RouteContext, metadata InboundContext) (tun.DirectDestination, error)
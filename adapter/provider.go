@@ -0,0 +1,34 @@
+package adapter
+
+import (
+	"context"
+	"time"
+
+	"github.com/sagernet/sing/common/x/list"
+)
+
+type OutboundProvider interface {
+	Service
+	Type() string
+	Tag() string
+	Outbounds() []Outbound
+	Outbound(tag string) (Outbound, bool)
+	UpdatedAt() time.Time
+	Update() error
+	HealthCheck(ctx context.Context) (map[string]uint16, error)
+	RegisterCallback(callback OutboundProviderUpdateCallback) *list.Element[OutboundProviderUpdateCallback]
+	UnregisterCallback(element *list.Element[OutboundProviderUpdateCallback])
+}
+
+type OutboundProviderUpdateCallback = func(provider OutboundProvider)
+
+type RuleProvider interface {
+	Service
+	Type() string
+	Tag() string
+	Format() string
+	RuleCount() int
+	UpdatedAt() time.Time
+	Update() error
+	Match(metadata *InboundContext) bool
+}
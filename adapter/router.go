@@ -17,9 +17,16 @@ import (
 type Router interface {
 	Service
 
+	Inbound(tag string) (Inbound, bool)
 	Outbounds() []Outbound
 	Outbound(tag string) (Outbound, bool)
 	DefaultOutbound(network string) Outbound
+	OutboundProviders() []OutboundProvider
+	OutboundProvider(tag string) (OutboundProvider, bool)
+	RuleProviders() []RuleProvider
+	RuleProvider(tag string) (RuleProvider, bool)
+	Script() RouteScript
+	SetScript(script RouteScript)
 
 	FakeIPStore() FakeIPStore
 
@@ -35,6 +42,7 @@ type Router interface {
 	Exchange(ctx context.Context, message *mdns.Msg) (*mdns.Msg, error)
 	Lookup(ctx context.Context, domain string, strategy dns.DomainStrategy) ([]netip.Addr, error)
 	LookupDefault(ctx context.Context, domain string) ([]netip.Addr, error)
+	ClearDNSCache() error
 
 	InterfaceFinder() control.InterfaceFinder
 	DefaultInterface() string
@@ -47,6 +55,7 @@ type Router interface {
 
 	Rules() []Rule
 	IPRules() []IPRule
+	DNSRules() []DNSRule
 
 	TimeService
 
@@ -55,6 +64,12 @@ type Router interface {
 
 	V2RayServer() V2RayServer
 	SetV2RayServer(server V2RayServer)
+
+	MetricsServer() MetricsServer
+	SetMetricsServer(server MetricsServer)
+
+	AccessLogger() AccessLogger
+	SetAccessLogger(logger AccessLogger)
 }
 
 type routerContextKey struct{}
@@ -78,6 +93,11 @@ type Rule interface {
 	Match(metadata *InboundContext) bool
 	Outbound() string
 	String() string
+	Statistics() *RuleStatistics
+}
+
+type RouteScript interface {
+	Outbound(ctx context.Context, metadata *InboundContext) (string, error)
 }
 
 type DNSRule interface {
@@ -0,0 +1,27 @@
+package adapter
+
+import (
+	"time"
+
+	"github.com/sagernet/sing/common/atomic"
+)
+
+// RuleStatistics counts matches of a rule and traffic of connections routed by it.
+type RuleStatistics struct {
+	Hits     atomic.Int64
+	LastHit  atomic.Int64
+	Upload   atomic.Int64
+	Download atomic.Int64
+}
+
+func (s *RuleStatistics) Hit() {
+	s.Hits.Add(1)
+	s.LastHit.Store(time.Now().Unix())
+}
+
+func (s *RuleStatistics) Reset() {
+	s.Hits.Store(0)
+	s.LastHit.Store(0)
+	s.Upload.Store(0)
+	s.Download.Store(0)
+}
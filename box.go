@@ -6,15 +6,18 @@ import (
 	"io"
 	"os"
 	"runtime/debug"
+	"sync"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/experimental"
+	"github.com/sagernet/sing-box/experimental/accesslog"
 	"github.com/sagernet/sing-box/experimental/libbox/platform"
-	"github.com/sagernet/sing-box/inbound"
+	"github.com/sagernet/sing-box/experimental/metrics"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing-box/outbound"
+	"github.com/sagernet/sing-box/provider"
 	"github.com/sagernet/sing-box/route"
 	"github.com/sagernet/sing/common"
 	E "github.com/sagernet/sing/common/exceptions"
@@ -24,15 +27,20 @@ import (
 var _ adapter.Service = (*Box)(nil)
 
 type Box struct {
-	createdAt    time.Time
-	router       adapter.Router
-	inbounds     []adapter.Inbound
-	outbounds    []adapter.Outbound
-	logFactory   log.Factory
-	logger       log.ContextLogger
-	preServices  map[string]adapter.Service
-	postServices map[string]adapter.Service
-	done         chan struct{}
+	createdAt         time.Time
+	ctx               context.Context
+	options           option.Options
+	platformInterface platform.Interface
+	reloadAccess      sync.Mutex
+	router            *route.Router
+	inbounds          []adapter.Inbound
+	outbounds         []adapter.Outbound
+	providers         []adapter.OutboundProvider
+	logFactory        log.Factory
+	logger            log.ContextLogger
+	preServices       map[string]adapter.Service
+	postServices      map[string]adapter.Service
+	done              chan struct{}
 }
 
 type Options struct {
@@ -51,11 +59,20 @@ func New(options Options) (*Box, error) {
 	applyDebugOptions(common.PtrValueOrDefault(experimentalOptions.Debug))
 	var needClashAPI bool
 	var needV2RayAPI bool
+	var needMetrics bool
 	if experimentalOptions.ClashAPI != nil && experimentalOptions.ClashAPI.ExternalController != "" {
 		needClashAPI = true
 	}
-	if experimentalOptions.V2RayAPI != nil && experimentalOptions.V2RayAPI.Listen != "" {
-		needV2RayAPI = true
+	if experimentalOptions.Metrics != nil && experimentalOptions.Metrics.Listen != "" {
+		needMetrics = true
+	}
+	if experimentalOptions.V2RayAPI != nil {
+		if experimentalOptions.V2RayAPI.Listen != "" {
+			needV2RayAPI = true
+		} else if needMetrics && experimentalOptions.V2RayAPI.Stats != nil && experimentalOptions.V2RayAPI.Stats.Enabled {
+			// statistics for metrics only
+			needV2RayAPI = true
+		}
 	}
 	var defaultLogWriter io.Writer
 	if options.PlatformInterface != nil {
@@ -87,44 +104,30 @@ func New(options Options) (*Box, error) {
 	outbounds := make([]adapter.Outbound, 0, len(options.Outbounds))
 	for i, inboundOptions := range options.Inbounds {
 		var in adapter.Inbound
-		var tag string
-		if inboundOptions.Tag != "" {
-			tag = inboundOptions.Tag
-		} else {
-			tag = F.ToString(i)
-		}
-		in, err = inbound.New(
-			ctx,
-			router,
-			logFactory.NewLogger(F.ToString("inbound/", inboundOptions.Type, "[", tag, "]")),
-			inboundOptions,
-			options.PlatformInterface,
-		)
+		in, err = newInbound(ctx, router, logFactory, i, inboundOptions, options.PlatformInterface)
 		if err != nil {
-			return nil, E.Cause(err, "parse inbound[", i, "]")
+			return nil, err
 		}
 		inbounds = append(inbounds, in)
 	}
 	for i, outboundOptions := range options.Outbounds {
 		var out adapter.Outbound
-		var tag string
-		if outboundOptions.Tag != "" {
-			tag = outboundOptions.Tag
-		} else {
-			tag = F.ToString(i)
-		}
-		out, err = outbound.New(
-			ctx,
-			router,
-			logFactory.NewLogger(F.ToString("outbound/", outboundOptions.Type, "[", tag, "]")),
-			tag,
-			outboundOptions)
+		out, err = newOutbound(ctx, router, logFactory, i, outboundOptions)
 		if err != nil {
-			return nil, E.Cause(err, "parse outbound[", i, "]")
+			return nil, err
 		}
 		outbounds = append(outbounds, out)
 	}
-	err = router.Initialize(inbounds, outbounds, func() adapter.Outbound {
+	providers := make([]adapter.OutboundProvider, 0, len(options.OutboundProviders))
+	for i, providerOptions := range options.OutboundProviders {
+		var outboundProvider adapter.OutboundProvider
+		outboundProvider, err = provider.NewOutbound(ctx, router, logFactory, providerOptions)
+		if err != nil {
+			return nil, E.Cause(err, "parse outbound provider[", i, "]")
+		}
+		providers = append(providers, outboundProvider)
+	}
+	err = router.Initialize(inbounds, outbounds, providers, func() adapter.Outbound {
 		out, oErr := outbound.New(ctx, router, logFactory.NewLogger("outbound/direct"), "direct", option.Outbound{Type: "direct", Tag: "default"})
 		common.Must(oErr)
 		outbounds = append(outbounds, out)
@@ -151,17 +154,38 @@ func New(options Options) (*Box, error) {
 		router.SetV2RayServer(v2rayServer)
 		preServices["v2ray api"] = v2rayServer
 	}
-	return &Box{
-		router:       router,
-		inbounds:     inbounds,
-		outbounds:    outbounds,
-		createdAt:    createdAt,
-		logFactory:   logFactory,
-		logger:       logFactory.Logger(),
-		preServices:  preServices,
-		postServices: postServices,
-		done:         make(chan struct{}),
-	}, nil
+	if needMetrics {
+		metricsServer := metrics.NewServer(router, logFactory.NewLogger("metrics"), common.PtrValueOrDefault(options.Experimental.Metrics))
+		router.SetMetricsServer(metricsServer)
+		preServices["metrics"] = metricsServer
+	}
+	if experimentalOptions.AccessLog != nil {
+		accessLogger, err := accesslog.NewLogger(router, logFactory.NewLogger("access-log"), *experimentalOptions.AccessLog)
+		if err != nil {
+			return nil, E.Cause(err, "create access logger")
+		}
+		router.SetAccessLogger(accessLogger)
+		preServices["access log"] = accessLogger
+	}
+	box := &Box{
+		ctx:               ctx,
+		options:           options.Options,
+		platformInterface: options.PlatformInterface,
+		router:            router,
+		inbounds:          inbounds,
+		outbounds:         outbounds,
+		providers:         providers,
+		createdAt:         createdAt,
+		logFactory:        logFactory,
+		logger:            logFactory.Logger(),
+		preServices:       preServices,
+		postServices:      postServices,
+		done:              make(chan struct{}),
+	}
+	if clashServer := router.ClashServer(); clashServer != nil {
+		clashServer.SetReloader(box)
+	}
+	return box, nil
 }
 
 func (s *Box) PreStart() error {
@@ -225,7 +249,18 @@ func (s *Box) preStart() error {
 			}
 		}
 	}
-	return s.router.Start()
+	err := s.router.Start()
+	if err != nil {
+		return err
+	}
+	for _, outboundProvider := range s.providers {
+		s.logger.Trace("initializing provider/outbound[", outboundProvider.Tag(), "]")
+		err = outboundProvider.Start()
+		if err != nil {
+			return E.Cause(err, "initialize provider/outbound[", outboundProvider.Tag(), "]")
+		}
+	}
+	return nil
 }
 
 func (s *Box) start() error {
@@ -289,6 +324,12 @@ func (s *Box) Close() error {
 			return E.Cause(err, "close outbound/", out.Type(), "[", i, "]")
 		})
 	}
+	for _, outboundProvider := range s.providers {
+		s.logger.Trace("closing provider/outbound[", outboundProvider.Tag(), "]")
+		errors = E.Append(errors, outboundProvider.Close(), func(err error) error {
+			return E.Cause(err, "close provider/outbound[", outboundProvider.Tag(), "]")
+		})
+	}
 	s.logger.Trace("closing router")
 	if err := common.Close(s.router); err != nil {
 		errors = E.Append(errors, err, func(err error) error {
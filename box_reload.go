@@ -0,0 +1,471 @@
+package box
+
+import (
+	"context"
+	"os"
+	"reflect"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/json"
+	"github.com/sagernet/sing-box/experimental/libbox/platform"
+	"github.com/sagernet/sing-box/inbound"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/outbound"
+	"github.com/sagernet/sing-box/provider"
+	"github.com/sagernet/sing-box/route"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+)
+
+var _ adapter.Reloader = (*Box)(nil)
+
+var ErrRestartRequired = E.New("restart required")
+
+// Reload applies new options to a started box.
+// Inbounds, outbounds, outbound providers, rule providers and DNS servers whose options
+// and dependencies are unchanged are kept running, along with their connections.
+// ErrRestartRequired is returned if options that can not be changed at runtime differ.
+// New objects are validated and started before replacing the running ones; on failure,
+// they are closed and the running configuration is kept. Replaced inbounds are closed
+// right before their replacements are started, so that those can bind the same addresses,
+// and are created again if any new inbound fails to start.
+func (s *Box) Reload(options option.Options) (err error) {
+	s.reloadAccess.Lock()
+	defer s.reloadAccess.Unlock()
+	select {
+	case <-s.done:
+		return os.ErrClosed
+	default:
+	}
+	err = checkReloadOptions(s.options, options)
+	if err != nil {
+		return err
+	}
+
+	changedProviders := make(map[string]bool)
+	oldProviderByTag := make(map[string]adapter.OutboundProvider)
+	oldProviderOptions := make(map[string]option.OutboundProvider)
+	for i, providerOptions := range s.options.OutboundProviders {
+		oldProviderByTag[providerOptions.Tag] = s.providers[i]
+		oldProviderOptions[providerOptions.Tag] = providerOptions
+		changedProviders[providerOptions.Tag] = true
+	}
+	for _, providerOptions := range options.OutboundProviders {
+		changedProviders[providerOptions.Tag] = !reflect.DeepEqual(oldProviderOptions[providerOptions.Tag], providerOptions)
+	}
+
+	changedOutbounds := make(map[string]bool)
+	oldOutboundByTag := make(map[string]adapter.Outbound)
+	oldOutboundOptions := make(map[string]option.Outbound)
+	for i, outboundOptions := range s.options.Outbounds {
+		tag := outboundTag(i, outboundOptions)
+		oldOutboundByTag[tag] = s.outbounds[i]
+		oldOutboundOptions[tag] = outboundOptions
+		changedOutbounds[tag] = true
+	}
+	outboundReferences := make(map[string]reloadReferences)
+	for i, outboundOptions := range options.Outbounds {
+		tag := outboundTag(i, outboundOptions)
+		changedOutbounds[tag] = !reflect.DeepEqual(oldOutboundOptions[tag], outboundOptions)
+		outboundReferences[tag] = findReferences(outboundOptions, true)
+	}
+	for {
+		var changed bool
+		for tag, references := range outboundReferences {
+			if !changedOutbounds[tag] && references.dependsOn(changedOutbounds, changedProviders) {
+				changedOutbounds[tag] = true
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	changedInbounds := make(map[string]bool)
+	oldInboundByTag := make(map[string]adapter.Inbound)
+	oldInboundOptions := make(map[string]option.Inbound)
+	for i, inboundOptions := range s.options.Inbounds {
+		tag := inboundTag(i, inboundOptions)
+		oldInboundByTag[tag] = s.inbounds[i]
+		oldInboundOptions[tag] = inboundOptions
+		changedInbounds[tag] = true
+	}
+	for i, inboundOptions := range options.Inbounds {
+		tag := inboundTag(i, inboundOptions)
+		changedInbounds[tag] = !reflect.DeepEqual(oldInboundOptions[tag], inboundOptions) ||
+			findReferences(inboundOptions, false).dependsOn(changedOutbounds, changedProviders)
+	}
+
+	var (
+		newInbounds  []adapter.Inbound
+		newOutbounds []adapter.Outbound
+		newProviders []adapter.OutboundProvider
+	)
+	var (
+		committed        bool
+		closeErr         error
+		restoredInbounds []adapter.Inbound
+	)
+	defer func() {
+		if err == nil || committed {
+			return
+		}
+		for _, in := range newInbounds {
+			in.Close()
+		}
+		for _, out := range newOutbounds {
+			common.Close(out)
+		}
+		for _, outboundProvider := range newProviders {
+			outboundProvider.Close()
+		}
+	}()
+	// new outbounds and providers resolve each other from the new options until the router is reloaded
+	reloadRouter := s.router.NewReloadRouter()
+	inbounds := make([]adapter.Inbound, 0, len(options.Inbounds))
+	for i, inboundOptions := range options.Inbounds {
+		tag := inboundTag(i, inboundOptions)
+		if !changedInbounds[tag] {
+			inbounds = append(inbounds, oldInboundByTag[tag])
+			continue
+		}
+		in, err := newInbound(s.ctx, s.router, s.logFactory, i, inboundOptions, s.platformInterface)
+		if err != nil {
+			return err
+		}
+		inbounds = append(inbounds, in)
+		newInbounds = append(newInbounds, in)
+	}
+	outbounds := make([]adapter.Outbound, 0, len(options.Outbounds))
+	for i, outboundOptions := range options.Outbounds {
+		tag := outboundTag(i, outboundOptions)
+		if !changedOutbounds[tag] {
+			outbounds = append(outbounds, oldOutboundByTag[tag])
+			continue
+		}
+		out, err := newOutbound(s.ctx, reloadRouter, s.logFactory, i, outboundOptions)
+		if err != nil {
+			return err
+		}
+		outbounds = append(outbounds, out)
+		newOutbounds = append(newOutbounds, out)
+	}
+	providers := make([]adapter.OutboundProvider, 0, len(options.OutboundProviders))
+	for i, providerOptions := range options.OutboundProviders {
+		if !changedProviders[providerOptions.Tag] {
+			providers = append(providers, oldProviderByTag[providerOptions.Tag])
+			continue
+		}
+		outboundProvider, err := provider.NewOutbound(s.ctx, reloadRouter, s.logFactory, providerOptions)
+		if err != nil {
+			return E.Cause(err, "parse outbound provider[", i, "]")
+		}
+		providers = append(providers, outboundProvider)
+		newProviders = append(newProviders, outboundProvider)
+	}
+	// the direct outbound created by the router when no default outbound is configured
+	var defaultOutbound adapter.Outbound
+	if len(s.outbounds) > len(s.options.Outbounds) {
+		defaultOutbound = s.outbounds[len(s.outbounds)-1]
+	}
+	err = s.router.Reload(
+		reloadRouter,
+		common.PtrValueOrDefault(options.Route),
+		common.PtrValueOrDefault(options.DNS),
+		inbounds,
+		outbounds,
+		providers,
+		func() adapter.Outbound {
+			if defaultOutbound == nil {
+				var oErr error
+				defaultOutbound, oErr = outbound.New(s.ctx, reloadRouter, s.logFactory.NewLogger("outbound/direct"), "direct", option.Outbound{Type: "direct", Tag: "default"})
+				common.Must(oErr)
+				newOutbounds = append(newOutbounds, defaultOutbound)
+			}
+			outbounds = append(outbounds, defaultOutbound)
+			return defaultOutbound
+		},
+		func() error {
+			for _, out := range newOutbounds {
+				if starter, isStarter := out.(common.Starter); isStarter {
+					s.logger.Trace("initializing outbound/", out.Type(), "[", out.Tag(), "]")
+					err := starter.Start()
+					if err != nil {
+						return E.Cause(err, "initialize outbound/", out.Type(), "[", out.Tag(), "]")
+					}
+				}
+			}
+			for _, outboundProvider := range newProviders {
+				s.logger.Trace("initializing provider/outbound[", outboundProvider.Tag(), "]")
+				err := outboundProvider.Start()
+				if err != nil {
+					return E.Cause(err, "initialize provider/outbound[", outboundProvider.Tag(), "]")
+				}
+			}
+			return nil
+		},
+		func() error {
+			closeErr = s.closeInbounds(oldInboundByTag, changedInbounds)
+			for _, in := range newInbounds {
+				s.logger.Trace("initializing inbound/", in.Type(), "[", in.Tag(), "]")
+				startErr := in.Start()
+				if startErr != nil {
+					startErr = E.Cause(startErr, "initialize inbound/", in.Type(), "[", in.Tag(), "]")
+					for _, in := range newInbounds {
+						in.Close()
+					}
+					newInbounds = nil
+					var restoreErr error
+					restoredInbounds, restoreErr = s.restoreInbounds(changedInbounds)
+					return E.Errors(startErr, restoreErr)
+				}
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		if restoredInbounds != nil {
+			s.inbounds = restoredInbounds
+			s.router.UpdateInbounds(restoredInbounds)
+		}
+		return E.Cause(err, "reload router")
+	}
+
+	committed = true
+	err = closeErr
+	for tag, out := range oldOutboundByTag {
+		if !changedOutbounds[tag] {
+			continue
+		}
+		s.logger.Trace("closing outbound/", out.Type(), "[", tag, "]")
+		err = E.Append(err, common.Close(out), func(err error) error {
+			return E.Cause(err, "close outbound/", out.Type(), "[", tag, "]")
+		})
+	}
+	if len(s.outbounds) > len(s.options.Outbounds) && len(outbounds) == len(options.Outbounds) {
+		err = E.Append(err, common.Close(defaultOutbound), func(err error) error {
+			return E.Cause(err, "close outbound/direct[default]")
+		})
+	}
+	for tag, outboundProvider := range oldProviderByTag {
+		if !changedProviders[tag] {
+			continue
+		}
+		s.logger.Trace("closing provider/outbound[", tag, "]")
+		err = E.Append(err, outboundProvider.Close(), func(err error) error {
+			return E.Cause(err, "close provider/outbound[", tag, "]")
+		})
+	}
+	s.inbounds = inbounds
+	s.outbounds = outbounds
+	s.providers = providers
+	s.options = options
+	if err != nil {
+		return err
+	}
+	s.logger.Info("sing-box reloaded: ", len(newInbounds), " inbounds, ", len(newOutbounds), " outbounds and ", len(newProviders), " providers replaced")
+	return nil
+}
+
+func (s *Box) closeInbounds(inboundByTag map[string]adapter.Inbound, changedInbounds map[string]bool) error {
+	var err error
+	for tag, in := range inboundByTag {
+		if !changedInbounds[tag] {
+			continue
+		}
+		s.logger.Trace("closing inbound/", in.Type(), "[", tag, "]")
+		err = E.Append(err, in.Close(), func(err error) error {
+			return E.Cause(err, "close inbound/", in.Type(), "[", tag, "]")
+		})
+	}
+	return err
+}
+
+// restoreInbounds creates and starts the closed inbounds again from the running options.
+func (s *Box) restoreInbounds(changedInbounds map[string]bool) ([]adapter.Inbound, error) {
+	inbounds := make([]adapter.Inbound, len(s.inbounds))
+	copy(inbounds, s.inbounds)
+	var err error
+	for i, inboundOptions := range s.options.Inbounds {
+		tag := inboundTag(i, inboundOptions)
+		if !changedInbounds[tag] {
+			continue
+		}
+		in, createErr := newInbound(s.ctx, s.router, s.logFactory, i, inboundOptions, s.platformInterface)
+		if createErr != nil {
+			err = E.Append(err, createErr, func(err error) error {
+				return E.Cause(err, "restore inbound[", i, "]")
+			})
+			continue
+		}
+		s.logger.Trace("restoring inbound/", in.Type(), "[", in.Tag(), "]")
+		startErr := in.Start()
+		if startErr != nil {
+			in.Close()
+			err = E.Append(err, startErr, func(err error) error {
+				return E.Cause(err, "restore inbound/", in.Type(), "[", in.Tag(), "]")
+			})
+			continue
+		}
+		inbounds[i] = in
+	}
+	return inbounds, err
+}
+
+func checkReloadOptions(oldOptions option.Options, newOptions option.Options) error {
+	oldLogOptions := common.PtrValueOrDefault(oldOptions.Log)
+	newLogOptions := common.PtrValueOrDefault(newOptions.Log)
+	newLogOptions.DisableColor = oldLogOptions.DisableColor
+	if !reflect.DeepEqual(oldLogOptions, newLogOptions) {
+		return E.Cause(ErrRestartRequired, "log options changed")
+	}
+	if !reflect.DeepEqual(oldOptions.NTP, newOptions.NTP) {
+		return E.Cause(ErrRestartRequired, "ntp options changed")
+	}
+	if !reflect.DeepEqual(oldOptions.Experimental, newOptions.Experimental) {
+		return E.Cause(ErrRestartRequired, "experimental options changed")
+	}
+	oldRouteOptions := common.PtrValueOrDefault(oldOptions.Route)
+	newRouteOptions := common.PtrValueOrDefault(newOptions.Route)
+	if !reflect.DeepEqual(oldRouteOptions.GeoIP, newRouteOptions.GeoIP) ||
+		!reflect.DeepEqual(oldRouteOptions.Geosite, newRouteOptions.Geosite) ||
+		oldRouteOptions.FindProcess != newRouteOptions.FindProcess ||
+		oldRouteOptions.AutoDetectInterface != newRouteOptions.AutoDetectInterface ||
+		oldRouteOptions.OverrideAndroidVPN != newRouteOptions.OverrideAndroidVPN ||
+		oldRouteOptions.DefaultInterface != newRouteOptions.DefaultInterface ||
+		oldRouteOptions.DefaultMark != newRouteOptions.DefaultMark {
+		return E.Cause(ErrRestartRequired, "route options changed")
+	}
+	oldDNSOptions := common.PtrValueOrDefault(oldOptions.DNS)
+	newDNSOptions := common.PtrValueOrDefault(newOptions.DNS)
+	if oldDNSOptions.ReverseMapping != newDNSOptions.ReverseMapping ||
+		!reflect.DeepEqual(oldDNSOptions.FakeIP, newDNSOptions.FakeIP) ||
+		oldDNSOptions.DisableCache != newDNSOptions.DisableCache ||
+		oldDNSOptions.DisableExpire != newDNSOptions.DisableExpire {
+		return E.Cause(ErrRestartRequired, "dns options changed")
+	}
+	return nil
+}
+
+type reloadReferences struct {
+	outbounds []string
+	providers []string
+}
+
+func (r reloadReferences) dependsOn(changedOutbounds map[string]bool, changedProviders map[string]bool) bool {
+	for _, tag := range r.outbounds {
+		if changedOutbounds[tag] {
+			return true
+		}
+	}
+	for _, tag := range r.providers {
+		if changedProviders[tag] {
+			return true
+		}
+	}
+	return false
+}
+
+// findReferences collects outbound and provider tags referenced by inbound or outbound options.
+// The top-level detour of an inbound refers to another inbound and is skipped.
+func findReferences(options any, topLevelDetour bool) reloadReferences {
+	var references reloadReferences
+	content, err := json.Marshal(options)
+	if err != nil {
+		return references
+	}
+	var object map[string]any
+	err = json.Unmarshal(content, &object)
+	if err != nil {
+		return references
+	}
+	if !topLevelDetour {
+		delete(object, "detour")
+	}
+	references.walk(object)
+	return references
+}
+
+func (r *reloadReferences) walk(value any) {
+	switch typedValue := value.(type) {
+	case map[string]any:
+		for key, item := range typedValue {
+			switch key {
+			case "detour":
+				if tag, isString := item.(string); isString {
+					r.outbounds = append(r.outbounds, tag)
+				}
+			case "outbounds":
+				r.outbounds = append(r.outbounds, stringList(item)...)
+			case "providers":
+				r.providers = append(r.providers, stringList(item)...)
+			default:
+				r.walk(item)
+			}
+		}
+	case []any:
+		for _, item := range typedValue {
+			r.walk(item)
+		}
+	}
+}
+
+func stringList(value any) []string {
+	var list []string
+	switch typedValue := value.(type) {
+	case string:
+		list = append(list, typedValue)
+	case []any:
+		for _, item := range typedValue {
+			if tag, isString := item.(string); isString {
+				list = append(list, tag)
+			}
+		}
+	}
+	return list
+}
+
+func inboundTag(index int, options option.Inbound) string {
+	if options.Tag != "" {
+		return options.Tag
+	}
+	return F.ToString(index)
+}
+
+func outboundTag(index int, options option.Outbound) string {
+	if options.Tag != "" {
+		return options.Tag
+	}
+	return F.ToString(index)
+}
+
+func newInbound(ctx context.Context, router *route.Router, logFactory log.Factory, index int, options option.Inbound, platformInterface platform.Interface) (adapter.Inbound, error) {
+	in, err := inbound.New(
+		ctx,
+		router,
+		logFactory.NewLogger(F.ToString("inbound/", options.Type, "[", inboundTag(index, options), "]")),
+		options,
+		platformInterface,
+	)
+	if err != nil {
+		return nil, E.Cause(err, "parse inbound[", index, "]")
+	}
+	return in, nil
+}
+
+func newOutbound(ctx context.Context, router adapter.Router, logFactory log.Factory, index int, options option.Outbound) (adapter.Outbound, error) {
+	tag := outboundTag(index, options)
+	out, err := outbound.New(
+		ctx,
+		router,
+		logFactory.NewLogger(F.ToString("outbound/", options.Type, "[", tag, "]")),
+		tag,
+		options)
+	if err != nil {
+		return nil, E.Cause(err, "parse outbound[", index, "]")
+	}
+	return out, nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"io"
 	"os"
 	"os/signal"
@@ -116,10 +117,10 @@ func readConfigAndMerge() (option.Options, error) {
 	return mergedOptions, nil
 }
 
-func create() (*box.Box, context.CancelFunc, error) {
+func readOptions() (option.Options, error) {
 	options, err := readConfigAndMerge()
 	if err != nil {
-		return nil, nil, err
+		return option.Options{}, err
 	}
 	if disableColor {
 		if options.Log == nil {
@@ -127,6 +128,14 @@ func create() (*box.Box, context.CancelFunc, error) {
 		}
 		options.Log.DisableColor = true
 	}
+	return options, nil
+}
+
+func create() (*box.Box, context.CancelFunc, error) {
+	options, err := readOptions()
+	if err != nil {
+		return nil, nil, err
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	instance, err := box.New(box.Options{
 		Context: ctx,
@@ -171,6 +180,15 @@ func run() error {
 		for {
 			osSignal := <-osSignals
 			if osSignal == syscall.SIGHUP {
+				err = reload(instance)
+				if err == nil {
+					continue
+				}
+				if !errors.Is(err, box.ErrRestartRequired) {
+					log.Error(E.Cause(err, "reload service"))
+					continue
+				}
+				log.Info(err, ", restarting service")
 				err = check()
 				if err != nil {
 					log.Error(E.Cause(err, "reload service"))
@@ -190,6 +208,14 @@ func run() error {
 	}
 }
 
+func reload(instance *box.Box) error {
+	options, err := readOptions()
+	if err != nil {
+		return err
+	}
+	return instance.Reload(options)
+}
+
 func closeMonitor(ctx context.Context) {
 	time.Sleep(3 * time.Second)
 	select {
@@ -0,0 +1,353 @@
+package congestion
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/sagernet/quic-go/congestion"
+)
+
+// A compact implementation of BBR (version 1) as described in
+// draft-cardwell-iccrg-bbr-congestion-control-00 and the Linux kernel,
+// driven by a per-packet delivery rate estimator.
+
+const (
+	bbrHighGain                   = 2.885 // 2/ln(2)
+	bbrDrainGain                  = 1 / bbrHighGain
+	bbrCongestionWindowGain       = 2
+	bbrBandwidthWindowRounds      = 10
+	bbrMinRTTWindow               = 10 * time.Second
+	bbrProbeRTTDuration           = 200 * time.Millisecond
+	bbrMinCongestionWindowPackets = 4
+	bbrFullBandwidthThreshold     = 1.25
+	bbrFullBandwidthRounds        = 3
+	bbrQuantizationBudgetPackets  = 3
+)
+
+var bbrPacingGainCycle = [...]float64{1.25, 0.75, 1, 1, 1, 1, 1, 1}
+
+type bbrMode uint8
+
+const (
+	bbrModeStartup bbrMode = iota
+	bbrModeDrain
+	bbrModeProbeBW
+	bbrModeProbeRTT
+)
+
+type bbrPacketState struct {
+	sentTime      time.Time
+	delivered     congestion.ByteCount
+	deliveredTime time.Time
+	firstSentTime time.Time
+}
+
+var _ congestion.CongestionControl = (*BBRSender)(nil)
+
+type BBRSender struct {
+	rttStats        congestion.RTTStatsProvider
+	pacer           *pacer
+	maxDatagramSize congestion.ByteCount
+
+	mode        bbrMode
+	pacingGain  float64
+	cwndGain    float64
+	cycleIndex  int
+	cycleStart  time.Time
+	lossInCycle bool
+
+	// delivery rate estimator
+	packets       map[congestion.PacketNumber]bbrPacketState
+	delivered     congestion.ByteCount
+	deliveredTime time.Time
+	firstSentTime time.Time
+
+	roundCount         uint64
+	nextRoundDelivered congestion.ByteCount
+	maxBandwidth       windowedMaxFilter // in bytes/s
+
+	minRTT            time.Duration
+	minRTTTimestamp   time.Time
+	probeRTTDoneTime  time.Time
+	probeRTTRoundDone bool
+
+	fullBandwidth        congestion.ByteCount
+	fullBandwidthCount   int
+	fullBandwidthReached bool
+
+	congestionWindow      congestion.ByteCount
+	priorCongestionWindow congestion.ByteCount
+}
+
+func NewBBRSender() *BBRSender {
+	b := &BBRSender{
+		maxDatagramSize:  initialMaxDatagramSize,
+		mode:             bbrModeStartup,
+		pacingGain:       bbrHighGain,
+		cwndGain:         bbrHighGain,
+		packets:          make(map[congestion.PacketNumber]bbrPacketState),
+		maxBandwidth:     windowedMaxFilter{window: bbrBandwidthWindowRounds},
+		congestionWindow: initialCongestionWindow * initialMaxDatagramSize,
+	}
+	b.pacer = newPacer(b.pacingRate)
+	return b
+}
+
+func (b *BBRSender) SetRTTStatsProvider(rttStats congestion.RTTStatsProvider) {
+	b.rttStats = rttStats
+}
+
+func (b *BBRSender) TimeUntilSend(_ congestion.ByteCount) time.Time {
+	return b.pacer.TimeUntilSend()
+}
+
+func (b *BBRSender) HasPacingBudget() bool {
+	return b.pacer.Budget(time.Now()) >= b.maxDatagramSize
+}
+
+func (b *BBRSender) OnPacketSent(sentTime time.Time, bytesInFlight congestion.ByteCount,
+	packetNumber congestion.PacketNumber, bytes congestion.ByteCount, isRetransmittable bool,
+) {
+	b.pacer.SentPacket(sentTime, bytes)
+	if !isRetransmittable {
+		return
+	}
+	if bytesInFlight <= bytes {
+		// nothing was in flight before this packet, restart the sampling interval
+		b.firstSentTime = sentTime
+		b.deliveredTime = sentTime
+	}
+	b.packets[packetNumber] = bbrPacketState{
+		sentTime:      sentTime,
+		delivered:     b.delivered,
+		deliveredTime: b.deliveredTime,
+		firstSentTime: b.firstSentTime,
+	}
+}
+
+func (b *BBRSender) CanSend(bytesInFlight congestion.ByteCount) bool {
+	return bytesInFlight < b.GetCongestionWindow()
+}
+
+func (b *BBRSender) MaybeExitSlowStart() {
+}
+
+func (b *BBRSender) OnPacketAcked(number congestion.PacketNumber, ackedBytes congestion.ByteCount,
+	priorInFlight congestion.ByteCount, eventTime time.Time,
+) {
+	b.delivered += ackedBytes
+	b.deliveredTime = eventTime
+	var roundStart bool
+	if packet, loaded := b.packets[number]; loaded {
+		delete(b.packets, number)
+		b.firstSentTime = packet.sentTime
+		if packet.delivered >= b.nextRoundDelivered {
+			b.nextRoundDelivered = b.delivered
+			b.roundCount++
+			roundStart = true
+		}
+		interval := maxDuration(packet.sentTime.Sub(packet.firstSentTime), eventTime.Sub(packet.deliveredTime))
+		if interval > 0 && interval >= b.minRTT {
+			bandwidth := congestion.ByteCount(float64(b.delivered-packet.delivered) / interval.Seconds())
+			b.maxBandwidth.Update(b.roundCount, bandwidth)
+		}
+	}
+	bytesInFlight := priorInFlight - ackedBytes
+	if roundStart {
+		b.checkFullBandwidthReached()
+	}
+	b.checkDrain(eventTime, bytesInFlight)
+	b.updateCyclePhase(eventTime, bytesInFlight)
+	b.updateMinRTT(eventTime, bytesInFlight, roundStart)
+	b.updateCongestionWindow(ackedBytes)
+}
+
+func (b *BBRSender) OnPacketLost(number congestion.PacketNumber, lostBytes congestion.ByteCount, priorInFlight congestion.ByteCount) {
+	delete(b.packets, number)
+	b.lossInCycle = true
+}
+
+func (b *BBRSender) OnRetransmissionTimeout(packetsRetransmitted bool) {
+	if !packetsRetransmitted {
+		return
+	}
+	b.congestionWindow = b.minCongestionWindow()
+}
+
+func (b *BBRSender) SetMaxDatagramSize(size congestion.ByteCount) {
+	if size < b.maxDatagramSize {
+		return
+	}
+	b.maxDatagramSize = size
+	b.congestionWindow = maxByteCount(b.congestionWindow, b.minCongestionWindow())
+	b.pacer.SetMaxDatagramSize(size)
+}
+
+func (b *BBRSender) InSlowStart() bool {
+	return b.mode == bbrModeStartup
+}
+
+func (b *BBRSender) InRecovery() bool {
+	return false
+}
+
+func (b *BBRSender) GetCongestionWindow() congestion.ByteCount {
+	return b.congestionWindow
+}
+
+func (b *BBRSender) minCongestionWindow() congestion.ByteCount {
+	return bbrMinCongestionWindowPackets * b.maxDatagramSize
+}
+
+func (b *BBRSender) maxCongestionWindow() congestion.ByteCount {
+	return maxCongestionWindowPackets * b.maxDatagramSize
+}
+
+func (b *BBRSender) smoothedRTT() time.Duration {
+	var rtt time.Duration
+	if b.rttStats != nil {
+		rtt = b.rttStats.SmoothedRTT()
+	}
+	if rtt == 0 {
+		rtt = defaultInitialRTT
+	}
+	return rtt
+}
+
+// pacingRate returns the current pacing rate in bytes/s
+func (b *BBRSender) pacingRate() congestion.ByteCount {
+	bandwidth := b.maxBandwidth.Get()
+	if bandwidth == 0 {
+		return congestion.ByteCount(bbrHighGain * float64(b.congestionWindow) / b.smoothedRTT().Seconds())
+	}
+	return congestion.ByteCount(b.pacingGain * float64(bandwidth))
+}
+
+// bdp returns the estimated bandwidth-delay product scaled by gain
+func (b *BBRSender) bdp(gain float64) congestion.ByteCount {
+	bandwidth := b.maxBandwidth.Get()
+	if bandwidth == 0 || b.minRTT == 0 {
+		return initialCongestionWindow * b.maxDatagramSize
+	}
+	return congestion.ByteCount(gain * float64(bandwidth) * b.minRTT.Seconds())
+}
+
+func (b *BBRSender) checkFullBandwidthReached() {
+	if b.fullBandwidthReached {
+		return
+	}
+	bandwidth := b.maxBandwidth.Get()
+	if float64(bandwidth) >= float64(b.fullBandwidth)*bbrFullBandwidthThreshold {
+		b.fullBandwidth = bandwidth
+		b.fullBandwidthCount = 0
+		return
+	}
+	b.fullBandwidthCount++
+	b.fullBandwidthReached = b.fullBandwidthCount >= bbrFullBandwidthRounds
+}
+
+func (b *BBRSender) checkDrain(now time.Time, bytesInFlight congestion.ByteCount) {
+	if b.mode == bbrModeStartup && b.fullBandwidthReached {
+		b.mode = bbrModeDrain
+		b.pacingGain = bbrDrainGain
+		b.cwndGain = bbrHighGain
+	}
+	if b.mode == bbrModeDrain && bytesInFlight <= b.bdp(1) {
+		b.enterProbeBandwidth(now)
+	}
+}
+
+func (b *BBRSender) enterProbeBandwidth(now time.Time) {
+	b.mode = bbrModeProbeBW
+	b.cwndGain = bbrCongestionWindowGain
+	// start at a random phase other than the draining one
+	b.cycleIndex = rand.Intn(len(bbrPacingGainCycle) - 1)
+	if b.cycleIndex >= 1 {
+		b.cycleIndex++
+	}
+	b.cycleStart = now
+	b.lossInCycle = false
+	b.pacingGain = bbrPacingGainCycle[b.cycleIndex]
+}
+
+func (b *BBRSender) updateCyclePhase(now time.Time, bytesInFlight congestion.ByteCount) {
+	if b.mode != bbrModeProbeBW {
+		return
+	}
+	isFullLength := now.Sub(b.cycleStart) > b.minRTT
+	var advance bool
+	switch {
+	case b.pacingGain == 1:
+		advance = isFullLength
+	case b.pacingGain > 1:
+		// probing for bandwidth until the pipe is full or losses occur
+		advance = isFullLength && (b.lossInCycle || bytesInFlight >= b.bdp(b.pacingGain))
+	default:
+		// draining the queue created while probing
+		advance = isFullLength || bytesInFlight <= b.bdp(1)
+	}
+	if advance {
+		b.cycleIndex = (b.cycleIndex + 1) % len(bbrPacingGainCycle)
+		b.cycleStart = now
+		b.lossInCycle = false
+		b.pacingGain = bbrPacingGainCycle[b.cycleIndex]
+	}
+}
+
+func (b *BBRSender) updateMinRTT(now time.Time, bytesInFlight congestion.ByteCount, roundStart bool) {
+	expired := !b.minRTTTimestamp.IsZero() && now.Sub(b.minRTTTimestamp) > bbrMinRTTWindow
+	if b.rttStats != nil {
+		latestRTT := b.rttStats.LatestRTT()
+		if latestRTT > 0 && (b.minRTT == 0 || latestRTT < b.minRTT || expired) {
+			b.minRTT = latestRTT
+			b.minRTTTimestamp = now
+		}
+	}
+	if expired && b.mode != bbrModeProbeRTT {
+		b.mode = bbrModeProbeRTT
+		b.pacingGain = 1
+		b.cwndGain = 1
+		b.priorCongestionWindow = maxByteCount(b.priorCongestionWindow, b.congestionWindow)
+		b.probeRTTDoneTime = time.Time{}
+	}
+	if b.mode != bbrModeProbeRTT {
+		return
+	}
+	if b.probeRTTDoneTime.IsZero() {
+		if bytesInFlight <= b.minCongestionWindow() {
+			b.probeRTTDoneTime = now.Add(bbrProbeRTTDuration)
+			b.probeRTTRoundDone = false
+			b.nextRoundDelivered = b.delivered
+		}
+		return
+	}
+	if roundStart {
+		b.probeRTTRoundDone = true
+	}
+	if b.probeRTTRoundDone && now.After(b.probeRTTDoneTime) {
+		b.minRTTTimestamp = now
+		b.congestionWindow = maxByteCount(b.congestionWindow, b.priorCongestionWindow)
+		b.priorCongestionWindow = 0
+		if b.fullBandwidthReached {
+			b.enterProbeBandwidth(now)
+		} else {
+			b.mode = bbrModeStartup
+			b.pacingGain = bbrHighGain
+			b.cwndGain = bbrHighGain
+		}
+	}
+}
+
+func (b *BBRSender) updateCongestionWindow(ackedBytes congestion.ByteCount) {
+	targetWindow := b.bdp(b.cwndGain) + bbrQuantizationBudgetPackets*b.maxDatagramSize
+	if b.fullBandwidthReached {
+		b.congestionWindow = minByteCount(b.congestionWindow+ackedBytes, targetWindow)
+	} else if b.congestionWindow < targetWindow || b.delivered < initialCongestionWindow*b.maxDatagramSize {
+		b.congestionWindow += ackedBytes
+	}
+	b.congestionWindow = maxByteCount(b.congestionWindow, b.minCongestionWindow())
+	b.congestionWindow = minByteCount(b.congestionWindow, b.maxCongestionWindow())
+	if b.mode == bbrModeProbeRTT {
+		b.congestionWindow = minByteCount(b.congestionWindow, b.minCongestionWindow())
+	}
+}
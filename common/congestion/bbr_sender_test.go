@@ -0,0 +1,234 @@
+package congestion
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sagernet/quic-go/congestion"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testRTTStats struct {
+	congestion.RTTStatsProvider
+	latestRTT time.Duration
+}
+
+func (s *testRTTStats) LatestRTT() time.Duration {
+	return s.latestRTT
+}
+
+func (s *testRTTStats) SmoothedRTT() time.Duration {
+	return s.latestRTT
+}
+
+type testPacket struct {
+	number   congestion.PacketNumber
+	sentTime time.Time
+	ackTime  time.Time
+}
+
+// testLink is a bottleneck link with a fixed bandwidth and base RTT,
+// sending whenever the congestion window allows.
+type testLink struct {
+	sender     *BBRSender
+	rttStats   *testRTTStats
+	bandwidth  congestion.ByteCount // in bytes/s
+	rtt        time.Duration
+	now        time.Time
+	linkFree   time.Time
+	nextNumber congestion.PacketNumber
+	inFlight   []testPacket
+}
+
+func newTestLink(bandwidth congestion.ByteCount, rtt time.Duration) *testLink {
+	link := &testLink{
+		sender:    NewBBRSender(),
+		rttStats:  new(testRTTStats),
+		bandwidth: bandwidth,
+		rtt:       rtt,
+		now:       time.Unix(0, 0),
+	}
+	link.sender.SetRTTStatsProvider(link.rttStats)
+	return link
+}
+
+func (l *testLink) bytesInFlight() congestion.ByteCount {
+	return congestion.ByteCount(len(l.inFlight)) * initialMaxDatagramSize
+}
+
+func (l *testLink) send() {
+	for l.sender.CanSend(l.bytesInFlight()) {
+		departure := l.now
+		if l.linkFree.After(departure) {
+			departure = l.linkFree
+		}
+		departure = departure.Add(time.Duration(float64(initialMaxDatagramSize) / float64(l.bandwidth) * float64(time.Second)))
+		l.linkFree = departure
+		l.nextNumber++
+		l.inFlight = append(l.inFlight, testPacket{l.nextNumber, l.now, departure.Add(l.rtt)})
+		l.sender.OnPacketSent(l.now, l.bytesInFlight(), l.nextNumber, initialMaxDatagramSize, true)
+	}
+}
+
+// ack acknowledges the oldest packet in flight and fills the congestion window again.
+func (l *testLink) ack() {
+	packet := l.inFlight[0]
+	priorInFlight := l.bytesInFlight()
+	l.inFlight = l.inFlight[1:]
+	l.now = packet.ackTime
+	l.rttStats.latestRTT = packet.ackTime.Sub(packet.sentTime)
+	l.sender.OnPacketAcked(packet.number, initialMaxDatagramSize, priorInFlight, l.now)
+	l.send()
+}
+
+func (l *testLink) runUntil(condition func() bool, maxAcks int) bool {
+	l.send()
+	for i := 0; i < maxAcks; i++ {
+		if condition() {
+			return true
+		}
+		l.ack()
+	}
+	return condition()
+}
+
+func (l *testLink) run(acks int) {
+	l.send()
+	for i := 0; i < acks; i++ {
+		l.ack()
+	}
+}
+
+func (l *testLink) bdp() congestion.ByteCount {
+	return congestion.ByteCount(float64(l.bandwidth) * l.rtt.Seconds())
+}
+
+func TestBBRStartup(t *testing.T) {
+	t.Parallel()
+	link := newTestLink(1250000, 100*time.Millisecond)
+	require.True(t, link.sender.InSlowStart())
+	require.Equal(t, bbrHighGain, link.sender.pacingGain)
+	initialWindow := link.sender.GetCongestionWindow()
+	require.True(t, link.runUntil(func() bool {
+		return link.sender.fullBandwidthReached
+	}, 100000))
+	require.Greater(t, link.sender.GetCongestionWindow(), initialWindow)
+	bandwidth := link.sender.maxBandwidth.Get()
+	require.InDelta(t, float64(link.bandwidth), float64(bandwidth), float64(link.bandwidth)/10)
+	require.InDelta(t, float64(link.rtt), float64(link.sender.minRTT), float64(5*time.Millisecond))
+}
+
+func TestBBRDrainToProbeBandwidth(t *testing.T) {
+	t.Parallel()
+	link := newTestLink(1250000, 100*time.Millisecond)
+	var (
+		drained    bool
+		drainedGap bool
+	)
+	require.True(t, link.runUntil(func() bool {
+		if link.sender.mode == bbrModeDrain {
+			drained = true
+			drainedGap = link.sender.pacingGain < 1
+		}
+		return link.sender.mode == bbrModeProbeBW
+	}, 100000))
+	require.True(t, drained)
+	require.True(t, drainedGap)
+	require.False(t, link.sender.InSlowStart())
+	require.Equal(t, float64(bbrCongestionWindowGain), link.sender.cwndGain)
+	require.NotEqual(t, 1, link.sender.cycleIndex)
+	// after draining the queue, the window converges to the configured gain of the BDP
+	link.run(2000)
+	targetWindow := bbrCongestionWindowGain*link.bdp() + bbrQuantizationBudgetPackets*initialMaxDatagramSize
+	require.InDelta(t, float64(targetWindow), float64(link.sender.GetCongestionWindow()), float64(targetWindow)/5)
+}
+
+func TestBBRProbeBandwidthCycle(t *testing.T) {
+	t.Parallel()
+	sender := NewBBRSender()
+	now := time.Unix(0, 0)
+	for i := 0; i < 100; i++ {
+		sender.enterProbeBandwidth(now)
+		require.NotEqual(t, 1, sender.cycleIndex, "probe bandwidth must not start in the draining phase")
+		require.Equal(t, bbrPacingGainCycle[sender.cycleIndex], sender.pacingGain)
+	}
+	sender.minRTT = 100 * time.Millisecond
+	sender.cycleIndex = len(bbrPacingGainCycle) - 1
+	sender.pacingGain = bbrPacingGainCycle[sender.cycleIndex]
+	sender.cycleStart = now
+	// a phase with unit gain lasts one minimum RTT
+	sender.updateCyclePhase(now.Add(50*time.Millisecond), 0)
+	require.Equal(t, len(bbrPacingGainCycle)-1, sender.cycleIndex)
+	now = now.Add(150 * time.Millisecond)
+	sender.updateCyclePhase(now, 0)
+	require.Equal(t, 0, sender.cycleIndex)
+	require.Equal(t, 1.25, sender.pacingGain)
+	// probing continues until the pipe is full or a loss occurs
+	now = now.Add(150 * time.Millisecond)
+	sender.updateCyclePhase(now, 0)
+	require.Equal(t, 0, sender.cycleIndex)
+	sender.OnPacketLost(1, initialMaxDatagramSize, 0)
+	sender.updateCyclePhase(now, 0)
+	require.Equal(t, 1, sender.cycleIndex)
+	require.Equal(t, 0.75, sender.pacingGain)
+	// draining ends as soon as the pipe is no longer overfilled
+	now = now.Add(time.Millisecond)
+	sender.updateCyclePhase(now, 0)
+	require.Equal(t, 2, sender.cycleIndex)
+}
+
+func TestBBRProbeRTT(t *testing.T) {
+	t.Parallel()
+	link := newTestLink(1250000, 100*time.Millisecond)
+	require.True(t, link.runUntil(func() bool {
+		return link.sender.mode == bbrModeProbeBW
+	}, 100000))
+	windowBefore := link.sender.GetCongestionWindow()
+	// no lower RTT sample for the whole min RTT window
+	require.True(t, link.runUntil(func() bool {
+		return link.sender.mode == bbrModeProbeRTT
+	}, 100000))
+	require.Equal(t, 1.0, link.sender.pacingGain)
+	require.True(t, link.runUntil(func() bool {
+		return link.sender.GetCongestionWindow() == link.sender.minCongestionWindow()
+	}, 1000))
+	probeStart := link.now
+	require.True(t, link.runUntil(func() bool {
+		return link.sender.mode != bbrModeProbeRTT
+	}, 1000))
+	require.GreaterOrEqual(t, link.now.Sub(probeStart), bbrProbeRTTDuration)
+	require.Equal(t, bbrModeProbeBW, link.sender.mode)
+	require.GreaterOrEqual(t, link.sender.GetCongestionWindow(), windowBefore)
+}
+
+func TestBBRRetransmissionTimeout(t *testing.T) {
+	t.Parallel()
+	sender := NewBBRSender()
+	sender.OnRetransmissionTimeout(false)
+	require.Equal(t, initialCongestionWindow*initialMaxDatagramSize, int(sender.GetCongestionWindow()))
+	sender.OnRetransmissionTimeout(true)
+	require.Equal(t, sender.minCongestionWindow(), sender.GetCongestionWindow())
+}
+
+func TestWindowedMaxFilter(t *testing.T) {
+	t.Parallel()
+	filter := windowedMaxFilter{window: 10}
+	filter.Update(0, 100)
+	require.Equal(t, congestion.ByteCount(100), filter.Get())
+	filter.Update(1, 80)
+	filter.Update(3, 60)
+	filter.Update(6, 40)
+	require.Equal(t, congestion.ByteCount(100), filter.Get())
+	filter.Update(8, 200)
+	require.Equal(t, congestion.ByteCount(200), filter.Get())
+	// the best estimate expires and the next best from the window takes its place
+	filter.Update(12, 150)
+	filter.Update(15, 120)
+	filter.Update(17, 90)
+	require.Equal(t, congestion.ByteCount(200), filter.Get())
+	filter.Update(19, 50)
+	require.Equal(t, congestion.ByteCount(150), filter.Get())
+	filter.Update(40, 10)
+	require.Equal(t, congestion.ByteCount(10), filter.Get())
+}
@@ -0,0 +1,238 @@
+// Copyright (c) 2016 the quic-go authors & Google, Inc.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file in this directory.
+
+package congestion
+
+import (
+	"time"
+
+	"github.com/sagernet/quic-go/congestion"
+)
+
+const (
+	maxBurstPackets            = 3
+	renoBeta                   = 0.7 // Reno backoff factor.
+	minCongestionWindowPackets = 2
+	initialCongestionWindow    = 32
+	maxCongestionWindowPackets = 20000
+	defaultInitialRTT          = 100 * time.Millisecond
+
+	invalidPacketNumber congestion.PacketNumber = -1
+	infiniteByteCount   congestion.ByteCount    = 1<<62 - 1
+)
+
+var _ congestion.CongestionControl = (*CubicSender)(nil)
+
+// CubicSender is the cubic (or NewReno) congestion controller from quic-go,
+// adapted to the public congestion.CongestionControl interface.
+type CubicSender struct {
+	hybridSlowStart hybridSlowStart
+	rttStats        congestion.RTTStatsProvider
+	cubic           *cubic
+	pacer           *pacer
+
+	reno bool
+
+	// Track the largest packet that has been sent.
+	largestSentPacketNumber congestion.PacketNumber
+
+	// Track the largest packet that has been acked.
+	largestAckedPacketNumber congestion.PacketNumber
+
+	// Track the largest packet number outstanding when a CWND cutback occurs.
+	largestSentAtLastCutback congestion.PacketNumber
+
+	// Congestion window in bytes.
+	congestionWindow congestion.ByteCount
+
+	// Slow start congestion window in bytes, aka ssthresh.
+	slowStartThreshold congestion.ByteCount
+
+	// ACK counter for the Reno implementation.
+	numAckedPackets uint64
+
+	maxDatagramSize congestion.ByteCount
+}
+
+func NewCubicSender(reno bool) *CubicSender {
+	c := &CubicSender{
+		largestSentPacketNumber:  invalidPacketNumber,
+		largestAckedPacketNumber: invalidPacketNumber,
+		largestSentAtLastCutback: invalidPacketNumber,
+		congestionWindow:         initialCongestionWindow * initialMaxDatagramSize,
+		slowStartThreshold:       infiniteByteCount,
+		cubic:                    newCubic(),
+		reno:                     reno,
+		maxDatagramSize:          initialMaxDatagramSize,
+	}
+	c.pacer = newPacer(c.bandwidthEstimate)
+	return c
+}
+
+func (c *CubicSender) SetRTTStatsProvider(rttStats congestion.RTTStatsProvider) {
+	c.rttStats = rttStats
+}
+
+func (c *CubicSender) TimeUntilSend(_ congestion.ByteCount) time.Time {
+	return c.pacer.TimeUntilSend()
+}
+
+func (c *CubicSender) HasPacingBudget() bool {
+	return c.pacer.Budget(time.Now()) >= c.maxDatagramSize
+}
+
+func (c *CubicSender) maxCongestionWindow() congestion.ByteCount {
+	return c.maxDatagramSize * maxCongestionWindowPackets
+}
+
+func (c *CubicSender) minCongestionWindow() congestion.ByteCount {
+	return c.maxDatagramSize * minCongestionWindowPackets
+}
+
+func (c *CubicSender) OnPacketSent(sentTime time.Time, _ congestion.ByteCount,
+	packetNumber congestion.PacketNumber, bytes congestion.ByteCount, isRetransmittable bool,
+) {
+	c.pacer.SentPacket(sentTime, bytes)
+	if !isRetransmittable {
+		return
+	}
+	c.largestSentPacketNumber = packetNumber
+	c.hybridSlowStart.OnPacketSent(packetNumber)
+}
+
+func (c *CubicSender) CanSend(bytesInFlight congestion.ByteCount) bool {
+	return bytesInFlight < c.GetCongestionWindow()
+}
+
+func (c *CubicSender) InRecovery() bool {
+	return c.largestAckedPacketNumber != invalidPacketNumber && c.largestAckedPacketNumber <= c.largestSentAtLastCutback
+}
+
+func (c *CubicSender) InSlowStart() bool {
+	return c.GetCongestionWindow() < c.slowStartThreshold
+}
+
+func (c *CubicSender) GetCongestionWindow() congestion.ByteCount {
+	return c.congestionWindow
+}
+
+func (c *CubicSender) MaybeExitSlowStart() {
+	if c.InSlowStart() &&
+		c.hybridSlowStart.ShouldExitSlowStart(c.rttStats.LatestRTT(), c.rttStats.MinRTT(), c.GetCongestionWindow()/c.maxDatagramSize) {
+		// exit slow start
+		c.slowStartThreshold = c.congestionWindow
+	}
+}
+
+func (c *CubicSender) OnPacketAcked(ackedPacketNumber congestion.PacketNumber, ackedBytes congestion.ByteCount,
+	priorInFlight congestion.ByteCount, eventTime time.Time,
+) {
+	if ackedPacketNumber > c.largestAckedPacketNumber {
+		c.largestAckedPacketNumber = ackedPacketNumber
+	}
+	if c.InRecovery() {
+		return
+	}
+	c.maybeIncreaseCwnd(ackedBytes, priorInFlight, eventTime)
+	if c.InSlowStart() {
+		c.hybridSlowStart.OnPacketAcked(ackedPacketNumber)
+	}
+}
+
+func (c *CubicSender) OnPacketLost(packetNumber congestion.PacketNumber, _ congestion.ByteCount, _ congestion.ByteCount) {
+	// TCP NewReno (RFC6582) says that once a loss occurs, any losses in packets
+	// already sent should be treated as a single loss event, since it's expected.
+	if packetNumber <= c.largestSentAtLastCutback {
+		return
+	}
+	if c.reno {
+		c.congestionWindow = congestion.ByteCount(float64(c.congestionWindow) * renoBeta)
+	} else {
+		c.congestionWindow = c.cubic.CongestionWindowAfterPacketLoss(c.congestionWindow)
+	}
+	if minCwnd := c.minCongestionWindow(); c.congestionWindow < minCwnd {
+		c.congestionWindow = minCwnd
+	}
+	c.slowStartThreshold = c.congestionWindow
+	c.largestSentAtLastCutback = c.largestSentPacketNumber
+	// reset packet count from congestion avoidance mode. We start
+	// counting again when we're out of recovery.
+	c.numAckedPackets = 0
+}
+
+// Called when we receive an ack. Normal TCP tracks how many packets one ack
+// represents, but quic has a separate ack for each packet.
+func (c *CubicSender) maybeIncreaseCwnd(ackedBytes congestion.ByteCount, priorInFlight congestion.ByteCount, eventTime time.Time) {
+	// Do not increase the congestion window unless the sender is close to using
+	// the current window.
+	if !c.isCwndLimited(priorInFlight) {
+		c.cubic.OnApplicationLimited()
+		return
+	}
+	if c.congestionWindow >= c.maxCongestionWindow() {
+		return
+	}
+	if c.InSlowStart() {
+		// TCP slow start, exponential growth, increase by one for each ACK.
+		c.congestionWindow += c.maxDatagramSize
+		return
+	}
+	// Congestion avoidance
+	if c.reno {
+		// Classic Reno congestion avoidance.
+		c.numAckedPackets++
+		if c.numAckedPackets >= uint64(c.congestionWindow/c.maxDatagramSize) {
+			c.congestionWindow += c.maxDatagramSize
+			c.numAckedPackets = 0
+		}
+	} else {
+		c.congestionWindow = minByteCount(c.maxCongestionWindow(), c.cubic.CongestionWindowAfterAck(ackedBytes, c.congestionWindow, c.rttStats.MinRTT(), eventTime))
+	}
+}
+
+func (c *CubicSender) isCwndLimited(bytesInFlight congestion.ByteCount) bool {
+	congestionWindow := c.GetCongestionWindow()
+	if bytesInFlight >= congestionWindow {
+		return true
+	}
+	availableBytes := congestionWindow - bytesInFlight
+	slowStartLimited := c.InSlowStart() && bytesInFlight > congestionWindow/2
+	return slowStartLimited || availableBytes <= maxBurstPackets*c.maxDatagramSize
+}
+
+// bandwidthEstimate returns the current bandwidth estimate in bytes/s
+func (c *CubicSender) bandwidthEstimate() congestion.ByteCount {
+	var srtt time.Duration
+	if c.rttStats != nil {
+		srtt = c.rttStats.SmoothedRTT()
+	}
+	if srtt == 0 {
+		// If we haven't measured an rtt, assume the default initial rtt.
+		srtt = defaultInitialRTT
+	}
+	return congestion.ByteCount(float64(c.GetCongestionWindow()) / srtt.Seconds())
+}
+
+func (c *CubicSender) OnRetransmissionTimeout(packetsRetransmitted bool) {
+	c.largestSentAtLastCutback = invalidPacketNumber
+	if !packetsRetransmitted {
+		return
+	}
+	c.hybridSlowStart.Restart()
+	c.cubic.Reset()
+	c.slowStartThreshold = c.congestionWindow / 2
+	c.congestionWindow = c.minCongestionWindow()
+}
+
+func (c *CubicSender) SetMaxDatagramSize(s congestion.ByteCount) {
+	if s < c.maxDatagramSize {
+		return
+	}
+	cwndIsMinCwnd := c.congestionWindow == c.minCongestionWindow()
+	c.maxDatagramSize = s
+	if cwndIsMinCwnd {
+		c.congestionWindow = c.minCongestionWindow()
+	}
+	c.pacer.SetMaxDatagramSize(s)
+}
@@ -0,0 +1,78 @@
+// Copyright 2016 The Chromium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file in this directory.
+
+package congestion
+
+import "github.com/sagernet/quic-go/congestion"
+
+// windowedMaxFilter tracks the maximum value of a data stream over a window
+// measured in round trips, using Kathleen Nichols' algorithm as implemented
+// by the WindowedFilter of Chromium's QUIC.
+//
+// It keeps the best, second best and third best estimates, each recorded in
+// a later part of the window than the previous one, so that an expired best
+// estimate can be replaced without keeping every sample.
+
+type windowedSample struct {
+	round uint64
+	value congestion.ByteCount
+}
+
+type windowedMaxFilter struct {
+	window  uint64
+	samples [3]windowedSample
+}
+
+func (f *windowedMaxFilter) Get() congestion.ByteCount {
+	return f.samples[0].value
+}
+
+func (f *windowedMaxFilter) Reset(round uint64, value congestion.ByteCount) {
+	sample := windowedSample{round, value}
+	f.samples = [3]windowedSample{sample, sample, sample}
+}
+
+func (f *windowedMaxFilter) Update(round uint64, value congestion.ByteCount) {
+	sample := windowedSample{round, value}
+	// Reset all estimates if they have not yet been initialized, if new sample
+	// is a new best, or if the newest recorded estimate is too old.
+	if f.samples[0].value == 0 || value >= f.samples[0].value || round-f.samples[2].round > f.window {
+		f.Reset(round, value)
+		return
+	}
+	if value >= f.samples[1].value {
+		f.samples[1] = sample
+		f.samples[2] = sample
+	} else if value >= f.samples[2].value {
+		f.samples[2] = sample
+	}
+	// Expire and update estimates as necessary.
+	if round-f.samples[0].round > f.window {
+		// The best estimate hasn't been updated for an entire window, so promote
+		// second and third best estimates.
+		f.samples[0] = f.samples[1]
+		f.samples[1] = f.samples[2]
+		f.samples[2] = sample
+		// Need to iterate one more time. Check if the new best estimate is
+		// outside the window as well, since it may also have been recorded a
+		// long time ago.
+		if round-f.samples[0].round > f.window {
+			f.samples[0] = f.samples[1]
+			f.samples[1] = f.samples[2]
+		}
+		return
+	}
+	if f.samples[1].value == f.samples[0].value && round-f.samples[1].round > f.window/4 {
+		// A quarter of the window has passed without a better sample, so the
+		// second-best estimate is taken from the second quarter of the window.
+		f.samples[1] = sample
+		f.samples[2] = sample
+		return
+	}
+	if f.samples[2].value == f.samples[1].value && round-f.samples[2].round > f.window/2 {
+		// We've passed a half of the window without a better sample, so a
+		// third-best estimate is taken from the second half of the window.
+		f.samples[2] = sample
+	}
+}
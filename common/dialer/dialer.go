@@ -20,5 +20,6 @@ func New(router adapter.Router, options option.DialerOptions) N.Dialer {
 	if domainStrategy != dns.DomainStrategyAsIS || options.Detour == "" {
 		dialer = NewResolveDialer(router, dialer, domainStrategy, time.Duration(options.FallbackDelay))
 	}
-	return dialer
+	// checked before resolving, so the server address is passed to the previous hop as is
+	return &overridableDialer{dialer}
 }
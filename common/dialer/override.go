@@ -0,0 +1,45 @@
+package dialer
+
+import (
+	"context"
+	"net"
+
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+type overrideKey struct{}
+
+// ContextWithOverride makes dialers created by New dial through the given
+// dialer instead of their own, which chains outbounds together. A nil dialer
+// removes the override.
+func ContextWithOverride(ctx context.Context, dialer N.Dialer) context.Context {
+	return context.WithValue(ctx, (*overrideKey)(nil), dialer)
+}
+
+func OverrideFromContext(ctx context.Context) N.Dialer {
+	dialer, _ := ctx.Value((*overrideKey)(nil)).(N.Dialer)
+	return dialer
+}
+
+type overridableDialer struct {
+	dialer N.Dialer
+}
+
+func (d *overridableDialer) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	if override := OverrideFromContext(ctx); override != nil {
+		return override.DialContext(ctx, network, destination)
+	}
+	return d.dialer.DialContext(ctx, network, destination)
+}
+
+func (d *overridableDialer) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	if override := OverrideFromContext(ctx); override != nil {
+		return override.ListenPacket(ctx, destination)
+	}
+	return d.dialer.ListenPacket(ctx, destination)
+}
+
+func (d *overridableDialer) Upstream() any {
+	return d.dialer
+}
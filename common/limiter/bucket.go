@@ -0,0 +1,44 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket allows the token count to go negative and makes the caller
+// sleep off the deficit, so large reads and writes are never split.
+type tokenBucket struct {
+	access sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(bytesPerSecond uint64) *tokenBucket {
+	return &tokenBucket{
+		rate:   float64(bytesPerSecond),
+		burst:  float64(bytesPerSecond),
+		tokens: float64(bytesPerSecond),
+		last:   time.Now(),
+	}
+}
+
+func (b *tokenBucket) wait(n int) {
+	if n <= 0 {
+		return
+	}
+	b.access.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	deficit := -b.tokens
+	b.access.Unlock()
+	if deficit > 0 {
+		time.Sleep(time.Duration(deficit / b.rate * float64(time.Second)))
+	}
+}
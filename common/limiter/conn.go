@@ -0,0 +1,142 @@
+package limiter
+
+import (
+	"net"
+	"sync"
+
+	"github.com/sagernet/sing/common/buf"
+	"github.com/sagernet/sing/common/bufio"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+type Conn struct {
+	N.ExtendedConn
+	user      *userLimiter
+	closeOnce sync.Once
+}
+
+func newConn(conn net.Conn, user *userLimiter) *Conn {
+	return &Conn{ExtendedConn: bufio.NewExtendedConn(conn), user: user}
+}
+
+func (c *Conn) Read(p []byte) (n int, err error) {
+	err = c.checkQuota()
+	if err != nil {
+		return
+	}
+	n, err = c.ExtendedConn.Read(p)
+	c.user.onUpload(n)
+	return
+}
+
+func (c *Conn) ReadBuffer(buffer *buf.Buffer) error {
+	err := c.checkQuota()
+	if err != nil {
+		return err
+	}
+	err = c.ExtendedConn.ReadBuffer(buffer)
+	if err != nil {
+		return err
+	}
+	c.user.onUpload(buffer.Len())
+	return nil
+}
+
+func (c *Conn) Write(p []byte) (n int, err error) {
+	err = c.checkQuota()
+	if err != nil {
+		return
+	}
+	c.user.beforeDownload(len(p))
+	n, err = c.ExtendedConn.Write(p)
+	c.user.onDownload(n)
+	return
+}
+
+func (c *Conn) WriteBuffer(buffer *buf.Buffer) error {
+	err := c.checkQuota()
+	if err != nil {
+		return err
+	}
+	dataLen := buffer.Len()
+	c.user.beforeDownload(dataLen)
+	err = c.ExtendedConn.WriteBuffer(buffer)
+	if err != nil {
+		return err
+	}
+	c.user.onDownload(dataLen)
+	return nil
+}
+
+// checkQuota closes the connection once the user has used up the quota.
+func (c *Conn) checkQuota() error {
+	err := c.user.checkQuota()
+	if err != nil {
+		c.Close()
+	}
+	return err
+}
+
+func (c *Conn) Close() error {
+	c.closeOnce.Do(c.user.release)
+	return c.ExtendedConn.Close()
+}
+
+func (c *Conn) Upstream() any {
+	return c.ExtendedConn
+}
+
+type PacketConn struct {
+	N.PacketConn
+	user      *userLimiter
+	closeOnce sync.Once
+}
+
+func newPacketConn(conn N.PacketConn, user *userLimiter) *PacketConn {
+	return &PacketConn{PacketConn: conn, user: user}
+}
+
+func (c *PacketConn) ReadPacket(buffer *buf.Buffer) (destination M.Socksaddr, err error) {
+	err = c.checkQuota()
+	if err != nil {
+		return
+	}
+	destination, err = c.PacketConn.ReadPacket(buffer)
+	if err == nil {
+		c.user.onUpload(buffer.Len())
+	}
+	return
+}
+
+func (c *PacketConn) WritePacket(buffer *buf.Buffer, destination M.Socksaddr) error {
+	err := c.checkQuota()
+	if err != nil {
+		return err
+	}
+	dataLen := buffer.Len()
+	c.user.beforeDownload(dataLen)
+	err = c.PacketConn.WritePacket(buffer, destination)
+	if err != nil {
+		return err
+	}
+	c.user.onDownload(dataLen)
+	return nil
+}
+
+func (c *PacketConn) checkQuota() error {
+	err := c.user.checkQuota()
+	if err != nil {
+		c.Close()
+	}
+	return err
+}
+
+func (c *PacketConn) Close() error {
+	c.closeOnce.Do(c.user.release)
+	return c.PacketConn.Close()
+}
+
+func (c *PacketConn) Upstream() any {
+	return c.PacketConn
+}
@@ -0,0 +1,301 @@
+package limiter
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/atomic"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var (
+	ErrQuotaExceeded      = E.New("quota exceeded")
+	ErrTooManyConnections = E.New("too many connections")
+)
+
+const mbpsToBps = 125000
+
+type Manager struct {
+	router       adapter.Router
+	logger       log.Logger
+	tag          string
+	initOnce     sync.Once
+	statsService adapter.V2RayStatsService
+	quotaStorage adapter.UserQuotaStorage
+	access       sync.RWMutex
+	users        map[string]*userLimiter
+}
+
+// NewManager creates limiters for users with limit options. Users without a
+// name are looked up by their index, as inbounds log them.
+func NewManager(router adapter.Router, logger log.Logger, tag string, names []string, options []option.UserLimitOptions) *Manager {
+	manager := &Manager{
+		router: router,
+		logger: logger,
+		tag:    tag,
+		users:  make(map[string]*userLimiter),
+	}
+	for i, name := range names {
+		if options[i].IsEmpty() {
+			continue
+		}
+		if name == "" {
+			name = F.ToString(i)
+		}
+		manager.users[name] = newUserLimiter(manager, name, options[i])
+	}
+	return manager
+}
+
+func (m *Manager) init() {
+	m.initOnce.Do(func() {
+		if v2rayServer := m.router.V2RayServer(); v2rayServer != nil {
+			m.statsService = v2rayServer.StatsService()
+		}
+		if clashServer := m.router.ClashServer(); clashServer != nil && clashServer.StoreQuota() && m.tag != "" {
+			m.quotaStorage = clashServer.CacheFile()
+		}
+		m.access.Lock()
+		for _, user := range m.users {
+			m.loadUsage(user)
+		}
+		m.access.Unlock()
+	})
+}
+
+// loadUsage binds the used quota of the user to the stats service counter and
+// restores it from the cache file.
+func (m *Manager) loadUsage(user *userLimiter) {
+	if m.statsService != nil {
+		user.used = m.statsService.Counter(usedCounterName(user.name))
+	}
+	if m.quotaStorage == nil || user.quota == 0 {
+		return
+	}
+	used, resetAt, loaded := m.quotaStorage.LoadUserQuota(m.tag, user.name)
+	if !loaded {
+		return
+	}
+	user.used.Store(used)
+	if user.resetInterval > 0 && !resetAt.IsZero() {
+		user.resetAt = resetAt
+	}
+}
+
+func (m *Manager) storeUsage(user *userLimiter) {
+	if m.quotaStorage == nil || user.quota == 0 {
+		return
+	}
+	user.resetAccess.Lock()
+	resetAt := user.resetAt
+	user.resetAccess.Unlock()
+	err := m.quotaStorage.StoreUserQuota(m.tag, user.name, user.used.Load(), resetAt)
+	if err != nil {
+		m.logger.Error("store quota for user ", user.name, ": ", err)
+	}
+}
+
+func usedCounterName(name string) string {
+	return "user>>>" + name + ">>>quota>>>used"
+}
+
+// Update replaces the limited users. Limiters of users whose options are
+// unchanged are kept along with their usage and connection counts.
+func (m *Manager) Update(names []string, options []option.UserLimitOptions) {
+	m.init()
+	m.access.Lock()
+	defer m.access.Unlock()
+	users := make(map[string]*userLimiter)
+	for i, name := range names {
+		if options[i].IsEmpty() {
+			continue
+		}
+		if user, loaded := m.users[name]; loaded && user.options == options[i] {
+			users[name] = user
+			continue
+		}
+		user := newUserLimiter(m, name, options[i])
+		m.loadUsage(user)
+		users[name] = user
+	}
+	m.users = users
+}
+
+func (m *Manager) user(name string) (*userLimiter, bool) {
+	m.access.RLock()
+	defer m.access.RUnlock()
+	user, loaded := m.users[name]
+	return user, loaded
+}
+
+// Check returns an error if the user is over quota or at the connection limit.
+func (m *Manager) Check(name string) error {
+	m.init()
+	user, loaded := m.user(name)
+	if !loaded {
+		return nil
+	}
+	return user.check()
+}
+
+func (m *Manager) NewConnection(name string, conn net.Conn) (net.Conn, error) {
+	m.init()
+	user, loaded := m.user(name)
+	if !loaded {
+		return conn, nil
+	}
+	err := user.acquire()
+	if err != nil {
+		return nil, err
+	}
+	return newConn(conn, user), nil
+}
+
+func (m *Manager) NewPacketConnection(name string, conn N.PacketConn) (N.PacketConn, error) {
+	m.init()
+	user, loaded := m.user(name)
+	if !loaded {
+		return conn, nil
+	}
+	err := user.acquire()
+	if err != nil {
+		return nil, err
+	}
+	return newPacketConn(conn, user), nil
+}
+
+// Usage returns the current usage of limited users.
+func (m *Manager) Usage() map[string]adapter.UserUsage {
+	m.init()
+	m.access.RLock()
+	defer m.access.RUnlock()
+	usage := make(map[string]adapter.UserUsage, len(m.users))
+	for name, user := range m.users {
+		user.resetIfExpired()
+		user.resetAccess.Lock()
+		userUsage := adapter.UserUsage{
+			Used:        user.used.Load(),
+			Quota:       user.quota,
+			Connections: user.connections.Load(),
+		}
+		if user.resetInterval > 0 {
+			resetAt := user.resetAt.Add(user.resetInterval)
+			userUsage.ResetAt = &resetAt
+		}
+		user.resetAccess.Unlock()
+		usage[name] = userUsage
+	}
+	return usage
+}
+
+type userLimiter struct {
+	manager        *Manager
+	name           string
+	options        option.UserLimitOptions
+	upload         *tokenBucket
+	download       *tokenBucket
+	quota          int64
+	resetInterval  time.Duration
+	resetAccess    sync.Mutex
+	resetAt        time.Time
+	used           *atomic.Int64
+	maxConnections int32
+	connections    atomic.Int32
+}
+
+func newUserLimiter(manager *Manager, name string, options option.UserLimitOptions) *userLimiter {
+	user := &userLimiter{
+		manager:        manager,
+		name:           name,
+		options:        options,
+		quota:          int64(options.Quota),
+		resetInterval:  time.Duration(options.QuotaResetInterval),
+		resetAt:        time.Now(),
+		used:           &atomic.Int64{},
+		maxConnections: int32(options.MaxConnections),
+	}
+	if options.UpMbps > 0 {
+		user.upload = newTokenBucket(uint64(options.UpMbps) * mbpsToBps)
+	}
+	if options.DownMbps > 0 {
+		user.download = newTokenBucket(uint64(options.DownMbps) * mbpsToBps)
+	}
+	return user
+}
+
+func (u *userLimiter) check() error {
+	err := u.checkQuota()
+	if err != nil {
+		return err
+	}
+	if u.maxConnections > 0 && u.connections.Load() >= u.maxConnections {
+		return ErrTooManyConnections
+	}
+	return nil
+}
+
+func (u *userLimiter) checkQuota() error {
+	if u.quota == 0 {
+		return nil
+	}
+	u.resetIfExpired()
+	if u.used.Load() >= u.quota {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+func (u *userLimiter) acquire() error {
+	err := u.checkQuota()
+	if err != nil {
+		return err
+	}
+	connections := u.connections.Add(1)
+	if u.maxConnections > 0 && connections > u.maxConnections {
+		u.connections.Add(-1)
+		return ErrTooManyConnections
+	}
+	return nil
+}
+
+func (u *userLimiter) release() {
+	u.connections.Add(-1)
+	u.manager.storeUsage(u)
+}
+
+func (u *userLimiter) resetIfExpired() {
+	if u.resetInterval == 0 {
+		return
+	}
+	u.resetAccess.Lock()
+	defer u.resetAccess.Unlock()
+	elapsed := time.Since(u.resetAt)
+	if elapsed < u.resetInterval {
+		return
+	}
+	u.resetAt = u.resetAt.Add(elapsed / u.resetInterval * u.resetInterval)
+	u.used.Store(0)
+}
+
+func (u *userLimiter) onUpload(n int) {
+	u.used.Add(int64(n))
+	if u.upload != nil {
+		u.upload.wait(n)
+	}
+}
+
+func (u *userLimiter) beforeDownload(n int) {
+	if u.download != nil {
+		u.download.wait(n)
+	}
+}
+
+func (u *userLimiter) onDownload(n int) {
+	u.used.Add(int64(n))
+}
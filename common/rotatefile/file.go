@@ -0,0 +1,128 @@
+package rotatefile
+
+import (
+	"os"
+	"strconv"
+	"sync"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// File is an append-only file renamed to path.1 once it exceeds the max size,
+// with older files shifted to path.2 and so on up to the max backups. Rotated
+// files are deleted if no backups are kept.
+type File struct {
+	access     sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	header     []byte
+	file       *os.File
+	size       int64
+	closed     bool
+}
+
+// Open opens the file for appending. The header, if any, is written at the
+// start of each new file.
+func Open(path string, maxSize int64, maxBackups int, header []byte) (*File, error) {
+	file := &File{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+		header:     header,
+	}
+	err := file.open()
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (f *File) open() error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	if f.size == 0 && len(f.header) > 0 {
+		n, err := file.Write(f.header)
+		f.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *File) Write(p []byte) (n int, err error) {
+	f.access.Lock()
+	defer f.access.Unlock()
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	if f.file == nil {
+		err = f.open()
+		if err != nil {
+			return
+		}
+	}
+	var rotateErr error
+	if f.maxSize > 0 && f.size > int64(len(f.header)) && f.size+int64(len(p)) > f.maxSize {
+		rotateErr = f.rotate()
+		if f.file == nil {
+			return 0, rotateErr
+		}
+	}
+	n, err = f.file.Write(p)
+	f.size += int64(n)
+	if rotateErr != nil {
+		// p is kept in the reopened file, the rotate error is reported along with the write result
+		err = E.Errors(rotateErr, err)
+	}
+	return
+}
+
+// rotate reopens the original path if the current file can not be rotated,
+// so the next write tries again. If reopening fails too, the next write
+// opens the file before writing.
+func (f *File) rotate() error {
+	err := f.file.Close()
+	f.file = nil
+	if err == nil {
+		if f.maxBackups > 0 {
+			for i := f.maxBackups - 1; i > 0; i-- {
+				os.Rename(f.backupPath(i), f.backupPath(i+1))
+			}
+			err = os.Rename(f.path, f.backupPath(1))
+		} else {
+			err = os.Remove(f.path)
+		}
+	}
+	openErr := f.open()
+	if err != nil {
+		return E.Errors(E.Cause(err, "rotate ", f.path), openErr)
+	}
+	return openErr
+}
+
+func (f *File) backupPath(index int) string {
+	return f.path + "." + strconv.Itoa(index)
+}
+
+func (f *File) Close() error {
+	f.access.Lock()
+	defer f.access.Unlock()
+	f.closed = true
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
@@ -0,0 +1,83 @@
+package script
+
+import (
+	"net/netip"
+
+	"github.com/sagernet/sing-box/adapter"
+	dns "github.com/sagernet/sing-dns"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+func newContext(script *Script) *starlarkstruct.Struct {
+	ruleProviders := starlark.NewDict(len(script.router.RuleProviders()))
+	for _, ruleProvider := range script.router.RuleProviders() {
+		ruleProviders.SetKey(starlark.String(ruleProvider.Tag()), newRuleProvider(ruleProvider))
+	}
+	ruleProviders.Freeze()
+	return starlarkstruct.FromStringDict(starlark.String("context"), starlark.StringDict{
+		"resolve_ip":     starlark.NewBuiltin("resolve_ip", script.resolveIP),
+		"geoip":          starlark.NewBuiltin("geoip", script.geoIP),
+		"log":            starlark.NewBuiltin("log", script.log),
+		"rule_providers": ruleProviders,
+	})
+}
+
+func (s *Script) resolveIP(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var host string
+	err := starlark.UnpackArgs(b.Name(), args, kwargs, "host", &host)
+	if err != nil {
+		return nil, err
+	}
+	if addr, parseErr := netip.ParseAddr(host); parseErr == nil {
+		return starlark.String(addr.String()), nil
+	}
+	addresses, err := s.router.Lookup(contextFromThread(thread), host, dns.DomainStrategyAsIS)
+	if err != nil || len(addresses) == 0 {
+		return starlark.String(""), nil
+	}
+	return starlark.String(addresses[0].String()), nil
+}
+
+func (s *Script) geoIP(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var ip string
+	err := starlark.UnpackArgs(b.Name(), args, kwargs, "ip", &ip)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil, E.Cause(err, b.Name())
+	}
+	geoIPReader := s.router.GeoIPReader()
+	if geoIPReader == nil {
+		return nil, E.New(b.Name(), ": geoip database not loaded")
+	}
+	return starlark.String(geoIPReader.Lookup(addr)), nil
+}
+
+func (s *Script) log(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var message string
+	err := starlark.UnpackArgs(b.Name(), args, kwargs, "message", &message)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.InfoContext(contextFromThread(thread), message)
+	return starlark.None, nil
+}
+
+func newRuleProvider(ruleProvider adapter.RuleProvider) *starlarkstruct.Struct {
+	return starlarkstruct.FromStringDict(starlark.String("rule_provider"), starlark.StringDict{
+		"tag": starlark.String(ruleProvider.Tag()),
+		"match": starlark.NewBuiltin("match", func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var metadataValue *Metadata
+			err := starlark.UnpackArgs(b.Name(), args, kwargs, "metadata", &metadataValue)
+			if err != nil {
+				return nil, err
+			}
+			return starlark.Bool(ruleProvider.Match(metadataValue.metadata)), nil
+		}),
+	})
+}
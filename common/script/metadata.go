@@ -0,0 +1,140 @@
+package script
+
+import (
+	"net/netip"
+	"path/filepath"
+	"sort"
+
+	"github.com/sagernet/sing-box/adapter"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+
+	"go.starlark.net/starlark"
+)
+
+var _ starlark.HasAttrs = (*Metadata)(nil)
+
+type Metadata struct {
+	router   adapter.Router
+	metadata *adapter.InboundContext
+}
+
+func newMetadata(router adapter.Router, metadata *adapter.InboundContext) *Metadata {
+	return &Metadata{router, metadata}
+}
+
+var metadataAttrNames = []string{
+	"inbound",
+	"inbound_type",
+	"network",
+	"protocol",
+	"user",
+	"domain",
+	"src_ip",
+	"src_port",
+	"dst_ip",
+	"dst_port",
+	"geoip",
+	"process_name",
+	"process_path",
+	"package_name",
+}
+
+func init() {
+	sort.Strings(metadataAttrNames)
+}
+
+func (m *Metadata) String() string {
+	return F.ToString("metadata(", m.metadata.Network, " ", m.metadata.Source, " => ", m.metadata.Destination, ")")
+}
+
+func (m *Metadata) Type() string {
+	return "metadata"
+}
+
+func (m *Metadata) Freeze() {
+}
+
+func (m *Metadata) Truth() starlark.Bool {
+	return starlark.True
+}
+
+func (m *Metadata) Hash() (uint32, error) {
+	return 0, E.New("unhashable type: metadata")
+}
+
+func (m *Metadata) AttrNames() []string {
+	return metadataAttrNames
+}
+
+func (m *Metadata) Attr(name string) (starlark.Value, error) {
+	metadata := m.metadata
+	switch name {
+	case "inbound":
+		return starlark.String(metadata.Inbound), nil
+	case "inbound_type":
+		return starlark.String(metadata.InboundType), nil
+	case "network":
+		return starlark.String(metadata.Network), nil
+	case "protocol":
+		return starlark.String(metadata.Protocol), nil
+	case "user":
+		return starlark.String(metadata.User), nil
+	case "domain":
+		if metadata.Domain != "" {
+			return starlark.String(metadata.Domain), nil
+		}
+		return starlark.String(metadata.Destination.Fqdn), nil
+	case "src_ip":
+		return addrValue(metadata.Source.Addr), nil
+	case "src_port":
+		return starlark.MakeInt(int(metadata.Source.Port)), nil
+	case "dst_ip":
+		return addrValue(m.destinationAddr()), nil
+	case "dst_port":
+		return starlark.MakeInt(int(metadata.Destination.Port)), nil
+	case "geoip":
+		if metadata.GeoIPCode != "" {
+			return starlark.String(metadata.GeoIPCode), nil
+		}
+		geoIPReader := m.router.GeoIPReader()
+		destination := m.destinationAddr()
+		if geoIPReader == nil || !destination.IsValid() {
+			return starlark.String(""), nil
+		}
+		return starlark.String(geoIPReader.Lookup(destination)), nil
+	case "process_name":
+		if metadata.ProcessInfo == nil || metadata.ProcessInfo.ProcessPath == "" {
+			return starlark.String(""), nil
+		}
+		return starlark.String(filepath.Base(metadata.ProcessInfo.ProcessPath)), nil
+	case "process_path":
+		if metadata.ProcessInfo == nil {
+			return starlark.String(""), nil
+		}
+		return starlark.String(metadata.ProcessInfo.ProcessPath), nil
+	case "package_name":
+		if metadata.ProcessInfo == nil {
+			return starlark.String(""), nil
+		}
+		return starlark.String(metadata.ProcessInfo.PackageName), nil
+	}
+	return nil, nil
+}
+
+func (m *Metadata) destinationAddr() netip.Addr {
+	if m.metadata.Destination.IsIP() {
+		return m.metadata.Destination.Addr
+	}
+	if len(m.metadata.DestinationAddresses) > 0 {
+		return m.metadata.DestinationAddresses[0]
+	}
+	return netip.Addr{}
+}
+
+func addrValue(addr netip.Addr) starlark.Value {
+	if !addr.IsValid() {
+		return starlark.String("")
+	}
+	return starlark.String(addr.String())
+}
@@ -0,0 +1,143 @@
+package script
+
+import (
+	"context"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	starlarktime "go.starlark.net/lib/time"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+const (
+	mainFunction      = "main"
+	maxExecutionSteps = 1 << 20
+	maxExecutionTime  = 5 * time.Second
+	localContext      = "context"
+)
+
+type Script struct {
+	router  adapter.Router
+	logger  log.ContextLogger
+	context *starlarkstruct.Struct
+	main    *starlark.Function
+}
+
+func Parse(router adapter.Router, logger log.ContextLogger, name string, code string) (*Script, error) {
+	script := &Script{
+		router: router,
+		logger: logger,
+	}
+	thread := &starlark.Thread{
+		Name: name,
+		Print: func(thread *starlark.Thread, msg string) {
+			logger.Info(msg)
+		},
+	}
+	thread.SetMaxExecutionSteps(maxExecutionSteps)
+	timer := time.AfterFunc(maxExecutionTime, func() {
+		thread.Cancel("parse timeout")
+	})
+	globals, err := starlark.ExecFile(thread, name, code, starlark.StringDict{
+		"time": starlarktime.Module,
+	})
+	timer.Stop()
+	if err != nil {
+		return nil, E.Cause(err, "parse script")
+	}
+	globals.Freeze()
+	mainValue, loaded := globals[mainFunction]
+	if !loaded {
+		return nil, E.New("missing function: ", mainFunction)
+	}
+	mainFunc, isFunc := mainValue.(*starlark.Function)
+	if !isFunc {
+		return nil, E.New("invalid ", mainFunction, ": expected function, got ", mainValue.Type())
+	}
+	if mainFunc.NumParams() != 2 {
+		return nil, E.New("invalid ", mainFunction, ": expected 2 parameters (ctx, metadata), got ", mainFunc.NumParams())
+	}
+	script.main = mainFunc
+	script.context = newContext(script)
+	script.context.Freeze()
+	return script, nil
+}
+
+func (s *Script) Call(ctx context.Context, metadata *adapter.InboundContext) (starlark.Value, error) {
+	ctx, cancel := context.WithTimeout(ctx, maxExecutionTime)
+	defer cancel()
+	thread := &starlark.Thread{
+		Name: mainFunction,
+		Print: func(thread *starlark.Thread, msg string) {
+			s.logger.InfoContext(ctx, msg)
+		},
+	}
+	thread.SetMaxExecutionSteps(maxExecutionSteps)
+	thread.SetLocal(localContext, ctx)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			thread.Cancel(ctx.Err().Error())
+		case <-done:
+		}
+	}()
+	return starlark.Call(thread, s.main, starlark.Tuple{s.context, newMetadata(s.router, metadata)}, nil)
+}
+
+func (s *Script) Match(ctx context.Context, metadata *adapter.InboundContext) (bool, error) {
+	result, err := s.Call(ctx, metadata)
+	if err != nil {
+		return false, err
+	}
+	return bool(result.Truth()), nil
+}
+
+func (s *Script) Outbound(ctx context.Context, metadata *adapter.InboundContext) (string, error) {
+	result, err := s.Call(ctx, metadata)
+	if err != nil {
+		return "", err
+	}
+	switch outbound := result.(type) {
+	case starlark.NoneType:
+		return "", nil
+	case starlark.String:
+		return string(outbound), nil
+	default:
+		return "", E.New("invalid ", mainFunction, " result: expected string, got ", result.Type())
+	}
+}
+
+func contextFromThread(thread *starlark.Thread) context.Context {
+	if ctx, loaded := thread.Local(localContext).(context.Context); loaded {
+		return ctx
+	}
+	return context.Background()
+}
+
+// References reports whether the script accesses any of the given attributes,
+// such as metadata.geoip. Scripts that fail to parse reference nothing.
+func References(code string, attributes ...string) bool {
+	file, err := syntax.Parse("script", code, 0)
+	if err != nil {
+		return false
+	}
+	var referenced bool
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if referenced {
+			return false
+		}
+		if dotExpr, isDot := node.(*syntax.DotExpr); isDot && common.Contains(attributes, dotExpr.Name.Name) {
+			referenced = true
+		}
+		return !referenced
+	})
+	return referenced
+}
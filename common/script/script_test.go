@@ -0,0 +1,26 @@
+package script
+
+import (
+	"testing"
+
+	"github.com/sagernet/sing-box/log"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStepLimit(t *testing.T) {
+	t.Parallel()
+	_, err := Parse(nil, log.NewNOPFactory().Logger(), "test", `
+def spin():
+    n = 0
+    for i in range(1 << 30):
+        n += i
+    return n
+
+spin()
+
+def main(ctx, metadata):
+    return None
+`)
+	require.ErrorContains(t, err, "too many steps")
+}
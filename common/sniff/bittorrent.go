@@ -0,0 +1,76 @@
+package sniff
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+)
+
+const bitTorrentProtocol = "\x13BitTorrent protocol"
+
+// BitTorrentHandshake matches the peer wire handshake (BEP 3).
+func BitTorrentHandshake(ctx context.Context, reader io.Reader) (*adapter.InboundContext, error) {
+	var header [len(bitTorrentProtocol)]byte
+	_, err := io.ReadFull(reader, header[:])
+	if err != nil {
+		return nil, err
+	}
+	if string(header[:]) != bitTorrentProtocol {
+		return nil, os.ErrInvalid
+	}
+	return &adapter.InboundContext{Protocol: C.ProtocolBitTorrent}, nil
+}
+
+// UTPPacket matches the ST_SYN packet opening a uTP connection (BEP 29).
+func UTPPacket(ctx context.Context, packet []byte) (*adapter.InboundContext, error) {
+	const (
+		headerLen          = 20
+		typeSyn            = 4
+		extensionSelectAck = 1
+		extensionBits      = 2
+	)
+	if len(packet) < headerLen {
+		return nil, os.ErrInvalid
+	}
+	packetType := packet[0] >> 4
+	version := packet[0] & 0x0F
+	if version != 1 || packetType != typeSyn {
+		return nil, os.ErrInvalid
+	}
+	// the extension chain must only contain known extensions and fit in the packet
+	extension := packet[1]
+	offset := headerLen
+	for extension != 0 {
+		if extension != extensionSelectAck && extension != extensionBits || len(packet) < offset+2 {
+			return nil, os.ErrInvalid
+		}
+		extension = packet[offset]
+		offset += 2 + int(packet[offset+1])
+		if offset > len(packet) {
+			return nil, os.ErrInvalid
+		}
+	}
+	// ST_SYN packets carry no payload
+	if offset != len(packet) {
+		return nil, os.ErrInvalid
+	}
+	return &adapter.InboundContext{Protocol: C.ProtocolBitTorrent}, nil
+}
+
+// DHTMessage matches bencoded KRPC messages of the mainline DHT (BEP 5).
+func DHTMessage(ctx context.Context, packet []byte) (*adapter.InboundContext, error) {
+	if len(packet) < 12 || packet[0] != 'd' || packet[len(packet)-1] != 'e' {
+		return nil, os.ErrInvalid
+	}
+	if !bytes.Contains(packet, []byte("1:y1:q")) && !bytes.Contains(packet, []byte("1:y1:r")) && !bytes.Contains(packet, []byte("1:y1:e")) {
+		return nil, os.ErrInvalid
+	}
+	if !bytes.Contains(packet, []byte("1:t")) {
+		return nil, os.ErrInvalid
+	}
+	return &adapter.InboundContext{Protocol: C.ProtocolBitTorrent}, nil
+}
@@ -0,0 +1,73 @@
+package sniff_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/sagernet/sing-box/common/sniff"
+	C "github.com/sagernet/sing-box/constant"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffBitTorrentHandshake(t *testing.T) {
+	t.Parallel()
+	payload, err := hex.DecodeString("13426974546f7272656e742070726f746f636f6c0000000000100005a1b84b1c3c5a5b4f7c2a7e59a1c3e6e2e7cd4a2d2d5554333630532d2d5f8a3f0c9b1e7d4e6a2c01")
+	require.NoError(t, err)
+	metadata, err := sniff.BitTorrentHandshake(context.Background(), bytes.NewReader(payload))
+	require.NoError(t, err)
+	require.Equal(t, C.ProtocolBitTorrent, metadata.Protocol)
+}
+
+func TestSniffUTP(t *testing.T) {
+	t.Parallel()
+	// ST_SYN
+	packet, err := hex.DecodeString("41000a6d7ec7b39f00000000001000003d4e0000")
+	require.NoError(t, err)
+	metadata, err := sniff.UTPPacket(context.Background(), packet)
+	require.NoError(t, err)
+	require.Equal(t, C.ProtocolBitTorrent, metadata.Protocol)
+	// ST_SYN with an extension bits extension
+	packet, err = hex.DecodeString("41020a6d7ec7b39f00000000001000003d4e000000080000000000000000")
+	require.NoError(t, err)
+	metadata, err = sniff.UTPPacket(context.Background(), packet)
+	require.NoError(t, err)
+	require.Equal(t, C.ProtocolBitTorrent, metadata.Protocol)
+	// truncated extension
+	_, err = sniff.UTPPacket(context.Background(), packet[:23])
+	require.Error(t, err)
+	// unknown extension
+	packet[1] = 5
+	_, err = sniff.UTPPacket(context.Background(), packet)
+	require.Error(t, err)
+	// ST_STATE does not open a connection
+	packet, err = hex.DecodeString("21010a6c7ec7d13a0012d5a600100000d1223d4e000400000080")
+	require.NoError(t, err)
+	_, err = sniff.UTPPacket(context.Background(), packet)
+	require.Error(t, err)
+	// ST_DATA with a payload
+	packet, err = hex.DecodeString("01000a6d7ec7b39f00000000001000003d4e00006869")
+	require.NoError(t, err)
+	_, err = sniff.UTPPacket(context.Background(), packet)
+	require.Error(t, err)
+}
+
+func TestSniffDHT(t *testing.T) {
+	t.Parallel()
+	packet := []byte("d1:ad2:id20:abcdefghij0123456789e1:q4:ping1:t2:aa1:y1:qe")
+	metadata, err := sniff.DHTMessage(context.Background(), packet)
+	require.NoError(t, err)
+	require.Equal(t, C.ProtocolBitTorrent, metadata.Protocol)
+	packet = []byte("d1:rd2:id20:mnopqrstuvwxyz123456e1:t2:aa1:y1:re")
+	metadata, err = sniff.DHTMessage(context.Background(), packet)
+	require.NoError(t, err)
+	require.Equal(t, C.ProtocolBitTorrent, metadata.Protocol)
+}
+
+func FuzzSniffUTP(f *testing.F) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		sniff.UTPPacket(context.Background(), data)
+	})
+}
@@ -0,0 +1,39 @@
+package sniff
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+)
+
+// DTLSRecord matches a DTLS 1.0/1.2 record carrying a ClientHello. DTLS 1.3
+// clients send their first ClientHello with the DTLS 1.2 record version.
+func DTLSRecord(ctx context.Context, packet []byte) (*adapter.InboundContext, error) {
+	const (
+		recordHeaderLen    = 13
+		handshakeHeaderLen = 12
+		contentHandshake   = 22
+		typeClientHello    = 1
+	)
+	if len(packet) < recordHeaderLen+handshakeHeaderLen {
+		return nil, os.ErrInvalid
+	}
+	if packet[0] != contentHandshake || packet[1] != 0xFE || packet[2] != 0xFF && packet[2] != 0xFD {
+		return nil, os.ErrInvalid
+	}
+	// the first flight is always sent in epoch 0
+	if binary.BigEndian.Uint16(packet[3:5]) != 0 {
+		return nil, os.ErrInvalid
+	}
+	length := int(binary.BigEndian.Uint16(packet[11:13]))
+	if length < handshakeHeaderLen || recordHeaderLen+length > len(packet) {
+		return nil, os.ErrInvalid
+	}
+	if packet[recordHeaderLen] != typeClientHello {
+		return nil, os.ErrInvalid
+	}
+	return &adapter.InboundContext{Protocol: C.ProtocolDTLS}, nil
+}
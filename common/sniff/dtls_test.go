@@ -0,0 +1,21 @@
+package sniff_test
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/sagernet/sing-box/common/sniff"
+	C "github.com/sagernet/sing-box/constant"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffDTLS(t *testing.T) {
+	t.Parallel()
+	packet, err := hex.DecodeString("16fefd0000000000000000004c010000400000000000000040fefd454349e422f05297191ead13e21d3db520e5abef52055e4964b82fb213f593a100000004c02bc02f01000012000d0006000404030401000a000400020017")
+	require.NoError(t, err)
+	metadata, err := sniff.DTLSRecord(context.Background(), packet)
+	require.NoError(t, err)
+	require.Equal(t, C.ProtocolDTLS, metadata.Protocol)
+}
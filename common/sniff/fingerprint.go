@@ -0,0 +1,313 @@
+package sniff
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+const (
+	extensionServerName             = 0
+	extensionALPN                   = 16
+	extensionRecordSizeLimit        = 28
+	extensionDelegatedCredential    = 34
+	extensionSignatureAlgorithms    = 13
+	extensionSupportedVersions      = 43
+	extensionApplicationSettings    = 17513
+	extensionApplicationSettingsNew = 17613
+)
+
+type clientHello struct {
+	version             uint16
+	cipherSuites        []uint16
+	extensions          []uint16
+	serverName          bool
+	alpn                []string
+	supportedVersions   []uint16
+	signatureAlgorithms []uint16
+	grease              bool
+}
+
+// readClientHello reads the ClientHello handshake message from TLS records.
+func readClientHello(reader io.Reader) (*clientHello, error) {
+	var message []byte
+	for {
+		var header [5]byte
+		_, err := io.ReadFull(reader, header[:])
+		if err != nil {
+			return nil, err
+		}
+		if header[0] != 22 {
+			return nil, E.New("not a handshake record")
+		}
+		fragment := make([]byte, binary.BigEndian.Uint16(header[3:]))
+		_, err = io.ReadFull(reader, fragment)
+		if err != nil {
+			return nil, err
+		}
+		message = append(message, fragment...)
+		if len(message) >= 4 && len(message) >= 4+handshakeLength(message) {
+			break
+		}
+	}
+	if message[0] != 1 {
+		return nil, E.New("not a client hello")
+	}
+	return parseClientHello(message[4 : 4+handshakeLength(message)])
+}
+
+func handshakeLength(message []byte) int {
+	return int(message[1])<<16 | int(message[2])<<8 | int(message[3])
+}
+
+func parseClientHello(data []byte) (*clientHello, error) {
+	hello := &clientHello{}
+	reader := clientHelloReader(data)
+	var ok bool
+	if hello.version, ok = reader.uint16(); !ok {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if !reader.skip(32) || !reader.skipVector(1) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	cipherSuites, ok := reader.vector(2)
+	if !ok || !reader.skipVector(1) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	for len(cipherSuites) >= 2 {
+		cipherSuite, _ := cipherSuites.uint16()
+		if isGREASE(cipherSuite) {
+			hello.grease = true
+			continue
+		}
+		hello.cipherSuites = append(hello.cipherSuites, cipherSuite)
+	}
+	if len(reader) == 0 {
+		return hello, nil
+	}
+	extensions, ok := reader.vector(2)
+	if !ok {
+		return nil, io.ErrUnexpectedEOF
+	}
+	for len(extensions) > 0 {
+		extension, ok := extensions.uint16()
+		if !ok {
+			return nil, io.ErrUnexpectedEOF
+		}
+		content, ok := extensions.vector(2)
+		if !ok {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if isGREASE(extension) {
+			hello.grease = true
+			continue
+		}
+		hello.extensions = append(hello.extensions, extension)
+		switch extension {
+		case extensionServerName:
+			hello.serverName = true
+		case extensionALPN:
+			protocols, _ := content.vector(2)
+			for len(protocols) > 0 {
+				protocol, ok := protocols.vector(1)
+				if !ok {
+					break
+				}
+				hello.alpn = append(hello.alpn, string(protocol))
+			}
+		case extensionSupportedVersions:
+			versions, _ := content.vector(1)
+			for len(versions) >= 2 {
+				version, _ := versions.uint16()
+				if !isGREASE(version) {
+					hello.supportedVersions = append(hello.supportedVersions, version)
+				}
+			}
+		case extensionSignatureAlgorithms:
+			algorithms, _ := content.vector(2)
+			for len(algorithms) >= 2 {
+				algorithm, _ := algorithms.uint16()
+				if !isGREASE(algorithm) {
+					hello.signatureAlgorithms = append(hello.signatureAlgorithms, algorithm)
+				}
+			}
+		}
+	}
+	return hello, nil
+}
+
+func isGREASE(value uint16) bool {
+	return value&0x0f0f == 0x0a0a && value>>8 == value&0xff
+}
+
+// maxVersion returns the highest TLS version offered by the client.
+func (h *clientHello) maxVersion() uint16 {
+	version := h.version
+	for _, supportedVersion := range h.supportedVersions {
+		if supportedVersion > version {
+			version = supportedVersion
+		}
+	}
+	return version
+}
+
+// fingerprint returns the JA4 fingerprint of the ClientHello.
+func (h *clientHello) fingerprint(quic bool) string {
+	var builder strings.Builder
+	if quic {
+		builder.WriteByte('q')
+	} else {
+		builder.WriteByte('t')
+	}
+	switch h.maxVersion() {
+	case 0x0304:
+		builder.WriteString("13")
+	case 0x0303:
+		builder.WriteString("12")
+	case 0x0302:
+		builder.WriteString("11")
+	case 0x0301:
+		builder.WriteString("10")
+	case 0x0300:
+		builder.WriteString("s3")
+	default:
+		builder.WriteString("00")
+	}
+	if h.serverName {
+		builder.WriteByte('d')
+	} else {
+		builder.WriteByte('i')
+	}
+	fmt.Fprintf(&builder, "%02d%02d", ja4Count(len(h.cipherSuites)), ja4Count(len(h.extensions)))
+	if len(h.alpn) > 0 && len(h.alpn[0]) > 0 {
+		alpn := h.alpn[0]
+		builder.WriteByte(alpn[0])
+		builder.WriteByte(alpn[len(alpn)-1])
+	} else {
+		builder.WriteString("00")
+	}
+	builder.WriteByte('_')
+	builder.WriteString(truncatedHash(sortedHex(h.cipherSuites, nil), ""))
+	builder.WriteByte('_')
+	builder.WriteString(truncatedHash(sortedHex(h.extensions, []uint16{extensionServerName, extensionALPN}), joinHex(h.signatureAlgorithms)))
+	return builder.String()
+}
+
+// client guesses the client implementation from extensions only sent by some TLS stacks.
+func (h *clientHello) client() string {
+	for _, extension := range h.extensions {
+		if extension == extensionApplicationSettings || extension == extensionApplicationSettingsNew {
+			return C.ClientChromium
+		}
+	}
+	if h.grease {
+		return C.ClientSafari
+	}
+	var recordSizeLimit, delegatedCredential bool
+	for _, extension := range h.extensions {
+		switch extension {
+		case extensionRecordSizeLimit:
+			recordSizeLimit = true
+		case extensionDelegatedCredential:
+			delegatedCredential = true
+		}
+	}
+	if recordSizeLimit && delegatedCredential {
+		return C.ClientFirefox
+	}
+	return ""
+}
+
+func sortedHex(values []uint16, excluded []uint16) string {
+	sorted := make([]uint16, 0, len(values))
+	for _, value := range values {
+		var skip bool
+		for _, excludedValue := range excluded {
+			if value == excludedValue {
+				skip = true
+				break
+			}
+		}
+		if !skip {
+			sorted = append(sorted, value)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	return joinHex(sorted)
+}
+
+func joinHex(values []uint16) string {
+	hexValues := make([]string, 0, len(values))
+	for _, value := range values {
+		hexValues = append(hexValues, fmt.Sprintf("%04x", value))
+	}
+	return strings.Join(hexValues, ",")
+}
+
+func truncatedHash(content string, suffix string) string {
+	if content == "" {
+		return "000000000000"
+	}
+	if suffix != "" {
+		content += "_" + suffix
+	}
+	hash := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(hash[:6])
+}
+
+// ja4Count caps a count at the two digits it has in the fingerprint.
+func ja4Count(count int) int {
+	if count > 99 {
+		return 99
+	}
+	return count
+}
+
+type clientHelloReader []byte
+
+func (r *clientHelloReader) skip(n int) bool {
+	if len(*r) < n {
+		return false
+	}
+	*r = (*r)[n:]
+	return true
+}
+
+func (r *clientHelloReader) uint16() (uint16, bool) {
+	if len(*r) < 2 {
+		return 0, false
+	}
+	value := binary.BigEndian.Uint16(*r)
+	*r = (*r)[2:]
+	return value, true
+}
+
+func (r *clientHelloReader) vector(lengthSize int) (clientHelloReader, bool) {
+	if len(*r) < lengthSize {
+		return nil, false
+	}
+	var length int
+	for _, b := range (*r)[:lengthSize] {
+		length = length<<8 | int(b)
+	}
+	if len(*r) < lengthSize+length {
+		return nil, false
+	}
+	content := (*r)[lengthSize : lengthSize+length]
+	*r = (*r)[lengthSize+length:]
+	return content, true
+}
+
+func (r *clientHelloReader) skipVector(lengthSize int) bool {
+	_, ok := r.vector(lengthSize)
+	return ok
+}
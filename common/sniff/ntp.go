@@ -0,0 +1,31 @@
+package sniff
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+)
+
+// NTPMessage matches NTPv3/v4 client requests (RFC 5905).
+func NTPMessage(ctx context.Context, packet []byte) (*adapter.InboundContext, error) {
+	const headerLen = 48
+	if len(packet) < headerLen {
+		return nil, os.ErrInvalid
+	}
+	version := packet[0] >> 3 & 0x07
+	mode := packet[0] & 0x07
+	if version != 3 && version != 4 || mode != 3 {
+		return nil, os.ErrInvalid
+	}
+	if stratum := packet[1]; stratum > 16 {
+		return nil, os.ErrInvalid
+	}
+	// root delay and dispersion are 16.16 fixed point seconds, and never exceed 16s in practice
+	if binary.BigEndian.Uint32(packet[4:8]) > 16<<16 || binary.BigEndian.Uint32(packet[8:12]) > 16<<16 {
+		return nil, os.ErrInvalid
+	}
+	return &adapter.InboundContext{Protocol: C.ProtocolNTP}, nil
+}
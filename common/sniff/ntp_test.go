@@ -0,0 +1,32 @@
+package sniff_test
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/sagernet/sing-box/common/sniff"
+	C "github.com/sagernet/sing-box/constant"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffNTP(t *testing.T) {
+	t.Parallel()
+	// SNTP client request
+	packet, err := hex.DecodeString("1b0000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000")
+	require.NoError(t, err)
+	metadata, err := sniff.NTPMessage(context.Background(), packet)
+	require.NoError(t, err)
+	require.Equal(t, C.ProtocolNTP, metadata.Protocol)
+	// ntpd client request
+	packet, err = hex.DecodeString("e30006ec000000000000000000000000000000000000000000000000000000000000000000000000e89ac9f2c4a60000")
+	require.NoError(t, err)
+	metadata, err = sniff.NTPMessage(context.Background(), packet)
+	require.NoError(t, err)
+	require.Equal(t, C.ProtocolNTP, metadata.Protocol)
+	// server response
+	packet[0] = 0x24
+	_, err = sniff.NTPMessage(context.Background(), packet)
+	require.Error(t, err)
+}
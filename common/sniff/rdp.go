@@ -0,0 +1,34 @@
+package sniff
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+)
+
+// RDPConnectionRequest matches the X.224 Connection Request TPDU in a TPKT
+// header, which starts every RDP connection ([MS-RDPBCGR] 2.2.1.1).
+func RDPConnectionRequest(ctx context.Context, reader io.Reader) (*adapter.InboundContext, error) {
+	var header [7]byte
+	_, err := io.ReadFull(reader, header[:])
+	if err != nil {
+		return nil, err
+	}
+	if header[0] != 3 || header[1] != 0 {
+		return nil, os.ErrInvalid
+	}
+	length := int(binary.BigEndian.Uint16(header[2:4]))
+	// length indicator counts the TPDU header after itself
+	if length < 11 || int(header[4]) != length-5 {
+		return nil, os.ErrInvalid
+	}
+	// connection request, with zero destination reference
+	if header[5] != 0xE0 || header[6] != 0 {
+		return nil, os.ErrInvalid
+	}
+	return &adapter.InboundContext{Protocol: C.ProtocolRDP}, nil
+}
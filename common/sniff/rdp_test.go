@@ -0,0 +1,22 @@
+package sniff_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/sagernet/sing-box/common/sniff"
+	C "github.com/sagernet/sing-box/constant"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffRDP(t *testing.T) {
+	t.Parallel()
+	payload, err := hex.DecodeString("030000332ee00000000000436f6f6b69653a206d737473686173683d61646d696e6973747261746f720d0a010008000b000000")
+	require.NoError(t, err)
+	metadata, err := sniff.RDPConnectionRequest(context.Background(), bytes.NewReader(payload))
+	require.NoError(t, err)
+	require.Equal(t, C.ProtocolRDP, metadata.Protocol)
+}
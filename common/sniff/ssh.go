@@ -0,0 +1,26 @@
+package sniff
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+)
+
+// SSHBanner matches the protocol version exchange sent by SSH clients (RFC 4253).
+func SSHBanner(ctx context.Context, reader io.Reader) (*adapter.InboundContext, error) {
+	const maxBannerLen = 255
+	line, err := bufio.NewReaderSize(reader, maxBannerLen).ReadSlice('\n')
+	if err != nil {
+		return nil, err
+	}
+	banner := string(line)
+	if !strings.HasPrefix(banner, "SSH-2.0-") && !strings.HasPrefix(banner, "SSH-1.99-") {
+		return nil, os.ErrInvalid
+	}
+	return &adapter.InboundContext{Protocol: C.ProtocolSSH}, nil
+}
@@ -0,0 +1,21 @@
+package sniff_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sagernet/sing-box/common/sniff"
+	C "github.com/sagernet/sing-box/constant"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffSSH(t *testing.T) {
+	t.Parallel()
+	metadata, err := sniff.SSHBanner(context.Background(), strings.NewReader("SSH-2.0-OpenSSH_9.3p1 Ubuntu-1ubuntu3\r\n"))
+	require.NoError(t, err)
+	require.Equal(t, C.ProtocolSSH, metadata.Protocol)
+	_, err = sniff.SSHBanner(context.Background(), strings.NewReader("GET / HTTP/1.1\r\n"))
+	require.Error(t, err)
+}
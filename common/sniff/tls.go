@@ -1,6 +1,7 @@
 package sniff
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"io"
@@ -11,15 +12,30 @@ import (
 )
 
 func TLSClientHello(ctx context.Context, reader io.Reader) (*adapter.InboundContext, error) {
+	return tlsClientHello(ctx, reader, false)
+}
+
+func tlsClientHello(ctx context.Context, reader io.Reader, quic bool) (*adapter.InboundContext, error) {
+	var records bytes.Buffer
 	var clientHello *tls.ClientHelloInfo
-	err := tls.Server(bufio.NewReadOnlyConn(reader), &tls.Config{
+	err := tls.Server(bufio.NewReadOnlyConn(io.TeeReader(reader, &records)), &tls.Config{
 		GetConfigForClient: func(argHello *tls.ClientHelloInfo) (*tls.Config, error) {
 			clientHello = argHello
 			return nil, nil
 		},
 	}).HandshakeContext(ctx)
-	if clientHello != nil {
-		return &adapter.InboundContext{Protocol: C.ProtocolTLS, Domain: clientHello.ServerName}, nil
+	if clientHello == nil {
+		return nil, err
+	}
+	metadata := &adapter.InboundContext{
+		Protocol: C.ProtocolTLS,
+		Domain:   clientHello.ServerName,
+		ALPN:     clientHello.SupportedProtos,
+	}
+	if hello, err := readClientHello(&records); err == nil {
+		metadata.TLSVersion = hello.maxVersion()
+		metadata.ClientFingerprint = hello.fingerprint(quic)
+		metadata.Client = hello.client()
 	}
-	return nil, err
+	return metadata, nil
 }
@@ -0,0 +1,47 @@
+package sniff_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net"
+	"regexp"
+	"testing"
+
+	"github.com/sagernet/sing-box/common/sniff"
+	C "github.com/sagernet/sing-box/constant"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffTLSClientHello(t *testing.T) {
+	t.Parallel()
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	go func() {
+		tls.Client(clientConn, &tls.Config{
+			ServerName: "example.com",
+			NextProtos: []string{"h2", "http/1.1"},
+		}).Handshake()
+		clientConn.Close()
+	}()
+	var payload [4096]byte
+	n, err := serverConn.Read(payload[:])
+	require.NoError(t, err)
+	metadata, err := sniff.TLSClientHello(context.Background(), bytes.NewReader(payload[:n]))
+	require.NoError(t, err)
+	require.Equal(t, C.ProtocolTLS, metadata.Protocol)
+	require.Equal(t, "example.com", metadata.Domain)
+	require.Equal(t, []string{"h2", "http/1.1"}, metadata.ALPN)
+	require.Equal(t, uint16(tls.VersionTLS13), metadata.TLSVersion)
+	require.Regexp(t, regexp.MustCompile(`^t13d\d{4}h2_[0-9a-f]{12}_[0-9a-f]{12}$`), metadata.ClientFingerprint)
+	require.Empty(t, metadata.Client)
+}
+
+func TestSniffHTTPUserAgent(t *testing.T) {
+	t.Parallel()
+	metadata, err := sniff.HTTPHost(context.Background(), bytes.NewReader([]byte("GET / HTTP/1.1\r\nHost: example.com\r\nUser-Agent: curl/8.0.1\r\n\r\n")))
+	require.NoError(t, err)
+	require.Equal(t, "example.com", metadata.Domain)
+	require.Equal(t, "curl/8.0.1", metadata.UserAgent)
+}
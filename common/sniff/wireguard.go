@@ -0,0 +1,37 @@
+package sniff
+
+import (
+	"context"
+	"os"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+)
+
+// WireGuardMessage matches WireGuard messages by their type, reserved bytes and size.
+func WireGuardMessage(ctx context.Context, packet []byte) (*adapter.InboundContext, error) {
+	if len(packet) < 4 || packet[1] != 0 || packet[2] != 0 || packet[3] != 0 {
+		return nil, os.ErrInvalid
+	}
+	switch packet[0] {
+	case 1: // handshake initiation
+		if len(packet) != 148 {
+			return nil, os.ErrInvalid
+		}
+	case 2: // handshake response
+		if len(packet) != 92 {
+			return nil, os.ErrInvalid
+		}
+	case 3: // cookie reply
+		if len(packet) != 64 {
+			return nil, os.ErrInvalid
+		}
+	case 4: // transport data, padded to 16 bytes
+		if len(packet) < 32 || len(packet)%16 != 0 {
+			return nil, os.ErrInvalid
+		}
+	default:
+		return nil, os.ErrInvalid
+	}
+	return &adapter.InboundContext{Protocol: C.ProtocolWireGuard}, nil
+}
@@ -0,0 +1,23 @@
+package sniff_test
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/sagernet/sing-box/common/sniff"
+	C "github.com/sagernet/sing-box/constant"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffWireGuard(t *testing.T) {
+	t.Parallel()
+	packet, err := hex.DecodeString("010000008a3b2c1d1f40fc92da241694750979ee6cf582f2d5d7d28e18335de05abc54d0560e0f5302860c652bf08d560252aa5e74210546f369fbbbce8c12cfc7957b2652fe9a755267768822ee624d48fce15ec5ca79cbd602cb7f4c2157a516556991f22ef8c7b5ef7b18d1ff41c59370efb0858651d44a936c11b7b144c48fe04df3c6a3e8da2e7d2c03a9507ae265ecf5b5")
+	require.NoError(t, err)
+	metadata, err := sniff.WireGuardMessage(context.Background(), packet)
+	require.NoError(t, err)
+	require.Equal(t, C.ProtocolWireGuard, metadata.Protocol)
+	_, err = sniff.WireGuardMessage(context.Background(), packet[:147])
+	require.Error(t, err)
+}
@@ -0,0 +1,157 @@
+package timerange
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+var ErrBadTimeRange = E.New("bad time range")
+
+const minutesPerDay = 24 * 60
+
+// Matcher matches times against daily time ranges and weekdays in a time zone.
+type Matcher struct {
+	timeRanges []string
+	weekdays   []string
+	timezone   string
+	location   *time.Location
+	ranges     []minuteRange
+	weekdaySet uint8
+}
+
+// minuteRange is a range of minutes since midnight, end excluded. Ranges with
+// start after end cross midnight.
+type minuteRange struct {
+	start int
+	end   int
+}
+
+func New(timeRanges []string, weekdays []string, timezone string) (*Matcher, error) {
+	if len(timeRanges) == 0 && len(weekdays) == 0 {
+		return nil, E.New("missing time_range or weekday")
+	}
+	matcher := &Matcher{
+		timeRanges: timeRanges,
+		weekdays:   weekdays,
+		timezone:   timezone,
+		location:   time.Local,
+	}
+	if timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, E.Cause(err, "load timezone")
+		}
+		matcher.location = location
+	}
+	for _, timeRange := range timeRanges {
+		subIndex := strings.Index(timeRange, "-")
+		if subIndex == -1 {
+			return nil, E.Extend(ErrBadTimeRange, timeRange)
+		}
+		start, err := parseMinute(timeRange[:subIndex])
+		if err != nil {
+			return nil, E.Cause(err, E.Extend(ErrBadTimeRange, timeRange))
+		}
+		end, err := parseMinute(timeRange[subIndex+1:])
+		if err != nil {
+			return nil, E.Cause(err, E.Extend(ErrBadTimeRange, timeRange))
+		}
+		if start == end || start == minutesPerDay {
+			return nil, E.Extend(ErrBadTimeRange, timeRange)
+		}
+		matcher.ranges = append(matcher.ranges, minuteRange{start, end})
+	}
+	for _, weekday := range weekdays {
+		day, err := parseWeekday(weekday)
+		if err != nil {
+			return nil, err
+		}
+		matcher.weekdaySet |= 1 << day
+	}
+	return matcher, nil
+}
+
+func parseMinute(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	subIndex := strings.Index(value, ":")
+	if subIndex == -1 {
+		return 0, E.New("missing minute: ", value)
+	}
+	hour, err := strconv.ParseUint(value[:subIndex], 10, 8)
+	if err != nil {
+		return 0, err
+	}
+	minute, err := strconv.ParseUint(value[subIndex+1:], 10, 8)
+	if err != nil {
+		return 0, err
+	}
+	if minute >= 60 || hour*60+minute > minutesPerDay {
+		return 0, E.New("invalid time: ", value)
+	}
+	return int(hour*60 + minute), nil
+}
+
+func parseWeekday(value string) (time.Weekday, error) {
+	name := strings.ToLower(strings.TrimSpace(value))
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		fullName := strings.ToLower(day.String())
+		if name == fullName || name == fullName[:3] {
+			return day, nil
+		}
+	}
+	return 0, E.New("unknown weekday: ", value)
+}
+
+// Match reports whether the time is in one of the time ranges on one of the
+// weekdays. The part of a range after midnight belongs to the day it started.
+func (m *Matcher) Match(now time.Time) bool {
+	now = now.In(m.location)
+	weekday := now.Weekday()
+	if len(m.ranges) == 0 {
+		return m.matchWeekday(weekday)
+	}
+	minute := now.Hour()*60 + now.Minute()
+	for _, timeRange := range m.ranges {
+		if timeRange.start < timeRange.end {
+			if minute >= timeRange.start && minute < timeRange.end && m.matchWeekday(weekday) {
+				return true
+			}
+		} else {
+			if minute >= timeRange.start && m.matchWeekday(weekday) {
+				return true
+			}
+			if minute < timeRange.end && m.matchWeekday((weekday+6)%7) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (m *Matcher) matchWeekday(weekday time.Weekday) bool {
+	return m.weekdaySet == 0 || m.weekdaySet&(1<<weekday) != 0
+}
+
+func (m *Matcher) String() string {
+	var descriptions []string
+	if len(m.timeRanges) > 0 {
+		descriptions = append(descriptions, "time_range="+listString(m.timeRanges))
+	}
+	if len(m.weekdays) > 0 {
+		descriptions = append(descriptions, "weekday="+listString(m.weekdays))
+	}
+	if m.timezone != "" {
+		descriptions = append(descriptions, "timezone="+m.timezone)
+	}
+	return strings.Join(descriptions, " ")
+}
+
+func listString(values []string) string {
+	if len(values) == 1 {
+		return values[0]
+	}
+	return "[" + strings.Join(values, " ") + "]"
+}
@@ -0,0 +1,48 @@
+package timerange
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatcher(t *testing.T) {
+	t.Parallel()
+	// 2023-05-05 is a Friday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2023, 5, day, hour, minute, 0, 0, time.UTC)
+	}
+	matcher, err := New([]string{"09:00-18:00"}, []string{"mon", "Tuesday", "friday"}, "UTC")
+	require.NoError(t, err)
+	require.True(t, matcher.Match(at(5, 9, 0)))
+	require.True(t, matcher.Match(at(5, 17, 59)))
+	require.False(t, matcher.Match(at(5, 18, 0)))
+	require.False(t, matcher.Match(at(5, 8, 59)))
+	require.False(t, matcher.Match(at(6, 12, 0)))
+
+	matcher, err = New([]string{"22:00-06:00"}, []string{"friday"}, "UTC")
+	require.NoError(t, err)
+	require.True(t, matcher.Match(at(5, 23, 0)))
+	require.True(t, matcher.Match(at(6, 5, 59)))
+	require.False(t, matcher.Match(at(5, 5, 0)))
+	require.False(t, matcher.Match(at(6, 23, 0)))
+
+	matcher, err = New([]string{"00:00-24:00"}, nil, "Asia/Shanghai")
+	require.NoError(t, err)
+	require.True(t, matcher.Match(at(5, 16, 0)))
+
+	matcher, err = New(nil, []string{"sat", "sun"}, "Asia/Shanghai")
+	require.NoError(t, err)
+	require.True(t, matcher.Match(at(5, 16, 0)))
+	require.False(t, matcher.Match(at(5, 15, 59)))
+
+	for _, timeRange := range []string{"09:00", "09:00-09:00", "24:00-01:00", "09:60-10:00", "25:00-26:00", "9-10"} {
+		_, err = New([]string{timeRange}, nil, "")
+		require.Error(t, err, timeRange)
+	}
+	_, err = New(nil, []string{"someday"}, "")
+	require.Error(t, err)
+	_, err = New(nil, nil, "UTC")
+	require.Error(t, err)
+}
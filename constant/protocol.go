@@ -1,9 +1,21 @@
 package constant
 
 const (
-	ProtocolTLS  = "tls"
-	ProtocolHTTP = "http"
-	ProtocolQUIC = "quic"
-	ProtocolDNS  = "dns"
-	ProtocolSTUN = "stun"
+	ProtocolTLS        = "tls"
+	ProtocolHTTP       = "http"
+	ProtocolQUIC       = "quic"
+	ProtocolDNS        = "dns"
+	ProtocolSTUN       = "stun"
+	ProtocolBitTorrent = "bittorrent"
+	ProtocolSSH        = "ssh"
+	ProtocolRDP        = "rdp"
+	ProtocolDTLS       = "dtls"
+	ProtocolWireGuard  = "wireguard"
+	ProtocolNTP        = "ntp"
+)
+
+const (
+	ClientChromium = "chromium"
+	ClientFirefox  = "firefox"
+	ClientSafari   = "safari"
 )
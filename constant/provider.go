@@ -0,0 +1,17 @@
+package constant
+
+const (
+	ProviderTypeLocal  = "local"
+	ProviderTypeRemote = "remote"
+)
+
+const (
+	RuleProviderFormatDomain    = "domain"
+	RuleProviderFormatIPCIDR    = "ipcidr"
+	RuleProviderFormatClassical = "classical"
+)
+
+const (
+	RuleProviderFileFormatYAML = "yaml"
+	RuleProviderFileFormatText = "text"
+)
@@ -21,9 +21,20 @@ const (
 	TypeShadowTLS    = "shadowtls"
 	TypeShadowsocksR = "shadowsocksr"
 	TypeVLESS        = "vless"
+	TypeTUIC         = "tuic"
+	TypeHysteria2    = "hysteria2"
 )
 
 const (
-	TypeSelector = "selector"
-	TypeURLTest  = "urltest"
+	TypeSelector    = "selector"
+	TypeURLTest     = "urltest"
+	TypeLoadBalance = "load_balance"
+	TypeFallback    = "fallback"
+	TypeChain       = "chain"
+)
+
+const (
+	LoadBalanceStrategyRoundRobin        = "round_robin"
+	LoadBalanceStrategyConsistentHashing = "consistent_hashing"
+	LoadBalanceStrategyStickySessions    = "sticky_sessions"
 )
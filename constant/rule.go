@@ -9,3 +9,9 @@ const (
 	LogicalTypeAnd = "and"
 	LogicalTypeOr  = "or"
 )
+
+const (
+	DNSResponseActionAccept = "accept"
+	DNSResponseActionReject = "reject"
+	DNSResponseActionRetry  = "retry"
+)
@@ -0,0 +1,145 @@
+package accesslog
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/rotatefile"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/experimental/trackerconn"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/atomic"
+	E "github.com/sagernet/sing/common/exceptions"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var _ adapter.AccessLogger = (*Logger)(nil)
+
+type Logger struct {
+	router     adapter.Router
+	logger     log.Logger
+	path       string
+	maxSize    int64
+	maxBackups int
+	format     func(record *Record) []byte
+	header     []byte
+	file       *rotatefile.File
+}
+
+func NewLogger(router adapter.Router, logger log.Logger, options option.AccessLogOptions) (*Logger, error) {
+	if options.Path == "" {
+		return nil, E.New("missing path")
+	}
+	if options.MaxSize < 0 || options.MaxBackups < 0 {
+		return nil, E.New("invalid max_size or max_backups")
+	}
+	accessLogger := &Logger{
+		router:     router,
+		logger:     logger,
+		path:       C.BasePath(options.Path),
+		maxSize:    int64(options.MaxSize) * 1024 * 1024,
+		maxBackups: options.MaxBackups,
+	}
+	switch options.Format {
+	case "", "text":
+		accessLogger.format = formatText
+	case "json":
+		accessLogger.format = formatJSON
+	case "csv":
+		accessLogger.format = formatCSV
+		accessLogger.header = csvLine(csvHeader)
+	default:
+		return nil, E.New("unknown access log format: ", options.Format)
+	}
+	return accessLogger, nil
+}
+
+func (l *Logger) Start() error {
+	file, err := rotatefile.Open(l.path, l.maxSize, l.maxBackups, l.header)
+	if err != nil {
+		return E.Cause(err, "open access log")
+	}
+	l.file = file
+	return nil
+}
+
+func (l *Logger) Close() error {
+	return common.Close(
+		common.PtrOrNil(l.file),
+	)
+}
+
+func (l *Logger) RoutedConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, matchedRule adapter.Rule, outbound adapter.Outbound) (net.Conn, adapter.Tracker) {
+	t := l.newTracker(ctx, metadata, matchedRule, outbound)
+	return trackerconn.New(conn, []*atomic.Int64{&t.upload}, []*atomic.Int64{&t.download}), t
+}
+
+func (l *Logger) RoutedPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, matchedRule adapter.Rule, outbound adapter.Outbound) (N.PacketConn, adapter.Tracker) {
+	t := l.newTracker(ctx, metadata, matchedRule, outbound)
+	return trackerconn.NewPacket(conn, []*atomic.Int64{&t.upload}, []*atomic.Int64{&t.download}), t
+}
+
+func (l *Logger) newTracker(ctx context.Context, metadata adapter.InboundContext, matchedRule adapter.Rule, outbound adapter.Outbound) *tracker {
+	record := Record{
+		Start:       time.Now(),
+		Network:     metadata.Network,
+		Inbound:     metadata.Inbound,
+		InboundType: metadata.InboundType,
+		User:        metadata.User,
+		Source:      metadata.Source.String(),
+		Destination: metadata.Destination.String(),
+		Domain:      metadata.Domain,
+		Protocol:    metadata.Protocol,
+		Rule:        "final",
+		Outbound:    adapter.ResolveOutboundChain(l.router, outbound.Tag()),
+	}
+	if id, loaded := log.IDFromContext(ctx); loaded {
+		record.ID = id.ID
+		record.Start = id.CreatedAt
+	}
+	if matchedRule != nil {
+		record.Rule = matchedRule.String()
+	}
+	return &tracker{logger: l, record: record}
+}
+
+func (l *Logger) write(record *Record) {
+	_, err := l.file.Write(l.format(record))
+	if err != nil && !errors.Is(err, os.ErrClosed) {
+		l.logger.Error("write access log: ", err)
+	}
+}
+
+type tracker struct {
+	logger   *Logger
+	record   Record
+	upload   atomic.Int64
+	download atomic.Int64
+}
+
+func (t *tracker) Leave(err error) {
+	record := t.record
+	record.Time = time.Now()
+	record.Upload = t.upload.Load()
+	record.Download = t.download.Load()
+	record.Duration = record.Time.Sub(record.Start).Milliseconds()
+	record.Reason = closeReason(err)
+	t.logger.write(&record)
+}
+
+func closeReason(err error) string {
+	switch {
+	case err == nil, E.IsClosedOrCanceled(err):
+		return "closed"
+	case E.IsTimeout(err):
+		return "timeout"
+	default:
+		return err.Error()
+	}
+}
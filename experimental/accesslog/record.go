@@ -0,0 +1,95 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Record struct {
+	Time        time.Time `json:"time"`
+	Start       time.Time `json:"start"`
+	ID          uint32    `json:"id,omitempty"`
+	Network     string    `json:"network"`
+	Inbound     string    `json:"inbound,omitempty"`
+	InboundType string    `json:"inbound_type"`
+	User        string    `json:"user,omitempty"`
+	Source      string    `json:"source"`
+	Destination string    `json:"destination"`
+	Domain      string    `json:"domain,omitempty"`
+	Protocol    string    `json:"protocol,omitempty"`
+	Rule        string    `json:"rule"`
+	Outbound    []string  `json:"outbound"`
+	Upload      int64     `json:"upload"`
+	Download    int64     `json:"download"`
+	Duration    int64     `json:"duration_ms"`
+	Reason      string    `json:"reason"`
+}
+
+var csvHeader = []string{
+	"time", "start", "id", "network", "inbound", "inbound_type", "user", "source", "destination",
+	"domain", "protocol", "rule", "outbound", "upload", "download", "duration_ms", "reason",
+}
+
+func (r *Record) values() []string {
+	return []string{
+		r.Time.Format(time.RFC3339Nano),
+		r.Start.Format(time.RFC3339Nano),
+		strconv.FormatUint(uint64(r.ID), 10),
+		r.Network,
+		r.Inbound,
+		r.InboundType,
+		r.User,
+		r.Source,
+		r.Destination,
+		r.Domain,
+		r.Protocol,
+		r.Rule,
+		strings.Join(r.Outbound, ">"),
+		strconv.FormatInt(r.Upload, 10),
+		strconv.FormatInt(r.Download, 10),
+		strconv.FormatInt(r.Duration, 10),
+		r.Reason,
+	}
+}
+
+// formatText formats the record as key=value pairs, omitting empty values.
+func formatText(record *Record) []byte {
+	var buffer bytes.Buffer
+	for i, value := range record.values() {
+		if value == "" {
+			continue
+		}
+		if buffer.Len() > 0 {
+			buffer.WriteByte(' ')
+		}
+		buffer.WriteString(csvHeader[i])
+		buffer.WriteByte('=')
+		if strings.ContainsAny(value, " \"=") {
+			value = strconv.Quote(value)
+		}
+		buffer.WriteString(value)
+	}
+	buffer.WriteByte('\n')
+	return buffer.Bytes()
+}
+
+func formatJSON(record *Record) []byte {
+	content, _ := json.Marshal(record)
+	return append(content, '\n')
+}
+
+func formatCSV(record *Record) []byte {
+	return csvLine(record.values())
+}
+
+func csvLine(values []string) []byte {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	writer.Write(values)
+	writer.Flush()
+	return buffer.Bytes()
+}
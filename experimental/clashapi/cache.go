@@ -12,6 +12,7 @@ import (
 func cacheRouter(router adapter.Router) http.Handler {
 	r := chi.NewRouter()
 	r.Post("/fakeip/flush", flushFakeip(router))
+	r.Post("/dns/flush", flushDNS(router))
 	return r
 }
 
@@ -28,3 +29,15 @@ func flushFakeip(router adapter.Router) func(w http.ResponseWriter, r *http.Requ
 		render.NoContent(w, r)
 	}
 }
+
+func flushDNS(router adapter.Router) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := router.ClearDNSCache()
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
+		render.NoContent(w, r)
+	}
+}
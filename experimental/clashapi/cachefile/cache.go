@@ -2,9 +2,11 @@ package cachefile
 
 import (
 	"os"
+	"sync"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
+	E "github.com/sagernet/sing/common/exceptions"
 
 	"go.etcd.io/bbolt"
 )
@@ -14,7 +16,11 @@ var bucketSelected = []byte("selected")
 var _ adapter.ClashCacheFile = (*CacheFile)(nil)
 
 type CacheFile struct {
-	DB *bbolt.DB
+	DB           *bbolt.DB
+	dnsAccess    sync.Mutex
+	dnsPending   map[string][]byte
+	dnsFlushOnce sync.Once
+	done         chan struct{}
 }
 
 func Open(path string) (*CacheFile, error) {
@@ -31,7 +37,10 @@ func Open(path string) (*CacheFile, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &CacheFile{db}, nil
+	return &CacheFile{
+		DB:   db,
+		done: make(chan struct{}),
+	}, nil
 }
 
 func (c *CacheFile) LoadSelected(group string) string {
@@ -61,5 +70,6 @@ func (c *CacheFile) StoreSelected(group, selected string) error {
 }
 
 func (c *CacheFile) Close() error {
-	return c.DB.Close()
+	close(c.done)
+	return E.Errors(c.flushDNSCache(), c.DB.Close())
 }
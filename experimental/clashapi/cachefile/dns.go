@@ -0,0 +1,185 @@
+package cachefile
+
+import (
+	"encoding/binary"
+	"sort"
+	"time"
+
+	"github.com/miekg/dns"
+	"go.etcd.io/bbolt"
+)
+
+var bucketDNS = []byte("dns")
+
+const (
+	// dnsCacheFlushInterval is the interval to write stored responses to the file.
+	// Responses expiring sooner are kept in memory only.
+	dnsCacheFlushInterval = 10 * time.Second
+	// dnsCacheMaxEntries is the number of responses kept in the file by DNSCachePurge.
+	dnsCacheMaxEntries = 16384
+)
+
+func dnsCacheKey(question dns.Question) []byte {
+	key := make([]byte, 4, 4+len(question.Name))
+	binary.BigEndian.PutUint16(key, question.Qtype)
+	binary.BigEndian.PutUint16(key[2:], question.Qclass)
+	return append(key, question.Name...)
+}
+
+func (c *CacheFile) LoadDNSCache(question dns.Question) (*dns.Msg, time.Time, bool) {
+	key := dnsCacheKey(question)
+	c.dnsAccess.Lock()
+	content := c.dnsPending[string(key)]
+	c.dnsAccess.Unlock()
+	if content == nil {
+		_ = c.DB.View(func(tx *bbolt.Tx) error {
+			bucket := tx.Bucket(bucketDNS)
+			if bucket == nil {
+				return nil
+			}
+			value := bucket.Get(key)
+			if len(value) > 8 {
+				content = make([]byte, len(value))
+				copy(content, value)
+			}
+			return nil
+		})
+	}
+	if content == nil {
+		return nil, time.Time{}, false
+	}
+	var message dns.Msg
+	err := message.Unpack(content[8:])
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	return &message, time.Unix(int64(binary.BigEndian.Uint64(content)), 0), true
+}
+
+// StoreDNSCache queues the response to be written with the next flush.
+func (c *CacheFile) StoreDNSCache(question dns.Question, message *dns.Msg, expireAt time.Time) error {
+	if time.Until(expireAt) < dnsCacheFlushInterval {
+		return nil
+	}
+	content, err := message.Pack()
+	if err != nil {
+		return err
+	}
+	value := make([]byte, 8, 8+len(content))
+	binary.BigEndian.PutUint64(value, uint64(expireAt.Unix()))
+	value = append(value, content...)
+	c.dnsAccess.Lock()
+	defer c.dnsAccess.Unlock()
+	if c.dnsPending == nil {
+		c.dnsPending = make(map[string][]byte)
+		c.dnsFlushOnce.Do(func() {
+			go c.loopFlushDNSCache()
+		})
+	}
+	c.dnsPending[string(dnsCacheKey(question))] = value
+	return nil
+}
+
+func (c *CacheFile) loopFlushDNSCache() {
+	ticker := time.NewTicker(dnsCacheFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_ = c.flushDNSCache()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *CacheFile) flushDNSCache() error {
+	c.dnsAccess.Lock()
+	pending := c.dnsPending
+	c.dnsPending = nil
+	c.dnsAccess.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+	return c.DB.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(bucketDNS)
+		if err != nil {
+			return err
+		}
+		for key, value := range pending {
+			err = bucket.Put([]byte(key), value)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DNSCachePurge removes expired responses if purgeExpired is set, then the responses
+// expiring first if more than dnsCacheMaxEntries are left.
+func (c *CacheFile) DNSCachePurge(purgeExpired bool) error {
+	err := c.flushDNSCache()
+	if err != nil {
+		return err
+	}
+	now := uint64(time.Now().Unix())
+	return c.DB.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(bucketDNS)
+		if bucket == nil {
+			return nil
+		}
+		type dnsCacheEntry struct {
+			key      []byte
+			expireAt uint64
+		}
+		var (
+			purgeKeys [][]byte
+			entries   []dnsCacheEntry
+		)
+		err := bucket.ForEach(func(key, value []byte) error {
+			if len(value) <= 8 {
+				purgeKeys = append(purgeKeys, append([]byte(nil), key...))
+				return nil
+			}
+			expireAt := binary.BigEndian.Uint64(value)
+			if purgeExpired && expireAt < now {
+				purgeKeys = append(purgeKeys, append([]byte(nil), key...))
+				return nil
+			}
+			entries = append(entries, dnsCacheEntry{append([]byte(nil), key...), expireAt})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if len(entries) > dnsCacheMaxEntries {
+			sort.Slice(entries, func(i, j int) bool {
+				return entries[i].expireAt < entries[j].expireAt
+			})
+			for _, entry := range entries[:len(entries)-dnsCacheMaxEntries] {
+				purgeKeys = append(purgeKeys, entry.key)
+			}
+		}
+		for _, key := range purgeKeys {
+			err = bucket.Delete(key)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *CacheFile) DNSCacheReset() error {
+	c.dnsAccess.Lock()
+	c.dnsPending = nil
+	c.dnsAccess.Unlock()
+	return c.DB.Batch(func(tx *bbolt.Tx) error {
+		err := tx.DeleteBucket(bucketDNS)
+		if err == bbolt.ErrBucketNotFound {
+			return nil
+		}
+		return err
+	})
+}
@@ -0,0 +1,53 @@
+package cachefile
+
+import (
+	"encoding/binary"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var bucketUserQuota = []byte("user_quota")
+
+func (c *CacheFile) LoadUserQuota(inbound string, user string) (used int64, resetAt time.Time, loaded bool) {
+	_ = c.DB.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(bucketUserQuota)
+		if bucket == nil {
+			return nil
+		}
+		bucket = bucket.Bucket([]byte(inbound))
+		if bucket == nil {
+			return nil
+		}
+		content := bucket.Get([]byte(user))
+		if len(content) != 16 {
+			return nil
+		}
+		used = int64(binary.BigEndian.Uint64(content))
+		if resetAtUnix := int64(binary.BigEndian.Uint64(content[8:])); resetAtUnix != 0 {
+			resetAt = time.Unix(resetAtUnix, 0)
+		}
+		loaded = true
+		return nil
+	})
+	return
+}
+
+func (c *CacheFile) StoreUserQuota(inbound string, user string, used int64, resetAt time.Time) error {
+	content := make([]byte, 16)
+	binary.BigEndian.PutUint64(content, uint64(used))
+	if !resetAt.IsZero() {
+		binary.BigEndian.PutUint64(content[8:], uint64(resetAt.Unix()))
+	}
+	return c.DB.Batch(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(bucketUserQuota)
+		if err != nil {
+			return err
+		}
+		bucket, err = bucket.CreateBucketIfNotExists([]byte(inbound))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(user), content)
+	})
+}
@@ -0,0 +1,40 @@
+package cachefile
+
+import (
+	"go.etcd.io/bbolt"
+)
+
+var bucketInboundUsers = []byte("inbound_users")
+
+func (c *CacheFile) LoadInboundUsers(inbound string) map[string][]byte {
+	users := make(map[string][]byte)
+	_ = c.DB.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(bucketInboundUsers)
+		if bucket == nil {
+			return nil
+		}
+		bucket = bucket.Bucket([]byte(inbound))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(key, value []byte) error {
+			users[string(key)] = append([]byte(nil), value...)
+			return nil
+		})
+	})
+	return users
+}
+
+func (c *CacheFile) StoreInboundUser(inbound string, name string, content []byte) error {
+	return c.DB.Batch(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(bucketInboundUsers)
+		if err != nil {
+			return err
+		}
+		bucket, err = bucket.CreateBucketIfNotExists([]byte(inbound))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(name), content)
+	})
+}
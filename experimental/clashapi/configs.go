@@ -2,9 +2,13 @@ package clashapi
 
 import (
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
@@ -13,7 +17,7 @@ import (
 func configRouter(server *Server, logFactory log.Factory, logger log.Logger) http.Handler {
 	r := chi.NewRouter()
 	r.Get("/", getConfigs(server, logFactory))
-	r.Put("/", updateConfigs)
+	r.Put("/", updateConfigs(server, logger))
 	r.Patch("/", patchConfigs(server, logger))
 	return r
 }
@@ -68,6 +72,60 @@ func patchConfigs(server *Server, logger log.Logger) func(w http.ResponseWriter,
 	}
 }
 
-func updateConfigs(w http.ResponseWriter, r *http.Request) {
-	render.NoContent(w, r)
+type updateConfigRequest struct {
+	Path    string `json:"path"`
+	Payload string `json:"payload"`
+}
+
+func updateConfigs(server *Server, logger log.Logger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req updateConfigRequest
+		err := render.DecodeJSON(r.Body, &req)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, ErrBadRequest)
+			return
+		}
+		if server.reloader == nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError("reload not supported"))
+			return
+		}
+		var content []byte
+		if req.Payload != "" {
+			content = []byte(req.Payload)
+		} else if req.Path != "" {
+			if !filepath.IsAbs(req.Path) {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, newError("path is not an absolute path"))
+				return
+			}
+			content, err = os.ReadFile(req.Path)
+			if err != nil {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, newError(err.Error()))
+				return
+			}
+		} else {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError("should send `path` or `payload`"))
+			return
+		}
+		var options option.Options
+		err = options.UnmarshalJSON(content)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
+		err = server.reloader.Reload(options)
+		if err != nil {
+			logger.Error(E.Cause(err, "reload configuration"))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
+		logger.Info("configuration reloaded")
+		render.NoContent(w, r)
+	}
 }
@@ -24,9 +24,22 @@ func connectionRouter(trafficManager *trafficontrol.Manager) http.Handler {
 
 func getConnections(trafficManager *trafficontrol.Manager) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		filter := trafficontrol.Filter{
+			Host:    query.Get("host"),
+			Rule:    query.Get("rule"),
+			Chain:   query.Get("chain"),
+			Process: query.Get("process"),
+		}
+		closed := query.Get("closed") == "true"
+		getSnapshot := func() *trafficontrol.Snapshot {
+			if closed {
+				return trafficManager.ClosedSnapshot(filter)
+			}
+			return trafficManager.FilteredSnapshot(filter)
+		}
 		if !websocket.IsWebSocketUpgrade(r) {
-			snapshot := trafficManager.Snapshot()
-			render.JSON(w, r, snapshot)
+			render.JSON(w, r, getSnapshot())
 			return
 		}
 
@@ -35,7 +48,7 @@ func getConnections(trafficManager *trafficontrol.Manager) func(w http.ResponseW
 			return
 		}
 
-		intervalStr := r.URL.Query().Get("interval")
+		intervalStr := query.Get("interval")
 		interval := 1000
 		if intervalStr != "" {
 			t, err := strconv.Atoi(intervalStr)
@@ -51,7 +64,7 @@ func getConnections(trafficManager *trafficontrol.Manager) func(w http.ResponseW
 		buf := &bytes.Buffer{}
 		sendSnapshot := func() error {
 			buf.Reset()
-			snapshot := trafficManager.Snapshot()
+			snapshot := getSnapshot()
 			if err := json.NewEncoder(buf).Encode(snapshot); err != nil {
 				return err
 			}
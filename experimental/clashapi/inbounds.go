@@ -0,0 +1,134 @@
+package clashapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/sagernet/sing-box/adapter"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+func inboundRouter(server *Server, router adapter.Router) http.Handler {
+	r := chi.NewRouter()
+	r.Route("/{tag}/users", func(r chi.Router) {
+		r.Use(findUserManagedInbound(router))
+		r.Get("/", getInboundUsers)
+		r.Post("/", addInboundUsers(server))
+		r.Delete("/", removeInboundUsers(server))
+		r.Delete("/{name}", removeInboundUser(server))
+	})
+	return r
+}
+
+func findUserManagedInbound(router adapter.Router) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			inbound, loaded := router.Inbound(getEscapeParam(r, "tag"))
+			if !loaded {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, ErrNotFound)
+				return
+			}
+			userManaged, isUserManaged := inbound.(adapter.UserManagedInbound)
+			if !isUserManaged {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, newError("Inbound does not support user management"))
+				return
+			}
+			ctx := context.WithValue(r.Context(), CtxKeyInbound, userManaged)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func getInboundUsers(w http.ResponseWriter, r *http.Request) {
+	inbound := r.Context().Value(CtxKeyInbound).(adapter.UserManagedInbound)
+	render.JSON(w, r, render.M{
+		"users": inbound.Users(),
+	})
+}
+
+type AddInboundUsersRequest struct {
+	Users []json.RawMessage `json:"users"`
+}
+
+func addInboundUsers(server *Server) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := AddInboundUsersRequest{}
+		if err := render.DecodeJSON(r.Body, &req); err != nil || len(req.Users) == 0 {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, ErrBadRequest)
+			return
+		}
+		content, err := json.Marshal(req.Users)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, ErrBadRequest)
+			return
+		}
+		inbound := r.Context().Value(CtxKeyInbound).(adapter.UserManagedInbound)
+		err = inbound.AddUsers(content)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
+		if server.StoreUsers() {
+			for _, user := range req.Users {
+				var userName struct {
+					Name string `json:"name"`
+				}
+				_ = json.Unmarshal(user, &userName)
+				err = server.CacheFile().StoreInboundUser(inbound.Tag(), userName.Name, user)
+				if err != nil {
+					server.logger.Error("store users for inbound ", inbound.Tag(), ": ", err)
+				}
+			}
+		}
+		render.NoContent(w, r)
+	}
+}
+
+type RemoveInboundUsersRequest struct {
+	Names []string `json:"names"`
+}
+
+func removeInboundUsers(server *Server) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := RemoveInboundUsersRequest{}
+		if err := render.DecodeJSON(r.Body, &req); err != nil || len(req.Names) == 0 {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, ErrBadRequest)
+			return
+		}
+		removeUsers(server, w, r, req.Names)
+	}
+}
+
+func removeInboundUser(server *Server) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		removeUsers(server, w, r, []string{getEscapeParam(r, "name")})
+	}
+}
+
+func removeUsers(server *Server, w http.ResponseWriter, r *http.Request, names []string) {
+	inbound := r.Context().Value(CtxKeyInbound).(adapter.UserManagedInbound)
+	err := inbound.RemoveUsers(names)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, newError(err.Error()))
+		return
+	}
+	if server.StoreUsers() {
+		for _, name := range names {
+			err = server.CacheFile().StoreInboundUser(inbound.Tag(), name, nil)
+			if err != nil {
+				server.logger.Error("store users for inbound ", inbound.Tag(), ": ", err)
+			}
+		}
+	}
+	render.NoContent(w, r)
+}
@@ -93,6 +93,12 @@ func proxyInfo(server *Server, detour adapter.Outbound) *badjson.JSONObject {
 		clashType = "Selector"
 	case C.TypeURLTest:
 		clashType = "URLTest"
+	case C.TypeLoadBalance:
+		clashType = "LoadBalance"
+	case C.TypeFallback:
+		clashType = "Fallback"
+	case C.TypeChain:
+		clashType = "Relay"
 	default:
 		clashType = "Direct"
 	}
@@ -118,6 +124,9 @@ func getProxies(server *Server, router adapter.Router) func(w http.ResponseWrite
 		outbounds := common.Filter(router.Outbounds(), func(detour adapter.Outbound) bool {
 			return detour.Tag() != ""
 		})
+		for _, provider := range router.OutboundProviders() {
+			outbounds = append(outbounds, provider.Outbounds()...)
+		}
 
 		allProxies := make([]string, 0, len(outbounds))
 
@@ -1,58 +1,109 @@
 package clashapi
 
 import (
+	"context"
 	"net/http"
 
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/badjson"
+	C "github.com/sagernet/sing-box/constant"
+
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 )
 
-func ruleProviderRouter() http.Handler {
+func ruleProviderRouter(router adapter.Router) http.Handler {
 	r := chi.NewRouter()
-	r.Get("/", getRuleProviders)
+	r.Get("/", getRuleProviders(router))
 
 	r.Route("/{name}", func(r chi.Router) {
-		r.Use(parseProviderName, findRuleProviderByName)
+		r.Use(parseProviderName, findRuleProviderByName(router))
 		r.Get("/", getRuleProvider)
 		r.Put("/", updateRuleProvider)
 	})
 	return r
 }
 
-func getRuleProviders(w http.ResponseWriter, r *http.Request) {
-	render.JSON(w, r, render.M{
-		"providers": []string{},
-	})
+func ruleProviderInfo(provider adapter.RuleProvider) *badjson.JSONObject {
+	var info badjson.JSONObject
+	var vehicleType string
+	switch provider.Type() {
+	case C.ProviderTypeRemote:
+		vehicleType = "HTTP"
+	case C.ProviderTypeLocal:
+		vehicleType = "File"
+	default:
+		vehicleType = "Compatible"
+	}
+	var behavior string
+	switch provider.Format() {
+	case C.RuleProviderFormatDomain:
+		behavior = "Domain"
+	case C.RuleProviderFormatIPCIDR:
+		behavior = "IPCIDR"
+	case C.RuleProviderFormatClassical:
+		behavior = "Classical"
+	}
+	info.Put("name", provider.Tag())
+	info.Put("type", "Rule")
+	info.Put("vehicleType", vehicleType)
+	info.Put("behavior", behavior)
+	info.Put("ruleCount", provider.RuleCount())
+	info.Put("updatedAt", provider.UpdatedAt())
+	return &info
+}
+
+func getRuleProviders(router adapter.Router) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var providerMap badjson.JSONObject
+		for _, provider := range router.RuleProviders() {
+			providerMap.Put(provider.Tag(), ruleProviderInfo(provider))
+		}
+		var responseMap badjson.JSONObject
+		responseMap.Put("providers", &providerMap)
+		response, err := responseMap.MarshalJSON()
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
+		w.Write(response)
+	}
 }
 
 func getRuleProvider(w http.ResponseWriter, r *http.Request) {
-	// provider := r.Context().Value(CtxKeyProvider).(provider.RuleProvider)
-	// render.JSON(w, r, provider)
-	render.NoContent(w, r)
+	provider := r.Context().Value(CtxKeyProvider).(adapter.RuleProvider)
+	response, err := ruleProviderInfo(provider).MarshalJSON()
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, newError(err.Error()))
+		return
+	}
+	w.Write(response)
 }
 
 func updateRuleProvider(w http.ResponseWriter, r *http.Request) {
-	/*provider := r.Context().Value(CtxKeyProvider).(provider.RuleProvider)
+	provider := r.Context().Value(CtxKeyProvider).(adapter.RuleProvider)
 	if err := provider.Update(); err != nil {
 		render.Status(r, http.StatusServiceUnavailable)
 		render.JSON(w, r, newError(err.Error()))
 		return
-	}*/
+	}
 	render.NoContent(w, r)
 }
 
-func findRuleProviderByName(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		/*name := r.Context().Value(CtxKeyProviderName).(string)
-		providers := tunnel.RuleProviders()
-		provider, exist := providers[name]
-		if !exist {*/
-		render.Status(r, http.StatusNotFound)
-		render.JSON(w, r, ErrNotFound)
-		//return
-		//}
-
-		// ctx := context.WithValue(r.Context(), CtxKeyProvider, provider)
-		// next.ServeHTTP(w, r.WithContext(ctx))
-	})
+func findRuleProviderByName(router adapter.Router) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name := r.Context().Value(CtxKeyProviderName).(string)
+			provider, exist := router.RuleProvider(name)
+			if !exist {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, ErrNotFound)
+				return
+			}
+			ctx := context.WithValue(r.Context(), CtxKeyProvider, provider)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
 }
@@ -2,6 +2,7 @@ package clashapi
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/sagernet/sing-box/adapter"
 
@@ -12,13 +13,36 @@ import (
 func ruleRouter(router adapter.Router) http.Handler {
 	r := chi.NewRouter()
 	r.Get("/", getRules(router))
+	r.Get("/dns", getDNSRules(router))
+	r.Delete("/statistics", resetRuleStatistics(router))
 	return r
 }
 
 type Rule struct {
-	Type    string `json:"type"`
-	Payload string `json:"payload"`
-	Proxy   string `json:"proxy"`
+	Type     string     `json:"type"`
+	Payload  string     `json:"payload"`
+	Proxy    string     `json:"proxy"`
+	Hits     int64      `json:"hits"`
+	LastHit  *time.Time `json:"lastHit,omitempty"`
+	Upload   int64      `json:"upload"`
+	Download int64      `json:"download"`
+}
+
+func newRule(rule adapter.Rule) Rule {
+	statistics := rule.Statistics()
+	item := Rule{
+		Type:     rule.Type(),
+		Payload:  rule.String(),
+		Proxy:    rule.Outbound(),
+		Hits:     statistics.Hits.Load(),
+		Upload:   statistics.Upload.Load(),
+		Download: statistics.Download.Load(),
+	}
+	if lastHit := statistics.LastHit.Load(); lastHit > 0 {
+		lastHitTime := time.Unix(lastHit, 0)
+		item.LastHit = &lastHitTime
+	}
+	return item
 }
 
 func getRules(router adapter.Router) func(w http.ResponseWriter, r *http.Request) {
@@ -27,11 +51,22 @@ func getRules(router adapter.Router) func(w http.ResponseWriter, r *http.Request
 
 		var rules []Rule
 		for _, rule := range rawRules {
-			rules = append(rules, Rule{
-				Type:    rule.Type(),
-				Payload: rule.String(),
-				Proxy:   rule.Outbound(),
-			})
+			rules = append(rules, newRule(rule))
+		}
+
+		render.JSON(w, r, render.M{
+			"rules": rules,
+		})
+	}
+}
+
+func getDNSRules(router adapter.Router) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rawRules := router.DNSRules()
+
+		var rules []Rule
+		for _, rule := range rawRules {
+			rules = append(rules, newRule(rule))
 		}
 
 		render.JSON(w, r, render.M{
@@ -39,3 +74,15 @@ func getRules(router adapter.Router) func(w http.ResponseWriter, r *http.Request
 		})
 	}
 }
+
+func resetRuleStatistics(router adapter.Router) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, rule := range router.Rules() {
+			rule.Statistics().Reset()
+		}
+		for _, rule := range router.DNSRules() {
+			rule.Statistics().Reset()
+		}
+		render.NoContent(w, r)
+	}
+}
@@ -2,39 +2,104 @@ package clashapi
 
 import (
 	"net/http"
+	"net/netip"
+	"strconv"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/process"
+	"github.com/sagernet/sing-box/common/script"
+	"github.com/sagernet/sing-box/log"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 )
 
-func scriptRouter() http.Handler {
+func scriptRouter(router adapter.Router, logger log.ContextLogger) http.Handler {
 	r := chi.NewRouter()
-	r.Post("/", testScript)
-	r.Patch("/", patchScript)
+	r.Post("/", testScript(router, logger))
+	r.Patch("/", patchScript(router, logger))
 	return r
 }
 
-/*type TestScriptRequest struct {
-	Script   *string    `json:"script"`
-	Metadata C.Metadata `json:"metadata"`
-}*/
+type ScriptMetadata struct {
+	Inbound         string `json:"inbound"`
+	InboundType     string `json:"inboundType"`
+	Network         string `json:"network"`
+	Protocol        string `json:"protocol"`
+	User            string `json:"user"`
+	Host            string `json:"host"`
+	SourceIP        string `json:"sourceIP"`
+	SourcePort      string `json:"sourcePort"`
+	DestinationIP   string `json:"destinationIP"`
+	DestinationPort string `json:"destinationPort"`
+	ProcessPath     string `json:"processPath"`
+}
+
+func (m ScriptMetadata) Build() (*adapter.InboundContext, bool) {
+	switch m.Network {
+	case N.NetworkTCP, N.NetworkUDP:
+	default:
+		return nil, false
+	}
+	metadata := &adapter.InboundContext{
+		Inbound:     m.Inbound,
+		InboundType: m.InboundType,
+		Network:     m.Network,
+		Protocol:    m.Protocol,
+		User:        m.User,
+	}
+	destinationPort, _ := strconv.ParseUint(m.DestinationPort, 10, 16)
+	if m.Host != "" {
+		metadata.Destination = M.ParseSocksaddrHostPort(m.Host, uint16(destinationPort))
+		metadata.Domain = metadata.Destination.Fqdn
+	} else if destinationAddr, err := netip.ParseAddr(m.DestinationIP); err == nil {
+		metadata.Destination = M.SocksaddrFrom(destinationAddr, uint16(destinationPort))
+	} else {
+		return nil, false
+	}
+	if metadata.Destination.IsFqdn() {
+		if destinationAddr, err := netip.ParseAddr(m.DestinationIP); err == nil {
+			metadata.DestinationAddresses = []netip.Addr{destinationAddr}
+		}
+	}
+	if sourceAddr, err := netip.ParseAddr(m.SourceIP); err == nil {
+		sourcePort, _ := strconv.ParseUint(m.SourcePort, 10, 16)
+		metadata.Source = M.SocksaddrFrom(sourceAddr, uint16(sourcePort))
+	}
+	if m.ProcessPath != "" {
+		metadata.ProcessInfo = &process.Info{
+			ProcessPath: m.ProcessPath,
+			UserId:      -1,
+		}
+	}
+	return metadata, true
+}
+
+type TestScriptRequest struct {
+	Script   *string        `json:"script"`
+	Metadata ScriptMetadata `json:"metadata"`
+}
 
-func testScript(w http.ResponseWriter, r *http.Request) {
-	/*	req := TestScriptRequest{}
+func testScript(router adapter.Router, logger log.ContextLogger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := TestScriptRequest{}
 		if err := render.DecodeJSON(r.Body, &req); err != nil {
 			render.Status(r, http.StatusBadRequest)
 			render.JSON(w, r, ErrBadRequest)
 			return
 		}
 
-		fn := tunnel.ScriptFn()
-		if req.Script == nil && fn == nil {
+		routeScript := router.Script()
+		if req.Script == nil && routeScript == nil {
 			render.Status(r, http.StatusBadRequest)
 			render.JSON(w, r, newError("should send `script`"))
 			return
 		}
 
-		if !req.Metadata.Valid() {
+		metadata, valid := req.Metadata.Build()
+		if !valid {
 			render.Status(r, http.StatusBadRequest)
 			render.JSON(w, r, newError("metadata not valid"))
 			return
@@ -42,7 +107,7 @@ func testScript(w http.ResponseWriter, r *http.Request) {
 
 		if req.Script != nil {
 			var err error
-			fn, err = script.ParseScript(*req.Script)
+			routeScript, err = script.Parse(router, logger, "test", *req.Script)
 			if err != nil {
 				render.Status(r, http.StatusBadRequest)
 				render.JSON(w, r, newError(err.Error()))
@@ -50,49 +115,46 @@ func testScript(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		ctx, _ := script.MakeContext(tunnel.ProxyProviders(), tunnel.RuleProviders())
-
-		thread := &starlark.Thread{}
-		ret, err := starlark.Call(thread, fn, starlark.Tuple{ctx, script.MakeMetadata(&req.Metadata)}, nil)
+		result, err := routeScript.Outbound(r.Context(), metadata)
 		if err != nil {
 			render.Status(r, http.StatusBadRequest)
 			render.JSON(w, r, newError(err.Error()))
 			return
 		}
 
-		elm, ok := ret.(starlark.String)
-		if !ok {
-			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, "script fn must return a string")
-			return
-		}
-
 		render.JSON(w, r, render.M{
-			"result": string(elm),
-		})*/
-	render.Status(r, http.StatusBadRequest)
-	render.JSON(w, r, newError("not implemented"))
+			"result": result,
+		})
+	}
 }
 
 type PatchScriptRequest struct {
 	Script string `json:"script"`
 }
 
-func patchScript(w http.ResponseWriter, r *http.Request) {
-	/*req := PatchScriptRequest{}
-	if err := render.DecodeJSON(r.Body, &req); err != nil {
-		render.Status(r, http.StatusBadRequest)
-		render.JSON(w, r, ErrBadRequest)
-		return
-	}
+func patchScript(router adapter.Router, logger log.ContextLogger) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := PatchScriptRequest{}
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, ErrBadRequest)
+			return
+		}
 
-	fn, err := script.ParseScript(req.Script)
-	if err != nil {
-		render.Status(r, http.StatusBadRequest)
-		render.JSON(w, r, newError(err.Error()))
-		return
-	}
+		if req.Script == "" {
+			router.SetScript(nil)
+			render.NoContent(w, r)
+			return
+		}
 
-	tunnel.UpdateScript(fn)*/
-	render.NoContent(w, r)
+		routeScript, err := script.Parse(router, logger, "route", req.Script)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
+
+		router.SetScript(routeScript)
+		render.NoContent(w, r)
+	}
 }
@@ -45,8 +45,12 @@ type Server struct {
 	mode           string
 	storeSelected  bool
 	storeFakeIP    bool
+	storeDNS       bool
+	storeUsers     bool
+	storeQuota     bool
 	cacheFilePath  string
 	cacheFile      adapter.ClashCacheFile
+	reloader       adapter.Reloader
 
 	externalUI               string
 	externalUIDownloadURL    string
@@ -54,7 +58,13 @@ type Server struct {
 }
 
 func NewServer(router adapter.Router, logFactory log.ObservableFactory, options option.ClashAPIOptions) (adapter.ClashServer, error) {
-	trafficManager := trafficontrol.NewManager()
+	closedConnections := options.ClosedConnections
+	if closedConnections == 0 {
+		closedConnections = trafficontrol.DefaultClosedConnections
+	} else if closedConnections < 0 {
+		closedConnections = 0
+	}
+	trafficManager := trafficontrol.NewManager(closedConnections)
 	chiRouter := chi.NewRouter()
 	server := &Server{
 		router: router,
@@ -68,13 +78,16 @@ func NewServer(router adapter.Router, logFactory log.ObservableFactory, options
 		mode:                     strings.ToLower(options.DefaultMode),
 		storeSelected:            options.StoreSelected,
 		storeFakeIP:              options.StoreFakeIP,
+		storeDNS:                 options.StoreDNS,
+		storeUsers:               options.StoreUsers,
+		storeQuota:               options.StoreQuota,
 		externalUIDownloadURL:    options.ExternalUIDownloadURL,
 		externalUIDownloadDetour: options.ExternalUIDownloadDetour,
 	}
 	if server.mode == "" {
 		server.mode = "rule"
 	}
-	if options.StoreSelected || options.StoreFakeIP {
+	if options.StoreSelected || options.StoreFakeIP || options.StoreDNS || options.StoreUsers || options.StoreQuota {
 		cachePath := os.ExpandEnv(options.CacheFile)
 		if cachePath == "" {
 			cachePath = "cache.db"
@@ -103,12 +116,13 @@ func NewServer(router adapter.Router, logFactory log.ObservableFactory, options
 		r.Mount("/proxies", proxyRouter(server, router))
 		r.Mount("/rules", ruleRouter(router))
 		r.Mount("/connections", connectionRouter(trafficManager))
-		r.Mount("/providers/proxies", proxyProviderRouter())
-		r.Mount("/providers/rules", ruleProviderRouter())
-		r.Mount("/script", scriptRouter())
+		r.Mount("/providers/proxies", proxyProviderRouter(server, router))
+		r.Mount("/providers/rules", ruleProviderRouter(router))
+		r.Mount("/script", scriptRouter(router, logFactory.NewLogger("clash-api/script")))
 		r.Mount("/profile", profileRouter())
 		r.Mount("/cache", cacheRouter(router))
 		r.Mount("/dns", dnsRouter(router))
+		r.Mount("/inbounds", inboundRouter(server, router))
 
 		server.setupMetaAPI(r)
 	})
@@ -164,6 +178,10 @@ func (s *Server) Mode() string {
 	return s.mode
 }
 
+func (s *Server) SetReloader(reloader adapter.Reloader) {
+	s.reloader = reloader
+}
+
 func (s *Server) StoreSelected() bool {
 	return s.storeSelected
 }
@@ -172,6 +190,18 @@ func (s *Server) StoreFakeIP() bool {
 	return s.storeFakeIP
 }
 
+func (s *Server) StoreDNS() bool {
+	return s.storeDNS
+}
+
+func (s *Server) StoreUsers() bool {
+	return s.storeUsers
+}
+
+func (s *Server) StoreQuota() bool {
+	return s.storeQuota
+}
+
 func (s *Server) CacheFile() adapter.ClashCacheFile {
 	return s.cacheFile
 }
@@ -180,6 +210,15 @@ func (s *Server) HistoryStorage() *urltest.HistoryStorage {
 	return s.urlTestHistory
 }
 
+func (s *Server) TrafficSnapshot() adapter.TrafficSnapshot {
+	snapshot := s.trafficManager.Snapshot()
+	return adapter.TrafficSnapshot{
+		UploadTotal:   snapshot.UploadTotal,
+		DownloadTotal: snapshot.DownloadTotal,
+		Connections:   s.trafficManager.ConnectionSnapshots(),
+	}
+}
+
 func (s *Server) RoutedConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, matchedRule adapter.Rule) (net.Conn, adapter.Tracker) {
 	tracker := trafficontrol.NewTCPTracker(conn, s.trafficManager, castMetadata(metadata), s.router, matchedRule)
 	return tracker, tracker
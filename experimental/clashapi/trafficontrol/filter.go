@@ -0,0 +1,37 @@
+package trafficontrol
+
+import (
+	"strings"
+
+	"github.com/sagernet/sing/common"
+)
+
+// Filter selects connections by case-insensitive substrings of the host or
+// destination IP, rule and process path, and by an outbound in the chain.
+type Filter struct {
+	Host    string
+	Rule    string
+	Chain   string
+	Process string
+}
+
+func (f Filter) match(info *trackerInfo) bool {
+	if f.Host != "" && !containsFold(info.Metadata.Host, f.Host) &&
+		!(info.Metadata.DstIP.IsValid() && containsFold(info.Metadata.DstIP.String(), f.Host)) {
+		return false
+	}
+	if f.Rule != "" && !containsFold(info.Rule, f.Rule) {
+		return false
+	}
+	if f.Chain != "" && !common.Contains(info.Chain, f.Chain) {
+		return false
+	}
+	if f.Process != "" && !containsFold(info.Metadata.ProcessPath, f.Process) {
+		return false
+	}
+	return true
+}
+
+func containsFold(s string, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
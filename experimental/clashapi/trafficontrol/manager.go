@@ -1,10 +1,13 @@
 package trafficontrol
 
 import (
+	"sync"
 	"time"
 
+	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/experimental/clashapi/compatible"
 	"github.com/sagernet/sing/common/atomic"
+	E "github.com/sagernet/sing/common/exceptions"
 )
 
 type Manager struct {
@@ -18,12 +21,21 @@ type Manager struct {
 	connections compatible.Map[string, tracker]
 	ticker      *time.Ticker
 	done        chan struct{}
+
+	closedAccess sync.Mutex
+	closed       []*trackerInfo
+	closedIndex  int
 }
 
-func NewManager() *Manager {
+const DefaultClosedConnections = 100
+
+// NewManager creates a manager keeping up to closedConnections recently
+// closed connections.
+func NewManager(closedConnections int) *Manager {
 	manager := &Manager{
 		ticker: time.NewTicker(time.Second),
 		done:   make(chan struct{}),
+		closed: make([]*trackerInfo, 0, closedConnections),
 	}
 	go manager.handle()
 	return manager
@@ -33,8 +45,28 @@ func (m *Manager) Join(c tracker) {
 	m.connections.Store(c.ID(), c)
 }
 
-func (m *Manager) Leave(c tracker) {
-	m.connections.Delete(c.ID())
+// Leave removes the connection and adds it to the history with the error it
+// was closed with, unless the connection was closed normally.
+func (m *Manager) Leave(c tracker, err error) {
+	if _, loaded := m.connections.LoadAndDelete(c.ID()); !loaded {
+		return
+	}
+	if cap(m.closed) == 0 {
+		return
+	}
+	closed := *c.info()
+	closed.End = time.Now()
+	if err != nil && !E.IsClosedOrCanceled(err) {
+		closed.Error = err.Error()
+	}
+	m.closedAccess.Lock()
+	defer m.closedAccess.Unlock()
+	if len(m.closed) < cap(m.closed) {
+		m.closed = append(m.closed, &closed)
+	} else {
+		m.closed[m.closedIndex] = &closed
+		m.closedIndex = (m.closedIndex + 1) % len(m.closed)
+	}
 }
 
 func (m *Manager) PushUploaded(size int64) {
@@ -52,9 +84,16 @@ func (m *Manager) Now() (up int64, down int64) {
 }
 
 func (m *Manager) Snapshot() *Snapshot {
+	return m.FilteredSnapshot(Filter{})
+}
+
+// FilteredSnapshot returns active connections matching the filter.
+func (m *Manager) FilteredSnapshot(filter Filter) *Snapshot {
 	var connections []tracker
 	m.connections.Range(func(_ string, value tracker) bool {
-		connections = append(connections, value)
+		if filter.match(value.info()) {
+			connections = append(connections, value)
+		}
 		return true
 	})
 
@@ -65,6 +104,48 @@ func (m *Manager) Snapshot() *Snapshot {
 	}
 }
 
+// ClosedSnapshot returns recently closed connections matching the filter,
+// the most recently closed first.
+func (m *Manager) ClosedSnapshot(filter Filter) *Snapshot {
+	m.closedAccess.Lock()
+	closed := make([]*trackerInfo, 0, len(m.closed))
+	for i := len(m.closed) - 1; i >= 0; i-- {
+		closed = append(closed, m.closed[(m.closedIndex+i)%len(m.closed)])
+	}
+	m.closedAccess.Unlock()
+	var connections []tracker
+	for _, info := range closed {
+		if filter.match(info) {
+			connections = append(connections, closedTracker{info})
+		}
+	}
+	return &Snapshot{
+		UploadTotal:   m.uploadTotal.Load(),
+		DownloadTotal: m.downloadTotal.Load(),
+		Connections:   connections,
+	}
+}
+
+// ConnectionSnapshots returns active connections with the outbound finally selected by groups,
+// which is the first element of the reversed chain of trackers.
+func (m *Manager) ConnectionSnapshots() []adapter.ConnectionSnapshot {
+	var connections []adapter.ConnectionSnapshot
+	m.connections.Range(func(_ string, value tracker) bool {
+		info := value.info()
+		var outbound string
+		if len(info.Chain) > 0 {
+			outbound = info.Chain[0]
+		}
+		connections = append(connections, adapter.ConnectionSnapshot{
+			Network:  info.Metadata.NetWork,
+			Inbound:  info.Metadata.Type,
+			Outbound: outbound,
+		})
+		return true
+	})
+	return connections
+}
+
 func (m *Manager) ResetStatistic() {
 	m.uploadTemp.Store(0)
 	m.uploadBlip.Store(0)
@@ -30,7 +30,8 @@ type Metadata struct {
 type tracker interface {
 	ID() string
 	Close() error
-	Leave()
+	Leave(err error)
+	info() *trackerInfo
 }
 
 type trackerInfo struct {
@@ -42,10 +43,16 @@ type trackerInfo struct {
 	Chain         []string      `json:"chains"`
 	Rule          string        `json:"rule"`
 	RulePayload   string        `json:"rulePayload"`
+	End           time.Time     `json:"end"`
+	Error         string        `json:"error"`
+}
+
+func (t *trackerInfo) info() *trackerInfo {
+	return t
 }
 
 func (t trackerInfo) MarshalJSON() ([]byte, error) {
-	return json.Marshal(map[string]any{
+	content := map[string]any{
 		"id":          t.UUID.String(),
 		"metadata":    t.Metadata,
 		"upload":      t.UploadTotal.Load(),
@@ -54,7 +61,28 @@ func (t trackerInfo) MarshalJSON() ([]byte, error) {
 		"chains":      t.Chain,
 		"rule":        t.Rule,
 		"rulePayload": t.RulePayload,
-	})
+	}
+	if !t.End.IsZero() {
+		content["end"] = t.End
+		content["error"] = t.Error
+	}
+	return json.Marshal(content)
+}
+
+// closedTracker is a finished connection kept in the history.
+type closedTracker struct {
+	*trackerInfo
+}
+
+func (t closedTracker) ID() string {
+	return t.UUID.String()
+}
+
+func (t closedTracker) Close() error {
+	return nil
+}
+
+func (t closedTracker) Leave(err error) {
 }
 
 type tcpTracker struct {
@@ -68,12 +96,11 @@ func (tt *tcpTracker) ID() string {
 }
 
 func (tt *tcpTracker) Close() error {
-	tt.manager.Leave(tt)
 	return tt.ExtendedConn.Close()
 }
 
-func (tt *tcpTracker) Leave() {
-	tt.manager.Leave(tt)
+func (tt *tcpTracker) Leave(err error) {
+	tt.manager.Leave(tt, err)
 }
 
 func (tt *tcpTracker) Upstream() any {
@@ -91,25 +118,13 @@ func (tt *tcpTracker) WriterReplaceable() bool {
 func NewTCPTracker(conn net.Conn, manager *Manager, metadata Metadata, router adapter.Router, rule adapter.Rule) *tcpTracker {
 	uuid, _ := uuid.NewV4()
 
-	var chain []string
 	var next string
 	if rule == nil {
 		next = router.DefaultOutbound(N.NetworkTCP).Tag()
 	} else {
 		next = rule.Outbound()
 	}
-	for {
-		chain = append(chain, next)
-		detour, loaded := router.Outbound(next)
-		if !loaded {
-			break
-		}
-		group, isGroup := detour.(adapter.OutboundGroup)
-		if !isGroup {
-			break
-		}
-		next = group.Now()
-	}
+	chain := adapter.ResolveOutboundChain(router, next)
 
 	upload := new(atomic.Int64)
 	download := new(atomic.Int64)
@@ -155,12 +170,11 @@ func (ut *udpTracker) ID() string {
 }
 
 func (ut *udpTracker) Close() error {
-	ut.manager.Leave(ut)
 	return ut.PacketConn.Close()
 }
 
-func (ut *udpTracker) Leave() {
-	ut.manager.Leave(ut)
+func (ut *udpTracker) Leave(err error) {
+	ut.manager.Leave(ut, err)
 }
 
 func (ut *udpTracker) Upstream() any {
@@ -178,25 +192,13 @@ func (ut *udpTracker) WriterReplaceable() bool {
 func NewUDPTracker(conn N.PacketConn, manager *Manager, metadata Metadata, router adapter.Router, rule adapter.Rule) *udpTracker {
 	uuid, _ := uuid.NewV4()
 
-	var chain []string
 	var next string
 	if rule == nil {
 		next = router.DefaultOutbound(N.NetworkUDP).Tag()
 	} else {
 		next = rule.Outbound()
 	}
-	for {
-		chain = append(chain, next)
-		detour, loaded := router.Outbound(next)
-		if !loaded {
-			break
-		}
-		group, isGroup := detour.(adapter.OutboundGroup)
-		if !isGroup {
-			break
-		}
-		next = group.Now()
-	}
+	chain := adapter.ResolveOutboundChain(router, next)
 
 	upload := new(atomic.Int64)
 	download := new(atomic.Int64)
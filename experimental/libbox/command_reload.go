@@ -34,7 +34,7 @@ func ClientServiceReload(sharedDirectory string) error {
 }
 
 func (s *CommandServer) handleServiceReload(conn net.Conn) error {
-	rErr := s.handler.ServiceReload()
+	rErr := s.reloadService()
 	err := binary.Write(conn, binary.BigEndian, rErr != nil)
 	if err != nil {
 		return err
@@ -44,3 +44,22 @@ func (s *CommandServer) handleServiceReload(conn net.Conn) error {
 	}
 	return nil
 }
+
+// reloadService reloads the running service in place, and restarts it if that fails.
+func (s *CommandServer) reloadService() error {
+	s.access.Lock()
+	service := s.service
+	s.access.Unlock()
+	configHandler, isConfigHandler := s.handler.(CommandServerConfigHandler)
+	if service != nil && isConfigHandler {
+		configContent, err := configHandler.ServiceConfig()
+		if err == nil {
+			err = service.Reload(configContent)
+		}
+		if err == nil {
+			return nil
+		}
+		s.WriteMessage("reload failed, restarting service: " + err.Error())
+	}
+	return s.handler.ServiceReload()
+}
@@ -19,6 +19,7 @@ type CommandServer struct {
 	sockPath string
 	listener net.Listener
 	handler  CommandServerHandler
+	service  *BoxService
 
 	access     sync.Mutex
 	savedLines *list.List[string]
@@ -31,6 +32,12 @@ type CommandServerHandler interface {
 	ServiceReload() error
 }
 
+// CommandServerConfigHandler is optionally implemented by the CommandServerHandler
+// to provide the configuration, so that the service is reloaded in place instead of restarted.
+type CommandServerConfigHandler interface {
+	ServiceConfig() (string, error)
+}
+
 func NewCommandServer(sharedDirectory string, handler CommandServerHandler) *CommandServer {
 	server := &CommandServer{
 		sockPath:   filepath.Join(sharedDirectory, "command.sock"),
@@ -42,6 +49,13 @@ func NewCommandServer(sharedDirectory string, handler CommandServerHandler) *Com
 	return server
 }
 
+// SetService sets the running service, which is reloaded in place by the reload command.
+func (s *CommandServer) SetService(service *BoxService) {
+	s.access.Lock()
+	s.service = service
+	s.access.Unlock()
+}
+
 func (s *CommandServer) Start() error {
 	os.Remove(s.sockPath)
 	listener, err := net.ListenUnix("unix", &net.UnixAddr{
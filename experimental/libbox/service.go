@@ -2,6 +2,7 @@ package libbox
 
 import (
 	"context"
+	"errors"
 	"net/netip"
 	"syscall"
 
@@ -48,6 +49,20 @@ func (s *BoxService) Start() error {
 	return s.instance.Start()
 }
 
+// Reload applies a new configuration without restarting unchanged inbounds, outbounds and DNS servers.
+// The service must be restarted if the returned error is a restart required error, see IsRestartRequired.
+func (s *BoxService) Reload(configContent string) error {
+	options, err := parseConfig(configContent)
+	if err != nil {
+		return err
+	}
+	return s.instance.Reload(options)
+}
+
+func IsRestartRequired(err error) bool {
+	return errors.Is(err, box.ErrRestartRequired)
+}
+
 func (s *BoxService) Close() error {
 	s.cancel()
 	return s.instance.Close()
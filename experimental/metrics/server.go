@@ -0,0 +1,262 @@
+package metrics
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/urltest"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+var _ adapter.MetricsServer = (*Server)(nil)
+
+var dnsDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type Server struct {
+	router     adapter.Router
+	logger     log.Logger
+	httpServer *http.Server
+	dnsAccess  sync.Mutex
+	dnsServers map[string]*dnsStatistics
+}
+
+type dnsStatistics struct {
+	queries  uint64
+	errors   uint64
+	duration float64
+	buckets  []uint64
+}
+
+func NewServer(router adapter.Router, logger log.Logger, options option.MetricsOptions) *Server {
+	server := &Server{
+		router:     router,
+		logger:     logger,
+		dnsServers: make(map[string]*dnsStatistics),
+	}
+	path := options.Path
+	if path == "" {
+		path = "/metrics"
+	}
+	mux := http.NewServeMux()
+	mux.Handle(path, server)
+	server.httpServer = &http.Server{
+		Addr:    options.Listen,
+		Handler: mux,
+	}
+	return server
+}
+
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return E.Cause(err, "metrics listen error")
+	}
+	s.logger.Info("metrics server listening at ", listener.Addr())
+	go func() {
+		err = s.httpServer.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("metrics serve error: ", err)
+		}
+	}()
+	return nil
+}
+
+func (s *Server) Close() error {
+	return common.Close(
+		common.PtrOrNil(s.httpServer),
+	)
+}
+
+func (s *Server) DNSQuery(server string, elapsed time.Duration, err error) {
+	s.dnsAccess.Lock()
+	defer s.dnsAccess.Unlock()
+	statistics, loaded := s.dnsServers[server]
+	if !loaded {
+		statistics = &dnsStatistics{buckets: make([]uint64, len(dnsDurationBuckets))}
+		s.dnsServers[server] = statistics
+	}
+	statistics.queries++
+	if err != nil {
+		statistics.errors++
+	}
+	seconds := elapsed.Seconds()
+	statistics.duration += seconds
+	for i, bucket := range dnsDurationBuckets {
+		if seconds <= bucket {
+			statistics.buckets[i]++
+		}
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var metrics writer
+	metrics.header("sing_box_info", "gauge", "Version of sing-box.")
+	metrics.sample("sing_box_info", 1, "version", C.Version)
+	s.writeTraffic(&metrics)
+	s.writeConnections(&metrics)
+	s.writeDNS(&metrics)
+	s.writeURLTest(&metrics)
+	s.writeFakeIP(&metrics)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(metrics.Bytes())
+}
+
+// writeTraffic writes traffic counters of the V2Ray API statistics service.
+func (s *Server) writeTraffic(metrics *writer) {
+	v2rayServer := s.router.V2RayServer()
+	if v2rayServer == nil {
+		return
+	}
+	statsService := v2rayServer.StatsService()
+	if statsService == nil {
+		return
+	}
+	counters := statsService.Counters()
+	names := make([]string, 0, len(counters))
+	for name := range counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	metrics.header("sing_box_traffic_bytes_total", "counter", "Traffic of inbounds, outbounds and users counted by the V2Ray API.")
+	for _, name := range names {
+		parts := strings.Split(name, ">>>")
+		if len(parts) != 4 || parts[2] != "traffic" {
+			continue
+		}
+		switch parts[0] {
+		case "inbound", "outbound", "user":
+		default:
+			continue
+		}
+		metrics.sample("sing_box_traffic_bytes_total", float64(counters[name]), "type", parts[0], "name", parts[1], "direction", parts[3])
+	}
+}
+
+// writeConnections writes connections tracked by the Clash API.
+func (s *Server) writeConnections(metrics *writer) {
+	clashServer := s.router.ClashServer()
+	if clashServer == nil {
+		return
+	}
+	snapshot := clashServer.TrafficSnapshot()
+	metrics.header("sing_box_upload_bytes_total", "counter", "Uploaded traffic of all connections.")
+	metrics.sample("sing_box_upload_bytes_total", float64(snapshot.UploadTotal))
+	metrics.header("sing_box_download_bytes_total", "counter", "Downloaded traffic of all connections.")
+	metrics.sample("sing_box_download_bytes_total", float64(snapshot.DownloadTotal))
+	activeConnections := make(map[adapter.ConnectionSnapshot]int)
+	for _, connection := range snapshot.Connections {
+		activeConnections[connection]++
+	}
+	connections := make([]adapter.ConnectionSnapshot, 0, len(activeConnections))
+	for connection := range activeConnections {
+		connections = append(connections, connection)
+	}
+	sort.Slice(connections, func(i, j int) bool {
+		if connections[i].Network != connections[j].Network {
+			return connections[i].Network < connections[j].Network
+		}
+		if connections[i].Inbound != connections[j].Inbound {
+			return connections[i].Inbound < connections[j].Inbound
+		}
+		return connections[i].Outbound < connections[j].Outbound
+	})
+	metrics.header("sing_box_connections", "gauge", "Active connections.")
+	for _, connection := range connections {
+		metrics.sample("sing_box_connections", float64(activeConnections[connection]), "network", connection.Network, "inbound", connection.Inbound, "outbound", connection.Outbound)
+	}
+}
+
+func (s *Server) writeDNS(metrics *writer) {
+	s.dnsAccess.Lock()
+	defer s.dnsAccess.Unlock()
+	servers := make([]string, 0, len(s.dnsServers))
+	for server := range s.dnsServers {
+		servers = append(servers, server)
+	}
+	sort.Strings(servers)
+	metrics.header("sing_box_dns_queries_total", "counter", "DNS queries by server.")
+	for _, server := range servers {
+		metrics.sample("sing_box_dns_queries_total", float64(s.dnsServers[server].queries), "server", server)
+	}
+	metrics.header("sing_box_dns_query_errors_total", "counter", "Failed DNS queries by server.")
+	for _, server := range servers {
+		metrics.sample("sing_box_dns_query_errors_total", float64(s.dnsServers[server].errors), "server", server)
+	}
+	metrics.header("sing_box_dns_query_duration_seconds", "histogram", "DNS query latency by server, including cached responses.")
+	for _, server := range servers {
+		statistics := s.dnsServers[server]
+		for i, bucket := range dnsDurationBuckets {
+			metrics.sample("sing_box_dns_query_duration_seconds_bucket", float64(statistics.buckets[i]), "server", server, "le", formatValue(bucket))
+		}
+		metrics.sample("sing_box_dns_query_duration_seconds_bucket", float64(statistics.queries), "server", server, "le", "+Inf")
+		metrics.sample("sing_box_dns_query_duration_seconds_sum", statistics.duration, "server", server)
+		metrics.sample("sing_box_dns_query_duration_seconds_count", float64(statistics.queries), "server", server)
+	}
+}
+
+// writeURLTest writes the latest URL test results stored by the Clash API.
+func (s *Server) writeURLTest(metrics *writer) {
+	clashServer := s.router.ClashServer()
+	if clashServer == nil {
+		return
+	}
+	history := clashServer.HistoryStorage()
+	outbounds := s.router.Outbounds()
+	for _, provider := range s.router.OutboundProviders() {
+		outbounds = append(outbounds, provider.Outbounds()...)
+	}
+	var tags []string
+	results := make(map[string]*urltest.History)
+	for _, detour := range outbounds {
+		if _, loaded := results[detour.Tag()]; loaded {
+			continue
+		}
+		result := history.LoadURLTestHistory(detour.Tag())
+		if result == nil {
+			continue
+		}
+		tags = append(tags, detour.Tag())
+		results[detour.Tag()] = result
+	}
+	sort.Strings(tags)
+	metrics.header("sing_box_outbound_delay_milliseconds", "gauge", "Delay of the latest URL test by outbound.")
+	for _, tag := range tags {
+		metrics.sample("sing_box_outbound_delay_milliseconds", float64(results[tag].Delay), "outbound", tag)
+	}
+	metrics.header("sing_box_outbound_delay_timestamp_seconds", "gauge", "Time of the latest URL test by outbound.")
+	for _, tag := range tags {
+		metrics.sample("sing_box_outbound_delay_timestamp_seconds", float64(results[tag].Time.Unix()), "outbound", tag)
+	}
+}
+
+func (s *Server) writeFakeIP(metrics *writer) {
+	fakeIPStore := s.router.FakeIPStore()
+	if fakeIPStore == nil {
+		return
+	}
+	usage := fakeIPStore.Usage()
+	metrics.header("sing_box_fakeip_allocated_addresses", "gauge", "Addresses allocated from the FakeIP range.")
+	if usage.Inet4Size > 0 {
+		metrics.sample("sing_box_fakeip_allocated_addresses", usage.Inet4Allocated, "family", "ipv4")
+	}
+	if usage.Inet6Size > 0 {
+		metrics.sample("sing_box_fakeip_allocated_addresses", usage.Inet6Allocated, "family", "ipv6")
+	}
+	metrics.header("sing_box_fakeip_range_size", "gauge", "Addresses available in the FakeIP range.")
+	if usage.Inet4Size > 0 {
+		metrics.sample("sing_box_fakeip_range_size", usage.Inet4Size, "family", "ipv4")
+	}
+	if usage.Inet6Size > 0 {
+		metrics.sample("sing_box_fakeip_range_size", usage.Inet6Size, "family", "ipv6")
+	}
+}
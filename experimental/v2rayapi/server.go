@@ -45,6 +45,9 @@ func NewServer(logger log.Logger, options option.V2RayAPIOptions) (adapter.V2Ray
 }
 
 func (s *Server) Start() error {
+	if s.listen == "" {
+		return nil
+	}
 	listener, err := net.Listen("tcp", s.listen)
 	if err != nil {
 		return err
@@ -71,5 +74,8 @@ func (s *Server) Close() error {
 }
 
 func (s *Server) StatsService() adapter.V2RayStatsService {
+	if s.statsService == nil {
+		return nil
+	}
 	return s.statsService
 }
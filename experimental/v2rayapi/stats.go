@@ -14,6 +14,7 @@ import (
 	"github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing/common/atomic"
 	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
 	N "github.com/sagernet/sing/common/network"
 )
 
@@ -31,6 +32,7 @@ type StatsService struct {
 	inbounds  map[string]bool
 	outbounds map[string]bool
 	users     map[string]bool
+	rules     bool
 	access    sync.Mutex
 	counters  map[string]*atomic.Int64
 }
@@ -56,6 +58,7 @@ func NewStatsService(options option.V2RayStatsServiceOptions) *StatsService {
 		inbounds:  inbounds,
 		outbounds: outbounds,
 		users:     users,
+		rules:     options.Rules,
 		counters:  make(map[string]*atomic.Int64),
 	}
 }
@@ -112,6 +115,51 @@ func (s *StatsService) RoutedPacketConnection(inbound string, outbound string, u
 	return trackerconn.NewPacket(conn, readCounter, writeCounter)
 }
 
+func (s *StatsService) Counter(name string) *atomic.Int64 {
+	s.access.Lock()
+	defer s.access.Unlock()
+	return s.loadOrCreateCounter(name)
+}
+
+func (s *StatsService) Counters() map[string]int64 {
+	s.access.Lock()
+	defer s.access.Unlock()
+	counters := make(map[string]int64, len(s.counters))
+	for name, counter := range s.counters {
+		counters[name] = counter.Load()
+	}
+	return counters
+}
+
+// UpdateRules replaces rule counters with statistics of the rules, which are
+// named by the index of the rule.
+func (s *StatsService) UpdateRules(rules []adapter.Rule, dnsRules []adapter.DNSRule) {
+	if !s.rules {
+		return
+	}
+	s.access.Lock()
+	defer s.access.Unlock()
+	for name := range s.counters {
+		if strings.HasPrefix(name, "rule>>>") || strings.HasPrefix(name, "dns_rule>>>") {
+			delete(s.counters, name)
+		}
+	}
+	for i, rule := range rules {
+		statistics := rule.Statistics()
+		prefix := "rule>>>" + F.ToString(i)
+		s.counters[prefix+">>>hits"] = &statistics.Hits
+		s.counters[prefix+">>>last_hit"] = &statistics.LastHit
+		s.counters[prefix+">>>traffic>>>uplink"] = &statistics.Upload
+		s.counters[prefix+">>>traffic>>>downlink"] = &statistics.Download
+	}
+	for i, rule := range dnsRules {
+		statistics := rule.Statistics()
+		prefix := "dns_rule>>>" + F.ToString(i)
+		s.counters[prefix+">>>hits"] = &statistics.Hits
+		s.counters[prefix+">>>last_hit"] = &statistics.LastHit
+	}
+}
+
 func (s *StatsService) GetStats(ctx context.Context, request *GetStatsRequest) (*GetStatsResponse, error) {
 	s.access.Lock()
 	counter, loaded := s.counters[request.Name]
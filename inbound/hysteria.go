@@ -9,6 +9,7 @@ import (
 	"github.com/sagernet/quic-go"
 	"github.com/sagernet/quic-go/congestion"
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/limiter"
 	"github.com/sagernet/sing-box/common/tls"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
@@ -20,18 +21,22 @@ import (
 	F "github.com/sagernet/sing/common/format"
 	M "github.com/sagernet/sing/common/metadata"
 	N "github.com/sagernet/sing/common/network"
-
-	"golang.org/x/exp/slices"
 )
 
-var _ adapter.Inbound = (*Hysteria)(nil)
+var (
+	_ adapter.Inbound            = (*Hysteria)(nil)
+	_ adapter.UserManagedInbound = (*Hysteria)(nil)
+)
 
 type Hysteria struct {
 	myInboundAdapter
+	*userList[option.HysteriaUser]
 	quicConfig   *quic.Config
 	tlsConfig    tls.ServerConfig
-	authKey      []string
-	authUser     []string
+	authAccess   sync.RWMutex
+	authRequired bool
+	authIndex    map[string]int
+	limiter      *limiter.Manager
 	xplusKey     []byte
 	sendBPS      uint64
 	recvBPS      uint64
@@ -65,16 +70,8 @@ func NewHysteria(ctx context.Context, router adapter.Router, logger log.ContextL
 	if quicConfig.MaxIncomingStreams == 0 {
 		quicConfig.MaxIncomingStreams = hysteria.DefaultMaxIncomingStreams
 	}
-	authKey := common.Map(options.Users, func(it option.HysteriaUser) string {
-		if len(it.Auth) > 0 {
-			return string(it.Auth)
-		} else {
-			return it.AuthString
-		}
-	})
-	authUser := common.Map(options.Users, func(it option.HysteriaUser) string {
-		return it.Name
-	})
+	userNames := common.Map(options.Users, hysteriaUserName)
+	userLimits := common.Map(options.Users, hysteriaUserLimit)
 	var xplus []byte
 	if options.Obfs != "" {
 		xplus = []byte(options.Obfs)
@@ -113,8 +110,7 @@ func NewHysteria(ctx context.Context, router adapter.Router, logger log.ContextL
 			listenOptions: options.ListenOptions,
 		},
 		quicConfig:  quicConfig,
-		authKey:     authKey,
-		authUser:    authUser,
+		limiter:     limiter.NewManager(router, logger, tag, userNames, userLimits),
 		xplusKey:    xplus,
 		sendBPS:     up,
 		recvBPS:     down,
@@ -131,10 +127,58 @@ func NewHysteria(ctx context.Context, router adapter.Router, logger log.ContextL
 		return nil, err
 	}
 	inbound.tlsConfig = tlsConfig
+	inbound.userList = newUserList(options.Users, hysteriaUserName, hysteriaUserLimit, inbound.updateUsers, inbound.limiter)
+	indexes := make([]int, len(options.Users))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	inbound.updateUsers(indexes, options.Users)
 	return inbound, nil
 }
 
+func hysteriaUserName(it option.HysteriaUser) string {
+	return it.Name
+}
+
+func hysteriaUserLimit(it option.HysteriaUser) option.UserLimitOptions {
+	return it.UserLimitOptions
+}
+
+func (h *Hysteria) updateUsers(indexes []int, users []option.HysteriaUser) error {
+	authIndex := make(map[string]int)
+	for i, user := range users {
+		authKey := user.AuthString
+		if len(user.Auth) > 0 {
+			authKey = string(user.Auth)
+		}
+		if _, loaded := authIndex[authKey]; !loaded {
+			authIndex[authKey] = indexes[i]
+		}
+	}
+	h.authAccess.Lock()
+	// once users are configured, authentication stays required even if all of them are removed
+	h.authRequired = h.authRequired || len(users) > 0
+	h.authIndex = authIndex
+	h.authAccess.Unlock()
+	return nil
+}
+
+func (h *Hysteria) loadUser(authKey string) (int, option.HysteriaUser, bool) {
+	h.authAccess.RLock()
+	userIndex, loaded := h.authIndex[authKey]
+	h.authAccess.RUnlock()
+	if !loaded {
+		return -1, option.HysteriaUser{}, false
+	}
+	userOptions, loaded := h.userList.Load(userIndex)
+	return userIndex, userOptions, loaded
+}
+
 func (h *Hysteria) Start() error {
+	err := h.userList.restore(h.router, h.tag)
+	if err != nil {
+		return E.Cause(err, "restore users")
+	}
 	packetConn, err := h.myInboundAdapter.ListenUDP()
 	if err != nil {
 		return err
@@ -187,20 +231,30 @@ func (h *Hysteria) accept(ctx context.Context, conn quic.Connection) error {
 	if err != nil {
 		return err
 	}
-	if len(h.authKey) > 0 {
-		userIndex := slices.Index(h.authKey, string(clientHello.Auth))
-		if userIndex == -1 {
+	var user string
+	h.authAccess.RLock()
+	authRequired := h.authRequired
+	h.authAccess.RUnlock()
+	if authRequired {
+		userIndex, userOptions, loaded := h.loadUser(string(clientHello.Auth))
+		if !loaded {
 			err = hysteria.WriteServerHello(controlStream, hysteria.ServerHello{
 				Message: "wrong password",
 			})
 			return E.Errors(E.New("wrong password: ", string(clientHello.Auth)), err)
 		}
-		user := h.authUser[userIndex]
+		user = userOptions.Name
 		if user == "" {
 			user = F.ToString(userIndex)
 		} else {
 			ctx = auth.ContextWithUser(ctx, user)
 		}
+		err = h.limiter.Check(user)
+		if err != nil {
+			return E.Errors(E.Cause(err, "reject user ", user), hysteria.WriteServerHello(controlStream, hysteria.ServerHello{
+				Message: err.Error(),
+			}))
+		}
 		h.logger.InfoContext(ctx, "[", user, "] inbound connection from ", conn.RemoteAddr())
 	} else {
 		h.logger.InfoContext(ctx, "inbound connection from ", conn.RemoteAddr())
@@ -233,7 +287,7 @@ func (h *Hysteria) accept(ctx context.Context, conn quic.Connection) error {
 			return err
 		}
 		go func() {
-			hErr := h.acceptStream(ctx, conn /*&hysteria.StreamWrapper{Stream: stream}*/, stream)
+			hErr := h.acceptStream(ctx, conn /*&hysteria.StreamWrapper{Stream: stream}*/, stream, user)
 			if hErr != nil {
 				stream.Close()
 				NewError(h.logger, ctx, E.Cause(hErr, "process stream from ", conn.RemoteAddr()))
@@ -271,7 +325,7 @@ func (h *Hysteria) udpRecvLoop(conn quic.Connection) {
 	}
 }
 
-func (h *Hysteria) acceptStream(ctx context.Context, conn quic.Connection, stream quic.Stream) error {
+func (h *Hysteria) acceptStream(ctx context.Context, conn quic.Connection, stream quic.Stream, user string) error {
 	request, err := hysteria.ReadClientRequest(stream)
 	if err != nil {
 		return err
@@ -283,6 +337,7 @@ func (h *Hysteria) acceptStream(ctx context.Context, conn quic.Connection, strea
 	metadata.Source = M.SocksaddrFromNet(conn.RemoteAddr()).Unwrap()
 	metadata.OriginDestination = M.SocksaddrFromNet(conn.LocalAddr()).Unwrap()
 	metadata.Destination = M.ParseSocksaddrHostPort(request.Host, request.Port).Unwrap()
+	metadata.User, _ = auth.UserFromContext[string](ctx)
 
 	if !request.UDP {
 		err = hysteria.WriteServerResponse(stream, hysteria.ServerResponse{
@@ -292,7 +347,18 @@ func (h *Hysteria) acceptStream(ctx context.Context, conn quic.Connection, strea
 			return err
 		}
 		h.logger.InfoContext(ctx, "inbound connection to ", metadata.Destination)
-		return h.router.RouteConnection(ctx, hysteria.NewConn(stream, metadata.Destination, false), metadata)
+		limitedConn, err := h.limiter.NewConnection(user, hysteria.NewConn(stream, metadata.Destination, false))
+		if err != nil {
+			return E.Cause(err, "reject user ", user)
+		}
+		defer limitedConn.Close()
+		if user != "" {
+			if !h.userList.addConn(user, limitedConn) {
+				return E.New("user removed: ", user)
+			}
+			defer h.userList.removeConn(user, limitedConn)
+		}
+		return h.router.RouteConnection(ctx, limitedConn, metadata)
 	} else {
 		h.logger.InfoContext(ctx, "inbound packet connection to ", metadata.Destination)
 		var id uint32
@@ -319,7 +385,19 @@ func (h *Hysteria) acceptStream(ctx context.Context, conn quic.Connection, strea
 			return nil
 		}))
 		go packetConn.Hold()
-		return h.router.RoutePacketConnection(ctx, packetConn, metadata)
+		limitedConn, err := h.limiter.NewPacketConnection(user, packetConn)
+		if err != nil {
+			packetConn.Close()
+			return E.Cause(err, "reject user ", user)
+		}
+		defer limitedConn.Close()
+		if user != "" {
+			if !h.userList.addConn(user, limitedConn) {
+				return E.New("user removed: ", user)
+			}
+			defer h.userList.removeConn(user, limitedConn)
+		}
+		return h.router.RoutePacketConnection(ctx, limitedConn, metadata)
 	}
 }
 
@@ -0,0 +1,231 @@
+//go:build with_quic
+
+package inbound
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/limiter"
+	"github.com/sagernet/sing-box/common/tls"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/hysteria"
+	"github.com/sagernet/sing-box/transport/hysteria2"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/auth"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var (
+	_ adapter.Inbound            = (*Hysteria2)(nil)
+	_ adapter.UserManagedInbound = (*Hysteria2)(nil)
+)
+
+type Hysteria2 struct {
+	myInboundAdapter
+	*userList[option.Hysteria2User]
+	tlsConfig tls.ServerConfig
+	limiter   *limiter.Manager
+	service   *hysteria2.Service[int]
+}
+
+func NewHysteria2(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.Hysteria2InboundOptions) (*Hysteria2, error) {
+	if options.TLS == nil || !options.TLS.Enabled {
+		return nil, C.ErrTLSRequired
+	}
+	if len(options.TLS.ALPN) == 0 {
+		options.TLS.ALPN = []string{hysteria2.DefaultALPN}
+	}
+	tlsConfig, err := tls.NewServer(ctx, router, logger, common.PtrValueOrDefault(options.TLS))
+	if err != nil {
+		return nil, err
+	}
+	var salamanderPassword string
+	if options.Obfs != nil {
+		if options.Obfs.Password == "" {
+			return nil, E.New("missing obfs password")
+		}
+		switch options.Obfs.Type {
+		case hysteria2.ObfsTypeSalamander:
+			salamanderPassword = options.Obfs.Password
+		default:
+			return nil, E.New("unknown obfs type: ", options.Obfs.Type)
+		}
+	}
+	var masqueradeHandler http.Handler
+	if options.Masquerade != "" {
+		masqueradeURL, err := url.Parse(options.Masquerade)
+		if err != nil {
+			return nil, E.Cause(err, "parse masquerade URL")
+		}
+		switch masqueradeURL.Scheme {
+		case "file":
+			masqueradeHandler = http.FileServer(http.Dir(masqueradeURL.Path))
+		case "http", "https":
+			reverseProxy := httputil.NewSingleHostReverseProxy(masqueradeURL)
+			director := reverseProxy.Director
+			reverseProxy.Director = func(request *http.Request) {
+				director(request)
+				request.Host = masqueradeURL.Host
+			}
+			reverseProxy.ErrorHandler = func(writer http.ResponseWriter, request *http.Request, err error) {
+				logger.DebugContext(request.Context(), E.Cause(err, "masquerade"))
+				writer.WriteHeader(http.StatusBadGateway)
+			}
+			masqueradeHandler = reverseProxy
+		default:
+			return nil, E.New("unknown masquerade URL scheme: ", masqueradeURL.Scheme)
+		}
+	}
+	inbound := &Hysteria2{
+		myInboundAdapter: myInboundAdapter{
+			protocol:      C.TypeHysteria2,
+			network:       []string{N.NetworkUDP},
+			ctx:           ctx,
+			router:        router,
+			logger:        logger,
+			tag:           tag,
+			listenOptions: options.ListenOptions,
+		},
+		tlsConfig: tlsConfig,
+		limiter:   limiter.NewManager(router, logger, tag, common.Map(options.Users, hysteria2UserName), common.Map(options.Users, hysteria2UserLimit)),
+	}
+	service, err := hysteria2.NewService[int](hysteria2.ServiceOptions{
+		Context:               ctx,
+		SendBPS:               uint64(options.UpMbps) * hysteria.MbpsToBps,
+		ReceiveBPS:            uint64(options.DownMbps) * hysteria.MbpsToBps,
+		IgnoreClientBandwidth: options.IgnoreClientBandwidth,
+		SalamanderPassword:    salamanderPassword,
+		TLSConfig:             tlsConfig,
+		Handler:               inbound,
+		MasqueradeHandler:     masqueradeHandler,
+	})
+	if err != nil {
+		return nil, err
+	}
+	inbound.service = service
+	inbound.userList = newUserList(options.Users, hysteria2UserName, hysteria2UserLimit, inbound.updateUsers, inbound.limiter)
+	err = inbound.updateUsers(common.MapIndexed(options.Users, func(index int, it option.Hysteria2User) int {
+		return index
+	}), options.Users)
+	if err != nil {
+		return nil, err
+	}
+	return inbound, nil
+}
+
+func hysteria2UserName(it option.Hysteria2User) string {
+	return it.Name
+}
+
+func hysteria2UserLimit(it option.Hysteria2User) option.UserLimitOptions {
+	return it.UserLimitOptions
+}
+
+func (h *Hysteria2) updateUsers(indexes []int, users []option.Hysteria2User) error {
+	h.service.UpdateUsers(indexes, common.Map(users, func(it option.Hysteria2User) string {
+		return it.Password
+	}))
+	return nil
+}
+
+func (h *Hysteria2) Start() error {
+	err := h.userList.restore(h.router, h.tag)
+	if err != nil {
+		return E.Cause(err, "restore users")
+	}
+	err = h.tlsConfig.Start()
+	if err != nil {
+		return err
+	}
+	packetConn, err := h.myInboundAdapter.ListenUDP()
+	if err != nil {
+		return err
+	}
+	return h.service.Start(packetConn)
+}
+
+func (h *Hysteria2) Close() error {
+	return common.Close(
+		&h.myInboundAdapter,
+		h.tlsConfig,
+		h.service,
+	)
+}
+
+func (h *Hysteria2) NewConnection(ctx context.Context, conn net.Conn, upstreamMetadata M.Metadata) error {
+	metadata := h.createMetadata(upstreamMetadata)
+	user, err := h.loadUser(ctx, &metadata)
+	if err != nil {
+		return err
+	}
+	h.logger.InfoContext(ctx, "[", user, "] inbound connection to ", metadata.Destination)
+	conn, err = h.limiter.NewConnection(user, conn)
+	if err != nil {
+		return E.Cause(err, "reject user ", user)
+	}
+	defer conn.Close()
+	if !h.userList.addConn(user, conn) {
+		return E.New("user removed: ", user)
+	}
+	defer h.userList.removeConn(user, conn)
+	return h.router.RouteConnection(ctx, conn, metadata)
+}
+
+func (h *Hysteria2) NewPacketConnection(ctx context.Context, conn N.PacketConn, upstreamMetadata M.Metadata) error {
+	metadata := h.createMetadata(upstreamMetadata)
+	user, err := h.loadUser(ctx, &metadata)
+	if err != nil {
+		return err
+	}
+	h.logger.InfoContext(ctx, "[", user, "] inbound packet connection to ", metadata.Destination)
+	conn, err = h.limiter.NewPacketConnection(user, conn)
+	if err != nil {
+		return E.Cause(err, "reject user ", user)
+	}
+	defer conn.Close()
+	if !h.userList.addConn(user, conn) {
+		return E.New("user removed: ", user)
+	}
+	defer h.userList.removeConn(user, conn)
+	return h.router.RoutePacketConnection(ctx, conn, metadata)
+}
+
+func (h *Hysteria2) createMetadata(upstreamMetadata M.Metadata) adapter.InboundContext {
+	var metadata adapter.InboundContext
+	metadata.Inbound = h.tag
+	metadata.InboundType = C.TypeHysteria2
+	metadata.InboundDetour = h.listenOptions.Detour
+	metadata.InboundOptions = h.listenOptions.InboundOptions
+	metadata.Source = upstreamMetadata.Source
+	metadata.Destination = upstreamMetadata.Destination
+	return metadata
+}
+
+func (h *Hysteria2) loadUser(ctx context.Context, metadata *adapter.InboundContext) (string, error) {
+	userIndex, loaded := auth.UserFromContext[int](ctx)
+	if !loaded {
+		return "", os.ErrInvalid
+	}
+	userOptions, loaded := h.userList.Load(userIndex)
+	if !loaded {
+		return "", os.ErrInvalid
+	}
+	user := userOptions.Name
+	if user == "" {
+		user = F.ToString(userIndex)
+	} else {
+		metadata.User = user
+	}
+	return user, nil
+}
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/limiter"
 	"github.com/sagernet/sing-box/common/tls"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/include"
@@ -33,6 +34,7 @@ var _ adapter.Inbound = (*Naive)(nil)
 type Naive struct {
 	myInboundAdapter
 	authenticator auth.Authenticator
+	limiter       *limiter.Manager
 	tlsConfig     tls.ServerConfig
 	httpServer    *http.Server
 	h3Server      any
@@ -49,7 +51,14 @@ func NewNaive(ctx context.Context, router adapter.Router, logger log.ContextLogg
 			tag:           tag,
 			listenOptions: options.ListenOptions,
 		},
-		authenticator: auth.NewAuthenticator(options.Users),
+		authenticator: auth.NewAuthenticator(common.Map(options.Users, func(it option.NaiveUser) auth.User {
+			return it.User
+		})),
+		limiter: limiter.NewManager(router, logger, tag, common.Map(options.Users, func(it option.NaiveUser) string {
+			return it.Username
+		}), common.Map(options.Users, func(it option.NaiveUser) option.UserLimitOptions {
+			return it.UserLimitOptions
+		})),
 	}
 	if common.Contains(inbound.network, N.NetworkUDP) {
 		if options.TLS == nil || !options.TLS.Enabled {
@@ -153,6 +162,11 @@ func (n *Naive) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 		n.badRequest(ctx, request, E.New("authorization failed"))
 		return
 	}
+	if err := n.limiter.Check(userName); err != nil {
+		rejectHTTP(writer, http.StatusForbidden)
+		n.badRequest(ctx, request, E.Cause(err, "reject user ", userName))
+		return
+	}
 	writer.Header().Set("Padding", generateNaivePaddingHeader())
 	writer.WriteHeader(http.StatusOK)
 	writer.(http.Flusher).Flush()
@@ -184,6 +198,13 @@ func (n *Naive) newConnection(ctx context.Context, conn net.Conn, userName strin
 		n.logger.InfoContext(ctx, "inbound connection from ", source)
 		n.logger.InfoContext(ctx, "inbound connection to ", destination)
 	}
+	limitedConn, err := n.limiter.NewConnection(userName, conn)
+	if err != nil {
+		conn.Close()
+		n.NewError(ctx, E.Cause(err, "reject user ", userName))
+		return
+	}
+	conn = limitedConn
 	hErr := n.router.RouteConnection(ctx, conn, n.createMetadata(conn, adapter.InboundContext{
 		Source:      source,
 		Destination: destination,
@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/limiter"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
@@ -19,14 +20,16 @@ import (
 )
 
 var (
-	_ adapter.Inbound           = (*ShadowsocksMulti)(nil)
-	_ adapter.InjectableInbound = (*ShadowsocksMulti)(nil)
+	_ adapter.Inbound            = (*ShadowsocksMulti)(nil)
+	_ adapter.InjectableInbound  = (*ShadowsocksMulti)(nil)
+	_ adapter.UserManagedInbound = (*ShadowsocksMulti)(nil)
 )
 
 type ShadowsocksMulti struct {
 	myInboundAdapter
+	*userList[option.ShadowsocksUser]
 	service *shadowaead_2022.MultiService[int]
-	users   []option.ShadowsocksUser
+	limiter *limiter.Manager
 }
 
 func newShadowsocksMulti(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.ShadowsocksInboundOptions) (*ShadowsocksMulti, error) {
@@ -72,10 +75,31 @@ func newShadowsocksMulti(ctx context.Context, router adapter.Router, logger log.
 	}
 	inbound.service = service
 	inbound.packetUpstream = service
-	inbound.users = options.Users
+	inbound.limiter = limiter.NewManager(router, logger, tag, common.Map(options.Users, func(it option.ShadowsocksUser) string {
+		return it.Name
+	}), common.Map(options.Users, func(it option.ShadowsocksUser) option.UserLimitOptions {
+		return it.UserLimitOptions
+	}))
+	inbound.userList = newUserList(options.Users, func(it option.ShadowsocksUser) string {
+		return it.Name
+	}, func(it option.ShadowsocksUser) option.UserLimitOptions {
+		return it.UserLimitOptions
+	}, func(indexes []int, users []option.ShadowsocksUser) error {
+		return service.UpdateUsersWithPasswords(indexes, common.Map(users, func(it option.ShadowsocksUser) string {
+			return it.Password
+		}))
+	}, inbound.limiter)
 	return inbound, err
 }
 
+func (h *ShadowsocksMulti) Start() error {
+	err := h.userList.restore(h.router, h.tag)
+	if err != nil {
+		return E.Cause(err, "restore users")
+	}
+	return h.myInboundAdapter.Start()
+}
+
 func (h *ShadowsocksMulti) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
 	return h.service.NewConnection(adapter.WithContext(log.ContextWithNewID(ctx), &metadata), conn, adapter.UpstreamMetadata(metadata))
 }
@@ -93,13 +117,26 @@ func (h *ShadowsocksMulti) newConnection(ctx context.Context, conn net.Conn, met
 	if !loaded {
 		return os.ErrInvalid
 	}
-	user := h.users[userIndex].Name
+	userOptions, loaded := h.userList.Load(userIndex)
+	if !loaded {
+		return os.ErrInvalid
+	}
+	user := userOptions.Name
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
 		metadata.User = user
 	}
 	h.logger.InfoContext(ctx, "[", user, "] inbound connection to ", metadata.Destination)
+	conn, err := h.limiter.NewConnection(user, conn)
+	if err != nil {
+		return E.Cause(err, "reject user ", user)
+	}
+	defer conn.Close()
+	if !h.userList.addConn(user, conn) {
+		return E.New("user removed: ", user)
+	}
+	defer h.userList.removeConn(user, conn)
 	return h.router.RouteConnection(ctx, conn, metadata)
 }
 
@@ -108,7 +145,11 @@ func (h *ShadowsocksMulti) newPacketConnection(ctx context.Context, conn N.Packe
 	if !loaded {
 		return os.ErrInvalid
 	}
-	user := h.users[userIndex].Name
+	userOptions, loaded := h.userList.Load(userIndex)
+	if !loaded {
+		return os.ErrInvalid
+	}
+	user := userOptions.Name
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
@@ -117,5 +158,14 @@ func (h *ShadowsocksMulti) newPacketConnection(ctx context.Context, conn N.Packe
 	ctx = log.ContextWithNewID(ctx)
 	h.logger.InfoContext(ctx, "[", user, "] inbound packet connection from ", metadata.Source)
 	h.logger.InfoContext(ctx, "[", user, "] inbound packet connection to ", metadata.Destination)
+	conn, err := h.limiter.NewPacketConnection(user, conn)
+	if err != nil {
+		return E.Cause(err, "reject user ", user)
+	}
+	defer conn.Close()
+	if !h.userList.addConn(user, conn) {
+		return E.New("user removed: ", user)
+	}
+	defer h.userList.removeConn(user, conn)
 	return h.router.RoutePacketConnection(ctx, conn, metadata)
 }
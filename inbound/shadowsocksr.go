@@ -0,0 +1,342 @@
+//go:build with_shadowsocksr
+
+package inbound
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/limiter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/clashssr/obfs"
+	"github.com/sagernet/sing-box/transport/clashssr/protocol"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/auth"
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/common/udpnat"
+
+	"github.com/Dreamacro/clash/transport/shadowsocks/core"
+	"github.com/Dreamacro/clash/transport/shadowsocks/shadowstream"
+)
+
+var (
+	_ adapter.Inbound            = (*ShadowsocksR)(nil)
+	_ adapter.InjectableInbound  = (*ShadowsocksR)(nil)
+	_ adapter.UserManagedInbound = (*ShadowsocksR)(nil)
+)
+
+type ShadowsocksR struct {
+	myInboundAdapter
+	*userList[option.ShadowsocksRUser]
+	limiter      *limiter.Manager
+	cipher       core.Cipher
+	streamCipher *core.StreamCipher
+	obfs         obfs.ServerObfs
+	protocol     protocol.ServerProtocol
+	udpNat       *udpnat.Service[netip.AddrPort]
+	multiUser    bool
+	userAccess   sync.RWMutex
+	userMap      map[uint32]int
+}
+
+func NewShadowsocksR(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.ShadowsocksRInboundOptions) (*ShadowsocksR, error) {
+	inbound := &ShadowsocksR{
+		myInboundAdapter: myInboundAdapter{
+			protocol:      C.TypeShadowsocksR,
+			network:       options.Network.Build(),
+			ctx:           ctx,
+			router:        router,
+			logger:        logger,
+			tag:           tag,
+			listenOptions: options.ListenOptions,
+		},
+		limiter:   limiter.NewManager(router, logger, tag, common.Map(options.Users, shadowsocksRUserName), common.Map(options.Users, shadowsocksRUserLimit)),
+		multiUser: len(options.Users) > 0,
+	}
+	inbound.connHandler = inbound
+	inbound.packetHandler = inbound
+	var (
+		cipherName string
+		key        []byte
+		err        error
+	)
+	switch options.Method {
+	case "none":
+		cipherName = "dummy"
+	default:
+		cipherName = options.Method
+	}
+	inbound.cipher, err = core.PickCipher(cipherName, nil, options.Password)
+	if err != nil {
+		return nil, err
+	}
+	if cipherName == "dummy" {
+		key = core.Kdf(options.Password, 16)
+	} else {
+		streamCipher, isStream := inbound.cipher.(*core.StreamCipher)
+		if !isStream {
+			return nil, E.New(options.Method, " is not none or a supported stream cipher in ssr")
+		}
+		inbound.streamCipher = streamCipher
+		key = streamCipher.Key
+	}
+	inbound.obfs, err = obfs.PickServerObfs(options.Obfs)
+	if err != nil {
+		return nil, E.Cause(err, "initialize obfs")
+	}
+	switch options.Protocol {
+	case "", "origin":
+		if len(options.Users) > 0 {
+			return nil, E.New("users is only supported by auth_aes128 protocols")
+		}
+	}
+	inbound.protocol, err = protocol.PickServerProtocol(options.Protocol, &protocol.ServerBase{
+		Key:      key,
+		LoadUser: inbound.loadProtocolUser,
+	})
+	if err != nil {
+		return nil, E.Cause(err, "initialize protocol")
+	}
+	var udpTimeout int64
+	if options.UDPTimeout != 0 {
+		udpTimeout = options.UDPTimeout
+	} else {
+		udpTimeout = int64(C.UDPTimeout.Seconds())
+	}
+	inbound.udpNat = udpnat.New[netip.AddrPort](udpTimeout, adapter.NewUpstreamContextHandler(nil, inbound.newPacketConnection, inbound))
+	inbound.packetUpstream = inbound.udpNat
+	inbound.userList = newUserList(options.Users, shadowsocksRUserName, shadowsocksRUserLimit, inbound.updateUsers, inbound.limiter)
+	err = inbound.updateUsers(common.MapIndexed(options.Users, func(index int, it option.ShadowsocksRUser) int {
+		return index
+	}), options.Users)
+	if err != nil {
+		return nil, err
+	}
+	return inbound, nil
+}
+
+func shadowsocksRUserName(it option.ShadowsocksRUser) string {
+	return it.Name
+}
+
+func shadowsocksRUserLimit(it option.ShadowsocksRUser) option.UserLimitOptions {
+	return it.UserLimitOptions
+}
+
+func (h *ShadowsocksR) updateUsers(indexes []int, users []option.ShadowsocksRUser) error {
+	if !h.multiUser && len(users) > 0 {
+		return E.New("users can only be added to an inbound configured with users")
+	}
+	userMap := make(map[uint32]int)
+	for i, user := range users {
+		if _, loaded := userMap[user.ID]; !loaded {
+			userMap[user.ID] = indexes[i]
+		}
+	}
+	h.userAccess.Lock()
+	h.userMap = userMap
+	h.userAccess.Unlock()
+	return nil
+}
+
+func (h *ShadowsocksR) loadProtocolUser(userID uint32) (string, bool) {
+	userIndex, loaded := h.loadUserIndex(userID)
+	if !loaded {
+		return "", false
+	}
+	if userIndex < 0 {
+		// no users configured, authenticate with the key
+		return "", true
+	}
+	user, loaded := h.userList.Load(userIndex)
+	if !loaded {
+		return "", false
+	}
+	return user.Password, true
+}
+
+// loadUserIndex returns -1 if no users configured.
+func (h *ShadowsocksR) loadUserIndex(userID uint32) (int, bool) {
+	if !h.multiUser {
+		return -1, true
+	}
+	h.userAccess.RLock()
+	defer h.userAccess.RUnlock()
+	userIndex, loaded := h.userMap[userID]
+	return userIndex, loaded
+}
+
+func (h *ShadowsocksR) Start() error {
+	err := h.userList.restore(h.router, h.tag)
+	if err != nil {
+		return E.Cause(err, "restore users")
+	}
+	return h.myInboundAdapter.Start()
+}
+
+func (h *ShadowsocksR) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	conn = h.cipher.StreamConn(h.obfs.ServerConn(conn))
+	var iv []byte
+	if streamConn, isStream := conn.(*shadowstream.Conn); isStream {
+		var err error
+		iv, err = streamConn.ObtainReadIV()
+		if err != nil {
+			return E.Cause(err, "read iv")
+		}
+	}
+	conn, userID, err := h.protocol.StreamConn(conn, iv)
+	if err != nil {
+		return E.Cause(err, "read protocol header")
+	}
+	destination, err := M.SocksaddrSerializer.ReadAddrPort(conn)
+	if err != nil {
+		return E.Cause(err, "read destination")
+	}
+	metadata.Protocol = C.TypeShadowsocksR
+	metadata.Destination = destination
+	userIndex, loaded := h.loadUserIndex(userID)
+	if !loaded {
+		return E.New("unknown user: ", userID)
+	}
+	if userIndex < 0 {
+		return h.newConnection(ctx, conn, metadata)
+	}
+	userOptions, loaded := h.userList.Load(userIndex)
+	if !loaded {
+		return os.ErrInvalid
+	}
+	user := userOptions.Name
+	if user == "" {
+		user = F.ToString(userIndex)
+	} else {
+		metadata.User = user
+	}
+	h.logger.InfoContext(ctx, "[", user, "] inbound connection to ", metadata.Destination)
+	conn, err = h.limiter.NewConnection(user, conn)
+	if err != nil {
+		return E.Cause(err, "reject user ", user)
+	}
+	defer conn.Close()
+	if !h.userList.addConn(user, conn) {
+		return E.New("user removed: ", user)
+	}
+	defer h.userList.removeConn(user, conn)
+	return h.router.RouteConnection(ctx, conn, metadata)
+}
+
+func (h *ShadowsocksR) NewPacket(ctx context.Context, conn N.PacketConn, buffer *buf.Buffer, metadata adapter.InboundContext) error {
+	if h.streamCipher != nil {
+		_, err := shadowstream.Unpack(buffer.From(h.streamCipher.IVSize()), buffer.Bytes(), h.streamCipher)
+		if err != nil {
+			return err
+		}
+		buffer.Advance(h.streamCipher.IVSize())
+	}
+	packet, userID, err := h.protocol.DecodeServerPacket(buffer.Bytes())
+	if err != nil {
+		return E.Cause(err, "decode packet")
+	}
+	buffer.Truncate(len(packet))
+	userIndex, loaded := h.loadUserIndex(userID)
+	if !loaded {
+		return E.New("unknown user: ", userID)
+	}
+	destination, err := M.SocksaddrSerializer.ReadAddrPort(buffer)
+	if err != nil {
+		return E.Cause(err, "read destination")
+	}
+	metadata.Protocol = C.TypeShadowsocksR
+	metadata.Destination = destination
+	if userIndex >= 0 {
+		ctx = auth.ContextWithUser(ctx, userIndex)
+	}
+	h.udpNat.NewPacket(adapter.WithContext(ctx, &metadata), metadata.Source.AddrPort(), buffer, adapter.UpstreamMetadata(metadata), func(natConn N.PacketConn) N.PacketWriter {
+		return &shadowsocksRPacketWriter{h, conn, natConn}
+	})
+	return nil
+}
+
+func (h *ShadowsocksR) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	return os.ErrInvalid
+}
+
+func (h *ShadowsocksR) newPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	userIndex, loaded := auth.UserFromContext[int](ctx)
+	if !loaded {
+		return h.myInboundAdapter.newPacketConnection(ctx, conn, metadata)
+	}
+	userOptions, loaded := h.userList.Load(userIndex)
+	if !loaded {
+		return os.ErrInvalid
+	}
+	user := userOptions.Name
+	if user == "" {
+		user = F.ToString(userIndex)
+	} else {
+		metadata.User = user
+	}
+	ctx = log.ContextWithNewID(ctx)
+	h.logger.InfoContext(ctx, "[", user, "] inbound packet connection from ", metadata.Source)
+	h.logger.InfoContext(ctx, "[", user, "] inbound packet connection to ", metadata.Destination)
+	conn, err := h.limiter.NewPacketConnection(user, conn)
+	if err != nil {
+		return E.Cause(err, "reject user ", user)
+	}
+	defer conn.Close()
+	if !h.userList.addConn(user, conn) {
+		return E.New("user removed: ", user)
+	}
+	defer h.userList.removeConn(user, conn)
+	return h.router.RoutePacketConnection(ctx, conn, metadata)
+}
+
+type shadowsocksRPacketWriter struct {
+	inbound *ShadowsocksR
+	source  N.PacketConn
+	nat     N.PacketConn
+}
+
+func (w *shadowsocksRPacketWriter) WritePacket(buffer *buf.Buffer, destination M.Socksaddr) error {
+	defer buffer.Release()
+	header := buf.With(buffer.ExtendHeader(M.SocksaddrSerializer.AddrPortLen(destination)))
+	err := M.SocksaddrSerializer.WriteAddrPort(header, destination)
+	if err != nil {
+		return err
+	}
+	var encoded bytes.Buffer
+	err = w.inbound.protocol.EncodeServerPacket(&encoded, buffer.Bytes())
+	if err != nil {
+		return err
+	}
+	var packet *buf.Buffer
+	if w.inbound.streamCipher != nil {
+		packet = buf.NewSize(w.inbound.streamCipher.IVSize() + encoded.Len())
+		_, err = shadowstream.Pack(packet.Extend(packet.FreeLen()), encoded.Bytes(), w.inbound.streamCipher)
+		if err != nil {
+			packet.Release()
+			return err
+		}
+	} else {
+		packet = buf.As(encoded.Bytes())
+	}
+	return w.source.WritePacket(packet, M.SocksaddrFromNet(w.nat.LocalAddr()))
+}
+
+func (w *shadowsocksRPacketWriter) Upstream() any {
+	return w.source
+}
+
+func (w *shadowsocksRPacketWriter) FrontHeadroom() int {
+	return M.MaxSocksaddrLength
+}
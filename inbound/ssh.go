@@ -0,0 +1,261 @@
+package inbound
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/limiter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/bufio/deadline"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	_ adapter.Inbound            = (*SSH)(nil)
+	_ adapter.InjectableInbound  = (*SSH)(nil)
+	_ adapter.UserManagedInbound = (*SSH)(nil)
+)
+
+type SSH struct {
+	myInboundAdapter
+	*userList[option.SSHUser]
+	limiter    *limiter.Manager
+	config     *ssh.ServerConfig
+	userAccess sync.RWMutex
+	userMap    map[string]sshUser
+}
+
+type sshUser struct {
+	password       string
+	authorizedKeys [][]byte
+}
+
+func NewSSH(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.SSHInboundOptions) (*SSH, error) {
+	inbound := &SSH{
+		myInboundAdapter: myInboundAdapter{
+			protocol:      C.TypeSSH,
+			network:       []string{N.NetworkTCP},
+			ctx:           ctx,
+			router:        router,
+			logger:        logger,
+			tag:           tag,
+			listenOptions: options.ListenOptions,
+		},
+		limiter: limiter.NewManager(router, logger, tag, common.Map(options.Users, sshUserName), common.Map(options.Users, sshUserLimit)),
+	}
+	inbound.connHandler = inbound
+	inbound.config = &ssh.ServerConfig{
+		PasswordCallback:  inbound.passwordCallback,
+		PublicKeyCallback: inbound.publicKeyCallback,
+		ServerVersion:     options.ServerVersion,
+	}
+	hostKeys := common.Map(options.HostKey, func(it string) []byte {
+		return []byte(it)
+	})
+	for _, hostKeyPath := range options.HostKeyPath {
+		hostKey, err := os.ReadFile(os.ExpandEnv(hostKeyPath))
+		if err != nil {
+			return nil, E.Cause(err, "read host key")
+		}
+		hostKeys = append(hostKeys, hostKey)
+	}
+	if len(hostKeys) == 0 {
+		return nil, E.New("missing host key")
+	}
+	for _, hostKey := range hostKeys {
+		signer, err := ssh.ParsePrivateKey(hostKey)
+		if err != nil {
+			return nil, E.Cause(err, "parse host key")
+		}
+		inbound.config.AddHostKey(signer)
+	}
+	inbound.userList = newUserList(options.Users, sshUserName, sshUserLimit, inbound.updateUsers, inbound.limiter)
+	err := inbound.updateUsers(common.MapIndexed(options.Users, func(index int, it option.SSHUser) int {
+		return index
+	}), options.Users)
+	if err != nil {
+		return nil, err
+	}
+	return inbound, nil
+}
+
+func sshUserName(it option.SSHUser) string {
+	return it.Name
+}
+
+func sshUserLimit(it option.SSHUser) option.UserLimitOptions {
+	return it.UserLimitOptions
+}
+
+func (h *SSH) updateUsers(indexes []int, users []option.SSHUser) error {
+	userMap := make(map[string]sshUser)
+	for i, user := range users {
+		if user.Name == "" {
+			return E.New("missing name for user ", indexes[i])
+		}
+		if user.Password == "" && len(user.AuthorizedKeys) == 0 {
+			return E.New("missing password or authorized keys for user ", user.Name)
+		}
+		authorizedKeys := make([][]byte, 0, len(user.AuthorizedKeys))
+		for _, authorizedKey := range user.AuthorizedKeys {
+			publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(authorizedKey))
+			if err != nil {
+				return E.Cause(err, "parse authorized key for user ", user.Name)
+			}
+			authorizedKeys = append(authorizedKeys, publicKey.Marshal())
+		}
+		userMap[user.Name] = sshUser{
+			password:       user.Password,
+			authorizedKeys: authorizedKeys,
+		}
+	}
+	h.userAccess.Lock()
+	defer h.userAccess.Unlock()
+	h.userMap = userMap
+	return nil
+}
+
+func (h *SSH) loadUser(name string) (sshUser, bool) {
+	h.userAccess.RLock()
+	defer h.userAccess.RUnlock()
+	user, loaded := h.userMap[name]
+	return user, loaded
+}
+
+func (h *SSH) passwordCallback(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+	user, loaded := h.loadUser(conn.User())
+	if !loaded || user.password == "" || subtle.ConstantTimeCompare([]byte(user.password), password) != 1 {
+		return nil, E.New("password rejected for ", conn.User())
+	}
+	return nil, nil
+}
+
+func (h *SSH) publicKeyCallback(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	user, loaded := h.loadUser(conn.User())
+	if loaded {
+		publicKey := key.Marshal()
+		for _, authorizedKey := range user.authorizedKeys {
+			if bytes.Equal(authorizedKey, publicKey) {
+				return nil, nil
+			}
+		}
+	}
+	return nil, E.New("unknown public key for ", conn.User())
+}
+
+func (h *SSH) Start() error {
+	err := h.userList.restore(h.router, h.tag)
+	if err != nil {
+		return E.Cause(err, "restore users")
+	}
+	return h.myInboundAdapter.Start()
+}
+
+func (h *SSH) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	serverConn, channels, requests, err := ssh.NewServerConn(conn, h.config)
+	if err != nil {
+		return E.Cause(err, "ssh handshake")
+	}
+	defer serverConn.Close()
+	go ssh.DiscardRequests(requests)
+	user := serverConn.User()
+	if !h.userList.addConn(user, serverConn) {
+		return E.New("user removed: ", user)
+	}
+	defer h.userList.removeConn(user, serverConn)
+	for newChannel := range channels {
+		if _, loaded := h.loadUser(user); !loaded {
+			newChannel.Reject(ssh.Prohibited, "user removed")
+			continue
+		}
+		if newChannel.ChannelType() != "direct-tcpip" {
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type: "+newChannel.ChannelType())
+			continue
+		}
+		// RFC 4254 section 7.2
+		var request struct {
+			Host       string
+			Port       uint32
+			OriginAddr string
+			OriginPort uint32
+		}
+		err = ssh.Unmarshal(newChannel.ExtraData(), &request)
+		if err != nil || request.Port > 65535 {
+			newChannel.Reject(ssh.ConnectionFailed, "bad direct-tcpip request")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			h.NewError(ctx, E.Cause(err, "accept channel"))
+			continue
+		}
+		go ssh.DiscardRequests(channelRequests)
+		channelMetadata := metadata
+		channelMetadata.Destination = M.ParseSocksaddrHostPort(request.Host, uint16(request.Port))
+		channelMetadata.User = user
+		go h.newChannel(log.ContextWithNewID(ctx), &sshChannelConn{Channel: channel, conn: conn}, channelMetadata)
+	}
+	return nil
+}
+
+func (h *SSH) newChannel(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) {
+	err := h.newConnection(ctx, conn, metadata)
+	if err != nil {
+		conn.Close()
+		h.NewError(ctx, E.Cause(err, "process connection from ", metadata.Source))
+	}
+}
+
+func (h *SSH) newConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	user := metadata.User
+	h.logger.InfoContext(ctx, "[", user, "] inbound connection to ", metadata.Destination)
+	conn, err := h.limiter.NewConnection(user, deadline.NewConn(conn))
+	if err != nil {
+		return E.Cause(err, "reject user ", user)
+	}
+	defer conn.Close()
+	return h.router.RouteConnection(ctx, conn, metadata)
+}
+
+func (h *SSH) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	return os.ErrInvalid
+}
+
+// sshChannelConn is a direct-tcpip channel, with the addresses of the underlying connection.
+type sshChannelConn struct {
+	ssh.Channel
+	conn net.Conn
+}
+
+func (c *sshChannelConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *sshChannelConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *sshChannelConn) SetDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *sshChannelConn) SetReadDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *sshChannelConn) SetWriteDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
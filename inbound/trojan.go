@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/limiter"
 	"github.com/sagernet/sing-box/common/tls"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
@@ -21,14 +22,16 @@ import (
 )
 
 var (
-	_ adapter.Inbound           = (*Trojan)(nil)
-	_ adapter.InjectableInbound = (*Trojan)(nil)
+	_ adapter.Inbound            = (*Trojan)(nil)
+	_ adapter.InjectableInbound  = (*Trojan)(nil)
+	_ adapter.UserManagedInbound = (*Trojan)(nil)
 )
 
 type Trojan struct {
 	myInboundAdapter
+	*userList[option.TrojanUser]
 	service                  *trojan.Service[int]
-	users                    []option.TrojanUser
+	limiter                  *limiter.Manager
 	tlsConfig                tls.ServerConfig
 	fallbackAddr             M.Socksaddr
 	fallbackAddrTLSNextProto map[string]M.Socksaddr
@@ -46,7 +49,11 @@ func NewTrojan(ctx context.Context, router adapter.Router, logger log.ContextLog
 			tag:           tag,
 			listenOptions: options.ListenOptions,
 		},
-		users: options.Users,
+		limiter: limiter.NewManager(router, logger, tag, common.Map(options.Users, func(it option.TrojanUser) string {
+			return it.Name
+		}), common.Map(options.Users, func(it option.TrojanUser) option.UserLimitOptions {
+			return it.UserLimitOptions
+		})),
 	}
 	if options.TLS != nil {
 		tlsConfig, err := tls.NewServer(ctx, router, logger, common.PtrValueOrDefault(options.TLS))
@@ -95,13 +102,26 @@ func NewTrojan(ctx context.Context, router adapter.Router, logger log.ContextLog
 		}
 	}
 	inbound.service = service
+	inbound.userList = newUserList(options.Users, func(it option.TrojanUser) string {
+		return it.Name
+	}, func(it option.TrojanUser) option.UserLimitOptions {
+		return it.UserLimitOptions
+	}, func(indexes []int, users []option.TrojanUser) error {
+		return service.UpdateUsers(indexes, common.Map(users, func(it option.TrojanUser) string {
+			return it.Password
+		}))
+	}, inbound.limiter)
 	inbound.connHandler = inbound
 	return inbound, nil
 }
 
 func (h *Trojan) Start() error {
+	err := h.userList.restore(h.router, h.tag)
+	if err != nil {
+		return E.Cause(err, "restore users")
+	}
 	if h.tlsConfig != nil {
-		err := h.tlsConfig.Start()
+		err = h.tlsConfig.Start()
 		if err != nil {
 			return E.Cause(err, "create TLS config")
 		}
@@ -169,13 +189,26 @@ func (h *Trojan) newConnection(ctx context.Context, conn net.Conn, metadata adap
 	if !loaded {
 		return os.ErrInvalid
 	}
-	user := h.users[userIndex].Name
+	userOptions, loaded := h.userList.Load(userIndex)
+	if !loaded {
+		return os.ErrInvalid
+	}
+	user := userOptions.Name
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
 		metadata.User = user
 	}
 	h.logger.InfoContext(ctx, "[", user, "] inbound connection to ", metadata.Destination)
+	conn, err := h.limiter.NewConnection(user, conn)
+	if err != nil {
+		return E.Cause(err, "reject user ", user)
+	}
+	defer conn.Close()
+	if !h.userList.addConn(user, conn) {
+		return E.New("user removed: ", user)
+	}
+	defer h.userList.removeConn(user, conn)
 	return h.router.RouteConnection(ctx, conn, metadata)
 }
 
@@ -207,13 +240,26 @@ func (h *Trojan) newPacketConnection(ctx context.Context, conn N.PacketConn, met
 	if !loaded {
 		return os.ErrInvalid
 	}
-	user := h.users[userIndex].Name
+	userOptions, loaded := h.userList.Load(userIndex)
+	if !loaded {
+		return os.ErrInvalid
+	}
+	user := userOptions.Name
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
 		metadata.User = user
 	}
 	h.logger.InfoContext(ctx, "[", user, "] inbound packet connection to ", metadata.Destination)
+	conn, err := h.limiter.NewPacketConnection(user, conn)
+	if err != nil {
+		return E.Cause(err, "reject user ", user)
+	}
+	defer conn.Close()
+	if !h.userList.addConn(user, conn) {
+		return E.New("user removed: ", user)
+	}
+	defer h.userList.removeConn(user, conn)
 	return h.router.RoutePacketConnection(ctx, conn, metadata)
 }
 
@@ -0,0 +1,200 @@
+//go:build with_quic
+
+package inbound
+
+import (
+	"context"
+	"net"
+	"os"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/limiter"
+	"github.com/sagernet/sing-box/common/tls"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/tuic"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/auth"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/gofrs/uuid/v5"
+)
+
+var (
+	_ adapter.Inbound            = (*TUIC)(nil)
+	_ adapter.UserManagedInbound = (*TUIC)(nil)
+)
+
+type TUIC struct {
+	myInboundAdapter
+	*userList[option.TUICUser]
+	tlsConfig tls.ServerConfig
+	limiter   *limiter.Manager
+	service   *tuic.Service[int]
+}
+
+func NewTUIC(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.TUICInboundOptions) (*TUIC, error) {
+	if options.TLS == nil || !options.TLS.Enabled {
+		return nil, C.ErrTLSRequired
+	}
+	if len(options.TLS.ALPN) == 0 {
+		options.TLS.ALPN = []string{tuic.DefaultALPN}
+	}
+	tlsConfig, err := tls.NewServer(ctx, router, logger, common.PtrValueOrDefault(options.TLS))
+	if err != nil {
+		return nil, err
+	}
+	inbound := &TUIC{
+		myInboundAdapter: myInboundAdapter{
+			protocol:      C.TypeTUIC,
+			network:       []string{N.NetworkUDP},
+			ctx:           ctx,
+			router:        router,
+			logger:        logger,
+			tag:           tag,
+			listenOptions: options.ListenOptions,
+		},
+		tlsConfig: tlsConfig,
+		limiter:   limiter.NewManager(router, logger, tag, common.Map(options.Users, tuicUserName), common.Map(options.Users, tuicUserLimit)),
+	}
+	service, err := tuic.NewService[int](tuic.ServiceOptions{
+		Context:           ctx,
+		TLSConfig:         tlsConfig,
+		CongestionControl: options.CongestionControl,
+		AuthTimeout:       time.Duration(options.AuthTimeout),
+		ZeroRTTHandshake:  options.ZeroRTTHandshake,
+		Heartbeat:         time.Duration(options.Heartbeat),
+		Handler:           inbound,
+	})
+	if err != nil {
+		return nil, err
+	}
+	inbound.service = service
+	inbound.userList = newUserList(options.Users, tuicUserName, tuicUserLimit, inbound.updateUsers, inbound.limiter)
+	err = inbound.updateUsers(common.MapIndexed(options.Users, func(index int, it option.TUICUser) int {
+		return index
+	}), options.Users)
+	if err != nil {
+		return nil, err
+	}
+	return inbound, nil
+}
+
+func tuicUserName(it option.TUICUser) string {
+	return it.Name
+}
+
+func tuicUserLimit(it option.TUICUser) option.UserLimitOptions {
+	return it.UserLimitOptions
+}
+
+func (h *TUIC) updateUsers(indexes []int, users []option.TUICUser) error {
+	uuidList := make([][16]byte, 0, len(users))
+	for index, user := range users {
+		userUUID, err := uuid.FromString(user.UUID)
+		if err != nil {
+			return E.Cause(err, "invalid uuid for user ", index)
+		}
+		uuidList = append(uuidList, userUUID)
+	}
+	h.service.UpdateUsers(indexes, uuidList, common.Map(users, func(it option.TUICUser) string {
+		return it.Password
+	}))
+	return nil
+}
+
+func (h *TUIC) Start() error {
+	err := h.userList.restore(h.router, h.tag)
+	if err != nil {
+		return E.Cause(err, "restore users")
+	}
+	err = h.tlsConfig.Start()
+	if err != nil {
+		return err
+	}
+	packetConn, err := h.myInboundAdapter.ListenUDP()
+	if err != nil {
+		return err
+	}
+	return h.service.Start(packetConn)
+}
+
+func (h *TUIC) Close() error {
+	return common.Close(
+		&h.myInboundAdapter,
+		h.tlsConfig,
+		h.service,
+	)
+}
+
+func (h *TUIC) NewConnection(ctx context.Context, conn net.Conn, upstreamMetadata M.Metadata) error {
+	metadata := h.createMetadata(upstreamMetadata)
+	user, err := h.loadUser(ctx, &metadata)
+	if err != nil {
+		return err
+	}
+	h.logger.InfoContext(ctx, "[", user, "] inbound connection to ", metadata.Destination)
+	conn, err = h.limiter.NewConnection(user, conn)
+	if err != nil {
+		return E.Cause(err, "reject user ", user)
+	}
+	defer conn.Close()
+	if !h.userList.addConn(user, conn) {
+		return E.New("user removed: ", user)
+	}
+	defer h.userList.removeConn(user, conn)
+	return h.router.RouteConnection(ctx, conn, metadata)
+}
+
+func (h *TUIC) NewPacketConnection(ctx context.Context, conn N.PacketConn, upstreamMetadata M.Metadata) error {
+	metadata := h.createMetadata(upstreamMetadata)
+	user, err := h.loadUser(ctx, &metadata)
+	if err != nil {
+		return err
+	}
+	h.logger.InfoContext(ctx, "[", user, "] inbound packet connection to ", metadata.Destination)
+	conn, err = h.limiter.NewPacketConnection(user, conn)
+	if err != nil {
+		return E.Cause(err, "reject user ", user)
+	}
+	defer conn.Close()
+	if !h.userList.addConn(user, conn) {
+		return E.New("user removed: ", user)
+	}
+	defer h.userList.removeConn(user, conn)
+	return h.router.RoutePacketConnection(ctx, conn, metadata)
+}
+
+func (h *TUIC) createMetadata(upstreamMetadata M.Metadata) adapter.InboundContext {
+	var metadata adapter.InboundContext
+	metadata.Inbound = h.tag
+	metadata.InboundType = C.TypeTUIC
+	metadata.InboundDetour = h.listenOptions.Detour
+	metadata.InboundOptions = h.listenOptions.InboundOptions
+	metadata.Source = upstreamMetadata.Source
+	metadata.Destination = upstreamMetadata.Destination
+	return metadata
+}
+
+func (h *TUIC) loadUser(ctx context.Context, metadata *adapter.InboundContext) (string, error) {
+	userIndex, loaded := auth.UserFromContext[int](ctx)
+	if !loaded {
+		return "", os.ErrInvalid
+	}
+	userOptions, loaded := h.userList.Load(userIndex)
+	if !loaded {
+		return "", os.ErrInvalid
+	}
+	user := userOptions.Name
+	if user == "" {
+		user = F.ToString(userIndex)
+	} else {
+		metadata.User = user
+	}
+	return user, nil
+}
@@ -0,0 +1,252 @@
+package inbound
+
+import (
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/json"
+	"github.com/sagernet/sing-box/common/limiter"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+)
+
+// userList holds the users of a multi-user inbound. Protocol services key
+// users by their index, so removed users leave a hole and the indexes of
+// remaining users never change.
+type userList[U any] struct {
+	access  sync.RWMutex
+	users   []U
+	removed []bool
+	active  map[string]bool
+	conns   map[string]map[io.Closer]struct{}
+	nameOf  func(U) string
+	limitOf func(U) option.UserLimitOptions
+	update  func(indexes []int, users []U) error
+	limiter *limiter.Manager
+}
+
+func newUserList[U any](users []U, nameOf func(U) string, limitOf func(U) option.UserLimitOptions, update func(indexes []int, users []U) error, limiter *limiter.Manager) *userList[U] {
+	list := &userList[U]{
+		users:   users,
+		removed: make([]bool, len(users)),
+		active:  make(map[string]bool),
+		conns:   make(map[string]map[io.Closer]struct{}),
+		nameOf:  nameOf,
+		limitOf: limitOf,
+		update:  update,
+		limiter: limiter,
+	}
+	for index, user := range users {
+		list.active[list.userName(index, user)] = true
+	}
+	return list
+}
+
+func (l *userList[U]) Load(index int) (U, bool) {
+	l.access.RLock()
+	defer l.access.RUnlock()
+	if index < 0 || index >= len(l.users) || l.removed[index] {
+		var user U
+		return user, false
+	}
+	return l.users[index], true
+}
+
+// Users lists the names, limits and usage of the users, leaving out their credentials.
+func (l *userList[U]) Users() []adapter.UserInfo {
+	usage := l.limiter.Usage()
+	l.access.RLock()
+	defer l.access.RUnlock()
+	users := make([]adapter.UserInfo, 0, len(l.users))
+	for index, user := range l.users {
+		if l.removed[index] {
+			continue
+		}
+		userInfo := adapter.UserInfo{
+			Name:             l.nameOf(user),
+			UserLimitOptions: l.limitOf(user),
+		}
+		if userUsage, loaded := usage[l.userName(index, user)]; loaded {
+			userInfo.Usage = &userUsage
+		}
+		users = append(users, userInfo)
+	}
+	return users
+}
+
+func (l *userList[U]) AddUsers(content []byte) error {
+	var newUsers []U
+	err := json.Unmarshal(content, &newUsers)
+	if err != nil {
+		return E.Cause(err, "decode users")
+	}
+	l.access.Lock()
+	defer l.access.Unlock()
+	nameIndex := l.nameIndex()
+	for _, user := range newUsers {
+		name := l.nameOf(user)
+		if name == "" {
+			return E.New("missing user name")
+		}
+		if _, loaded := nameIndex[name]; loaded {
+			return E.New("user already exists: ", name)
+		}
+		nameIndex[name] = -1
+	}
+	users := append(l.users[:len(l.users):len(l.users)], newUsers...)
+	removed := append(l.removed[:len(l.removed):len(l.removed)], make([]bool, len(newUsers))...)
+	return l.commit(users, removed)
+}
+
+func (l *userList[U]) RemoveUsers(names []string) error {
+	l.access.Lock()
+	defer l.access.Unlock()
+	nameIndex := l.nameIndex()
+	removed := make([]bool, len(l.removed))
+	copy(removed, l.removed)
+	for _, name := range names {
+		index, loaded := nameIndex[name]
+		if !loaded {
+			return E.New("user not found: ", name)
+		}
+		removed[index] = true
+	}
+	err := l.commit(l.users, removed)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		for conn := range l.conns[name] {
+			conn.Close()
+		}
+	}
+	return nil
+}
+
+// addConn tracks a connection of the user, so it is closed once the user is
+// removed. It returns false if the user has been removed already.
+func (l *userList[U]) addConn(name string, conn io.Closer) bool {
+	l.access.Lock()
+	defer l.access.Unlock()
+	if !l.active[name] {
+		return false
+	}
+	conns := l.conns[name]
+	if conns == nil {
+		conns = make(map[io.Closer]struct{})
+		l.conns[name] = conns
+	}
+	conns[conn] = struct{}{}
+	return true
+}
+
+func (l *userList[U]) removeConn(name string, conn io.Closer) {
+	l.access.Lock()
+	defer l.access.Unlock()
+	conns := l.conns[name]
+	delete(conns, conn)
+	if len(conns) == 0 {
+		delete(l.conns, name)
+	}
+}
+
+// restore replays users added or removed through the API, as recorded in the
+// cache file. Users that are configured again are left as configured.
+func (l *userList[U]) restore(router adapter.Router, tag string) error {
+	clashServer := router.ClashServer()
+	if tag == "" || clashServer == nil || !clashServer.StoreUsers() {
+		return nil
+	}
+	storedUsers := clashServer.CacheFile().LoadInboundUsers(tag)
+	if len(storedUsers) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(storedUsers))
+	for name := range storedUsers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	l.access.Lock()
+	defer l.access.Unlock()
+	nameIndex := l.nameIndex()
+	users := l.users[:len(l.users):len(l.users)]
+	removed := make([]bool, len(l.removed))
+	copy(removed, l.removed)
+	for _, name := range names {
+		content := storedUsers[name]
+		index, loaded := nameIndex[name]
+		if len(content) == 0 {
+			if loaded {
+				removed[index] = true
+			}
+			continue
+		}
+		if loaded {
+			continue
+		}
+		var user U
+		err := json.Unmarshal(content, &user)
+		if err != nil {
+			return E.Cause(err, "decode stored user ", name)
+		}
+		users = append(users, user)
+		removed = append(removed, false)
+	}
+	return l.commit(users, removed)
+}
+
+func (l *userList[U]) nameIndex() map[string]int {
+	nameIndex := make(map[string]int)
+	for index, user := range l.users {
+		if l.removed[index] {
+			continue
+		}
+		if name := l.nameOf(user); name != "" {
+			nameIndex[name] = index
+		}
+	}
+	return nameIndex
+}
+
+// userName returns the name of the user as used by the limiter and connection
+// tracking, which is its index if the user has no name.
+func (l *userList[U]) userName(index int, user U) string {
+	name := l.nameOf(user)
+	if name == "" {
+		name = F.ToString(index)
+	}
+	return name
+}
+
+func (l *userList[U]) commit(users []U, removed []bool) error {
+	var (
+		indexes     []int
+		activeUsers []U
+		names       []string
+		limits      []option.UserLimitOptions
+	)
+	active := make(map[string]bool)
+	for index, user := range users {
+		if removed[index] {
+			continue
+		}
+		indexes = append(indexes, index)
+		activeUsers = append(activeUsers, user)
+		name := l.userName(index, user)
+		names = append(names, name)
+		limits = append(limits, l.limitOf(user))
+		active[name] = true
+	}
+	err := l.update(indexes, activeUsers)
+	if err != nil {
+		return err
+	}
+	l.users = users
+	l.removed = removed
+	l.active = active
+	l.limiter.Update(names, limits)
+	return nil
+}
@@ -0,0 +1,232 @@
+package inbound
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/limiter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testUserRouter struct {
+	adapter.Router
+	clashServer adapter.ClashServer
+}
+
+func (r *testUserRouter) V2RayServer() adapter.V2RayServer {
+	return nil
+}
+
+func (r *testUserRouter) ClashServer() adapter.ClashServer {
+	return r.clashServer
+}
+
+type testUserClashServer struct {
+	adapter.ClashServer
+	cacheFile *testUserCacheFile
+}
+
+func (s *testUserClashServer) StoreUsers() bool {
+	return true
+}
+
+func (s *testUserClashServer) StoreQuota() bool {
+	return false
+}
+
+func (s *testUserClashServer) CacheFile() adapter.ClashCacheFile {
+	return s.cacheFile
+}
+
+type testUserCacheFile struct {
+	adapter.ClashCacheFile
+	users map[string][]byte
+}
+
+func (c *testUserCacheFile) LoadInboundUsers(inbound string) map[string][]byte {
+	return c.users
+}
+
+func TestUserList(t *testing.T) {
+	t.Parallel()
+	type testUserList = userList[option.TrojanUser]
+	testCases := []struct {
+		name   string
+		stored map[string][]byte
+		action func(list *testUserList, router adapter.Router) error
+		err    bool
+		users  map[int]string
+	}{
+		{
+			name: "add",
+			action: func(list *testUserList, router adapter.Router) error {
+				return list.AddUsers([]byte(`[{"name":"c","password":"c"}]`))
+			},
+			users: map[int]string{0: "a", 1: "b", 2: "c"},
+		},
+		{
+			name: "add existing",
+			action: func(list *testUserList, router adapter.Router) error {
+				return list.AddUsers([]byte(`[{"name":"c","password":"c"},{"name":"a","password":"a"}]`))
+			},
+			err:   true,
+			users: map[int]string{0: "a", 1: "b"},
+		},
+		{
+			name: "add unnamed",
+			action: func(list *testUserList, router adapter.Router) error {
+				return list.AddUsers([]byte(`[{"password":"c"}]`))
+			},
+			err:   true,
+			users: map[int]string{0: "a", 1: "b"},
+		},
+		{
+			name: "remove",
+			action: func(list *testUserList, router adapter.Router) error {
+				return list.RemoveUsers([]string{"a"})
+			},
+			users: map[int]string{1: "b"},
+		},
+		{
+			name: "remove missing",
+			action: func(list *testUserList, router adapter.Router) error {
+				return list.RemoveUsers([]string{"a", "c"})
+			},
+			err:   true,
+			users: map[int]string{0: "a", 1: "b"},
+		},
+		{
+			name: "add removed",
+			action: func(list *testUserList, router adapter.Router) error {
+				err := list.RemoveUsers([]string{"a"})
+				if err != nil {
+					return err
+				}
+				return list.AddUsers([]byte(`[{"name":"a","password":"a"}]`))
+			},
+			users: map[int]string{1: "b", 2: "a"},
+		},
+		{
+			name: "restore",
+			stored: map[string][]byte{
+				"a": nil,
+				"b": []byte(`{"name":"b","password":"stored"}`),
+				"c": []byte(`{"name":"c","password":"c"}`),
+				"d": nil,
+			},
+			action: func(list *testUserList, router adapter.Router) error {
+				return list.restore(router, "test")
+			},
+			users: map[int]string{1: "b", 2: "c"},
+		},
+		{
+			name: "restore malformed",
+			stored: map[string][]byte{
+				"c": []byte(`{"name":`),
+			},
+			action: func(list *testUserList, router adapter.Router) error {
+				return list.restore(router, "test")
+			},
+			err:   true,
+			users: map[int]string{0: "a", 1: "b"},
+		},
+	}
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			router := &testUserRouter{clashServer: &testUserClashServer{cacheFile: &testUserCacheFile{users: testCase.stored}}}
+			var updated map[int]string
+			list := newUserList([]option.TrojanUser{
+				{Name: "a", Password: "a"},
+				{Name: "b", Password: "b"},
+			}, func(it option.TrojanUser) string {
+				return it.Name
+			}, func(it option.TrojanUser) option.UserLimitOptions {
+				return it.UserLimitOptions
+			}, func(indexes []int, users []option.TrojanUser) error {
+				updated = make(map[int]string)
+				for i, user := range users {
+					updated[indexes[i]] = user.Name
+				}
+				return nil
+			}, limiter.NewManager(router, log.NewNOPFactory().Logger(), "test", nil, nil))
+			err := testCase.action(list, router)
+			if testCase.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, testCase.users, updated)
+			}
+			for index := 0; index < 4; index++ {
+				user, loaded := list.Load(index)
+				name, active := testCase.users[index]
+				require.Equal(t, active, loaded, "index ", index)
+				if active {
+					// configured users are left as configured when restored
+					require.Equal(t, name, user.Name)
+					require.Equal(t, name, user.Password)
+				}
+			}
+			require.Len(t, list.Users(), len(testCase.users))
+		})
+	}
+}
+
+func newTestUserList(router adapter.Router) *userList[option.TrojanUser] {
+	return newUserList([]option.TrojanUser{
+		{Name: "a", Password: "a"},
+		{Name: "b", Password: "b", UserLimitOptions: option.UserLimitOptions{MaxConnections: 1}},
+	}, func(it option.TrojanUser) string {
+		return it.Name
+	}, func(it option.TrojanUser) option.UserLimitOptions {
+		return it.UserLimitOptions
+	}, func(indexes []int, users []option.TrojanUser) error {
+		return nil
+	}, limiter.NewManager(router, log.NewNOPFactory().Logger(), "test", nil, nil))
+}
+
+func TestUserListUsers(t *testing.T) {
+	t.Parallel()
+	router := &testUserRouter{clashServer: &testUserClashServer{cacheFile: &testUserCacheFile{}}}
+	list := newTestUserList(router)
+	require.NoError(t, list.AddUsers([]byte(`[{"name":"c","password":"c","quota":1024}]`)))
+	require.Equal(t, []adapter.UserInfo{
+		{Name: "a"},
+		{Name: "b", UserLimitOptions: option.UserLimitOptions{MaxConnections: 1}, Usage: &adapter.UserUsage{}},
+		{Name: "c", UserLimitOptions: option.UserLimitOptions{Quota: 1024}, Usage: &adapter.UserUsage{Quota: 1024}},
+	}, list.Users())
+}
+
+func TestUserListRemoveClosesConnections(t *testing.T) {
+	t.Parallel()
+	router := &testUserRouter{clashServer: &testUserClashServer{cacheFile: &testUserCacheFile{}}}
+	list := newTestUserList(router)
+	removedConn, removedPeer := net.Pipe()
+	defer removedPeer.Close()
+	keptConn, keptPeer := net.Pipe()
+	defer keptConn.Close()
+	defer keptPeer.Close()
+	require.True(t, list.addConn("a", removedConn))
+	require.True(t, list.addConn("b", keptConn))
+
+	require.NoError(t, list.RemoveUsers([]string{"a"}))
+	_, err := removedConn.Write([]byte{0})
+	require.ErrorIs(t, err, io.ErrClosedPipe)
+	go keptPeer.Read(make([]byte, 1))
+	_, err = keptConn.Write([]byte{0})
+	require.NoError(t, err)
+
+	newConn, newPeer := net.Pipe()
+	defer newConn.Close()
+	defer newPeer.Close()
+	require.False(t, list.addConn("a", newConn))
+	list.removeConn("a", removedConn)
+	list.removeConn("b", keptConn)
+	require.Empty(t, list.conns)
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/limiter"
 	"github.com/sagernet/sing-box/common/tls"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
@@ -24,14 +25,16 @@ import (
 )
 
 var (
-	_ adapter.Inbound           = (*VLESS)(nil)
-	_ adapter.InjectableInbound = (*VLESS)(nil)
+	_ adapter.Inbound            = (*VLESS)(nil)
+	_ adapter.InjectableInbound  = (*VLESS)(nil)
+	_ adapter.UserManagedInbound = (*VLESS)(nil)
 )
 
 type VLESS struct {
 	myInboundAdapter
+	*userList[option.VLESSUser]
 	ctx                      context.Context
-	users                    []option.VLESSUser
+	limiter                  *limiter.Manager
 	service                  *vless.Service[int]
 	tlsConfig                tls.ServerConfig
 	fallbackAddr             M.Socksaddr
@@ -50,8 +53,12 @@ func NewVLESS(ctx context.Context, router adapter.Router, logger log.ContextLogg
 			tag:           tag,
 			listenOptions: options.ListenOptions,
 		},
-		ctx:   ctx,
-		users: options.Users,
+		ctx: ctx,
+		limiter: limiter.NewManager(router, logger, tag, common.Map(options.Users, func(it option.VLESSUser) string {
+			return it.Name
+		}), common.Map(options.Users, func(it option.VLESSUser) option.UserLimitOptions {
+			return it.UserLimitOptions
+		})),
 	}
 	var err error
 	if options.TLS != nil {
@@ -85,11 +92,11 @@ func NewVLESS(ctx context.Context, router adapter.Router, logger log.ContextLogg
 		fallbackHandler = adapter.NewUpstreamContextHandler(inbound.fallbackConnection, nil, nil)
 	}
 	service := vless.NewService[int](logger, adapter.NewUpstreamContextHandler(inbound.newConnection, inbound.newPacketConnection, inbound), fallbackHandler)
-	service.UpdateUsers(common.MapIndexed(inbound.users, func(index int, _ option.VLESSUser) int {
+	service.UpdateUsers(common.MapIndexed(options.Users, func(index int, _ option.VLESSUser) int {
 		return index
-	}), common.Map(inbound.users, func(it option.VLESSUser) string {
+	}), common.Map(options.Users, func(it option.VLESSUser) string {
 		return it.UUID
-	}), common.Map(inbound.users, func(it option.VLESSUser) string {
+	}), common.Map(options.Users, func(it option.VLESSUser) string {
 		return it.Flow
 	}))
 	if options.Transport != nil {
@@ -99,12 +106,28 @@ func NewVLESS(ctx context.Context, router adapter.Router, logger log.ContextLogg
 		}
 	}
 	inbound.service = service
+	inbound.userList = newUserList(options.Users, func(it option.VLESSUser) string {
+		return it.Name
+	}, func(it option.VLESSUser) option.UserLimitOptions {
+		return it.UserLimitOptions
+	}, func(indexes []int, users []option.VLESSUser) error {
+		service.UpdateUsers(indexes, common.Map(users, func(it option.VLESSUser) string {
+			return it.UUID
+		}), common.Map(users, func(it option.VLESSUser) string {
+			return it.Flow
+		}))
+		return nil
+	}, inbound.limiter)
 	inbound.connHandler = inbound
 	return inbound, nil
 }
 
 func (h *VLESS) Start() error {
-	err := common.Start(
+	err := h.userList.restore(h.router, h.tag)
+	if err != nil {
+		return E.Cause(err, "restore users")
+	}
+	err = common.Start(
 		h.service,
 		h.tlsConfig,
 	)
@@ -175,13 +198,26 @@ func (h *VLESS) newConnection(ctx context.Context, conn net.Conn, metadata adapt
 	if !loaded {
 		return os.ErrInvalid
 	}
-	user := h.users[userIndex].Name
+	userOptions, loaded := h.userList.Load(userIndex)
+	if !loaded {
+		return os.ErrInvalid
+	}
+	user := userOptions.Name
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
 		metadata.User = user
 	}
 	h.logger.InfoContext(ctx, "[", user, "] inbound connection to ", metadata.Destination)
+	conn, err := h.limiter.NewConnection(user, conn)
+	if err != nil {
+		return E.Cause(err, "reject user ", user)
+	}
+	defer conn.Close()
+	if !h.userList.addConn(user, conn) {
+		return E.New("user removed: ", user)
+	}
+	defer h.userList.removeConn(user, conn)
 	return h.router.RouteConnection(ctx, conn, metadata)
 }
 
@@ -213,7 +249,11 @@ func (h *VLESS) newPacketConnection(ctx context.Context, conn N.PacketConn, meta
 	if !loaded {
 		return os.ErrInvalid
 	}
-	user := h.users[userIndex].Name
+	userOptions, loaded := h.userList.Load(userIndex)
+	if !loaded {
+		return os.ErrInvalid
+	}
+	user := userOptions.Name
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
@@ -226,6 +266,15 @@ func (h *VLESS) newPacketConnection(ctx context.Context, conn N.PacketConn, meta
 	} else {
 		h.logger.InfoContext(ctx, "[", user, "] inbound packet connection to ", metadata.Destination)
 	}
+	conn, err := h.limiter.NewPacketConnection(user, conn)
+	if err != nil {
+		return E.Cause(err, "reject user ", user)
+	}
+	defer conn.Close()
+	if !h.userList.addConn(user, conn) {
+		return E.New("user removed: ", user)
+	}
+	defer h.userList.removeConn(user, conn)
 	return h.router.RoutePacketConnection(ctx, conn, metadata)
 }
 
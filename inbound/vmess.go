@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/limiter"
 	"github.com/sagernet/sing-box/common/tls"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
@@ -23,15 +24,17 @@ import (
 )
 
 var (
-	_ adapter.Inbound           = (*VMess)(nil)
-	_ adapter.InjectableInbound = (*VMess)(nil)
+	_ adapter.Inbound            = (*VMess)(nil)
+	_ adapter.InjectableInbound  = (*VMess)(nil)
+	_ adapter.UserManagedInbound = (*VMess)(nil)
 )
 
 type VMess struct {
 	myInboundAdapter
+	*userList[option.VMessUser]
 	ctx       context.Context
 	service   *vmess.Service[int]
-	users     []option.VMessUser
+	limiter   *limiter.Manager
 	tlsConfig tls.ServerConfig
 	transport adapter.V2RayServerTransport
 }
@@ -47,8 +50,12 @@ func NewVMess(ctx context.Context, router adapter.Router, logger log.ContextLogg
 			tag:           tag,
 			listenOptions: options.ListenOptions,
 		},
-		ctx:   ctx,
-		users: options.Users,
+		ctx: ctx,
+		limiter: limiter.NewManager(router, logger, tag, common.Map(options.Users, func(it option.VMessUser) string {
+			return it.Name
+		}), common.Map(options.Users, func(it option.VMessUser) option.UserLimitOptions {
+			return it.UserLimitOptions
+		})),
 	}
 	var serviceOptions []vmess.ServiceOption
 	if timeFunc := router.TimeFunc(); timeFunc != nil {
@@ -59,6 +66,17 @@ func NewVMess(ctx context.Context, router adapter.Router, logger log.ContextLogg
 	}
 	service := vmess.NewService[int](adapter.NewUpstreamContextHandler(inbound.newConnection, inbound.newPacketConnection, inbound), serviceOptions...)
 	inbound.service = service
+	inbound.userList = newUserList(options.Users, func(it option.VMessUser) string {
+		return it.Name
+	}, func(it option.VMessUser) option.UserLimitOptions {
+		return it.UserLimitOptions
+	}, func(indexes []int, users []option.VMessUser) error {
+		return service.UpdateUsers(indexes, common.Map(users, func(it option.VMessUser) string {
+			return it.UUID
+		}), common.Map(users, func(it option.VMessUser) int {
+			return it.AlterId
+		}))
+	}, inbound.limiter)
 	err := service.UpdateUsers(common.MapIndexed(options.Users, func(index int, it option.VMessUser) int {
 		return index
 	}), common.Map(options.Users, func(it option.VMessUser) string {
@@ -86,7 +104,11 @@ func NewVMess(ctx context.Context, router adapter.Router, logger log.ContextLogg
 }
 
 func (h *VMess) Start() error {
-	err := common.Start(
+	err := h.userList.restore(h.router, h.tag)
+	if err != nil {
+		return E.Cause(err, "restore users")
+	}
+	err = common.Start(
 		h.service,
 		h.tlsConfig,
 	)
@@ -157,13 +179,26 @@ func (h *VMess) newConnection(ctx context.Context, conn net.Conn, metadata adapt
 	if !loaded {
 		return os.ErrInvalid
 	}
-	user := h.users[userIndex].Name
+	userOptions, loaded := h.userList.Load(userIndex)
+	if !loaded {
+		return os.ErrInvalid
+	}
+	user := userOptions.Name
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
 		metadata.User = user
 	}
 	h.logger.InfoContext(ctx, "[", user, "] inbound connection to ", metadata.Destination)
+	conn, err := h.limiter.NewConnection(user, conn)
+	if err != nil {
+		return E.Cause(err, "reject user ", user)
+	}
+	defer conn.Close()
+	if !h.userList.addConn(user, conn) {
+		return E.New("user removed: ", user)
+	}
+	defer h.userList.removeConn(user, conn)
 	return h.router.RouteConnection(ctx, conn, metadata)
 }
 
@@ -172,7 +207,11 @@ func (h *VMess) newPacketConnection(ctx context.Context, conn N.PacketConn, meta
 	if !loaded {
 		return os.ErrInvalid
 	}
-	user := h.users[userIndex].Name
+	userOptions, loaded := h.userList.Load(userIndex)
+	if !loaded {
+		return os.ErrInvalid
+	}
+	user := userOptions.Name
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
@@ -185,6 +224,15 @@ func (h *VMess) newPacketConnection(ctx context.Context, conn N.PacketConn, meta
 	} else {
 		h.logger.InfoContext(ctx, "[", user, "] inbound packet connection to ", metadata.Destination)
 	}
+	conn, err := h.limiter.NewPacketConnection(user, conn)
+	if err != nil {
+		return E.Cause(err, "reject user ", user)
+	}
+	defer conn.Close()
+	if !h.userList.addConn(user, conn) {
+		return E.New("user removed: ", user)
+	}
+	defer h.userList.removeConn(user, conn)
 	return h.router.RoutePacketConnection(ctx, conn, metadata)
 }
 
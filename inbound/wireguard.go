@@ -0,0 +1,222 @@
+//go:build with_wireguard
+
+package inbound
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/wireguard"
+	"github.com/sagernet/sing/common/buf"
+	"github.com/sagernet/sing/common/debug"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/wireguard-go/device"
+)
+
+var _ adapter.Inbound = (*WireGuard)(nil)
+
+type WireGuard struct {
+	myInboundAdapter
+	bind      *wireguard.ServerBind
+	device    *device.Device
+	tunDevice *wireguard.ServerDevice
+	peers     []wireGuardPeer
+}
+
+type wireGuardPeer struct {
+	name       string
+	allowedIPs []netip.Prefix
+}
+
+func NewWireGuard(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.WireGuardInboundOptions) (*WireGuard, error) {
+	inbound := &WireGuard{
+		myInboundAdapter: myInboundAdapter{
+			protocol:      C.TypeWireGuard,
+			network:       []string{N.NetworkUDP},
+			ctx:           ctx,
+			router:        router,
+			logger:        logger,
+			tag:           tag,
+			listenOptions: options.ListenOptions,
+		},
+	}
+	if len(options.Peers) == 0 {
+		return nil, E.New("missing peers")
+	}
+	var privateKey string
+	{
+		bytes, err := base64.StdEncoding.DecodeString(options.PrivateKey)
+		if err != nil {
+			return nil, E.Cause(err, "decode private key")
+		}
+		privateKey = hex.EncodeToString(bytes)
+	}
+	ipcConf := "private_key=" + privateKey
+	for i, peer := range options.Peers {
+		var peerPublicKey, preSharedKey string
+		{
+			bytes, err := base64.StdEncoding.DecodeString(peer.PublicKey)
+			if err != nil {
+				return nil, E.Cause(err, "decode public key for peer ", i)
+			}
+			peerPublicKey = hex.EncodeToString(bytes)
+		}
+		if peer.PreSharedKey != "" {
+			bytes, err := base64.StdEncoding.DecodeString(peer.PreSharedKey)
+			if err != nil {
+				return nil, E.Cause(err, "decode pre shared key for peer ", i)
+			}
+			preSharedKey = hex.EncodeToString(bytes)
+		}
+		ipcConf += "\npublic_key=" + peerPublicKey
+		if preSharedKey != "" {
+			ipcConf += "\npreshared_key=" + preSharedKey
+		}
+		if len(peer.AllowedIPs) == 0 {
+			return nil, E.New("missing allowed_ips for peer ", i)
+		}
+		peerName := peer.Name
+		if peerName == "" {
+			peerName = F.ToString(i)
+		}
+		allowedIPs := make([]netip.Prefix, 0, len(peer.AllowedIPs))
+		for _, allowedIP := range peer.AllowedIPs {
+			prefix, err := netip.ParsePrefix(allowedIP)
+			if err != nil {
+				return nil, E.Cause(err, "parse allowed_ips for peer ", i)
+			}
+			allowedIPs = append(allowedIPs, prefix.Masked())
+			ipcConf += "\nallowed_ip=" + prefix.Masked().String()
+		}
+		inbound.peers = append(inbound.peers, wireGuardPeer{
+			name:       peerName,
+			allowedIPs: allowedIPs,
+		})
+	}
+	mtu := options.MTU
+	if mtu == 0 {
+		mtu = 1408
+	}
+	var udpTimeout int64
+	if options.UDPTimeout != 0 {
+		udpTimeout = options.UDPTimeout
+	} else {
+		udpTimeout = int64(C.UDPTimeout.Seconds())
+	}
+	tunDevice, err := wireguard.NewServerDevice(ctx, mtu, udpTimeout, inbound, logger)
+	if err != nil {
+		return nil, E.Cause(err, "create WireGuard device")
+	}
+	inbound.bind = wireguard.NewServerBind((*wireGuardPacketWriter)(&inbound.myInboundAdapter))
+	wgDevice := device.NewDevice(tunDevice, inbound.bind, &device.Logger{
+		Verbosef: func(format string, args ...interface{}) {
+			logger.Debug(fmt.Sprintf(strings.ToLower(format), args...))
+		},
+		Errorf: func(format string, args ...interface{}) {
+			logger.Error(fmt.Sprintf(strings.ToLower(format), args...))
+		},
+	}, options.Workers)
+	if debug.Enabled {
+		logger.Trace("created wireguard ipc conf: \n", ipcConf)
+	}
+	err = wgDevice.IpcSet(ipcConf)
+	if err != nil {
+		return nil, E.Cause(err, "setup wireguard")
+	}
+	inbound.packetHandler = inbound
+	inbound.packetUpstream = inbound.bind
+	inbound.device = wgDevice
+	inbound.tunDevice = tunDevice
+	return inbound, nil
+}
+
+func (w *WireGuard) Start() error {
+	err := w.myInboundAdapter.Start()
+	if err != nil {
+		return err
+	}
+	return w.tunDevice.Start()
+}
+
+func (w *WireGuard) Close() error {
+	err := w.myInboundAdapter.Close()
+	w.bind.Abort()
+	w.device.Close()
+	return err
+}
+
+func (w *WireGuard) NewPacket(ctx context.Context, conn N.PacketConn, buffer *buf.Buffer, metadata adapter.InboundContext) error {
+	return w.bind.WritePacket(buffer, metadata.Source)
+}
+
+// peerName returns the name of the peer whose allowed IPs contain the address,
+// preferring the most specific prefix as WireGuard does.
+func (w *WireGuard) peerName(addr netip.Addr) string {
+	var name string
+	bits := -1
+	for _, peer := range w.peers {
+		for _, prefix := range peer.allowedIPs {
+			if prefix.Bits() > bits && prefix.Contains(addr) {
+				name = peer.name
+				bits = prefix.Bits()
+			}
+		}
+	}
+	return name
+}
+
+func (w *WireGuard) NewConnection(ctx context.Context, conn net.Conn, upstreamMetadata M.Metadata) error {
+	ctx = log.ContextWithNewID(ctx)
+	var metadata adapter.InboundContext
+	metadata.Inbound = w.tag
+	metadata.InboundType = C.TypeWireGuard
+	metadata.InboundOptions = w.listenOptions.InboundOptions
+	metadata.Source = upstreamMetadata.Source
+	metadata.Destination = upstreamMetadata.Destination
+	metadata.User = w.peerName(metadata.Source.Addr)
+	w.logger.InfoContext(ctx, "[", metadata.User, "] inbound connection from ", metadata.Source)
+	w.logger.InfoContext(ctx, "[", metadata.User, "] inbound connection to ", metadata.Destination)
+	err := w.router.RouteConnection(ctx, conn, metadata)
+	if err != nil {
+		w.NewError(ctx, err)
+	}
+	return nil
+}
+
+func (w *WireGuard) NewPacketConnection(ctx context.Context, conn N.PacketConn, upstreamMetadata M.Metadata) error {
+	ctx = log.ContextWithNewID(ctx)
+	var metadata adapter.InboundContext
+	metadata.Inbound = w.tag
+	metadata.InboundType = C.TypeWireGuard
+	metadata.InboundOptions = w.listenOptions.InboundOptions
+	metadata.Source = upstreamMetadata.Source
+	metadata.Destination = upstreamMetadata.Destination
+	metadata.User = w.peerName(metadata.Source.Addr)
+	w.logger.InfoContext(ctx, "[", metadata.User, "] inbound packet connection from ", metadata.Source)
+	w.logger.InfoContext(ctx, "[", metadata.User, "] inbound packet connection to ", metadata.Destination)
+	err := w.router.RoutePacketConnection(ctx, conn, metadata)
+	if err != nil {
+		w.NewError(ctx, err)
+	}
+	return nil
+}
+
+// wireGuardPacketWriter writes handshake and transport packets back to peers
+// synchronously, as the bind expects the buffer to be consumed on return.
+type wireGuardPacketWriter myInboundAdapter
+
+func (w *wireGuardPacketWriter) WritePacket(buffer *buf.Buffer, destination M.Socksaddr) error {
+	return (*myInboundAdapter)(w).writePacket(buffer, destination)
+}
@@ -0,0 +1,34 @@
+//go:build with_wireguard
+
+package inbound
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWireGuardPeerName(t *testing.T) {
+	t.Parallel()
+	inbound := &WireGuard{
+		peers: []wireGuardPeer{
+			{"site", []netip.Prefix{netip.MustParsePrefix("10.0.0.0/16"), netip.MustParsePrefix("fd00::/64")}},
+			{"laptop", []netip.Prefix{netip.MustParsePrefix("10.0.1.2/32")}},
+			{"office", []netip.Prefix{netip.MustParsePrefix("10.0.1.0/24")}},
+		},
+	}
+	testCases := []struct {
+		addr string
+		name string
+	}{
+		{"10.0.0.1", "site"},
+		{"10.0.1.1", "office"},
+		{"10.0.1.2", "laptop"},
+		{"fd00::2", "site"},
+		{"10.1.0.1", ""},
+	}
+	for _, testCase := range testCases {
+		require.Equal(t, testCase.name, inbound.peerName(netip.MustParseAddr(testCase.addr)), testCase.addr)
+	}
+}
@@ -13,21 +13,25 @@ import (
 var _ Factory = (*simpleFactory)(nil)
 
 type simpleFactory struct {
-	formatter         Formatter
+	outputs           []Output
 	platformFormatter Formatter
-	writer            io.Writer
 	platformWriter    io.Writer
 	level             Level
 }
 
 func NewFactory(formatter Formatter, writer io.Writer, platformWriter io.Writer) Factory {
+	return NewOutputFactory(formatter.BaseTime, []Output{{LevelTrace, formatter, writer}}, platformWriter)
+}
+
+// NewOutputFactory creates a factory writing to multiple outputs, each filtered
+// by its own level after the level of the factory.
+func NewOutputFactory(baseTime time.Time, outputs []Output, platformWriter io.Writer) Factory {
 	return &simpleFactory{
-		formatter: formatter,
+		outputs: outputs,
 		platformFormatter: Formatter{
-			BaseTime:      formatter.BaseTime,
+			BaseTime:      baseTime,
 			DisableColors: C.IsDarwin || C.IsIos,
 		},
-		writer:         writer,
 		platformWriter: platformWriter,
 		level:          LevelTrace,
 	}
@@ -66,16 +70,16 @@ func (l *simpleLogger) Log(ctx context.Context, level Level, args []any) {
 		return
 	}
 	nowTime := time.Now()
-	message := l.formatter.Format(ctx, level, l.tag, F.ToString(args...), nowTime)
+	message := F.ToString(args...)
 	if level == LevelPanic {
-		panic(message)
+		panic(formatPanic(l.outputs, l.platformFormatter, ctx, l.tag, message, nowTime))
 	}
-	l.writer.Write([]byte(message))
+	writeOutputs(l.outputs, ctx, level, l.tag, message, nowTime)
 	if level == LevelFatal {
 		os.Exit(1)
 	}
 	if l.platformWriter != nil {
-		l.platformWriter.Write([]byte(l.platformFormatter.Format(ctx, level, l.tag, F.ToString(args...), nowTime)))
+		l.platformWriter.Write([]byte(l.platformFormatter.Format(ctx, level, l.tag, message, nowTime)))
 	}
 }
 
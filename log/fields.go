@@ -0,0 +1,28 @@
+package log
+
+import "context"
+
+type Field struct {
+	Key   string
+	Value string
+}
+
+// FieldsProvider provides fields of structured log entries, such as the inbound
+// and outbound of a connection, which may change after being added to the context.
+type FieldsProvider interface {
+	LogFields() []Field
+}
+
+type fieldsKey struct{}
+
+func ContextWithFields(ctx context.Context, provider FieldsProvider) context.Context {
+	return context.WithValue(ctx, (*fieldsKey)(nil), provider)
+}
+
+func FieldsFromContext(ctx context.Context) []Field {
+	provider, loaded := ctx.Value((*fieldsKey)(nil)).(FieldsProvider)
+	if !loaded {
+		return nil
+	}
+	return provider.LogFields()
+}
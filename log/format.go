@@ -82,70 +82,18 @@ func (f Formatter) Format(ctx context.Context, level Level, tag string, message
 	return message
 }
 
-func (f Formatter) FormatWithSimple(ctx context.Context, level Level, tag string, message string, timestamp time.Time) (string, string) {
-	levelString := strings.ToUpper(FormatLevel(level))
-	if !f.DisableColors {
-		switch level {
-		case LevelDebug, LevelTrace:
-			levelString = aurora.White(levelString).String()
-		case LevelInfo:
-			levelString = aurora.Cyan(levelString).String()
-		case LevelWarn:
-			levelString = aurora.Yellow(levelString).String()
-		case LevelError, LevelFatal, LevelPanic:
-			levelString = aurora.Red(levelString).String()
-		}
-	}
+// FormatSimple formats the message for log subscribers, with the tag and
+// connection ID but without level, time and colors.
+func FormatSimple(ctx context.Context, tag string, message string) string {
 	if tag != "" {
 		message = tag + ": " + message
 	}
-	messageSimple := message
-	var id ID
-	var hasId bool
 	if ctx != nil {
-		id, hasId = IDFromContext(ctx)
-	}
-	if hasId {
-		activeDuration := formatDuration(time.Since(id.CreatedAt))
-		if !f.DisableColors {
-			var color aurora.Color
-			color = aurora.Color(uint8(id.ID))
-			color %= 215
-			row := uint(color / 36)
-			column := uint(color % 36)
-
-			var r, g, b float32
-			r = float32(row * 51)
-			g = float32(column / 6 * 51)
-			b = float32((column % 6) * 51)
-			luma := 0.2126*r + 0.7152*g + 0.0722*b
-			if luma < 60 {
-				row = 5 - row
-				column = 35 - column
-				color = aurora.Color(row*36 + column)
-			}
-			color += 16
-			color = color << 16
-			color |= 1 << 14
-			message = F.ToString("[", aurora.Colorize(id.ID, color).String(), " ", activeDuration, "] ", message)
-		} else {
-			message = F.ToString("[", id.ID, " ", activeDuration, "] ", message)
+		if id, hasId := IDFromContext(ctx); hasId {
+			message = F.ToString("[", id.ID, " ", formatDuration(time.Since(id.CreatedAt)), "] ", message)
 		}
-		messageSimple = F.ToString("[", id.ID, " ", activeDuration, "] ", messageSimple)
-
 	}
-	switch {
-	case f.DisableTimestamp:
-		message = levelString + " " + message
-	case f.FullTimestamp:
-		message = timestamp.Format(f.TimestampFormat) + " " + levelString + " " + message
-	default:
-		message = levelString + "[" + xd(int(timestamp.Sub(f.BaseTime)/time.Second), 4) + "] " + message
-	}
-	if message[len(message)-1] != '\n' {
-		message += "\n"
-	}
-	return message, messageSimple
+	return message
 }
 
 func xd(value int, x int) string {
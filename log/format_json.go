@@ -0,0 +1,50 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+var _ EntryFormatter = JSONFormatter{}
+
+// JSONFormatter formats log entries as JSON objects, one per line, with the
+// connection ID and fields from the context.
+type JSONFormatter struct{}
+
+func (f JSONFormatter) Format(ctx context.Context, level Level, tag string, message string, timestamp time.Time) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(`{"time":`)
+	writeJSONString(&buffer, timestamp.Format(time.RFC3339Nano))
+	buffer.WriteString(`,"level":`)
+	writeJSONString(&buffer, FormatLevel(level))
+	if tag != "" {
+		buffer.WriteString(`,"tag":`)
+		writeJSONString(&buffer, tag)
+	}
+	if ctx != nil {
+		if id, hasId := IDFromContext(ctx); hasId {
+			buffer.WriteString(`,"id":`)
+			buffer.WriteString(strconv.FormatUint(uint64(id.ID), 10))
+			buffer.WriteString(`,"elapsed_ms":`)
+			buffer.WriteString(strconv.FormatInt(time.Since(id.CreatedAt).Milliseconds(), 10))
+		}
+		for _, field := range FieldsFromContext(ctx) {
+			buffer.WriteByte(',')
+			writeJSONString(&buffer, field.Key)
+			buffer.WriteByte(':')
+			writeJSONString(&buffer, field.Value)
+		}
+	}
+	buffer.WriteString(`,"message":`)
+	writeJSONString(&buffer, message)
+	buffer.WriteString("}\n")
+	return buffer.String()
+}
+
+func writeJSONString(buffer *bytes.Buffer, value string) {
+	content, _ := json.Marshal(value)
+	buffer.Write(content)
+}
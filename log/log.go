@@ -5,33 +5,32 @@ import (
 	"os"
 	"time"
 
+	"github.com/sagernet/sing-box/common/rotatefile"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing/common"
 	E "github.com/sagernet/sing/common/exceptions"
 )
 
-type factoryWithFile struct {
+type factoryWithClosers struct {
 	Factory
-	file *os.File
+	closers []any
 }
 
-func (f *factoryWithFile) Close() error {
+func (f *factoryWithClosers) Close() error {
 	return common.Close(
-		f.Factory,
-		common.PtrOrNil(f.file),
+		append([]any{f.Factory}, f.closers...)...,
 	)
 }
 
-type observableFactoryWithFile struct {
+type observableFactoryWithClosers struct {
 	ObservableFactory
-	file *os.File
+	closers []any
 }
 
-func (f *observableFactoryWithFile) Close() error {
+func (f *observableFactoryWithClosers) Close() error {
 	return common.Close(
-		f.ObservableFactory,
-		common.PtrOrNil(f.file),
+		append([]any{f.ObservableFactory}, f.closers...)...,
 	)
 }
 
@@ -50,61 +49,146 @@ func New(options Options) (Factory, error) {
 		return NewNOPFactory(), nil
 	}
 
-	var logFile *os.File
-	var logWriter io.Writer
-
-	switch logOptions.Output {
-	case "":
-		logWriter = options.DefaultWriter
-		if logWriter == nil {
-			logWriter = os.Stderr
-		}
-	case "stderr":
-		logWriter = os.Stderr
-	case "stdout":
-		logWriter = os.Stdout
-	default:
+	logLevel := LevelTrace
+	if logOptions.Level != "" {
 		var err error
-		logFile, err = os.OpenFile(C.BasePath(logOptions.Output), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		logLevel, err = ParseLevel(logOptions.Level)
 		if err != nil {
-			return nil, err
+			return nil, E.Cause(err, "parse log level")
 		}
-		logWriter = logFile
-	}
-	logFormatter := Formatter{
-		BaseTime:         options.BaseTime,
-		DisableColors:    logOptions.DisableColor || logFile != nil,
-		DisableTimestamp: !logOptions.Timestamp && logFile != nil,
-		FullTimestamp:    logOptions.Timestamp,
-		TimestampFormat:  "-0700 2006-01-02 15:04:05",
 	}
-	var factory Factory
-	if options.Observable {
-		factory = NewObservableFactory(logFormatter, logWriter, options.PlatformWriter)
-	} else {
-		factory = NewFactory(logFormatter, logWriter, options.PlatformWriter)
+	var outputOptions []option.LogOutputOptions
+	if len(logOptions.Outputs) == 0 || logOptions.Output != "" {
+		legacyOptions := option.LogOutputOptions{
+			Type:       logOptions.Output,
+			MaxSize:    logOptions.MaxSize,
+			MaxBackups: logOptions.MaxBackups,
+		}
+		switch logOptions.Output {
+		case "", "stderr", "stdout":
+		default:
+			legacyOptions.Type = "file"
+			legacyOptions.Path = logOptions.Output
+		}
+		outputOptions = append(outputOptions, legacyOptions)
 	}
-	if logOptions.Level != "" {
-		logLevel, err := ParseLevel(logOptions.Level)
+	outputOptions = append(outputOptions, logOptions.Outputs...)
+
+	var (
+		outputs []Output
+		closers []any
+	)
+	factoryLevel := LevelPanic
+	for i, outputOption := range outputOptions {
+		output, closer, err := newOutput(options, outputOption, logLevel)
 		if err != nil {
-			return nil, E.Cause(err, "parse log level")
+			common.Close(closers...)
+			return nil, E.Cause(err, "initialize log output[", i, "]")
 		}
-		factory.SetLevel(logLevel)
+		outputs = append(outputs, output)
+		if closer != nil {
+			closers = append(closers, closer)
+		}
+		if output.Level > factoryLevel {
+			factoryLevel = output.Level
+		}
+	}
+	var factory Factory
+	if options.Observable {
+		factory = NewObservableOutputFactory(options.BaseTime, outputs, options.PlatformWriter)
 	} else {
-		factory.SetLevel(LevelTrace)
+		factory = NewOutputFactory(options.BaseTime, outputs, options.PlatformWriter)
 	}
-	if logFile != nil {
+	factory.SetLevel(factoryLevel)
+	if len(closers) > 0 {
 		if options.Observable {
-			factory = &observableFactoryWithFile{
+			factory = &observableFactoryWithClosers{
 				ObservableFactory: factory.(ObservableFactory),
-				file:              logFile,
+				closers:           closers,
 			}
 		} else {
-			factory = &factoryWithFile{
+			factory = &factoryWithClosers{
 				Factory: factory,
-				file:    logFile,
+				closers: closers,
 			}
 		}
 	}
 	return factory, nil
 }
+
+func newOutput(options Options, outputOptions option.LogOutputOptions, defaultLevel Level) (Output, io.Closer, error) {
+	logOptions := options.Options
+	output := Output{
+		Level: defaultLevel,
+	}
+	if outputOptions.Level != "" {
+		var err error
+		output.Level, err = ParseLevel(outputOptions.Level)
+		if err != nil {
+			return Output{}, nil, E.Cause(err, "parse log level")
+		}
+	}
+	var closer io.Closer
+	var isTerminal bool
+	switch outputOptions.Type {
+	case "":
+		output.Writer = options.DefaultWriter
+		if output.Writer == nil {
+			output.Writer = os.Stderr
+		}
+		isTerminal = true
+	case "stderr":
+		output.Writer = os.Stderr
+		isTerminal = true
+	case "stdout":
+		output.Writer = os.Stdout
+		isTerminal = true
+	case "file":
+		if outputOptions.Path == "" {
+			return Output{}, nil, E.New("missing path")
+		}
+		if outputOptions.MaxSize < 0 || outputOptions.MaxBackups < 0 {
+			return Output{}, nil, E.New("invalid max_size or max_backups")
+		}
+		file, err := rotatefile.Open(C.BasePath(outputOptions.Path), int64(outputOptions.MaxSize)*1024*1024, outputOptions.MaxBackups, nil)
+		if err != nil {
+			return Output{}, nil, err
+		}
+		output.Writer = file
+		closer = file
+	case "syslog":
+		writer, err := newSyslogWriter(outputOptions.Path)
+		if err != nil {
+			return Output{}, nil, err
+		}
+		output.Writer = writer
+		closer = writer
+	default:
+		return Output{}, nil, E.New("unknown log output type: ", outputOptions.Type)
+	}
+	format := outputOptions.Format
+	if format == "" {
+		format = logOptions.Format
+	}
+	timestamp := outputOptions.Timestamp || logOptions.Timestamp
+	isSyslog := outputOptions.Type == "syslog"
+	switch format {
+	case "", "text":
+		output.Formatter = Formatter{
+			BaseTime:         options.BaseTime,
+			DisableColors:    logOptions.DisableColor || !isTerminal,
+			DisableTimestamp: !timestamp && !isTerminal || isSyslog,
+			FullTimestamp:    timestamp,
+			TimestampFormat:  "-0700 2006-01-02 15:04:05",
+		}
+	case "json":
+		output.Formatter = JSONFormatter{}
+	default:
+		common.Close(closer)
+		return Output{}, nil, E.New("unknown log format: ", format)
+	}
+	if isSyslog {
+		output.Formatter = newSyslogFormatter(output.Formatter, outputOptions.Tag)
+	}
+	return output, closer, nil
+}
@@ -15,9 +15,8 @@ import (
 var _ Factory = (*observableFactory)(nil)
 
 type observableFactory struct {
-	formatter         Formatter
+	outputs           []Output
 	platformFormatter Formatter
-	writer            io.Writer
 	platformWriter    io.Writer
 	level             Level
 	subscriber        *observable.Subscriber[Entry]
@@ -25,13 +24,16 @@ type observableFactory struct {
 }
 
 func NewObservableFactory(formatter Formatter, writer io.Writer, platformWriter io.Writer) ObservableFactory {
+	return NewObservableOutputFactory(formatter.BaseTime, []Output{{LevelTrace, formatter, writer}}, platformWriter)
+}
+
+func NewObservableOutputFactory(baseTime time.Time, outputs []Output, platformWriter io.Writer) ObservableFactory {
 	factory := &observableFactory{
-		formatter: formatter,
+		outputs: outputs,
 		platformFormatter: Formatter{
-			BaseTime:      formatter.BaseTime,
+			BaseTime:      baseTime,
 			DisableColors: C.IsDarwin || C.IsIos,
 		},
-		writer:         writer,
 		platformWriter: platformWriter,
 		level:          LevelTrace,
 		subscriber:     observable.NewSubscriber[Entry](128),
@@ -83,17 +85,17 @@ func (l *observableLogger) Log(ctx context.Context, level Level, args []any) {
 		return
 	}
 	nowTime := time.Now()
-	message, messageSimple := l.formatter.FormatWithSimple(ctx, level, l.tag, F.ToString(args...), nowTime)
+	message := F.ToString(args...)
 	if level == LevelPanic {
-		panic(message)
+		panic(formatPanic(l.outputs, l.platformFormatter, ctx, l.tag, message, nowTime))
 	}
-	l.writer.Write([]byte(message))
+	writeOutputs(l.outputs, ctx, level, l.tag, message, nowTime)
 	if level == LevelFatal {
 		os.Exit(1)
 	}
-	l.subscriber.Emit(Entry{level, messageSimple})
+	l.subscriber.Emit(Entry{level, FormatSimple(ctx, l.tag, message)})
 	if l.platformWriter != nil {
-		l.platformWriter.Write([]byte(l.platformFormatter.Format(ctx, level, l.tag, F.ToString(args...), nowTime)))
+		l.platformWriter.Write([]byte(l.platformFormatter.Format(ctx, level, l.tag, message, nowTime)))
 	}
 }
 
@@ -0,0 +1,36 @@
+package log
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// EntryFormatter formats log entries of an output.
+type EntryFormatter interface {
+	Format(ctx context.Context, level Level, tag string, message string, timestamp time.Time) string
+}
+
+// Output writes log entries up to its level.
+type Output struct {
+	Level     Level
+	Formatter EntryFormatter
+	Writer    io.Writer
+}
+
+func writeOutputs(outputs []Output, ctx context.Context, level Level, tag string, message string, timestamp time.Time) {
+	for _, output := range outputs {
+		if level > output.Level {
+			continue
+		}
+		output.Writer.Write([]byte(output.Formatter.Format(ctx, level, tag, message, timestamp)))
+	}
+}
+
+func formatPanic(outputs []Output, defaultFormatter EntryFormatter, ctx context.Context, tag string, message string, timestamp time.Time) string {
+	formatter := defaultFormatter
+	if len(outputs) > 0 {
+		formatter = outputs[0].Formatter
+	}
+	return formatter.Format(ctx, LevelPanic, tag, message, timestamp)
+}
@@ -0,0 +1,126 @@
+package log
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+var _ EntryFormatter = (*syslogFormatter)(nil)
+
+// syslogFormatter wraps entries in the local syslog format, as written to
+// the Unix socket of the syslog daemon.
+type syslogFormatter struct {
+	formatter EntryFormatter
+	prefix    string
+}
+
+func newSyslogFormatter(formatter EntryFormatter, tag string) *syslogFormatter {
+	if tag == "" {
+		tag = "sing-box"
+	}
+	return &syslogFormatter{
+		formatter: formatter,
+		prefix:    " " + tag + "[" + strconv.Itoa(os.Getpid()) + "]: ",
+	}
+}
+
+func (f *syslogFormatter) Format(ctx context.Context, level Level, tag string, message string, timestamp time.Time) string {
+	message = f.formatter.Format(ctx, level, tag, message, timestamp)
+	if message == "" || message[len(message)-1] != '\n' {
+		message += "\n"
+	}
+	return "<" + strconv.Itoa(syslogFacilityDaemon|syslogSeverity(level)) + ">" + timestamp.Format(time.Stamp) + f.prefix + message
+}
+
+const syslogFacilityDaemon = 3 << 3
+
+func syslogSeverity(level Level) int {
+	switch level {
+	case LevelPanic:
+		return 1
+	case LevelFatal:
+		return 2
+	case LevelError:
+		return 3
+	case LevelWarn:
+		return 4
+	case LevelInfo:
+		return 6
+	default:
+		return 7
+	}
+}
+
+var defaultSyslogPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// syslogWriter writes to the local syslog daemon, reconnecting if the
+// connection is lost.
+type syslogWriter struct {
+	access sync.Mutex
+	path   string
+	conn   net.Conn
+}
+
+func newSyslogWriter(path string) (*syslogWriter, error) {
+	writer := &syslogWriter{path: path}
+	err := writer.connect()
+	if err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (w *syslogWriter) connect() error {
+	paths := defaultSyslogPaths
+	if w.path != "" {
+		paths = []string{w.path}
+	}
+	for _, path := range paths {
+		for _, network := range []string{"unixgram", "unix"} {
+			conn, err := net.Dial(network, path)
+			if err == nil {
+				w.conn = conn
+				return nil
+			}
+		}
+	}
+	if w.path != "" {
+		return E.New("unable to connect to syslog: ", w.path)
+	}
+	return E.New("unable to connect to local syslog")
+}
+
+func (w *syslogWriter) Write(p []byte) (n int, err error) {
+	w.access.Lock()
+	defer w.access.Unlock()
+	if w.conn != nil {
+		n, err = w.conn.Write(p)
+		if err == nil {
+			return
+		}
+		w.conn.Close()
+		w.conn = nil
+	}
+	err = w.connect()
+	if err != nil {
+		return
+	}
+	return w.conn.Write(p)
+}
+
+func (w *syslogWriter) Close() error {
+	w.access.Lock()
+	defer w.access.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
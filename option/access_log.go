@@ -0,0 +1,8 @@
+package option
+
+type AccessLogOptions struct {
+	Path       string `json:"path,omitempty"`
+	Format     string `json:"format,omitempty"`
+	MaxSize    int    `json:"max_size,omitempty"`
+	MaxBackups int    `json:"max_backups,omitempty"`
+}
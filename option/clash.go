@@ -9,17 +9,52 @@ type ClashAPIOptions struct {
 	DefaultMode              string `json:"default_mode,omitempty"`
 	StoreSelected            bool   `json:"store_selected,omitempty"`
 	StoreFakeIP              bool   `json:"store_fakeip,omitempty"`
+	StoreDNS                 bool   `json:"store_dns,omitempty"`
+	StoreUsers               bool   `json:"store_users,omitempty"`
+	StoreQuota               bool   `json:"store_quota,omitempty"`
 	CacheFile                string `json:"cache_file,omitempty"`
+	ClosedConnections        int    `json:"closed_connections,omitempty"`
 }
 
 type SelectorOutboundOptions struct {
-	Outbounds []string `json:"outbounds"`
-	Default   string   `json:"default,omitempty"`
+	Outbounds []string                  `json:"outbounds"`
+	Providers Listable[string]          `json:"providers,omitempty"`
+	Default   string                    `json:"default,omitempty"`
+	Schedule  []SelectorScheduleOptions `json:"schedule,omitempty"`
+}
+
+type SelectorScheduleOptions struct {
+	TimeRange Listable[string] `json:"time_range,omitempty"`
+	Weekday   Listable[string] `json:"weekday,omitempty"`
+	Timezone  string           `json:"timezone,omitempty"`
+	Outbound  string           `json:"outbound"`
 }
 
 type URLTestOutboundOptions struct {
+	Outbounds []string         `json:"outbounds"`
+	Providers Listable[string] `json:"providers,omitempty"`
+	URL       string           `json:"url,omitempty"`
+	Interval  Duration         `json:"interval,omitempty"`
+	Tolerance uint16           `json:"tolerance,omitempty"`
+}
+
+type LoadBalanceOutboundOptions struct {
+	Outbounds      []string         `json:"outbounds"`
+	Providers      Listable[string] `json:"providers,omitempty"`
+	URL            string           `json:"url,omitempty"`
+	Interval       Duration         `json:"interval,omitempty"`
+	Strategy       string           `json:"strategy,omitempty"`
+	HashFullDomain bool             `json:"hash_full_domain,omitempty"`
+}
+
+type FallbackOutboundOptions struct {
+	Outbounds []string         `json:"outbounds"`
+	Providers Listable[string] `json:"providers,omitempty"`
+	URL       string           `json:"url,omitempty"`
+	Interval  Duration         `json:"interval,omitempty"`
+	Timeout   Duration         `json:"timeout,omitempty"`
+}
+
+type ChainOutboundOptions struct {
 	Outbounds []string `json:"outbounds"`
-	URL       string   `json:"url,omitempty"`
-	Interval  Duration `json:"interval,omitempty"`
-	Tolerance uint16   `json:"tolerance,omitempty"`
 }
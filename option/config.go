@@ -9,14 +9,15 @@ import (
 )
 
 type _Options struct {
-	Schema       string               `json:"$schema,omitempty"`
-	Log          *LogOptions          `json:"log,omitempty"`
-	DNS          *DNSOptions          `json:"dns,omitempty"`
-	NTP          *NTPOptions          `json:"ntp,omitempty"`
-	Inbounds     []Inbound            `json:"inbounds,omitempty"`
-	Outbounds    []Outbound           `json:"outbounds,omitempty"`
-	Route        *RouteOptions        `json:"route,omitempty"`
-	Experimental *ExperimentalOptions `json:"experimental,omitempty"`
+	Schema            string               `json:"$schema,omitempty"`
+	Log               *LogOptions          `json:"log,omitempty"`
+	DNS               *DNSOptions          `json:"dns,omitempty"`
+	NTP               *NTPOptions          `json:"ntp,omitempty"`
+	Inbounds          []Inbound            `json:"inbounds,omitempty"`
+	Outbounds         []Outbound           `json:"outbounds,omitempty"`
+	OutboundProviders []OutboundProvider   `json:"outbound_providers,omitempty"`
+	Route             *RouteOptions        `json:"route,omitempty"`
+	Experimental      *ExperimentalOptions `json:"experimental,omitempty"`
 }
 
 type Options _Options
@@ -38,9 +39,24 @@ func (o *Options) UnmarshalJSON(content []byte) error {
 }
 
 type LogOptions struct {
-	Disabled     bool   `json:"disabled,omitempty"`
-	Level        string `json:"level,omitempty"`
-	Output       string `json:"output,omitempty"`
-	Timestamp    bool   `json:"timestamp,omitempty"`
-	DisableColor bool   `json:"-"`
+	Disabled     bool               `json:"disabled,omitempty"`
+	Level        string             `json:"level,omitempty"`
+	Format       string             `json:"format,omitempty"`
+	Output       string             `json:"output,omitempty"`
+	MaxSize      int                `json:"max_size,omitempty"`
+	MaxBackups   int                `json:"max_backups,omitempty"`
+	Timestamp    bool               `json:"timestamp,omitempty"`
+	Outputs      []LogOutputOptions `json:"outputs,omitempty"`
+	DisableColor bool               `json:"-"`
+}
+
+type LogOutputOptions struct {
+	Type       string `json:"type"`
+	Level      string `json:"level,omitempty"`
+	Format     string `json:"format,omitempty"`
+	Timestamp  bool   `json:"timestamp,omitempty"`
+	Path       string `json:"path,omitempty"`
+	MaxSize    int    `json:"max_size,omitempty"`
+	MaxBackups int    `json:"max_backups,omitempty"`
+	Tag        string `json:"tag,omitempty"`
 }
@@ -3,6 +3,7 @@ package option
 type DNSOptions struct {
 	Servers        []DNSServerOptions `json:"servers,omitempty"`
 	Rules          []DNSRule          `json:"rules,omitempty"`
+	ResponseRules  []DNSResponseRule  `json:"response_rules,omitempty"`
 	Final          string             `json:"final,omitempty"`
 	ReverseMapping bool               `json:"reverse_mapping,omitempty"`
 	FakeIP         *DNSFakeIPOptions  `json:"fakeip,omitempty"`
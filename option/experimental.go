@@ -1,7 +1,9 @@
 package option
 
 type ExperimentalOptions struct {
-	ClashAPI *ClashAPIOptions `json:"clash_api,omitempty"`
-	V2RayAPI *V2RayAPIOptions `json:"v2ray_api,omitempty"`
-	Debug    *DebugOptions    `json:"debug,omitempty"`
+	ClashAPI  *ClashAPIOptions  `json:"clash_api,omitempty"`
+	V2RayAPI  *V2RayAPIOptions  `json:"v2ray_api,omitempty"`
+	Metrics   *MetricsOptions   `json:"metrics,omitempty"`
+	AccessLog *AccessLogOptions `json:"access_log,omitempty"`
+	Debug     *DebugOptions     `json:"debug,omitempty"`
 }
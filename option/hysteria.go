@@ -19,6 +19,7 @@ type HysteriaUser struct {
 	Name       string `json:"name,omitempty"`
 	Auth       []byte `json:"auth,omitempty"`
 	AuthString string `json:"auth_str,omitempty"`
+	UserLimitOptions
 }
 
 type HysteriaOutboundOptions struct {
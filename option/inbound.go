@@ -7,22 +7,27 @@ import (
 )
 
 type _Inbound struct {
-	Type               string                    `json:"type"`
-	Tag                string                    `json:"tag,omitempty"`
-	TunOptions         TunInboundOptions         `json:"-"`
-	RedirectOptions    RedirectInboundOptions    `json:"-"`
-	TProxyOptions      TProxyInboundOptions      `json:"-"`
-	DirectOptions      DirectInboundOptions      `json:"-"`
-	SocksOptions       SocksInboundOptions       `json:"-"`
-	HTTPOptions        HTTPMixedInboundOptions   `json:"-"`
-	MixedOptions       HTTPMixedInboundOptions   `json:"-"`
-	ShadowsocksOptions ShadowsocksInboundOptions `json:"-"`
-	VMessOptions       VMessInboundOptions       `json:"-"`
-	TrojanOptions      TrojanInboundOptions      `json:"-"`
-	NaiveOptions       NaiveInboundOptions       `json:"-"`
-	HysteriaOptions    HysteriaInboundOptions    `json:"-"`
-	ShadowTLSOptions   ShadowTLSInboundOptions   `json:"-"`
-	VLESSOptions       VLESSInboundOptions       `json:"-"`
+	Type                string                     `json:"type"`
+	Tag                 string                     `json:"tag,omitempty"`
+	TunOptions          TunInboundOptions          `json:"-"`
+	RedirectOptions     RedirectInboundOptions     `json:"-"`
+	TProxyOptions       TProxyInboundOptions       `json:"-"`
+	DirectOptions       DirectInboundOptions       `json:"-"`
+	SocksOptions        SocksInboundOptions        `json:"-"`
+	HTTPOptions         HTTPMixedInboundOptions    `json:"-"`
+	MixedOptions        HTTPMixedInboundOptions    `json:"-"`
+	ShadowsocksOptions  ShadowsocksInboundOptions  `json:"-"`
+	VMessOptions        VMessInboundOptions        `json:"-"`
+	TrojanOptions       TrojanInboundOptions       `json:"-"`
+	NaiveOptions        NaiveInboundOptions        `json:"-"`
+	HysteriaOptions     HysteriaInboundOptions     `json:"-"`
+	ShadowTLSOptions    ShadowTLSInboundOptions    `json:"-"`
+	VLESSOptions        VLESSInboundOptions        `json:"-"`
+	WireGuardOptions    WireGuardInboundOptions    `json:"-"`
+	TUICOptions         TUICInboundOptions         `json:"-"`
+	Hysteria2Options    Hysteria2InboundOptions    `json:"-"`
+	ShadowsocksROptions ShadowsocksRInboundOptions `json:"-"`
+	SSHOptions          SSHInboundOptions          `json:"-"`
 }
 
 type Inbound _Inbound
@@ -58,6 +63,16 @@ func (h Inbound) MarshalJSON() ([]byte, error) {
 		v = h.ShadowTLSOptions
 	case C.TypeVLESS:
 		v = h.VLESSOptions
+	case C.TypeWireGuard:
+		v = h.WireGuardOptions
+	case C.TypeTUIC:
+		v = h.TUICOptions
+	case C.TypeHysteria2:
+		v = h.Hysteria2Options
+	case C.TypeShadowsocksR:
+		v = h.ShadowsocksROptions
+	case C.TypeSSH:
+		v = h.SSHOptions
 	default:
 		return nil, E.New("unknown inbound type: ", h.Type)
 	}
@@ -99,6 +114,16 @@ func (h *Inbound) UnmarshalJSON(bytes []byte) error {
 		v = &h.ShadowTLSOptions
 	case C.TypeVLESS:
 		v = &h.VLESSOptions
+	case C.TypeWireGuard:
+		v = &h.WireGuardOptions
+	case C.TypeTUIC:
+		v = &h.TUICOptions
+	case C.TypeHysteria2:
+		v = &h.Hysteria2Options
+	case C.TypeShadowsocksR:
+		v = &h.ShadowsocksROptions
+	case C.TypeSSH:
+		v = &h.SSHOptions
 	default:
 		return E.New("unknown inbound type: ", h.Type)
 	}
@@ -0,0 +1,13 @@
+package option
+
+type UserLimitOptions struct {
+	UpMbps             int      `json:"up_mbps,omitempty"`
+	DownMbps           int      `json:"down_mbps,omitempty"`
+	Quota              uint64   `json:"quota,omitempty"`
+	QuotaResetInterval Duration `json:"quota_reset_interval,omitempty"`
+	MaxConnections     int      `json:"max_connections,omitempty"`
+}
+
+func (o UserLimitOptions) IsEmpty() bool {
+	return o == UserLimitOptions{}
+}
@@ -0,0 +1,6 @@
+package option
+
+type MetricsOptions struct {
+	Listen string `json:"listen,omitempty"`
+	Path   string `json:"path,omitempty"`
+}
@@ -4,7 +4,12 @@ import "github.com/sagernet/sing/common/auth"
 
 type NaiveInboundOptions struct {
 	ListenOptions
-	Users   []auth.User        `json:"users,omitempty"`
+	Users   []NaiveUser        `json:"users,omitempty"`
 	Network NetworkList        `json:"network,omitempty"`
 	TLS     *InboundTLSOptions `json:"tls,omitempty"`
 }
+
+type NaiveUser struct {
+	auth.User
+	UserLimitOptions
+}
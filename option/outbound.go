@@ -23,8 +23,13 @@ type _Outbound struct {
 	ShadowTLSOptions    ShadowTLSOutboundOptions    `json:"-"`
 	ShadowsocksROptions ShadowsocksROutboundOptions `json:"-"`
 	VLESSOptions        VLESSOutboundOptions        `json:"-"`
+	TUICOptions         TUICOutboundOptions         `json:"-"`
+	Hysteria2Options    Hysteria2OutboundOptions    `json:"-"`
 	SelectorOptions     SelectorOutboundOptions     `json:"-"`
 	URLTestOptions      URLTestOutboundOptions      `json:"-"`
+	LoadBalanceOptions  LoadBalanceOutboundOptions  `json:"-"`
+	FallbackOptions     FallbackOutboundOptions     `json:"-"`
+	ChainOptions        ChainOutboundOptions        `json:"-"`
 }
 
 type Outbound _Outbound
@@ -60,10 +65,20 @@ func (h Outbound) MarshalJSON() ([]byte, error) {
 		v = h.ShadowsocksROptions
 	case C.TypeVLESS:
 		v = h.VLESSOptions
+	case C.TypeTUIC:
+		v = h.TUICOptions
+	case C.TypeHysteria2:
+		v = h.Hysteria2Options
 	case C.TypeSelector:
 		v = h.SelectorOptions
 	case C.TypeURLTest:
 		v = h.URLTestOptions
+	case C.TypeLoadBalance:
+		v = h.LoadBalanceOptions
+	case C.TypeFallback:
+		v = h.FallbackOptions
+	case C.TypeChain:
+		v = h.ChainOptions
 	default:
 		return nil, E.New("unknown outbound type: ", h.Type)
 	}
@@ -105,10 +120,20 @@ func (h *Outbound) UnmarshalJSON(bytes []byte) error {
 		v = &h.ShadowsocksROptions
 	case C.TypeVLESS:
 		v = &h.VLESSOptions
+	case C.TypeTUIC:
+		v = &h.TUICOptions
+	case C.TypeHysteria2:
+		v = &h.Hysteria2Options
 	case C.TypeSelector:
 		v = &h.SelectorOptions
 	case C.TypeURLTest:
 		v = &h.URLTestOptions
+	case C.TypeLoadBalance:
+		v = &h.LoadBalanceOptions
+	case C.TypeFallback:
+		v = &h.FallbackOptions
+	case C.TypeChain:
+		v = &h.ChainOptions
 	default:
 		return E.New("unknown outbound type: ", h.Type)
 	}
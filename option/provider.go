@@ -0,0 +1,19 @@
+package option
+
+type OutboundProvider struct {
+	Tag            string   `json:"tag"`
+	Path           string   `json:"path,omitempty"`
+	DownloadURL    string   `json:"download_url,omitempty"`
+	DownloadDetour string   `json:"download_detour,omitempty"`
+	UpdateInterval Duration `json:"update_interval,omitempty"`
+}
+
+type RuleProvider struct {
+	Tag            string   `json:"tag"`
+	Format         string   `json:"format"`
+	FileFormat     string   `json:"file_format,omitempty"`
+	Path           string   `json:"path,omitempty"`
+	DownloadURL    string   `json:"download_url,omitempty"`
+	DownloadDetour string   `json:"download_detour,omitempty"`
+	UpdateInterval Duration `json:"update_interval,omitempty"`
+}
@@ -1,16 +1,18 @@
 package option
 
 type RouteOptions struct {
-	GeoIP               *GeoIPOptions   `json:"geoip,omitempty"`
-	Geosite             *GeositeOptions `json:"geosite,omitempty"`
-	IPRules             []IPRule        `json:"ip_rules,omitempty"`
-	Rules               []Rule          `json:"rules,omitempty"`
-	Final               string          `json:"final,omitempty"`
-	FindProcess         bool            `json:"find_process,omitempty"`
-	AutoDetectInterface bool            `json:"auto_detect_interface,omitempty"`
-	OverrideAndroidVPN  bool            `json:"override_android_vpn,omitempty"`
-	DefaultInterface    string          `json:"default_interface,omitempty"`
-	DefaultMark         int             `json:"default_mark,omitempty"`
+	GeoIP               *GeoIPOptions    `json:"geoip,omitempty"`
+	Geosite             *GeositeOptions  `json:"geosite,omitempty"`
+	IPRules             []IPRule         `json:"ip_rules,omitempty"`
+	Rules               []Rule           `json:"rules,omitempty"`
+	RuleProviders       []RuleProvider   `json:"rule_providers,omitempty"`
+	Script              Listable[string] `json:"script,omitempty"`
+	Final               string           `json:"final,omitempty"`
+	FindProcess         bool             `json:"find_process,omitempty"`
+	AutoDetectInterface bool             `json:"auto_detect_interface,omitempty"`
+	OverrideAndroidVPN  bool             `json:"override_android_vpn,omitempty"`
+	DefaultInterface    string           `json:"default_interface,omitempty"`
+	DefaultMark         int              `json:"default_mark,omitempty"`
 }
 
 type GeoIPOptions struct {
@@ -77,6 +77,10 @@ type DefaultDNSRule struct {
 	User            Listable[string]       `json:"user,omitempty"`
 	UserID          Listable[int32]        `json:"user_id,omitempty"`
 	Outbound        Listable[string]       `json:"outbound,omitempty"`
+	RuleProvider    Listable[string]       `json:"rule_provider,omitempty"`
+	TimeRange       Listable[string]       `json:"time_range,omitempty"`
+	Weekday         Listable[string]       `json:"weekday,omitempty"`
+	Timezone        string                 `json:"timezone,omitempty"`
 	ClashMode       string                 `json:"clash_mode,omitempty"`
 	Invert          bool                   `json:"invert,omitempty"`
 	Server          string                 `json:"server,omitempty"`
@@ -105,3 +109,12 @@ type LogicalDNSRule struct {
 func (r LogicalDNSRule) IsValid() bool {
 	return len(r.Rules) > 0 && common.All(r.Rules, DefaultDNSRule.IsValid)
 }
+
+type DNSResponseRule struct {
+	Server      Listable[string] `json:"server,omitempty"`
+	IPCIDR      Listable[string] `json:"ip_cidr,omitempty"`
+	GeoIP       Listable[string] `json:"geoip,omitempty"`
+	Invert      bool             `json:"invert,omitempty"`
+	Action      string           `json:"action,omitempty"`
+	RetryServer string           `json:"retry_server,omitempty"`
+}
@@ -1,5 +1,22 @@
 package option
 
+type ShadowsocksRInboundOptions struct {
+	ListenOptions
+	Network  NetworkList        `json:"network,omitempty"`
+	Method   string             `json:"method"`
+	Password string             `json:"password"`
+	Obfs     string             `json:"obfs,omitempty"`
+	Protocol string             `json:"protocol,omitempty"`
+	Users    []ShadowsocksRUser `json:"users,omitempty"`
+}
+
+type ShadowsocksRUser struct {
+	Name     string `json:"name"`
+	ID       uint32 `json:"id"`
+	Password string `json:"password"`
+	UserLimitOptions
+}
+
 type ShadowsocksROutboundOptions struct {
 	DialerOptions
 	ServerOptions
@@ -12,3 +12,18 @@ type SSHOutboundOptions struct {
 	HostKeyAlgorithms    Listable[string] `json:"host_key_algorithms,omitempty"`
 	ClientVersion        string           `json:"client_version,omitempty"`
 }
+
+type SSHInboundOptions struct {
+	ListenOptions
+	Users         []SSHUser        `json:"users,omitempty"`
+	HostKey       Listable[string] `json:"host_key,omitempty"`
+	HostKeyPath   Listable[string] `json:"host_key_path,omitempty"`
+	ServerVersion string           `json:"server_version,omitempty"`
+}
+
+type SSHUser struct {
+	Name           string           `json:"name"`
+	Password       string           `json:"password,omitempty"`
+	AuthorizedKeys Listable[string] `json:"authorized_keys,omitempty"`
+	UserLimitOptions
+}
@@ -7,11 +7,13 @@ import (
 )
 
 type _V2RayTransportOptions struct {
-	Type             string                `json:"type,omitempty"`
-	HTTPOptions      V2RayHTTPOptions      `json:"-"`
-	WebsocketOptions V2RayWebsocketOptions `json:"-"`
-	QUICOptions      V2RayQUICOptions      `json:"-"`
-	GRPCOptions      V2RayGRPCOptions      `json:"-"`
+	Type               string                `json:"type,omitempty"`
+	HTTPOptions        V2RayHTTPOptions      `json:"-"`
+	WebsocketOptions   V2RayWebsocketOptions `json:"-"`
+	QUICOptions        V2RayQUICOptions      `json:"-"`
+	GRPCOptions        V2RayGRPCOptions      `json:"-"`
+	HTTPUpgradeOptions V2RayWebsocketOptions `json:"-"`
+	SplitHTTPOptions   V2RaySplitHTTPOptions `json:"-"`
 }
 
 type V2RayTransportOptions _V2RayTransportOptions
@@ -29,6 +31,10 @@ func (o V2RayTransportOptions) MarshalJSON() ([]byte, error) {
 		v = o.QUICOptions
 	case C.V2RayTransportTypeGRPC:
 		v = o.GRPCOptions
+	case C.V2RayTransportTypeHTTPUpgrade:
+		v = o.HTTPUpgradeOptions
+	case C.V2RayTransportTypeSplitHTTP:
+		v = o.SplitHTTPOptions
 	default:
 		return nil, E.New("unknown transport type: " + o.Type)
 	}
@@ -50,6 +56,10 @@ func (o *V2RayTransportOptions) UnmarshalJSON(bytes []byte) error {
 		v = &o.QUICOptions
 	case C.V2RayTransportTypeGRPC:
 		v = &o.GRPCOptions
+	case C.V2RayTransportTypeHTTPUpgrade:
+		v = &o.HTTPUpgradeOptions
+	case C.V2RayTransportTypeSplitHTTP:
+		v = &o.SplitHTTPOptions
 	default:
 		return E.New("unknown transport type: " + o.Type)
 	}
@@ -85,3 +95,11 @@ type V2RayGRPCOptions struct {
 	PermitWithoutStream bool     `json:"permit_without_stream,omitempty"`
 	ForceLite           bool     `json:"-"` // for test
 }
+
+type V2RaySplitHTTPOptions struct {
+	Host                 string                      `json:"host,omitempty"`
+	Path                 string                      `json:"path,omitempty"`
+	Headers              map[string]Listable[string] `json:"headers,omitempty"`
+	MaxUploadSize        uint32                      `json:"max_upload_size,omitempty"`
+	MaxConcurrentUploads uint32                      `json:"max_concurrent_uploads,omitempty"`
+}
@@ -13,6 +13,7 @@ type VLESSUser struct {
 	Name string `json:"name"`
 	UUID string `json:"uuid"`
 	Flow string `json:"flow,omitempty"`
+	UserLimitOptions
 }
 
 type VLESSOutboundOptions struct {
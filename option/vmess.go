@@ -11,6 +11,7 @@ type VMessUser struct {
 	Name    string `json:"name"`
 	UUID    string `json:"uuid"`
 	AlterId int    `json:"alterId,omitempty"`
+	UserLimitOptions
 }
 
 type VMessOutboundOptions struct {
@@ -24,3 +24,18 @@ type WireGuardPeer struct {
 	AllowedIPs   Listable[string] `json:"allowed_ips,omitempty"`
 	Reserved     []uint8          `json:"reserved,omitempty"`
 }
+
+type WireGuardInboundOptions struct {
+	ListenOptions
+	PrivateKey string                 `json:"private_key"`
+	Peers      []WireGuardInboundPeer `json:"peers,omitempty"`
+	Workers    int                    `json:"workers,omitempty"`
+	MTU        uint32                 `json:"mtu,omitempty"`
+}
+
+type WireGuardInboundPeer struct {
+	Name         string           `json:"name,omitempty"`
+	PublicKey    string           `json:"public_key,omitempty"`
+	PreSharedKey string           `json:"pre_shared_key,omitempty"`
+	AllowedIPs   Listable[string] `json:"allowed_ips,omitempty"`
+}
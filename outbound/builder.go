@@ -51,10 +51,20 @@ func New(ctx context.Context, router adapter.Router, logger log.ContextLogger, t
 		return NewShadowsocksR(ctx, router, logger, tag, options.ShadowsocksROptions)
 	case C.TypeVLESS:
 		return NewVLESS(ctx, router, logger, tag, options.VLESSOptions)
+	case C.TypeTUIC:
+		return NewTUIC(ctx, router, logger, tag, options.TUICOptions)
+	case C.TypeHysteria2:
+		return NewHysteria2(ctx, router, logger, tag, options.Hysteria2Options)
 	case C.TypeSelector:
 		return NewSelector(router, logger, tag, options.SelectorOptions)
 	case C.TypeURLTest:
 		return NewURLTest(ctx, router, logger, tag, options.URLTestOptions)
+	case C.TypeLoadBalance:
+		return NewLoadBalance(ctx, router, logger, tag, options.LoadBalanceOptions)
+	case C.TypeFallback:
+		return NewFallback(ctx, router, logger, tag, options.FallbackOptions)
+	case C.TypeChain:
+		return NewChain(router, logger, tag, options.ChainOptions)
 	default:
 		return nil, E.New("unknown outbound type: ", options.Type)
 	}
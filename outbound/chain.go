@@ -0,0 +1,180 @@
+package outbound
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/dialer"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var (
+	_ adapter.Outbound      = (*Chain)(nil)
+	_ adapter.OutboundChain = (*Chain)(nil)
+)
+
+type Chain struct {
+	myOutboundAdapter
+	tags      []string
+	outbounds []adapter.Outbound
+}
+
+func NewChain(router adapter.Router, logger log.ContextLogger, tag string, options option.ChainOutboundOptions) (*Chain, error) {
+	outbound := &Chain{
+		myOutboundAdapter: myOutboundAdapter{
+			protocol: C.TypeChain,
+			router:   router,
+			logger:   logger,
+			tag:      tag,
+		},
+		tags: options.Outbounds,
+	}
+	if len(outbound.tags) == 0 {
+		return nil, E.New("missing tags")
+	}
+	return outbound, nil
+}
+
+func (s *Chain) Network() []string {
+	if s.outbounds == nil {
+		return []string{N.NetworkTCP, N.NetworkUDP}
+	}
+	for _, detour := range s.outbounds {
+		if !common.Contains(detour.Network(), N.NetworkUDP) {
+			return []string{N.NetworkTCP}
+		}
+	}
+	return []string{N.NetworkTCP, N.NetworkUDP}
+}
+
+func (s *Chain) Start() error {
+	outbounds := make([]adapter.Outbound, 0, len(s.tags))
+	for i, tag := range s.tags {
+		if tag == s.tag {
+			return E.New("outbound ", i, " is the chain itself")
+		}
+		detour, loaded := s.router.Outbound(tag)
+		if !loaded {
+			return E.New("outbound ", i, " not found: ", tag)
+		}
+		outbounds = append(outbounds, detour)
+	}
+	err := s.checkHops(s.tag, s.tags, false, []string{s.tag})
+	if err != nil {
+		return err
+	}
+	s.outbounds = outbounds
+	return nil
+}
+
+// checkHops walks the outbounds reachable from the hops of a chain, through nested chains and groups,
+// rejecting cycles and shared session outbounds dialed through a previous hop.
+func (s *Chain) checkHops(chainTag string, hops []string, chained bool, path []string) error {
+	for i, tag := range hops {
+		err := s.checkHop(tag, chained || i > 0, path)
+		if err != nil {
+			return E.Cause(err, "outbound/chain[", chainTag, "]: outbound ", i)
+		}
+	}
+	return nil
+}
+
+func (s *Chain) checkHop(tag string, chained bool, path []string) error {
+	if common.Contains(path, tag) {
+		return E.New("loop detected: ", strings.Join(append(path, tag), " -> "))
+	}
+	detour, loaded := s.router.Outbound(tag)
+	if !loaded {
+		return nil
+	}
+	path = append(path, tag)
+	if outboundChain, isChain := detour.(adapter.OutboundChain); isChain {
+		return s.checkHops(tag, outboundChain.Chain(), chained, path)
+	}
+	if group, isGroup := detour.(adapter.OutboundGroup); isGroup {
+		for _, member := range group.All() {
+			err := s.checkHop(member, chained, path)
+			if err != nil {
+				return E.Cause(err, "outbound/", detour.Type(), "[", tag, "]")
+			}
+		}
+		return nil
+	}
+	if chained && sharedSession(detour) {
+		return E.New(detour.Type(), "[", tag, "] keeps a session shared by all connections, which can only be the first outbound of a chain")
+	}
+	return nil
+}
+
+// sharedSession reports whether connections of the outbound share a session to the server. Such a session
+// could be dialed through a chain and then reused for connections not routed through the chain.
+func sharedSession(detour adapter.Outbound) bool {
+	switch detour.Type() {
+	case C.TypeWireGuard, C.TypeHysteria, C.TypeHysteria2, C.TypeTUIC, C.TypeSSH, C.TypeTor:
+		return true
+	}
+	switch typedDetour := detour.(type) {
+	case *Shadowsocks:
+		return typedDetour.multiplexDialer != nil
+	case *Trojan:
+		return typedDetour.multiplexDialer != nil
+	case *VMess:
+		return typedDetour.multiplexDialer != nil
+	}
+	return false
+}
+
+func (s *Chain) Chain() []string {
+	return s.tags
+}
+
+// context returns a context in which the last outbound dials through all previous ones.
+// A chain used as a hop of another chain dials through the previous hops of that chain.
+func (s *Chain) context(ctx context.Context) (context.Context, adapter.Outbound) {
+	detour := dialer.OverrideFromContext(ctx)
+	for _, outbound := range s.outbounds[:len(s.outbounds)-1] {
+		detour = &chainDialer{outbound, detour}
+	}
+	return dialer.ContextWithOverride(ctx, detour), s.outbounds[len(s.outbounds)-1]
+}
+
+func (s *Chain) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	ctx, outbound := s.context(ctx)
+	return outbound.DialContext(ctx, network, destination)
+}
+
+func (s *Chain) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	ctx, outbound := s.context(ctx)
+	return outbound.ListenPacket(ctx, destination)
+}
+
+func (s *Chain) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	return NewConnection(ctx, s, conn, metadata)
+}
+
+func (s *Chain) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	return NewPacketConnection(ctx, s, conn, metadata)
+}
+
+// chainDialer dials the server of the next outbound through an outbound of the chain,
+// which in turn dials through the previous one.
+type chainDialer struct {
+	outbound adapter.Outbound
+	previous N.Dialer
+}
+
+func (d *chainDialer) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	return d.outbound.DialContext(dialer.ContextWithOverride(ctx, d.previous), network, destination)
+}
+
+func (d *chainDialer) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	return d.outbound.ListenPacket(dialer.ContextWithOverride(ctx, d.previous), destination)
+}
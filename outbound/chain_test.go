@@ -0,0 +1,230 @@
+package outbound
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/dialer"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	M "github.com/sagernet/sing/common/metadata"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testChainRouter struct {
+	adapter.Router
+	outbounds map[string]adapter.Outbound
+}
+
+func (r *testChainRouter) Outbound(tag string) (adapter.Outbound, bool) {
+	outbound, loaded := r.outbounds[tag]
+	return outbound, loaded
+}
+
+type testChainDial struct {
+	tag         string
+	destination string
+	overridden  bool
+}
+
+// testHop records its dials and reaches its server, named after its tag, through the override dialer.
+type testHop struct {
+	adapter.Outbound
+	tag          string
+	outboundType string
+	dials        *[]testChainDial
+}
+
+func (h *testHop) Type() string {
+	return h.outboundType
+}
+
+func (h *testHop) Tag() string {
+	return h.tag
+}
+
+func (h *testHop) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	override := dialer.OverrideFromContext(ctx)
+	*h.dials = append(*h.dials, testChainDial{h.tag, destination.String(), override != nil})
+	if override != nil {
+		return override.DialContext(ctx, network, M.ParseSocksaddrHostPort(h.tag, 443))
+	}
+	return nil, nil
+}
+
+type testGroup struct {
+	testHop
+	router  *testChainRouter
+	members []string
+}
+
+func (g *testGroup) Now() string {
+	return g.members[0]
+}
+
+func (g *testGroup) All() []string {
+	return g.members
+}
+
+func (g *testGroup) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	return g.router.outbounds[g.Now()].DialContext(ctx, network, destination)
+}
+
+func newTestChainRouter(t *testing.T, dials *[]testChainDial, hops map[string]string, groups map[string][]string, chains map[string][]string) *testChainRouter {
+	router := &testChainRouter{outbounds: make(map[string]adapter.Outbound)}
+	for tag, outboundType := range hops {
+		router.outbounds[tag] = &testHop{tag: tag, outboundType: outboundType, dials: dials}
+	}
+	for tag, members := range groups {
+		router.outbounds[tag] = &testGroup{testHop{tag: tag, outboundType: C.TypeSelector, dials: dials}, router, members}
+	}
+	for tag, tags := range chains {
+		chain, err := NewChain(router, log.NewNOPFactory().Logger(), tag, option.ChainOutboundOptions{Outbounds: tags})
+		require.NoError(t, err)
+		router.outbounds[tag] = chain
+	}
+	return router
+}
+
+func TestChainCheckHops(t *testing.T) {
+	t.Parallel()
+	hops := map[string]string{
+		"a":  C.TypeShadowsocks,
+		"b":  C.TypeVMess,
+		"wg": C.TypeWireGuard,
+	}
+	testCases := []struct {
+		name   string
+		groups map[string][]string
+		chains map[string][]string
+		err    string
+	}{
+		{
+			name:   "plain",
+			chains: map[string][]string{"chain": {"a", "b"}},
+		},
+		{
+			name:   "shared session first",
+			chains: map[string][]string{"chain": {"wg", "a"}},
+		},
+		{
+			name:   "shared session chained",
+			chains: map[string][]string{"chain": {"a", "wg"}},
+			err:    "keeps a session shared by all connections",
+		},
+		{
+			name:   "shared session in group",
+			groups: map[string][]string{"group": {"b", "wg"}},
+			chains: map[string][]string{"chain": {"a", "group"}},
+			err:    "keeps a session shared by all connections",
+		},
+		{
+			name:   "shared session first in nested chain",
+			chains: map[string][]string{"chain": {"a", "inner"}, "inner": {"wg", "b"}},
+			err:    "keeps a session shared by all connections",
+		},
+		{
+			name:   "shared session first in nested first chain",
+			chains: map[string][]string{"chain": {"inner", "a"}, "inner": {"wg", "b"}},
+		},
+		{
+			name:   "loop through nested chain",
+			chains: map[string][]string{"chain": {"a", "inner"}, "inner": {"b", "chain"}},
+			err:    "loop detected: chain -> inner -> chain",
+		},
+		{
+			name:   "loop through group",
+			groups: map[string][]string{"group": {"b", "chain"}},
+			chains: map[string][]string{"chain": {"a", "group"}},
+			err:    "loop detected: chain -> group -> chain",
+		},
+		{
+			name:   "repeated hop",
+			chains: map[string][]string{"chain": {"a", "b", "a"}},
+		},
+	}
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			var dials []testChainDial
+			router := newTestChainRouter(t, &dials, hops, testCase.groups, testCase.chains)
+			err := router.outbounds["chain"].(*Chain).Start()
+			if testCase.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, testCase.err)
+			}
+		})
+	}
+}
+
+func TestChainContext(t *testing.T) {
+	t.Parallel()
+	hops := map[string]string{
+		"a": C.TypeShadowsocks,
+		"b": C.TypeVMess,
+		"c": C.TypeTrojan,
+	}
+	testCases := []struct {
+		name   string
+		groups map[string][]string
+		chains map[string][]string
+		dials  []testChainDial
+	}{
+		{
+			name:   "plain",
+			chains: map[string][]string{"chain": {"a", "b", "c"}},
+			dials: []testChainDial{
+				{"c", "example.org:80", true},
+				{"b", "c:443", true},
+				{"a", "b:443", false},
+			},
+		},
+		{
+			name:   "nested chain",
+			chains: map[string][]string{"chain": {"a", "inner"}, "inner": {"b", "c"}},
+			dials: []testChainDial{
+				{"c", "example.org:80", true},
+				{"b", "c:443", true},
+				{"a", "b:443", false},
+			},
+		},
+		{
+			name:   "nested first chain",
+			chains: map[string][]string{"chain": {"inner", "c"}, "inner": {"a", "b"}},
+			dials: []testChainDial{
+				{"c", "example.org:80", true},
+				{"b", "c:443", true},
+				{"a", "b:443", false},
+			},
+		},
+		{
+			name:   "group",
+			groups: map[string][]string{"group": {"b", "c"}},
+			chains: map[string][]string{"chain": {"a", "group"}},
+			dials: []testChainDial{
+				{"b", "example.org:80", true},
+				{"a", "b:443", false},
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			var dials []testChainDial
+			router := newTestChainRouter(t, &dials, hops, testCase.groups, testCase.chains)
+			for tag := range testCase.chains {
+				require.NoError(t, router.outbounds[tag].(*Chain).Start())
+			}
+			_, err := router.outbounds["chain"].DialContext(context.Background(), "tcp", M.ParseSocksaddr("example.org:80"))
+			require.NoError(t, err)
+			require.Equal(t, testCase.dials, dials)
+		})
+	}
+}
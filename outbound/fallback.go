@@ -0,0 +1,288 @@
+package outbound
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var (
+	_ adapter.Outbound                = (*Fallback)(nil)
+	_ adapter.URLTestGroup            = (*Fallback)(nil)
+	_ adapter.InterfaceUpdateListener = (*Fallback)(nil)
+)
+
+type Fallback struct {
+	myOutboundAdapter
+	ctx           context.Context
+	tags          []string
+	providers     []string
+	link          string
+	interval      time.Duration
+	timeout       time.Duration
+	group         *URLTestGroup
+	failureAccess sync.Mutex
+	failures      map[string]time.Time
+}
+
+func NewFallback(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.FallbackOutboundOptions) (*Fallback, error) {
+	outbound := &Fallback{
+		myOutboundAdapter: myOutboundAdapter{
+			protocol: C.TypeFallback,
+			router:   router,
+			logger:   logger,
+			tag:      tag,
+		},
+		ctx:       ctx,
+		tags:      options.Outbounds,
+		providers: options.Providers,
+		link:      options.URL,
+		interval:  time.Duration(options.Interval),
+		timeout:   time.Duration(options.Timeout),
+		failures:  make(map[string]time.Time),
+	}
+	if len(outbound.tags) == 0 && len(outbound.providers) == 0 {
+		return nil, E.New("missing tags")
+	}
+	if outbound.interval == 0 {
+		outbound.interval = C.DefaultURLTestInterval
+	}
+	if outbound.timeout == 0 {
+		outbound.timeout = C.TCPTimeout
+	}
+	return outbound, nil
+}
+
+func (s *Fallback) Network() []string {
+	if s.group == nil {
+		return []string{N.NetworkTCP, N.NetworkUDP}
+	}
+	for _, detour := range s.group.Outbounds() {
+		if common.Contains(detour.Network(), N.NetworkUDP) {
+			return []string{N.NetworkTCP, N.NetworkUDP}
+		}
+	}
+	return []string{N.NetworkTCP}
+}
+
+func (s *Fallback) Start() error {
+	outbounds := make([]adapter.Outbound, 0, len(s.tags))
+	for i, tag := range s.tags {
+		detour, loaded := s.router.Outbound(tag)
+		if !loaded {
+			return E.New("outbound ", i, " not found: ", tag)
+		}
+		outbounds = append(outbounds, detour)
+	}
+	providers := make([]adapter.OutboundProvider, 0, len(s.providers))
+	for i, tag := range s.providers {
+		provider, loaded := s.router.OutboundProvider(tag)
+		if !loaded {
+			return E.New("outbound provider ", i, " not found: ", tag)
+		}
+		providers = append(providers, provider)
+	}
+	s.group = NewURLTestGroup(s.ctx, s.router, s.logger, outbounds, providers, s.link, s.interval, 0)
+	return s.group.Start()
+}
+
+func (s *Fallback) Close() error {
+	return common.Close(
+		common.PtrOrNil(s.group),
+	)
+}
+
+func (s *Fallback) Now() string {
+	candidates := s.candidates(N.NetworkTCP)
+	if len(candidates) == 0 {
+		return ""
+	}
+	return candidates[0].Tag()
+}
+
+func (s *Fallback) All() []string {
+	if len(s.providers) == 0 {
+		return s.tags
+	}
+	return common.Map(s.group.Outbounds(), func(it adapter.Outbound) string {
+		return it.Tag()
+	})
+}
+
+func (s *Fallback) URLTest(ctx context.Context, link string) (map[string]uint16, error) {
+	return s.group.URLTest(ctx, link)
+}
+
+// failed reports whether the member failed recently and has not passed a URL test since.
+func (s *Fallback) failed(detour adapter.Outbound) bool {
+	realTag := RealTag(detour)
+	s.failureAccess.Lock()
+	failedAt, loaded := s.failures[realTag]
+	s.failureAccess.Unlock()
+	if !loaded {
+		return false
+	}
+	if time.Since(failedAt) >= s.interval {
+		return false
+	}
+	history := s.group.history.LoadURLTestHistory(realTag)
+	return history == nil || history.Time.Before(failedAt)
+}
+
+func (s *Fallback) markFailed(detour adapter.Outbound) {
+	realTag := RealTag(detour)
+	s.failureAccess.Lock()
+	s.failures[realTag] = time.Now()
+	s.failureAccess.Unlock()
+	s.group.history.DeleteURLTestHistory(realTag)
+}
+
+func (s *Fallback) markAvailable(detour adapter.Outbound) {
+	s.failureAccess.Lock()
+	delete(s.failures, RealTag(detour))
+	s.failureAccess.Unlock()
+}
+
+// candidates returns members supporting the network in configured order,
+// with recently failed members moved to the end.
+func (s *Fallback) candidates(network string) []adapter.Outbound {
+	var available, failed []adapter.Outbound
+	for _, detour := range s.group.Outbounds() {
+		if !common.Contains(detour.Network(), network) {
+			continue
+		}
+		if s.failed(detour) {
+			failed = append(failed, detour)
+		} else {
+			available = append(available, detour)
+		}
+	}
+	return append(available, failed...)
+}
+
+func (s *Fallback) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	candidates := s.candidates(network)
+	if len(candidates) == 0 {
+		return nil, E.New("missing supported outbound")
+	}
+	var errors []error
+	for _, detour := range candidates {
+		dialCtx, cancel, stopTimeout := s.dialContext(ctx)
+		conn, err := detour.DialContext(dialCtx, network, destination)
+		if err == nil && !stopTimeout() {
+			conn.Close()
+			err = context.DeadlineExceeded
+		}
+		if err == nil {
+			s.markAvailable(detour)
+			return &fallbackConn{conn, cancel}, nil
+		}
+		cancel()
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		s.logger.DebugContext(ctx, "outbound ", detour.Tag(), " failed: ", err)
+		s.markFailed(detour)
+		errors = append(errors, E.Cause(err, detour.Tag()))
+	}
+	err := E.Errors(errors...)
+	s.logger.ErrorContext(ctx, err)
+	return nil, err
+}
+
+func (s *Fallback) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	candidates := s.candidates(N.NetworkUDP)
+	if len(candidates) == 0 {
+		return nil, E.New("missing supported outbound")
+	}
+	var errors []error
+	for _, detour := range candidates {
+		dialCtx, cancel, stopTimeout := s.dialContext(ctx)
+		conn, err := detour.ListenPacket(dialCtx, destination)
+		if err == nil && !stopTimeout() {
+			conn.Close()
+			err = context.DeadlineExceeded
+		}
+		if err == nil {
+			s.markAvailable(detour)
+			return &fallbackPacketConn{conn, cancel}, nil
+		}
+		cancel()
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		s.logger.DebugContext(ctx, "outbound ", detour.Tag(), " failed: ", err)
+		s.markFailed(detour)
+		errors = append(errors, E.Cause(err, detour.Tag()))
+	}
+	err := E.Errors(errors...)
+	s.logger.ErrorContext(ctx, err)
+	return nil, err
+}
+
+// dialContext returns the context of a dial attempt, canceled after the timeout unless stopTimeout is called before.
+// Connections may keep using the context of a successful attempt, so it is only canceled when they are closed.
+func (s *Fallback) dialContext(ctx context.Context) (dialCtx context.Context, cancel context.CancelFunc, stopTimeout func() bool) {
+	dialCtx, cancel = context.WithCancel(ctx)
+	timer := time.AfterFunc(s.timeout, cancel)
+	return dialCtx, cancel, timer.Stop
+}
+
+func (s *Fallback) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	return NewConnection(ctx, s, conn, metadata)
+}
+
+func (s *Fallback) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	return NewPacketConnection(ctx, s, conn, metadata)
+}
+
+func (s *Fallback) InterfaceUpdated() error {
+	go s.group.checkOutbounds()
+	return nil
+}
+
+type fallbackConn struct {
+	net.Conn
+	cancel context.CancelFunc
+}
+
+func (c *fallbackConn) Close() error {
+	c.cancel()
+	return c.Conn.Close()
+}
+
+func (c *fallbackConn) Upstream() any {
+	return c.Conn
+}
+
+func (c *fallbackConn) ReaderReplaceable() bool {
+	return true
+}
+
+func (c *fallbackConn) WriterReplaceable() bool {
+	return true
+}
+
+type fallbackPacketConn struct {
+	net.PacketConn
+	cancel context.CancelFunc
+}
+
+func (c *fallbackPacketConn) Close() error {
+	c.cancel()
+	return c.PacketConn.Close()
+}
+
+func (c *fallbackPacketConn) Upstream() any {
+	return c.PacketConn
+}
@@ -0,0 +1,257 @@
+package outbound
+
+import (
+	"context"
+	"hash/fnv"
+	"net"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/atomic"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+var (
+	_ adapter.Outbound                = (*LoadBalance)(nil)
+	_ adapter.OutboundGroup           = (*LoadBalance)(nil)
+	_ adapter.InterfaceUpdateListener = (*LoadBalance)(nil)
+)
+
+type LoadBalance struct {
+	myOutboundAdapter
+	ctx            context.Context
+	tags           []string
+	providers      []string
+	link           string
+	interval       time.Duration
+	strategy       string
+	hashFullDomain bool
+	group          *URLTestGroup
+	index          atomic.Uint32
+	lastTag        atomic.Value
+}
+
+func NewLoadBalance(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.LoadBalanceOutboundOptions) (*LoadBalance, error) {
+	outbound := &LoadBalance{
+		myOutboundAdapter: myOutboundAdapter{
+			protocol: C.TypeLoadBalance,
+			router:   router,
+			logger:   logger,
+			tag:      tag,
+		},
+		ctx:            ctx,
+		tags:           options.Outbounds,
+		providers:      options.Providers,
+		link:           options.URL,
+		interval:       time.Duration(options.Interval),
+		hashFullDomain: options.HashFullDomain,
+	}
+	if len(outbound.tags) == 0 && len(outbound.providers) == 0 {
+		return nil, E.New("missing tags")
+	}
+	switch options.Strategy {
+	case "", C.LoadBalanceStrategyRoundRobin:
+		outbound.strategy = C.LoadBalanceStrategyRoundRobin
+	case C.LoadBalanceStrategyConsistentHashing, C.LoadBalanceStrategyStickySessions:
+		outbound.strategy = options.Strategy
+	default:
+		return nil, E.New("unknown load balance strategy: ", options.Strategy)
+	}
+	return outbound, nil
+}
+
+func (s *LoadBalance) Network() []string {
+	if s.group == nil {
+		return []string{N.NetworkTCP, N.NetworkUDP}
+	}
+	if len(s.available(N.NetworkUDP)) > 0 {
+		return []string{N.NetworkTCP, N.NetworkUDP}
+	}
+	return []string{N.NetworkTCP}
+}
+
+func (s *LoadBalance) Start() error {
+	outbounds := make([]adapter.Outbound, 0, len(s.tags))
+	for i, tag := range s.tags {
+		detour, loaded := s.router.Outbound(tag)
+		if !loaded {
+			return E.New("outbound ", i, " not found: ", tag)
+		}
+		outbounds = append(outbounds, detour)
+	}
+	providers := make([]adapter.OutboundProvider, 0, len(s.providers))
+	for i, tag := range s.providers {
+		provider, loaded := s.router.OutboundProvider(tag)
+		if !loaded {
+			return E.New("outbound provider ", i, " not found: ", tag)
+		}
+		providers = append(providers, provider)
+	}
+	s.group = NewURLTestGroup(s.ctx, s.router, s.logger, outbounds, providers, s.link, s.interval, 0)
+	return s.group.Start()
+}
+
+func (s *LoadBalance) Close() error {
+	return common.Close(
+		common.PtrOrNil(s.group),
+	)
+}
+
+func (s *LoadBalance) Now() string {
+	if lastTag, loaded := s.lastTag.Load().(string); loaded {
+		return lastTag
+	}
+	available := s.available(N.NetworkTCP)
+	if len(available) == 0 {
+		return ""
+	}
+	return available[0].Tag()
+}
+
+func (s *LoadBalance) All() []string {
+	if len(s.providers) == 0 {
+		return s.tags
+	}
+	return common.Map(s.group.Outbounds(), func(it adapter.Outbound) string {
+		return it.Tag()
+	})
+}
+
+func (s *LoadBalance) URLTest(ctx context.Context, link string) (map[string]uint16, error) {
+	return s.group.URLTest(ctx, link)
+}
+
+// available returns members supporting the network that passed their last URL test,
+// or all members supporting the network if none has been tested successfully yet.
+func (s *LoadBalance) available(network string) []adapter.Outbound {
+	var tested, untested []adapter.Outbound
+	for _, detour := range s.group.Outbounds() {
+		if !common.Contains(detour.Network(), network) {
+			continue
+		}
+		if s.group.history.LoadURLTestHistory(RealTag(detour)) != nil {
+			tested = append(tested, detour)
+		} else {
+			untested = append(untested, detour)
+		}
+	}
+	if len(tested) > 0 {
+		return tested
+	}
+	return untested
+}
+
+func (s *LoadBalance) selectOutbound(ctx context.Context, network string, destination M.Socksaddr) adapter.Outbound {
+	outbounds := s.available(network)
+	if len(outbounds) == 0 {
+		return nil
+	}
+	var selected adapter.Outbound
+	if key := s.hashKey(ctx, destination); key != "" {
+		selected = rendezvousSelect(outbounds, key)
+	} else {
+		selected = outbounds[int(s.index.Add(1)-1)%len(outbounds)]
+	}
+	s.lastTag.Store(selected.Tag())
+	return selected
+}
+
+func (s *LoadBalance) hashKey(ctx context.Context, destination M.Socksaddr) string {
+	metadata := adapter.ContextFrom(ctx)
+	switch s.strategy {
+	case C.LoadBalanceStrategyConsistentHashing:
+		var domain string
+		if metadata != nil && metadata.Domain != "" {
+			domain = metadata.Domain
+		} else if metadata != nil && metadata.Destination.IsFqdn() {
+			domain = metadata.Destination.Fqdn
+		} else if destination.IsFqdn() {
+			domain = destination.Fqdn
+		}
+		if domain == "" {
+			if destination.IsIP() {
+				return destination.Addr.String()
+			}
+			return ""
+		}
+		if !s.hashFullDomain {
+			if topDomain, err := publicsuffix.EffectiveTLDPlusOne(domain); err == nil {
+				domain = topDomain
+			}
+		}
+		return domain
+	case C.LoadBalanceStrategyStickySessions:
+		if metadata != nil && metadata.Source.IsIP() {
+			return metadata.Source.Addr.String()
+		}
+	}
+	return ""
+}
+
+// rendezvousSelect picks the member with the highest hash weight for the key,
+// so only keys mapped to a removed member move when the member set changes.
+func rendezvousSelect(outbounds []adapter.Outbound, key string) adapter.Outbound {
+	var selected adapter.Outbound
+	var maxWeight uint64
+	for _, detour := range outbounds {
+		hash := fnv.New64a()
+		hash.Write([]byte(key))
+		hash.Write([]byte{0})
+		hash.Write([]byte(detour.Tag()))
+		weight := hash.Sum64()
+		if selected == nil || weight > maxWeight {
+			selected = detour
+			maxWeight = weight
+		}
+	}
+	return selected
+}
+
+func (s *LoadBalance) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	outbound := s.selectOutbound(ctx, network, destination)
+	if outbound == nil {
+		return nil, E.New("missing supported outbound")
+	}
+	conn, err := outbound.DialContext(ctx, network, destination)
+	if err == nil {
+		return conn, nil
+	}
+	s.logger.ErrorContext(ctx, err)
+	s.group.history.DeleteURLTestHistory(RealTag(outbound))
+	return nil, err
+}
+
+func (s *LoadBalance) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	outbound := s.selectOutbound(ctx, N.NetworkUDP, destination)
+	if outbound == nil {
+		return nil, E.New("missing supported outbound")
+	}
+	conn, err := outbound.ListenPacket(ctx, destination)
+	if err == nil {
+		return conn, nil
+	}
+	s.logger.ErrorContext(ctx, err)
+	s.group.history.DeleteURLTestHistory(RealTag(outbound))
+	return nil, err
+}
+
+func (s *LoadBalance) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	return NewConnection(ctx, s, conn, metadata)
+}
+
+func (s *LoadBalance) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	return NewPacketConnection(ctx, s, conn, metadata)
+}
+
+func (s *LoadBalance) InterfaceUpdated() error {
+	go s.group.checkOutbounds()
+	return nil
+}
@@ -0,0 +1,249 @@
+package outbound
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/urltest"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testLoadBalanceRouter struct {
+	adapter.Router
+	outbounds map[string]adapter.Outbound
+}
+
+func (r *testLoadBalanceRouter) Outbound(tag string) (adapter.Outbound, bool) {
+	outbound, loaded := r.outbounds[tag]
+	return outbound, loaded
+}
+
+func (r *testLoadBalanceRouter) ClashServer() adapter.ClashServer {
+	return nil
+}
+
+type testMember struct {
+	adapter.Outbound
+	tag     string
+	network []string
+	failed  bool
+}
+
+func (m *testMember) Type() string {
+	return C.TypeDirect
+}
+
+func (m *testMember) Tag() string {
+	return m.tag
+}
+
+func (m *testMember) Network() []string {
+	return m.network
+}
+
+func (m *testMember) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	if m.failed {
+		return nil, E.New("dial failed")
+	}
+	return nil, nil
+}
+
+func newTestMembers(tags ...string) []adapter.Outbound {
+	outbounds := make([]adapter.Outbound, 0, len(tags))
+	for _, tag := range tags {
+		outbounds = append(outbounds, &testMember{tag: tag, network: []string{N.NetworkTCP, N.NetworkUDP}})
+	}
+	return outbounds
+}
+
+func newTestLoadBalance(t *testing.T, options option.LoadBalanceOutboundOptions, outbounds []adapter.Outbound) *LoadBalance {
+	router := &testLoadBalanceRouter{outbounds: make(map[string]adapter.Outbound)}
+	for _, outbound := range outbounds {
+		router.outbounds[outbound.Tag()] = outbound
+		options.Outbounds = append(options.Outbounds, outbound.Tag())
+	}
+	logger := log.NewNOPFactory().Logger()
+	loadBalance, err := NewLoadBalance(context.Background(), router, logger, "load-balance", options)
+	require.NoError(t, err)
+	loadBalance.group = NewURLTestGroup(context.Background(), router, logger, outbounds, nil, "", 0, 0)
+	return loadBalance
+}
+
+func TestLoadBalanceHashKey(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name           string
+		strategy       string
+		hashFullDomain bool
+		metadata       *adapter.InboundContext
+		destination    M.Socksaddr
+		key            string
+	}{
+		{
+			name:        "round robin",
+			destination: M.ParseSocksaddr("www.example.com:443"),
+		},
+		{
+			name:        "sniffed domain",
+			strategy:    C.LoadBalanceStrategyConsistentHashing,
+			metadata:    &adapter.InboundContext{Domain: "www.example.co.uk", Destination: M.ParseSocksaddr("1.1.1.1:443")},
+			destination: M.ParseSocksaddr("1.1.1.1:443"),
+			key:         "example.co.uk",
+		},
+		{
+			name:        "destination domain",
+			strategy:    C.LoadBalanceStrategyConsistentHashing,
+			destination: M.ParseSocksaddr("mail.example.co.uk:443"),
+			key:         "example.co.uk",
+		},
+		{
+			name:           "full domain",
+			strategy:       C.LoadBalanceStrategyConsistentHashing,
+			hashFullDomain: true,
+			destination:    M.ParseSocksaddr("mail.example.co.uk:443"),
+			key:            "mail.example.co.uk",
+		},
+		{
+			name:        "destination address",
+			strategy:    C.LoadBalanceStrategyConsistentHashing,
+			destination: M.ParseSocksaddr("1.1.1.1:443"),
+			key:         "1.1.1.1",
+		},
+		{
+			name:        "source address",
+			strategy:    C.LoadBalanceStrategyStickySessions,
+			metadata:    &adapter.InboundContext{Source: M.ParseSocksaddr("192.168.1.2:50000")},
+			destination: M.ParseSocksaddr("www.example.com:443"),
+			key:         "192.168.1.2",
+		},
+		{
+			name:        "missing source address",
+			strategy:    C.LoadBalanceStrategyStickySessions,
+			destination: M.ParseSocksaddr("www.example.com:443"),
+		},
+	}
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			loadBalance := newTestLoadBalance(t, option.LoadBalanceOutboundOptions{
+				Strategy:       testCase.strategy,
+				HashFullDomain: testCase.hashFullDomain,
+			}, newTestMembers("a"))
+			ctx := context.Background()
+			if testCase.metadata != nil {
+				ctx = adapter.WithContext(ctx, testCase.metadata)
+			}
+			require.Equal(t, testCase.key, loadBalance.hashKey(ctx, testCase.destination))
+		})
+	}
+}
+
+func TestLoadBalanceStability(t *testing.T) {
+	t.Parallel()
+	members := newTestMembers("a", "b", "c", "d", "e")
+	testCases := []struct {
+		name   string
+		before []adapter.Outbound
+		after  []adapter.Outbound
+	}{
+		{"remove member", members[:4], []adapter.Outbound{members[0], members[1], members[3]}},
+		{"add member", members[:4], members},
+		{"reorder members", members[:4], []adapter.Outbound{members[3], members[2], members[1], members[0]}},
+	}
+	for _, strategy := range []string{C.LoadBalanceStrategyConsistentHashing, C.LoadBalanceStrategyStickySessions} {
+		for _, testCase := range testCases {
+			strategy, testCase := strategy, testCase
+			t.Run(strategy+"/"+testCase.name, func(t *testing.T) {
+				t.Parallel()
+				loadBalance := newTestLoadBalance(t, option.LoadBalanceOutboundOptions{Strategy: strategy}, testCase.before)
+				selectAll := func() map[int]string {
+					selected := make(map[int]string)
+					for i := 0; i < 256; i++ {
+						ctx := adapter.WithContext(context.Background(), &adapter.InboundContext{
+							Source: M.ParseSocksaddr(F.ToString("10.0.0.", i, ":50000")),
+						})
+						destination := M.ParseSocksaddr(F.ToString("www.site", i, ".com:443"))
+						selected[i] = loadBalance.selectOutbound(ctx, N.NetworkTCP, destination).Tag()
+					}
+					return selected
+				}
+				before := selectAll()
+				require.Equal(t, before, selectAll())
+				loadBalance.group.outbounds = testCase.after
+				after := selectAll()
+				tags := make(map[string]bool)
+				for _, outbound := range testCase.after {
+					tags[outbound.Tag()] = true
+				}
+				var moved int
+				for i, tag := range before {
+					if !tags[tag] {
+						require.True(t, tags[after[i]])
+						continue
+					}
+					if after[i] != tag {
+						require.False(t, containsOutbound(testCase.before, after[i]), "key ", i, " moved from ", tag, " to ", after[i])
+						moved++
+					}
+				}
+				require.Less(t, moved, 128)
+			})
+		}
+	}
+}
+
+func containsOutbound(outbounds []adapter.Outbound, tag string) bool {
+	for _, outbound := range outbounds {
+		if outbound.Tag() == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLoadBalanceRoundRobin(t *testing.T) {
+	t.Parallel()
+	loadBalance := newTestLoadBalance(t, option.LoadBalanceOutboundOptions{}, newTestMembers("a", "b", "c"))
+	var selected []string
+	for i := 0; i < 6; i++ {
+		selected = append(selected, loadBalance.selectOutbound(context.Background(), N.NetworkTCP, M.ParseSocksaddr("www.example.com:443")).Tag())
+	}
+	require.Equal(t, []string{"a", "b", "c", "a", "b", "c"}, selected)
+	require.Equal(t, "c", loadBalance.Now())
+}
+
+func TestLoadBalanceFailover(t *testing.T) {
+	t.Parallel()
+	members := newTestMembers("a", "b", "c")
+	members[0].(*testMember).failed = true
+	members[2].(*testMember).network = []string{N.NetworkTCP}
+	loadBalance := newTestLoadBalance(t, option.LoadBalanceOutboundOptions{}, members)
+	require.Equal(t, "a", loadBalance.Now())
+	for _, member := range members {
+		loadBalance.group.history.StoreURLTestHistory(member.Tag(), &urltest.History{Time: time.Now()})
+	}
+	_, err := loadBalance.DialContext(context.Background(), N.NetworkTCP, M.ParseSocksaddr("www.example.com:443"))
+	require.Error(t, err)
+	require.Equal(t, []adapter.Outbound{members[1], members[2]}, loadBalance.available(N.NetworkTCP))
+	require.Equal(t, []adapter.Outbound{members[1]}, loadBalance.available(N.NetworkUDP))
+	for i := 0; i < 4; i++ {
+		_, err = loadBalance.DialContext(context.Background(), N.NetworkTCP, M.ParseSocksaddr("www.example.com:443"))
+		require.NoError(t, err)
+		require.NotEqual(t, "a", loadBalance.Now())
+	}
+	loadBalance.group.history.DeleteURLTestHistory("b")
+	loadBalance.group.history.DeleteURLTestHistory("c")
+	require.Equal(t, members, loadBalance.available(N.NetworkTCP))
+}
@@ -3,14 +3,18 @@ package outbound
 import (
 	"context"
 	"net"
+	"sync"
+	"time"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/timerange"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
 	E "github.com/sagernet/sing/common/exceptions"
 	M "github.com/sagernet/sing/common/metadata"
 	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/common/x/list"
 )
 
 var (
@@ -20,10 +24,24 @@ var (
 
 type Selector struct {
 	myOutboundAdapter
-	tags       []string
-	defaultTag string
-	outbounds  map[string]adapter.Outbound
-	selected   adapter.Outbound
+	tags         []string
+	providerTags []string
+	defaultTag   string
+	outbounds    map[string]adapter.Outbound
+	providers    []adapter.OutboundProvider
+	callbacks    []*list.Element[adapter.OutboundProviderUpdateCallback]
+	schedule     []selectorSchedule
+	done         chan struct{}
+	closeOnce    sync.Once
+	access       sync.RWMutex
+	selectedTag  string
+	selected     adapter.Outbound
+	scheduled    int
+}
+
+type selectorSchedule struct {
+	matcher *timerange.Matcher
+	tag     string
 }
 
 func NewSelector(router adapter.Router, logger log.ContextLogger, tag string, options option.SelectorOutboundOptions) (*Selector, error) {
@@ -34,21 +52,43 @@ func NewSelector(router adapter.Router, logger log.ContextLogger, tag string, op
 			logger:   logger,
 			tag:      tag,
 		},
-		tags:       options.Outbounds,
-		defaultTag: options.Default,
-		outbounds:  make(map[string]adapter.Outbound),
+		tags:         options.Outbounds,
+		providerTags: options.Providers,
+		defaultTag:   options.Default,
+		outbounds:    make(map[string]adapter.Outbound),
+		scheduled:    -1,
 	}
-	if len(outbound.tags) == 0 {
+	if len(outbound.tags) == 0 && len(outbound.providerTags) == 0 {
 		return nil, E.New("missing tags")
 	}
+	for i, schedule := range options.Schedule {
+		if schedule.Outbound == "" {
+			return nil, E.New("schedule[", i, "]: missing outbound")
+		}
+		matcher, err := timerange.New(schedule.TimeRange, schedule.Weekday, schedule.Timezone)
+		if err != nil {
+			return nil, E.Cause(err, "schedule[", i, "]")
+		}
+		outbound.schedule = append(outbound.schedule, selectorSchedule{matcher, schedule.Outbound})
+	}
+	if len(outbound.schedule) > 0 {
+		outbound.done = make(chan struct{})
+	}
 	return outbound, nil
 }
 
 func (s *Selector) Network() []string {
-	if s.selected == nil {
+	selected := s.loadSelectedOutbound()
+	if selected == nil {
 		return []string{N.NetworkTCP, N.NetworkUDP}
 	}
-	return s.selected.Network()
+	return selected.Network()
+}
+
+func (s *Selector) loadSelectedOutbound() adapter.Outbound {
+	s.access.RLock()
+	defer s.access.RUnlock()
+	return s.selected
 }
 
 func (s *Selector) Start() error {
@@ -59,12 +99,39 @@ func (s *Selector) Start() error {
 		}
 		s.outbounds[tag] = detour
 	}
+	for i, tag := range s.providerTags {
+		provider, loaded := s.router.OutboundProvider(tag)
+		if !loaded {
+			return E.New("outbound provider ", i, " not found: ", tag)
+		}
+		s.providers = append(s.providers, provider)
+		s.callbacks = append(s.callbacks, provider.RegisterCallback(s.providerUpdated))
+	}
+	for i, schedule := range s.schedule {
+		if _, loaded := s.outbounds[schedule.tag]; !loaded && len(s.providers) == 0 {
+			return E.New("schedule[", i, "]: outbound not found: ", schedule.tag)
+		}
+	}
+	err := s.loadSelected()
+	if err != nil {
+		return err
+	}
+	if len(s.schedule) > 0 {
+		s.updateSchedule()
+		go s.loopSchedule()
+	}
+	return nil
+}
 
+func (s *Selector) loadSelected() error {
+	s.access.Lock()
+	defer s.access.Unlock()
 	if s.tag != "" {
 		if clashServer := s.router.ClashServer(); clashServer != nil && clashServer.StoreSelected() {
 			selected := clashServer.CacheFile().LoadSelected(s.tag)
 			if selected != "" {
-				detour, loaded := s.outbounds[selected]
+				s.selectedTag = selected
+				detour, loaded := s.outbound(selected)
 				if loaded {
 					s.selected = detour
 					return nil
@@ -74,32 +141,66 @@ func (s *Selector) Start() error {
 	}
 
 	if s.defaultTag != "" {
-		detour, loaded := s.outbounds[s.defaultTag]
-		if !loaded {
+		detour, loaded := s.outbound(s.defaultTag)
+		if !loaded && len(s.providers) == 0 {
 			return E.New("default outbound not found: ", s.defaultTag)
 		}
-		s.selected = detour
-		return nil
+		if s.selectedTag == "" {
+			s.selectedTag = s.defaultTag
+		}
+		if loaded {
+			s.selected = detour
+			return nil
+		}
 	}
 
-	s.selected = s.outbounds[s.tags[0]]
+	s.selected = s.first()
+	return nil
+}
+
+func (s *Selector) Close() error {
+	if s.done != nil {
+		s.closeOnce.Do(func() {
+			close(s.done)
+		})
+	}
+	for i, provider := range s.providers {
+		provider.UnregisterCallback(s.callbacks[i])
+	}
 	return nil
 }
 
 func (s *Selector) Now() string {
-	return s.selected.Tag()
+	selected := s.loadSelectedOutbound()
+	if selected == nil {
+		return ""
+	}
+	return selected.Tag()
 }
 
 func (s *Selector) All() []string {
-	return s.tags
+	if len(s.providers) == 0 {
+		return s.tags
+	}
+	tags := make([]string, 0, len(s.tags))
+	tags = append(tags, s.tags...)
+	for _, provider := range s.providers {
+		for _, detour := range provider.Outbounds() {
+			tags = append(tags, detour.Tag())
+		}
+	}
+	return tags
 }
 
 func (s *Selector) SelectOutbound(tag string) bool {
-	detour, loaded := s.outbounds[tag]
+	detour, loaded := s.outbound(tag)
 	if !loaded {
 		return false
 	}
+	s.access.Lock()
+	s.selectedTag = tag
 	s.selected = detour
+	s.access.Unlock()
 	if s.tag != "" {
 		if clashServer := s.router.ClashServer(); clashServer != nil && clashServer.StoreSelected() {
 			err := clashServer.CacheFile().StoreSelected(s.tag, tag)
@@ -112,19 +213,149 @@ func (s *Selector) SelectOutbound(tag string) bool {
 }
 
 func (s *Selector) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
-	return s.selected.DialContext(ctx, network, destination)
+	selected := s.loadSelectedOutbound()
+	if selected == nil {
+		return nil, E.New("no outbound available")
+	}
+	return selected.DialContext(ctx, network, destination)
 }
 
 func (s *Selector) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
-	return s.selected.ListenPacket(ctx, destination)
+	selected := s.loadSelectedOutbound()
+	if selected == nil {
+		return nil, E.New("no outbound available")
+	}
+	return selected.ListenPacket(ctx, destination)
 }
 
 func (s *Selector) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
-	return s.selected.NewConnection(ctx, conn, metadata)
+	selected := s.loadSelectedOutbound()
+	if selected == nil {
+		return E.New("no outbound available")
+	}
+	return selected.NewConnection(ctx, conn, metadata)
 }
 
 func (s *Selector) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
-	return s.selected.NewPacketConnection(ctx, conn, metadata)
+	selected := s.loadSelectedOutbound()
+	if selected == nil {
+		return E.New("no outbound available")
+	}
+	return selected.NewPacketConnection(ctx, conn, metadata)
+}
+
+func (s *Selector) outbound(tag string) (adapter.Outbound, bool) {
+	detour, loaded := s.outbounds[tag]
+	if loaded {
+		return detour, true
+	}
+	for _, provider := range s.providers {
+		detour, loaded = provider.Outbound(tag)
+		if loaded {
+			return detour, true
+		}
+	}
+	return nil, false
+}
+
+func (s *Selector) first() adapter.Outbound {
+	if len(s.tags) > 0 {
+		return s.outbounds[s.tags[0]]
+	}
+	for _, provider := range s.providers {
+		outbounds := provider.Outbounds()
+		if len(outbounds) > 0 {
+			return outbounds[0]
+		}
+	}
+	return nil
+}
+
+func (s *Selector) providerUpdated(adapter.OutboundProvider) {
+	s.access.Lock()
+	defer s.access.Unlock()
+	if s.scheduled >= 0 {
+		detour, loaded := s.outbound(s.schedule[s.scheduled].tag)
+		if loaded {
+			s.selected = detour
+			return
+		}
+	}
+	s.restoreSelected()
+}
+
+// restoreSelected must be called with access held.
+func (s *Selector) restoreSelected() {
+	if s.selectedTag != "" {
+		detour, loaded := s.outbound(s.selectedTag)
+		if loaded {
+			s.selected = detour
+			return
+		}
+	}
+	s.selected = s.first()
+}
+
+func (s *Selector) loopSchedule() {
+	for {
+		now := s.now()
+		timer := time.NewTimer(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+		select {
+		case <-timer.C:
+			s.updateSchedule()
+		case <-s.done:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// updateSchedule switches to the outbound of the first matching schedule when
+// it changes, and back to the selected outbound when no schedule matches.
+// Outbounds selected during a schedule are kept until the next change.
+func (s *Selector) updateSchedule() {
+	now := s.now()
+	scheduled := -1
+	for i, schedule := range s.schedule {
+		if schedule.matcher.Match(now) {
+			scheduled = i
+			break
+		}
+	}
+	s.access.Lock()
+	if scheduled == s.scheduled {
+		s.access.Unlock()
+		return
+	}
+	s.scheduled = scheduled
+	if scheduled == -1 {
+		s.restoreSelected()
+		selected := s.selected
+		s.access.Unlock()
+		var tag string
+		if selected != nil {
+			tag = selected.Tag()
+		}
+		s.logger.Info("schedule ended, switched to ", tag)
+		return
+	}
+	tag := s.schedule[scheduled].tag
+	detour, loaded := s.outbound(tag)
+	if !loaded {
+		s.access.Unlock()
+		s.logger.Warn("scheduled outbound not found: ", tag)
+		return
+	}
+	s.selected = detour
+	s.access.Unlock()
+	s.logger.Info("schedule started, switched to ", tag)
+}
+
+func (s *Selector) now() time.Time {
+	if timeFunc := s.router.TimeFunc(); timeFunc != nil {
+		return timeFunc()
+	}
+	return time.Now()
 }
 
 func RealTag(detour adapter.Outbound) string {
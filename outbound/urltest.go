@@ -29,6 +29,7 @@ type URLTest struct {
 	myOutboundAdapter
 	ctx       context.Context
 	tags      []string
+	providers []string
 	link      string
 	interval  time.Duration
 	tolerance uint16
@@ -45,11 +46,12 @@ func NewURLTest(ctx context.Context, router adapter.Router, logger log.ContextLo
 		},
 		ctx:       ctx,
 		tags:      options.Outbounds,
+		providers: options.Providers,
 		link:      options.URL,
 		interval:  time.Duration(options.Interval),
 		tolerance: options.Tolerance,
 	}
-	if len(outbound.tags) == 0 {
+	if len(outbound.tags) == 0 && len(outbound.providers) == 0 {
 		return nil, E.New("missing tags")
 	}
 	return outbound, nil
@@ -59,7 +61,11 @@ func (s *URLTest) Network() []string {
 	if s.group == nil {
 		return []string{N.NetworkTCP, N.NetworkUDP}
 	}
-	return s.group.Select(N.NetworkTCP).Network()
+	selected := s.group.Select(N.NetworkTCP)
+	if selected == nil {
+		return []string{N.NetworkTCP, N.NetworkUDP}
+	}
+	return selected.Network()
 }
 
 func (s *URLTest) Start() error {
@@ -71,7 +77,15 @@ func (s *URLTest) Start() error {
 		}
 		outbounds = append(outbounds, detour)
 	}
-	s.group = NewURLTestGroup(s.ctx, s.router, s.logger, outbounds, s.link, s.interval, s.tolerance)
+	providers := make([]adapter.OutboundProvider, 0, len(s.providers))
+	for i, tag := range s.providers {
+		provider, loaded := s.router.OutboundProvider(tag)
+		if !loaded {
+			return E.New("outbound provider ", i, " not found: ", tag)
+		}
+		providers = append(providers, provider)
+	}
+	s.group = NewURLTestGroup(s.ctx, s.router, s.logger, outbounds, providers, s.link, s.interval, s.tolerance)
 	return s.group.Start()
 }
 
@@ -82,11 +96,20 @@ func (s *URLTest) Close() error {
 }
 
 func (s *URLTest) Now() string {
-	return s.group.Select(N.NetworkTCP).Tag()
+	selected := s.group.Select(N.NetworkTCP)
+	if selected == nil {
+		return ""
+	}
+	return selected.Tag()
 }
 
 func (s *URLTest) All() []string {
-	return s.tags
+	if len(s.providers) == 0 {
+		return s.tags
+	}
+	return common.Map(s.group.Outbounds(), func(it adapter.Outbound) string {
+		return it.Tag()
+	})
 }
 
 func (s *URLTest) URLTest(ctx context.Context, link string) (map[string]uint16, error) {
@@ -95,6 +118,9 @@ func (s *URLTest) URLTest(ctx context.Context, link string) (map[string]uint16,
 
 func (s *URLTest) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
 	outbound := s.group.Select(network)
+	if outbound == nil {
+		return nil, E.New("missing supported outbound")
+	}
 	conn, err := outbound.DialContext(ctx, network, destination)
 	if err == nil {
 		return conn, nil
@@ -106,6 +132,9 @@ func (s *URLTest) DialContext(ctx context.Context, network string, destination M
 
 func (s *URLTest) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
 	outbound := s.group.Select(N.NetworkUDP)
+	if outbound == nil {
+		return nil, E.New("missing supported outbound")
+	}
 	conn, err := outbound.ListenPacket(ctx, destination)
 	if err == nil {
 		return conn, nil
@@ -133,6 +162,7 @@ type URLTestGroup struct {
 	router    adapter.Router
 	logger    log.Logger
 	outbounds []adapter.Outbound
+	providers []adapter.OutboundProvider
 	link      string
 	interval  time.Duration
 	tolerance uint16
@@ -142,7 +172,7 @@ type URLTestGroup struct {
 	close  chan struct{}
 }
 
-func NewURLTestGroup(ctx context.Context, router adapter.Router, logger log.Logger, outbounds []adapter.Outbound, link string, interval time.Duration, tolerance uint16) *URLTestGroup {
+func NewURLTestGroup(ctx context.Context, router adapter.Router, logger log.Logger, outbounds []adapter.Outbound, providers []adapter.OutboundProvider, link string, interval time.Duration, tolerance uint16) *URLTestGroup {
 	if interval == 0 {
 		interval = C.DefaultURLTestInterval
 	}
@@ -160,6 +190,7 @@ func NewURLTestGroup(ctx context.Context, router adapter.Router, logger log.Logg
 		router:    router,
 		logger:    logger,
 		outbounds: outbounds,
+		providers: providers,
 		link:      link,
 		interval:  interval,
 		tolerance: tolerance,
@@ -180,11 +211,24 @@ func (g *URLTestGroup) Close() error {
 	return nil
 }
 
+func (g *URLTestGroup) Outbounds() []adapter.Outbound {
+	if len(g.providers) == 0 {
+		return g.outbounds
+	}
+	outbounds := make([]adapter.Outbound, 0, len(g.outbounds))
+	outbounds = append(outbounds, g.outbounds...)
+	for _, provider := range g.providers {
+		outbounds = append(outbounds, provider.Outbounds()...)
+	}
+	return outbounds
+}
+
 func (g *URLTestGroup) Select(network string) adapter.Outbound {
 	var minDelay uint16
 	var minTime time.Time
 	var minOutbound adapter.Outbound
-	for _, detour := range g.outbounds {
+	allOutbounds := g.Outbounds()
+	for _, detour := range allOutbounds {
 		if !common.Contains(detour.Network(), network) {
 			continue
 		}
@@ -199,7 +243,7 @@ func (g *URLTestGroup) Select(network string) adapter.Outbound {
 		}
 	}
 	if minOutbound == nil {
-		for _, detour := range g.outbounds {
+		for _, detour := range allOutbounds {
 			if !common.Contains(detour.Network(), network) {
 				continue
 			}
@@ -211,8 +255,9 @@ func (g *URLTestGroup) Select(network string) adapter.Outbound {
 }
 
 func (g *URLTestGroup) Fallback(used adapter.Outbound) []adapter.Outbound {
-	outbounds := make([]adapter.Outbound, 0, len(g.outbounds)-1)
-	for _, detour := range g.outbounds {
+	allOutbounds := g.Outbounds()
+	outbounds := make([]adapter.Outbound, 0, len(allOutbounds))
+	for _, detour := range allOutbounds {
 		if detour != used {
 			outbounds = append(outbounds, detour)
 		}
@@ -254,7 +299,7 @@ func (g *URLTestGroup) URLTest(ctx context.Context, link string) (map[string]uin
 	checked := make(map[string]bool)
 	result := make(map[string]uint16)
 	var resultAccess sync.Mutex
-	for _, detour := range g.outbounds {
+	for _, detour := range g.Outbounds() {
 		tag := detour.Tag()
 		realTag := RealTag(detour)
 		if checked[realTag] {
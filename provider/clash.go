@@ -0,0 +1,418 @@
+package provider
+
+import (
+	"encoding/base64"
+	"net/netip"
+	"sort"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	N "github.com/sagernet/sing/common/network"
+
+	"gopkg.in/yaml.v3"
+)
+
+type clashConfig struct {
+	Proxies []clashProxy `yaml:"proxies"`
+}
+
+type clashProxy struct {
+	Name   string `yaml:"name"`
+	Type   string `yaml:"type"`
+	Server string `yaml:"server"`
+	Port   uint16 `yaml:"port"`
+	UDP    *bool  `yaml:"udp"`
+
+	Username   string         `yaml:"username"`
+	Password   string         `yaml:"password"`
+	Cipher     string         `yaml:"cipher"`
+	UUID       string         `yaml:"uuid"`
+	AlterID    int            `yaml:"alterId"`
+	Flow       string         `yaml:"flow"`
+	Plugin     string         `yaml:"plugin"`
+	PluginOpts map[string]any `yaml:"plugin-opts"`
+	UDPOverTCP bool           `yaml:"udp-over-tcp"`
+
+	Obfs          string `yaml:"obfs"`
+	ObfsParam     string `yaml:"obfs-param"`
+	Protocol      string `yaml:"protocol"`
+	ProtocolParam string `yaml:"protocol-param"`
+
+	TLS               bool              `yaml:"tls"`
+	SNI               string            `yaml:"sni"`
+	ServerName        string            `yaml:"servername"`
+	SkipCertVerify    bool              `yaml:"skip-cert-verify"`
+	ALPN              []string          `yaml:"alpn"`
+	ClientFingerprint string            `yaml:"client-fingerprint"`
+	RealityOpts       *clashRealityOpts `yaml:"reality-opts"`
+
+	Network  string         `yaml:"network"`
+	WSOpts   *clashWSOpts   `yaml:"ws-opts"`
+	H2Opts   *clashH2Opts   `yaml:"h2-opts"`
+	HTTPOpts *clashHTTPOpts `yaml:"http-opts"`
+	GRPCOpts *clashGRPCOpts `yaml:"grpc-opts"`
+
+	Up                  string `yaml:"up"`
+	Down                string `yaml:"down"`
+	Auth                string `yaml:"auth"`
+	AuthString          string `yaml:"auth-str"`
+	ReceiveWindowConn   uint64 `yaml:"recv-window-conn"`
+	ReceiveWindow       uint64 `yaml:"recv-window"`
+	DisableMTUDiscovery bool   `yaml:"disable-mtu-discovery"`
+
+	IP           string  `yaml:"ip"`
+	IPv6         string  `yaml:"ipv6"`
+	PrivateKey   string  `yaml:"private-key"`
+	PublicKey    string  `yaml:"public-key"`
+	PreSharedKey string  `yaml:"pre-shared-key"`
+	Reserved     []uint8 `yaml:"reserved"`
+	MTU          uint32  `yaml:"mtu"`
+}
+
+type clashRealityOpts struct {
+	PublicKey string `yaml:"public-key"`
+	ShortID   string `yaml:"short-id"`
+}
+
+type clashWSOpts struct {
+	Path                string            `yaml:"path"`
+	Headers             map[string]string `yaml:"headers"`
+	MaxEarlyData        uint32            `yaml:"max-early-data"`
+	EarlyDataHeaderName string            `yaml:"early-data-header-name"`
+}
+
+type clashH2Opts struct {
+	Host []string `yaml:"host"`
+	Path string   `yaml:"path"`
+}
+
+type clashHTTPOpts struct {
+	Method  string              `yaml:"method"`
+	Path    []string            `yaml:"path"`
+	Headers map[string][]string `yaml:"headers"`
+}
+
+type clashGRPCOpts struct {
+	ServiceName string `yaml:"grpc-service-name"`
+}
+
+// ParseClashProxies converts the `proxies` section of a Clash configuration to outbound options.
+// Unsupported proxies are skipped with a warning.
+func ParseClashProxies(logger log.Logger, content []byte) ([]option.Outbound, error) {
+	var config clashConfig
+	err := yaml.Unmarshal(content, &config)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.Proxies) == 0 {
+		return nil, E.New("no proxies found")
+	}
+	outbounds := make([]option.Outbound, 0, len(config.Proxies))
+	for _, proxy := range config.Proxies {
+		outbound, err := proxy.Build()
+		if err != nil {
+			logger.Warn("skipping proxy ", proxy.Name, " (", proxy.Type, "): ", err)
+			continue
+		}
+		outbounds = append(outbounds, outbound)
+	}
+	return outbounds, nil
+}
+
+func (p *clashProxy) Build() (option.Outbound, error) {
+	outbound := option.Outbound{
+		Tag: p.Name,
+	}
+	serverOptions := option.ServerOptions{
+		Server:     p.Server,
+		ServerPort: p.Port,
+	}
+	var network option.NetworkList
+	if p.UDP != nil && !*p.UDP {
+		network = N.NetworkTCP
+	}
+	switch p.Type {
+	case "ss":
+		outbound.Type = C.TypeShadowsocks
+		outbound.ShadowsocksOptions = option.ShadowsocksOutboundOptions{
+			ServerOptions: serverOptions,
+			Method:        p.Cipher,
+			Password:      p.Password,
+			Network:       network,
+		}
+		switch p.Plugin {
+		case "":
+		case "obfs":
+			outbound.ShadowsocksOptions.Plugin = "obfs-local"
+			outbound.ShadowsocksOptions.PluginOptions = p.pluginArgs(map[string]string{
+				"mode": "obfs",
+				"host": "obfs-host",
+			})
+		case "v2ray-plugin":
+			outbound.ShadowsocksOptions.Plugin = "v2ray-plugin"
+			outbound.ShadowsocksOptions.PluginOptions = p.pluginArgs(map[string]string{
+				"mode": "mode",
+				"host": "host",
+				"path": "path",
+				"tls":  "tls",
+				"mux":  "mux",
+			})
+		default:
+			return outbound, E.New("unsupported shadowsocks plugin: ", p.Plugin)
+		}
+		if p.UDPOverTCP {
+			outbound.ShadowsocksOptions.UDPOverTCPOptions = &option.UDPOverTCPOptions{
+				Enabled: true,
+			}
+		}
+	case "ssr":
+		outbound.Type = C.TypeShadowsocksR
+		outbound.ShadowsocksROptions = option.ShadowsocksROutboundOptions{
+			ServerOptions: serverOptions,
+			Method:        p.Cipher,
+			Password:      p.Password,
+			Obfs:          p.Obfs,
+			ObfsParam:     p.ObfsParam,
+			Protocol:      p.Protocol,
+			ProtocolParam: p.ProtocolParam,
+			Network:       network,
+		}
+	case "vmess":
+		security := p.Cipher
+		if security == "" {
+			security = "auto"
+		}
+		outbound.Type = C.TypeVMess
+		outbound.VMessOptions = option.VMessOutboundOptions{
+			ServerOptions: serverOptions,
+			UUID:          p.UUID,
+			Security:      security,
+			AlterId:       p.AlterID,
+			Network:       network,
+			TLS:           p.buildTLS(p.TLS),
+			Transport:     p.buildTransport(),
+		}
+	case "vless":
+		outbound.Type = C.TypeVLESS
+		outbound.VLESSOptions = option.VLESSOutboundOptions{
+			ServerOptions: serverOptions,
+			UUID:          p.UUID,
+			Flow:          p.Flow,
+			Network:       network,
+			TLS:           p.buildTLS(p.TLS),
+			Transport:     p.buildTransport(),
+		}
+	case "trojan":
+		outbound.Type = C.TypeTrojan
+		outbound.TrojanOptions = option.TrojanOutboundOptions{
+			ServerOptions: serverOptions,
+			Password:      p.Password,
+			Network:       network,
+			TLS:           p.buildTLS(true),
+			Transport:     p.buildTransport(),
+		}
+	case "socks5":
+		outbound.Type = C.TypeSocks
+		outbound.SocksOptions = option.SocksOutboundOptions{
+			ServerOptions: serverOptions,
+			Username:      p.Username,
+			Password:      p.Password,
+			Network:       network,
+		}
+	case "http":
+		outbound.Type = C.TypeHTTP
+		outbound.HTTPOptions = option.HTTPOutboundOptions{
+			ServerOptions: serverOptions,
+			Username:      p.Username,
+			Password:      p.Password,
+			TLS:           p.buildTLS(p.TLS),
+		}
+	case "hysteria":
+		if p.Protocol != "" && p.Protocol != "udp" {
+			return outbound, E.New("unsupported hysteria protocol: ", p.Protocol)
+		}
+		var auth []byte
+		if p.Auth != "" {
+			var err error
+			auth, err = base64.StdEncoding.DecodeString(p.Auth)
+			if err != nil {
+				return outbound, E.Cause(err, "decode hysteria auth")
+			}
+		}
+		outbound.Type = C.TypeHysteria
+		outbound.HysteriaOptions = option.HysteriaOutboundOptions{
+			ServerOptions:       serverOptions,
+			Up:                  p.Up,
+			Down:                p.Down,
+			Obfs:                p.Obfs,
+			Auth:                auth,
+			AuthString:          p.AuthString,
+			ReceiveWindowConn:   p.ReceiveWindowConn,
+			ReceiveWindow:       p.ReceiveWindow,
+			DisableMTUDiscovery: p.DisableMTUDiscovery,
+			Network:             network,
+			TLS:                 p.buildTLS(true),
+		}
+	case "wireguard":
+		var localAddress option.Listable[option.ListenPrefix]
+		for _, address := range []string{p.IP, p.IPv6} {
+			if address == "" {
+				continue
+			}
+			prefix, err := parsePrefix(address)
+			if err != nil {
+				return outbound, E.Cause(err, "parse wireguard address")
+			}
+			localAddress = append(localAddress, option.ListenPrefix(prefix))
+		}
+		outbound.Type = C.TypeWireGuard
+		outbound.WireGuardOptions = option.WireGuardOutboundOptions{
+			ServerOptions: serverOptions,
+			LocalAddress:  localAddress,
+			PrivateKey:    p.PrivateKey,
+			PeerPublicKey: p.PublicKey,
+			PreSharedKey:  p.PreSharedKey,
+			Reserved:      p.Reserved,
+			MTU:           p.MTU,
+			Network:       network,
+		}
+	default:
+		return outbound, E.New("unsupported proxy type: ", p.Type)
+	}
+	return outbound, nil
+}
+
+func (p *clashProxy) pluginArgs(keyMap map[string]string) string {
+	keys := make([]string, 0, len(p.PluginOpts))
+	for key := range p.PluginOpts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var args []string
+	for _, key := range keys {
+		value := p.PluginOpts[key]
+		argKey, loaded := keyMap[key]
+		if !loaded {
+			continue
+		}
+		switch typedValue := value.(type) {
+		case bool:
+			if typedValue {
+				args = append(args, argKey)
+			}
+		default:
+			args = append(args, argKey+"="+F.ToString(typedValue))
+		}
+	}
+	return strings.Join(args, ";")
+}
+
+func (p *clashProxy) buildTLS(enabled bool) *option.OutboundTLSOptions {
+	if !enabled {
+		return nil
+	}
+	serverName := p.SNI
+	if serverName == "" {
+		serverName = p.ServerName
+	}
+	tlsOptions := &option.OutboundTLSOptions{
+		Enabled:    true,
+		ServerName: serverName,
+		Insecure:   p.SkipCertVerify,
+		ALPN:       p.ALPN,
+	}
+	if p.ClientFingerprint != "" {
+		tlsOptions.UTLS = &option.OutboundUTLSOptions{
+			Enabled:     true,
+			Fingerprint: p.ClientFingerprint,
+		}
+	}
+	if p.RealityOpts != nil {
+		tlsOptions.Reality = &option.OutboundRealityOptions{
+			Enabled:   true,
+			PublicKey: p.RealityOpts.PublicKey,
+			ShortID:   p.RealityOpts.ShortID,
+		}
+	}
+	return tlsOptions
+}
+
+func (p *clashProxy) buildTransport() *option.V2RayTransportOptions {
+	switch p.Network {
+	case "ws":
+		transport := &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeWebsocket,
+		}
+		if p.WSOpts != nil {
+			transport.WebsocketOptions = option.V2RayWebsocketOptions{
+				Path:                p.WSOpts.Path,
+				Headers:             make(map[string]option.Listable[string]),
+				MaxEarlyData:        p.WSOpts.MaxEarlyData,
+				EarlyDataHeaderName: p.WSOpts.EarlyDataHeaderName,
+			}
+			for key, value := range p.WSOpts.Headers {
+				transport.WebsocketOptions.Headers[key] = option.Listable[string]{value}
+			}
+		}
+		return transport
+	case "h2":
+		transport := &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeHTTP,
+		}
+		if p.H2Opts != nil {
+			transport.HTTPOptions = option.V2RayHTTPOptions{
+				Host: p.H2Opts.Host,
+				Path: p.H2Opts.Path,
+			}
+		}
+		return transport
+	case "http":
+		transport := &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeHTTP,
+		}
+		if p.HTTPOpts != nil {
+			transport.HTTPOptions = option.V2RayHTTPOptions{
+				Method:  p.HTTPOpts.Method,
+				Headers: make(map[string]option.Listable[string]),
+			}
+			if len(p.HTTPOpts.Path) > 0 {
+				transport.HTTPOptions.Path = p.HTTPOpts.Path[0]
+			}
+			for key, value := range p.HTTPOpts.Headers {
+				if strings.EqualFold(key, "Host") {
+					transport.HTTPOptions.Host = value
+					continue
+				}
+				transport.HTTPOptions.Headers[key] = value
+			}
+		}
+		return transport
+	case "grpc":
+		transport := &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeGRPC,
+		}
+		if p.GRPCOpts != nil {
+			transport.GRPCOptions = option.V2RayGRPCOptions{
+				ServiceName: p.GRPCOpts.ServiceName,
+			}
+		}
+		return transport
+	default:
+		return nil
+	}
+}
+
+func parsePrefix(address string) (netip.Prefix, error) {
+	if strings.Contains(address, "/") {
+		return netip.ParsePrefix(address)
+	}
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
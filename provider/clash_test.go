@@ -0,0 +1,274 @@
+package provider_test
+
+import (
+	"net/netip"
+	"testing"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/provider"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseClashProxies(t *testing.T) {
+	t.Parallel()
+	serverOptions := option.ServerOptions{
+		Server:     "example.org",
+		ServerPort: 443,
+	}
+	testCases := []struct {
+		name     string
+		proxy    string
+		outbound option.Outbound
+	}{
+		{
+			name:  "shadowsocks",
+			proxy: `{name: a, type: ss, server: example.org, port: 443, cipher: aes-128-gcm, password: pw, udp: false, udp-over-tcp: true}`,
+			outbound: option.Outbound{
+				Type: C.TypeShadowsocks,
+				Tag:  "a",
+				ShadowsocksOptions: option.ShadowsocksOutboundOptions{
+					ServerOptions:     serverOptions,
+					Method:            "aes-128-gcm",
+					Password:          "pw",
+					Network:           N.NetworkTCP,
+					UDPOverTCPOptions: &option.UDPOverTCPOptions{Enabled: true},
+				},
+			},
+		},
+		{
+			name:  "shadowsocks obfs",
+			proxy: `{name: a, type: ss, server: example.org, port: 443, cipher: aes-128-gcm, password: pw, plugin: obfs, plugin-opts: {mode: http, host: bing.com}}`,
+			outbound: option.Outbound{
+				Type: C.TypeShadowsocks,
+				Tag:  "a",
+				ShadowsocksOptions: option.ShadowsocksOutboundOptions{
+					ServerOptions: serverOptions,
+					Method:        "aes-128-gcm",
+					Password:      "pw",
+					Plugin:        "obfs-local",
+					PluginOptions: "obfs-host=bing.com;obfs=http",
+				},
+			},
+		},
+		{
+			name:  "shadowsocks v2ray-plugin",
+			proxy: `{name: a, type: ss, server: example.org, port: 443, cipher: aes-128-gcm, password: pw, plugin: v2ray-plugin, plugin-opts: {mode: websocket, tls: true, mux: false, path: /ws}}`,
+			outbound: option.Outbound{
+				Type: C.TypeShadowsocks,
+				Tag:  "a",
+				ShadowsocksOptions: option.ShadowsocksOutboundOptions{
+					ServerOptions: serverOptions,
+					Method:        "aes-128-gcm",
+					Password:      "pw",
+					Plugin:        "v2ray-plugin",
+					PluginOptions: "mode=websocket;path=/ws;tls",
+				},
+			},
+		},
+		{
+			name:  "shadowsocksr",
+			proxy: `{name: a, type: ssr, server: example.org, port: 443, cipher: aes-256-cfb, password: pw, obfs: tls1.2_ticket_auth, obfs-param: bing.com, protocol: auth_aes128_md5, protocol-param: "1:pw"}`,
+			outbound: option.Outbound{
+				Type: C.TypeShadowsocksR,
+				Tag:  "a",
+				ShadowsocksROptions: option.ShadowsocksROutboundOptions{
+					ServerOptions: serverOptions,
+					Method:        "aes-256-cfb",
+					Password:      "pw",
+					Obfs:          "tls1.2_ticket_auth",
+					ObfsParam:     "bing.com",
+					Protocol:      "auth_aes128_md5",
+					ProtocolParam: "1:pw",
+				},
+			},
+		},
+		{
+			name:  "vmess websocket",
+			proxy: `{name: a, type: vmess, server: example.org, port: 443, uuid: b831381d-6324-4d53-ad4f-8cda48b30811, alterId: 0, tls: true, servername: bing.com, network: ws, ws-opts: {path: /ws, headers: {Host: bing.com}}}`,
+			outbound: option.Outbound{
+				Type: C.TypeVMess,
+				Tag:  "a",
+				VMessOptions: option.VMessOutboundOptions{
+					ServerOptions: serverOptions,
+					UUID:          "b831381d-6324-4d53-ad4f-8cda48b30811",
+					Security:      "auto",
+					TLS: &option.OutboundTLSOptions{
+						Enabled:    true,
+						ServerName: "bing.com",
+					},
+					Transport: &option.V2RayTransportOptions{
+						Type: C.V2RayTransportTypeWebsocket,
+						WebsocketOptions: option.V2RayWebsocketOptions{
+							Path: "/ws",
+							Headers: map[string]option.Listable[string]{
+								"Host": {"bing.com"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:  "vless reality grpc",
+			proxy: `{name: a, type: vless, server: example.org, port: 443, uuid: b831381d-6324-4d53-ad4f-8cda48b30811, tls: true, servername: bing.com, client-fingerprint: chrome, reality-opts: {public-key: key, short-id: "01"}, network: grpc, grpc-opts: {grpc-service-name: service}}`,
+			outbound: option.Outbound{
+				Type: C.TypeVLESS,
+				Tag:  "a",
+				VLESSOptions: option.VLESSOutboundOptions{
+					ServerOptions: serverOptions,
+					UUID:          "b831381d-6324-4d53-ad4f-8cda48b30811",
+					TLS: &option.OutboundTLSOptions{
+						Enabled:    true,
+						ServerName: "bing.com",
+						UTLS: &option.OutboundUTLSOptions{
+							Enabled:     true,
+							Fingerprint: "chrome",
+						},
+						Reality: &option.OutboundRealityOptions{
+							Enabled:   true,
+							PublicKey: "key",
+							ShortID:   "01",
+						},
+					},
+					Transport: &option.V2RayTransportOptions{
+						Type: C.V2RayTransportTypeGRPC,
+						GRPCOptions: option.V2RayGRPCOptions{
+							ServiceName: "service",
+						},
+					},
+				},
+			},
+		},
+		{
+			name:  "trojan",
+			proxy: `{name: a, type: trojan, server: example.org, port: 443, password: pw, sni: bing.com, skip-cert-verify: true, alpn: [h2]}`,
+			outbound: option.Outbound{
+				Type: C.TypeTrojan,
+				Tag:  "a",
+				TrojanOptions: option.TrojanOutboundOptions{
+					ServerOptions: serverOptions,
+					Password:      "pw",
+					TLS: &option.OutboundTLSOptions{
+						Enabled:    true,
+						ServerName: "bing.com",
+						Insecure:   true,
+						ALPN:       option.Listable[string]{"h2"},
+					},
+				},
+			},
+		},
+		{
+			name:  "socks5",
+			proxy: `{name: a, type: socks5, server: example.org, port: 443, username: user, password: pw}`,
+			outbound: option.Outbound{
+				Type: C.TypeSocks,
+				Tag:  "a",
+				SocksOptions: option.SocksOutboundOptions{
+					ServerOptions: serverOptions,
+					Username:      "user",
+					Password:      "pw",
+				},
+			},
+		},
+		{
+			name:  "http",
+			proxy: `{name: a, type: http, server: example.org, port: 443, username: user, password: pw, tls: true}`,
+			outbound: option.Outbound{
+				Type: C.TypeHTTP,
+				Tag:  "a",
+				HTTPOptions: option.HTTPOutboundOptions{
+					ServerOptions: serverOptions,
+					Username:      "user",
+					Password:      "pw",
+					TLS: &option.OutboundTLSOptions{
+						Enabled: true,
+					},
+				},
+			},
+		},
+		{
+			name:  "hysteria",
+			proxy: `{name: a, type: hysteria, server: example.org, port: 443, up: 10 Mbps, down: 50 Mbps, auth-str: pw, obfs: salt, sni: bing.com}`,
+			outbound: option.Outbound{
+				Type: C.TypeHysteria,
+				Tag:  "a",
+				HysteriaOptions: option.HysteriaOutboundOptions{
+					ServerOptions: serverOptions,
+					Up:            "10 Mbps",
+					Down:          "50 Mbps",
+					Obfs:          "salt",
+					AuthString:    "pw",
+					TLS: &option.OutboundTLSOptions{
+						Enabled:    true,
+						ServerName: "bing.com",
+					},
+				},
+			},
+		},
+		{
+			name:  "wireguard",
+			proxy: `{name: a, type: wireguard, server: example.org, port: 443, ip: 172.16.0.2, ipv6: "fd00::2/128", private-key: private, public-key: public, reserved: [1, 2, 3], mtu: 1280}`,
+			outbound: option.Outbound{
+				Type: C.TypeWireGuard,
+				Tag:  "a",
+				WireGuardOptions: option.WireGuardOutboundOptions{
+					ServerOptions: serverOptions,
+					LocalAddress: option.Listable[option.ListenPrefix]{
+						option.ListenPrefix(netip.MustParsePrefix("172.16.0.2/32")),
+						option.ListenPrefix(netip.MustParsePrefix("fd00::2/128")),
+					},
+					PrivateKey:    "private",
+					PeerPublicKey: "public",
+					Reserved:      []uint8{1, 2, 3},
+					MTU:           1280,
+				},
+			},
+		},
+	}
+	logger := log.NewNOPFactory().Logger()
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			outbounds, err := provider.ParseClashProxies(logger, []byte("proxies:\n  - "+testCase.proxy+"\n"))
+			require.NoError(t, err)
+			require.Equal(t, []option.Outbound{testCase.outbound}, outbounds)
+		})
+	}
+}
+
+func TestParseClashProxiesSkipped(t *testing.T) {
+	t.Parallel()
+	logger := log.NewNOPFactory().Logger()
+	content := `
+proxies:
+  - {name: snell, type: snell, server: example.org, port: 443, psk: pw}
+  - {name: plugin, type: ss, server: example.org, port: 443, cipher: aes-128-gcm, password: pw, plugin: shadow-tls}
+  - {name: auth, type: hysteria, server: example.org, port: 443, auth: "not base64"}
+  - {name: tcp, type: hysteria, server: example.org, port: 443, protocol: faketcp}
+  - {name: address, type: wireguard, server: example.org, port: 443, ip: bad}
+  - {name: socks, type: socks5, server: example.org, port: 443}
+`
+	outbounds, err := provider.ParseClashProxies(logger, []byte(content))
+	require.NoError(t, err)
+	require.Len(t, outbounds, 1)
+	require.Equal(t, "socks", outbounds[0].Tag)
+}
+
+func TestParseClashProxiesMalformed(t *testing.T) {
+	t.Parallel()
+	logger := log.NewNOPFactory().Logger()
+	for _, content := range []string{
+		"proxies: [",
+		"proxies: {name: a}",
+		"proxies: []",
+		"rules: []",
+	} {
+		_, err := provider.ParseClashProxies(logger, []byte(content))
+		require.Error(t, err, content)
+	}
+}
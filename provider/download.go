@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+func Detour(router adapter.Router, tag string) (N.Dialer, error) {
+	if tag == "" {
+		return router.DefaultOutbound(N.NetworkTCP), nil
+	}
+	outbound, loaded := router.Outbound(tag)
+	if !loaded {
+		return nil, E.New("detour outbound not found: ", tag)
+	}
+	return outbound, nil
+}
+
+func Download(ctx context.Context, detour N.Dialer, downloadURL string) ([]byte, error) {
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			ForceAttemptHTTP2:   true,
+			TLSHandshakeTimeout: 5 * time.Second,
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return detour.DialContext(ctx, network, M.ParseSocksaddr(addr))
+			},
+		},
+	}
+	defer httpClient.CloseIdleConnections()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, downloadURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("User-Agent", "sing-box "+C.Version)
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, E.New("unexpected status: ", response.Status)
+	}
+	var content bytes.Buffer
+	_, err = io.Copy(&content, response.Body)
+	if err != nil {
+		return nil, err
+	}
+	return content.Bytes(), nil
+}
+
+func SaveFile(savePath string, content []byte) error {
+	if parentDir := filepath.Dir(savePath); parentDir != "" {
+		os.MkdirAll(parentDir, 0o755)
+	}
+	return os.WriteFile(savePath, content, 0o644)
+}
@@ -0,0 +1,334 @@
+package provider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/urltest"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/outbound"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/batch"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/rw"
+	"github.com/sagernet/sing/common/x/list"
+)
+
+var _ adapter.OutboundProvider = (*Outbound)(nil)
+
+type Outbound struct {
+	ctx            context.Context
+	router         adapter.Router
+	logFactory     log.Factory
+	logger         log.ContextLogger
+	providerType   string
+	tag            string
+	path           string
+	downloadURL    string
+	downloadDetour string
+	updateInterval time.Duration
+
+	access        sync.RWMutex
+	outbounds     []adapter.Outbound
+	outboundByTag map[string]adapter.Outbound
+	updatedAt     time.Time
+	updateAccess  sync.Mutex
+
+	callbackAccess sync.Mutex
+	callbacks      list.List[adapter.OutboundProviderUpdateCallback]
+
+	ticker *time.Ticker
+	close  chan struct{}
+}
+
+func NewOutbound(ctx context.Context, router adapter.Router, logFactory log.Factory, options option.OutboundProvider) (*Outbound, error) {
+	if options.Tag == "" {
+		return nil, E.New("missing tag")
+	}
+	provider := &Outbound{
+		ctx:            ctx,
+		router:         router,
+		logFactory:     logFactory,
+		logger:         logFactory.NewLogger(F.ToString("provider/outbound[", options.Tag, "]")),
+		tag:            options.Tag,
+		downloadURL:    options.DownloadURL,
+		downloadDetour: options.DownloadDetour,
+		updateInterval: time.Duration(options.UpdateInterval),
+		outboundByTag:  make(map[string]adapter.Outbound),
+		close:          make(chan struct{}),
+	}
+	if options.DownloadURL != "" {
+		provider.providerType = C.ProviderTypeRemote
+	} else {
+		provider.providerType = C.ProviderTypeLocal
+		if options.Path == "" {
+			return nil, E.New("missing path or download_url")
+		}
+	}
+	path := options.Path
+	if path == "" {
+		path = filepath.Join("providers", options.Tag)
+	}
+	if foundPath, loaded := C.FindPath(path); loaded {
+		path = foundPath
+	}
+	provider.path = C.BasePath(path)
+	return provider, nil
+}
+
+func (p *Outbound) Type() string {
+	return p.providerType
+}
+
+func (p *Outbound) Tag() string {
+	return p.tag
+}
+
+func (p *Outbound) Start() error {
+	var needUpdate bool
+	if rw.FileExists(p.path) {
+		err := p.loadFile()
+		if err != nil {
+			if p.providerType == C.ProviderTypeLocal {
+				return err
+			}
+			p.logger.Error(E.Cause(err, "load cached provider"))
+			needUpdate = true
+		} else if p.providerType == C.ProviderTypeRemote && p.updateInterval > 0 {
+			if fileInfo, err := os.Stat(p.path); err == nil && time.Since(fileInfo.ModTime()) > p.updateInterval {
+				go p.updateOnce()
+			}
+		}
+	} else if p.providerType == C.ProviderTypeLocal {
+		return E.New("provider file not exists: ", p.path)
+	} else {
+		needUpdate = true
+	}
+	if needUpdate {
+		err := p.Update()
+		if err != nil {
+			p.logger.Error(err)
+		}
+	}
+	if p.updateInterval > 0 {
+		p.ticker = time.NewTicker(p.updateInterval)
+		go p.loopUpdate()
+	}
+	return nil
+}
+
+func (p *Outbound) Close() error {
+	if p.ticker != nil {
+		p.ticker.Stop()
+	}
+	close(p.close)
+	p.access.Lock()
+	defer p.access.Unlock()
+	var err error
+	for _, detour := range p.outbounds {
+		err = E.Append(err, common.Close(detour), func(err error) error {
+			return E.Cause(err, "close outbound/", detour.Type(), "[", detour.Tag(), "]")
+		})
+	}
+	p.outbounds = nil
+	p.outboundByTag = nil
+	return err
+}
+
+func (p *Outbound) Outbounds() []adapter.Outbound {
+	p.access.RLock()
+	defer p.access.RUnlock()
+	return p.outbounds
+}
+
+func (p *Outbound) Outbound(tag string) (adapter.Outbound, bool) {
+	p.access.RLock()
+	defer p.access.RUnlock()
+	detour, loaded := p.outboundByTag[tag]
+	return detour, loaded
+}
+
+func (p *Outbound) UpdatedAt() time.Time {
+	p.access.RLock()
+	defer p.access.RUnlock()
+	return p.updatedAt
+}
+
+func (p *Outbound) Update() error {
+	p.updateAccess.Lock()
+	defer p.updateAccess.Unlock()
+	if p.providerType == C.ProviderTypeLocal {
+		return p.loadFile()
+	}
+	p.logger.Info("downloading provider")
+	detour, err := Detour(p.router, p.downloadDetour)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(p.ctx, C.ProviderDownloadTimeout)
+	defer cancel()
+	content, err := Download(ctx, detour, p.downloadURL)
+	if err != nil {
+		return E.Cause(err, "download provider")
+	}
+	err = p.load(content)
+	if err != nil {
+		return err
+	}
+	err = SaveFile(p.path, content)
+	if err != nil {
+		p.logger.Warn(E.Cause(err, "save provider"))
+	}
+	return nil
+}
+
+func (p *Outbound) HealthCheck(ctx context.Context) (map[string]uint16, error) {
+	var history *urltest.HistoryStorage
+	if clashServer := p.router.ClashServer(); clashServer != nil {
+		history = clashServer.HistoryStorage()
+	} else {
+		history = urltest.NewHistoryStorage()
+	}
+	b, _ := batch.New(ctx, batch.WithConcurrencyNum[any](10))
+	result := make(map[string]uint16)
+	var resultAccess sync.Mutex
+	for _, detour := range p.Outbounds() {
+		tag := detour.Tag()
+		detour := detour
+		b.Go(tag, func() (any, error) {
+			testCtx, cancel := context.WithTimeout(ctx, C.TCPTimeout)
+			defer cancel()
+			t, err := urltest.URLTest(testCtx, "", detour)
+			if err != nil {
+				history.DeleteURLTestHistory(tag)
+			} else {
+				history.StoreURLTestHistory(tag, &urltest.History{
+					Time:  time.Now(),
+					Delay: t,
+				})
+				resultAccess.Lock()
+				result[tag] = t
+				resultAccess.Unlock()
+			}
+			return nil, nil
+		})
+	}
+	b.Wait()
+	return result, nil
+}
+
+func (p *Outbound) RegisterCallback(callback adapter.OutboundProviderUpdateCallback) *list.Element[adapter.OutboundProviderUpdateCallback] {
+	p.callbackAccess.Lock()
+	defer p.callbackAccess.Unlock()
+	return p.callbacks.PushBack(callback)
+}
+
+func (p *Outbound) UnregisterCallback(element *list.Element[adapter.OutboundProviderUpdateCallback]) {
+	p.callbackAccess.Lock()
+	defer p.callbackAccess.Unlock()
+	p.callbacks.Remove(element)
+}
+
+func (p *Outbound) loopUpdate() {
+	for {
+		select {
+		case <-p.close:
+			return
+		case <-p.ticker.C:
+			p.updateOnce()
+		}
+	}
+}
+
+func (p *Outbound) updateOnce() {
+	err := p.Update()
+	if err != nil {
+		p.logger.Error(err)
+	}
+}
+
+func (p *Outbound) loadFile() error {
+	content, err := os.ReadFile(p.path)
+	if err != nil {
+		return E.Cause(err, "read provider file")
+	}
+	return p.load(content)
+}
+
+func (p *Outbound) load(content []byte) error {
+	outboundOptions, err := ParseOutbounds(p.logger, content)
+	if err != nil {
+		return E.Cause(err, "parse provider")
+	}
+	outbounds := make([]adapter.Outbound, 0, len(outboundOptions))
+	outboundByTag := make(map[string]adapter.Outbound)
+	for i, options := range outboundOptions {
+		if options.Tag == "" {
+			options.Tag = F.ToString(p.tag, "-", i)
+		}
+		if _, exists := outboundByTag[options.Tag]; exists {
+			p.logger.Warn("ignoring outbound with duplicate tag: ", options.Tag)
+			continue
+		}
+		if _, exists := p.router.Outbound(options.Tag); exists {
+			if _, isProvided := p.Outbound(options.Tag); !isProvided {
+				p.logger.Warn("ignoring outbound with duplicate tag: ", options.Tag)
+				continue
+			}
+		}
+		switch options.Type {
+		case C.TypeSelector, C.TypeURLTest, C.TypeLoadBalance, C.TypeFallback, C.TypeChain:
+			p.logger.Warn("ignoring unsupported outbound type in provider: ", options.Type)
+			continue
+		}
+		detour, err := outbound.New(
+			p.ctx,
+			p.router,
+			p.logFactory.NewLogger(F.ToString("outbound/", options.Type, "[", options.Tag, "]")),
+			options.Tag,
+			options,
+		)
+		if err != nil {
+			p.logger.Warn(E.Cause(err, "parse outbound[", options.Tag, "]"))
+			continue
+		}
+		if starter, isStarter := detour.(common.Starter); isStarter {
+			err = starter.Start()
+			if err != nil {
+				common.Close(detour)
+				p.logger.Warn(E.Cause(err, "initialize outbound/", options.Type, "[", options.Tag, "]"))
+				continue
+			}
+		}
+		outbounds = append(outbounds, detour)
+		outboundByTag[options.Tag] = detour
+	}
+	p.access.Lock()
+	oldOutbounds := p.outbounds
+	p.outbounds = outbounds
+	p.outboundByTag = outboundByTag
+	p.updatedAt = time.Now()
+	p.access.Unlock()
+	p.logger.Info("loaded ", len(outbounds), " outbounds")
+	p.emit()
+	for _, detour := range oldOutbounds {
+		common.Close(detour)
+	}
+	return nil
+}
+
+func (p *Outbound) emit() {
+	p.callbackAccess.Lock()
+	callbacks := p.callbacks.Array()
+	p.callbackAccess.Unlock()
+	for _, callback := range callbacks {
+		callback(p)
+	}
+}
@@ -0,0 +1,41 @@
+package provider
+
+import (
+	"bytes"
+
+	"github.com/sagernet/sing-box/common/json"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+type outboundContent struct {
+	Outbounds []option.Outbound `json:"outbounds"`
+}
+
+// ParseOutbounds accepts sing-box outbound JSON (an object with `outbounds`, or a bare array)
+// and Clash YAML with a `proxies` list.
+func ParseOutbounds(logger log.Logger, content []byte) ([]option.Outbound, error) {
+	content = bytes.TrimSpace(content)
+	if len(content) == 0 {
+		return nil, E.New("empty content")
+	}
+	switch content[0] {
+	case '{':
+		var options outboundContent
+		err := json.NewDecoder(json.NewCommentFilter(bytes.NewReader(content))).Decode(&options)
+		if err != nil {
+			return nil, err
+		}
+		return options.Outbounds, nil
+	case '[':
+		var outbounds []option.Outbound
+		err := json.NewDecoder(json.NewCommentFilter(bytes.NewReader(content))).Decode(&outbounds)
+		if err != nil {
+			return nil, err
+		}
+		return outbounds, nil
+	default:
+		return ParseClashProxies(logger, content)
+	}
+}
@@ -4,14 +4,13 @@ import (
 	"context"
 	"net"
 	"net/netip"
-	"net/url"
 	"os"
 	"os/user"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
-	"github.com/sagernet/sing-box/common/dialer"
 	"github.com/sagernet/sing-box/common/geoip"
 	"github.com/sagernet/sing-box/common/geosite"
 	"github.com/sagernet/sing-box/common/mux"
@@ -20,6 +19,7 @@ import (
 	"github.com/sagernet/sing-box/common/warning"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/experimental/libbox/platform"
+	"github.com/sagernet/sing-box/experimental/trackerconn"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/ntp"
 	"github.com/sagernet/sing-box/option"
@@ -29,6 +29,7 @@ import (
 	tun "github.com/sagernet/sing-tun"
 	vmess "github.com/sagernet/sing-vmess"
 	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/atomic"
 	"github.com/sagernet/sing/common/buf"
 	"github.com/sagernet/sing/common/bufio"
 	"github.com/sagernet/sing/common/control"
@@ -64,12 +65,23 @@ var _ adapter.Router = (*Router)(nil)
 
 type Router struct {
 	ctx                                context.Context
+	logFactory                         log.Factory
 	logger                             log.ContextLogger
 	dnsLogger                          log.ContextLogger
 	inboundByTag                       map[string]adapter.Inbound
 	outbounds                          []adapter.Outbound
 	outboundByTag                      map[string]adapter.Outbound
+	outboundProviders                  []adapter.OutboundProvider
+	outboundProviderByTag              map[string]adapter.OutboundProvider
+	ruleProviders                      []adapter.RuleProvider
+	ruleProviderByTag                  map[string]adapter.RuleProvider
+	access                             sync.RWMutex
+	routeOptions                       option.RouteOptions
+	dnsOptions                         option.DNSOptions
 	rules                              []adapter.Rule
+	ruleIndex                          *ruleIndex
+	script                             adapter.RouteScript
+	scriptStatistics                   adapter.RuleStatistics
 	ipRules                            []adapter.IPRule
 	defaultDetour                      string
 	defaultOutboundForConnection       adapter.Outbound
@@ -84,6 +96,11 @@ type Router struct {
 	dnsClient                          *dns.Client
 	defaultDomainStrategy              dns.DomainStrategy
 	dnsRules                           []adapter.DNSRule
+	dnsResponseRules                   []*DNSResponseRule
+	dnsCache                           *dnsCache
+	dnsCacheStorage                    adapter.DNSCacheStorage
+	dnsCachePurgeDone                  chan struct{}
+	dnsDisableExpire                   bool
 	defaultTransport                   dns.Transport
 	transports                         []dns.Transport
 	transportMap                       map[string]dns.Transport
@@ -101,6 +118,8 @@ type Router struct {
 	timeService                        adapter.TimeService
 	clashServer                        adapter.ClashServer
 	v2rayServer                        adapter.V2RayServer
+	metricsServer                      adapter.MetricsServer
+	accessLogger                       adapter.AccessLogger
 	platformInterface                  platform.Interface
 }
 
@@ -125,13 +144,13 @@ func NewRouter(
 
 	router := &Router{
 		ctx:                   ctx,
+		logFactory:            logFactory,
 		logger:                logFactory.NewLogger("router"),
 		dnsLogger:             logFactory.NewLogger("dns"),
+		routeOptions:          options,
+		dnsOptions:            dnsOptions,
 		outboundByTag:         make(map[string]adapter.Outbound),
-		rules:                 make([]adapter.Rule, 0, len(options.Rules)),
-		ipRules:               make([]adapter.IPRule, 0, len(options.IPRules)),
-		dnsRules:              make([]adapter.DNSRule, 0, len(dnsOptions.Rules)),
-		needGeoIPDatabase:     hasRule(options.Rules, isGeoIPRule) || hasDNSRule(dnsOptions.Rules, isGeoIPDNSRule),
+		needGeoIPDatabase:     hasRule(options.Rules, isGeoIPRule) || hasDNSRule(dnsOptions.Rules, isGeoIPDNSRule) || hasGeoIPDNSResponseRule(dnsOptions.ResponseRules) || isGeoIPScript(options.Script),
 		needGeositeDatabase:   hasRule(options.Rules, isGeositeRule) || hasDNSRule(dnsOptions.Rules, isGeositeDNSRule),
 		geoIPOptions:          common.PtrValueOrDefault(options.GeoIP),
 		geositeOptions:        common.PtrValueOrDefault(options.Geosite),
@@ -144,134 +163,29 @@ func NewRouter(
 		platformInterface:     platformInterface,
 	}
 	router.dnsClient = dns.NewClient(dnsOptions.DNSClientOptions.DisableCache, dnsOptions.DNSClientOptions.DisableExpire, router.dnsLogger)
-	for i, ruleOptions := range options.Rules {
-		routeRule, err := NewRule(router, router.logger, ruleOptions)
-		if err != nil {
-			return nil, E.Cause(err, "parse rule[", i, "]")
-		}
-		router.rules = append(router.rules, routeRule)
-	}
-	for i, ipRuleOptions := range options.IPRules {
-		ipRule, err := NewIPRule(router, router.logger, ipRuleOptions)
-		if err != nil {
-			return nil, E.Cause(err, "parse ip rule[", i, "]")
-		}
-		router.ipRules = append(router.ipRules, ipRule)
+	router.dnsDisableExpire = dnsOptions.DNSClientOptions.DisableExpire
+	if !dnsOptions.DNSClientOptions.DisableCache {
+		router.dnsCache = newDNSCache()
 	}
-	for i, dnsRuleOptions := range dnsOptions.Rules {
-		dnsRule, err := NewDNSRule(router, router.logger, dnsRuleOptions)
-		if err != nil {
-			return nil, E.Cause(err, "parse dns rule[", i, "]")
-		}
-		router.dnsRules = append(router.dnsRules, dnsRule)
-	}
-
-	transports := make([]dns.Transport, len(dnsOptions.Servers))
-	dummyTransportMap := make(map[string]dns.Transport)
-	transportMap := make(map[string]dns.Transport)
-	transportTags := make([]string, len(dnsOptions.Servers))
-	transportTagMap := make(map[string]bool)
-	transportDomainStrategy := make(map[dns.Transport]dns.DomainStrategy)
-	for i, server := range dnsOptions.Servers {
-		var tag string
-		if server.Tag != "" {
-			tag = server.Tag
-		} else {
-			tag = F.ToString(i)
-		}
-		if transportTagMap[tag] {
-			return nil, E.New("duplicate dns server tag: ", tag)
-		}
-		transportTags[i] = tag
-		transportTagMap[tag] = true
+	var err error
+	router.ruleProviders, router.ruleProviderByTag, err = router.newRuleProviders(router, options.RuleProviders, nil)
+	if err != nil {
+		return nil, err
 	}
-	ctx = adapter.ContextWithRouter(ctx, router)
-	for {
-		lastLen := len(dummyTransportMap)
-		for i, server := range dnsOptions.Servers {
-			tag := transportTags[i]
-			if _, exists := dummyTransportMap[tag]; exists {
-				continue
-			}
-			var detour N.Dialer
-			if server.Detour == "" {
-				detour = dialer.NewRouter(router)
-			} else {
-				detour = dialer.NewDetour(router, server.Detour)
-			}
-			switch server.Address {
-			case "local":
-			default:
-				serverURL, _ := url.Parse(server.Address)
-				var serverAddress string
-				if serverURL != nil {
-					serverAddress = serverURL.Hostname()
-				}
-				if serverAddress == "" {
-					serverAddress = server.Address
-				}
-				_, notIpAddress := netip.ParseAddr(serverAddress)
-				if server.AddressResolver != "" {
-					if !transportTagMap[server.AddressResolver] {
-						return nil, E.New("parse dns server[", tag, "]: address resolver not found: ", server.AddressResolver)
-					}
-					if upstream, exists := dummyTransportMap[server.AddressResolver]; exists {
-						detour = dns.NewDialerWrapper(detour, router.dnsClient, upstream, dns.DomainStrategy(server.AddressStrategy), time.Duration(server.AddressFallbackDelay))
-					} else {
-						continue
-					}
-				} else if notIpAddress != nil && strings.Contains(server.Address, ".") {
-					return nil, E.New("parse dns server[", tag, "]: missing address_resolver")
-				}
-			}
-			transport, err := dns.CreateTransport(tag, ctx, logFactory.NewLogger(F.ToString("dns/transport[", tag, "]")), detour, server.Address)
-			if err != nil {
-				return nil, E.Cause(err, "parse dns server[", tag, "]")
-			}
-			transports[i] = transport
-			dummyTransportMap[tag] = transport
-			if server.Tag != "" {
-				transportMap[server.Tag] = transport
-			}
-			strategy := dns.DomainStrategy(server.Strategy)
-			if strategy != dns.DomainStrategyAsIS {
-				transportDomainStrategy[transport] = strategy
-			}
-		}
-		if len(transports) == len(dummyTransportMap) {
-			break
-		}
-		if lastLen != len(dummyTransportMap) {
-			continue
-		}
-		unresolvedTags := common.MapIndexed(common.FilterIndexed(dnsOptions.Servers, func(index int, server option.DNSServerOptions) bool {
-			_, exists := dummyTransportMap[transportTags[index]]
-			return !exists
-		}), func(index int, server option.DNSServerOptions) string {
-			return transportTags[index]
-		})
-		if len(unresolvedTags) == 0 {
-			panic(F.ToString("unexpected unresolved dns servers: ", len(transports), " ", len(dummyTransportMap), " ", len(transportMap)))
-		}
-		return nil, E.New("found circular reference in dns servers: ", strings.Join(unresolvedTags, " "))
+	router.script, router.rules, router.ipRules, router.dnsRules, err = router.newRules(router, options, dnsOptions)
+	if err != nil {
+		return nil, err
 	}
-	var defaultTransport dns.Transport
-	if dnsOptions.Final != "" {
-		defaultTransport = dummyTransportMap[dnsOptions.Final]
-		if defaultTransport == nil {
-			return nil, E.New("default dns server not found: ", dnsOptions.Final)
-		}
+	router.ruleIndex = newRuleIndex(router.rules)
+	router.transports, router.transportMap, router.transportDomainStrategy, router.defaultTransport, err = router.newTransports(router, dnsOptions, nil)
+	if err != nil {
+		return nil, err
 	}
-	if defaultTransport == nil {
-		if len(transports) == 0 {
-			transports = append(transports, dns.NewLocalTransport("local", N.SystemDialer))
-		}
-		defaultTransport = transports[0]
+	router.dnsResponseRules, err = router.newDNSResponseRules(router, dnsOptions, router.transportMap)
+	if err != nil {
+		return nil, err
 	}
-	router.defaultTransport = defaultTransport
-	router.transports = transports
-	router.transportMap = transportMap
-	router.transportDomainStrategy = transportDomainStrategy
+	ctx = adapter.ContextWithRouter(ctx, router)
 
 	if dnsOptions.ReverseMapping {
 		router.dnsReverseMapping = NewDNSReverseMapping()
@@ -312,7 +226,7 @@ func NewRouter(
 		router.interfaceMonitor = interfaceMonitor
 	}
 
-	needFindProcess := hasRule(options.Rules, isProcessRule) || hasDNSRule(dnsOptions.Rules, isProcessDNSRule) || options.FindProcess
+	needFindProcess := hasRule(options.Rules, isProcessRule) || hasDNSRule(dnsOptions.Rules, isProcessDNSRule) || isProcessScript(options.Script) || options.FindProcess
 	needPackageManager := C.IsAndroid && platformInterface == nil && (needFindProcess || common.Any(inbounds, func(inbound option.Inbound) bool {
 		return len(inbound.TunOptions.IncludePackage) > 0 || len(inbound.TunOptions.ExcludePackage) > 0
 	}))
@@ -346,7 +260,42 @@ func NewRouter(
 	return router, nil
 }
 
-func (r *Router) Initialize(inbounds []adapter.Inbound, outbounds []adapter.Outbound, defaultOutbound func() adapter.Outbound) error {
+func (r *Router) Initialize(inbounds []adapter.Inbound, outbounds []adapter.Outbound, outboundProviders []adapter.OutboundProvider, defaultOutbound func() adapter.Outbound) error {
+	err := r.initializeOutbounds(r.defaultDetour, inbounds, outbounds, outboundProviders, defaultOutbound)
+	if err != nil {
+		return err
+	}
+	for i, rule := range r.rules {
+		if _, loaded := r.outboundByTag[rule.Outbound()]; !loaded {
+			return E.New("outbound not found for rule[", i, "]: ", rule.Outbound())
+		}
+	}
+	return nil
+}
+
+func (r *Router) initializeOutbounds(defaultDetour string, inbounds []adapter.Inbound, outbounds []adapter.Outbound, outboundProviders []adapter.OutboundProvider, defaultOutbound func() adapter.Outbound) error {
+	state, err := r.newOutboundState(defaultDetour, inbounds, outbounds, outboundProviders, defaultOutbound)
+	if err != nil {
+		return err
+	}
+	r.access.Lock()
+	r.setOutboundState(state)
+	r.access.Unlock()
+	return nil
+}
+
+type outboundState struct {
+	defaultDetour                      string
+	inboundByTag                       map[string]adapter.Inbound
+	outbounds                          []adapter.Outbound
+	outboundByTag                      map[string]adapter.Outbound
+	outboundProviders                  []adapter.OutboundProvider
+	outboundProviderByTag              map[string]adapter.OutboundProvider
+	defaultOutboundForConnection       adapter.Outbound
+	defaultOutboundForPacketConnection adapter.Outbound
+}
+
+func (r *Router) newOutboundState(defaultDetour string, inbounds []adapter.Inbound, outbounds []adapter.Outbound, outboundProviders []adapter.OutboundProvider, defaultOutbound func() adapter.Outbound) (*outboundState, error) {
 	inboundByTag := make(map[string]adapter.Inbound)
 	for _, inbound := range inbounds {
 		inboundByTag[inbound.Tag()] = inbound
@@ -355,12 +304,19 @@ func (r *Router) Initialize(inbounds []adapter.Inbound, outbounds []adapter.Outb
 	for _, detour := range outbounds {
 		outboundByTag[detour.Tag()] = detour
 	}
+	outboundProviderByTag := make(map[string]adapter.OutboundProvider)
+	for _, provider := range outboundProviders {
+		if _, exists := outboundProviderByTag[provider.Tag()]; exists {
+			return nil, E.New("duplicate outbound provider tag: ", provider.Tag())
+		}
+		outboundProviderByTag[provider.Tag()] = provider
+	}
 	var defaultOutboundForConnection adapter.Outbound
 	var defaultOutboundForPacketConnection adapter.Outbound
-	if r.defaultDetour != "" {
-		detour, loaded := outboundByTag[r.defaultDetour]
+	if defaultDetour != "" {
+		detour, loaded := outboundByTag[defaultDetour]
 		if !loaded {
-			return E.New("default detour not found: ", r.defaultDetour)
+			return nil, E.New("default detour not found: ", defaultDetour)
 		}
 		if common.Contains(detour.Network(), N.NetworkTCP) {
 			defaultOutboundForConnection = detour
@@ -415,20 +371,33 @@ func (r *Router) Initialize(inbounds []adapter.Inbound, outbounds []adapter.Outb
 		r.logger.Info("using ", defaultOutboundForConnection.Type(), "[", description, "] as default outbound for connection")
 		r.logger.Info("using ", defaultOutboundForPacketConnection.Type(), "[", packetDescription, "] as default outbound for packet connection")
 	}
-	r.inboundByTag = inboundByTag
-	r.outbounds = outbounds
-	r.defaultOutboundForConnection = defaultOutboundForConnection
-	r.defaultOutboundForPacketConnection = defaultOutboundForPacketConnection
-	r.outboundByTag = outboundByTag
-	for i, rule := range r.rules {
-		if _, loaded := outboundByTag[rule.Outbound()]; !loaded {
-			return E.New("outbound not found for rule[", i, "]: ", rule.Outbound())
-		}
-	}
-	return nil
+	return &outboundState{
+		defaultDetour:                      defaultDetour,
+		inboundByTag:                       inboundByTag,
+		outbounds:                          outbounds,
+		outboundByTag:                      outboundByTag,
+		outboundProviders:                  outboundProviders,
+		outboundProviderByTag:              outboundProviderByTag,
+		defaultOutboundForConnection:       defaultOutboundForConnection,
+		defaultOutboundForPacketConnection: defaultOutboundForPacketConnection,
+	}, nil
+}
+
+// setOutboundState must be called with access held.
+func (r *Router) setOutboundState(state *outboundState) {
+	r.defaultDetour = state.defaultDetour
+	r.inboundByTag = state.inboundByTag
+	r.outbounds = state.outbounds
+	r.defaultOutboundForConnection = state.defaultOutboundForConnection
+	r.defaultOutboundForPacketConnection = state.defaultOutboundForPacketConnection
+	r.outboundByTag = state.outboundByTag
+	r.outboundProviders = state.outboundProviders
+	r.outboundProviderByTag = state.outboundProviderByTag
 }
 
 func (r *Router) Outbounds() []adapter.Outbound {
+	r.access.RLock()
+	defer r.access.RUnlock()
 	return r.outbounds
 }
 
@@ -495,18 +464,34 @@ func (r *Router) Start() error {
 			return E.Cause(err, "initialize DNS rule[", i, "]")
 		}
 	}
+	r.updateRuleStatistics(r.rules, r.dnsRules)
 	if r.fakeIPStore != nil {
 		err := r.fakeIPStore.Start()
 		if err != nil {
 			return err
 		}
 	}
+	if r.clashServer != nil && r.clashServer.StoreDNS() && !r.dnsOptions.DisableCache {
+		if cacheFile := r.clashServer.CacheFile(); cacheFile != nil {
+			r.dnsCacheStorage = cacheFile
+			r.purgeDNSCache()
+			r.dnsCachePurgeDone = make(chan struct{})
+			go r.loopPurgeDNSCache()
+		}
+	}
 	for i, transport := range r.transports {
 		err := transport.Start()
 		if err != nil {
 			return E.Cause(err, "initialize DNS server[", i, "]")
 		}
 	}
+	for _, ruleProvider := range r.ruleProviders {
+		r.logger.Trace("initializing provider/rule[", ruleProvider.Tag(), "]")
+		err := ruleProvider.Start()
+		if err != nil {
+			return E.Cause(err, "initialize provider/rule[", ruleProvider.Tag(), "]")
+		}
+	}
 	if r.timeService != nil {
 		err := r.timeService.Start()
 		if err != nil {
@@ -518,6 +503,9 @@ func (r *Router) Start() error {
 
 func (r *Router) Close() error {
 	var err error
+	if r.dnsCachePurgeDone != nil {
+		close(r.dnsCachePurgeDone)
+	}
 	for i, rule := range r.rules {
 		r.logger.Trace("closing rule[", i, "]")
 		err = E.Append(err, rule.Close(), func(err error) error {
@@ -530,6 +518,12 @@ func (r *Router) Close() error {
 			return E.Cause(err, "close dns rule[", i, "]")
 		})
 	}
+	for _, ruleProvider := range r.ruleProviders {
+		r.logger.Trace("closing provider/rule[", ruleProvider.Tag(), "]")
+		err = E.Append(err, ruleProvider.Close(), func(err error) error {
+			return E.Cause(err, "close provider/rule[", ruleProvider.Tag(), "]")
+		})
+	}
 	for i, transport := range r.transports {
 		r.logger.Trace("closing transport[", i, "] ")
 		err = E.Append(err, transport.Close(), func(err error) error {
@@ -576,11 +570,66 @@ func (r *Router) Close() error {
 }
 
 func (r *Router) Outbound(tag string) (adapter.Outbound, bool) {
-	outbound, loaded := r.outboundByTag[tag]
-	return outbound, loaded
+	r.access.RLock()
+	defer r.access.RUnlock()
+	return lookupOutbound(r.outboundByTag, r.outboundProviders, tag)
+}
+
+func lookupOutbound(outboundByTag map[string]adapter.Outbound, outboundProviders []adapter.OutboundProvider, tag string) (adapter.Outbound, bool) {
+	outbound, loaded := outboundByTag[tag]
+	if loaded {
+		return outbound, true
+	}
+	for _, provider := range outboundProviders {
+		outbound, loaded = provider.Outbound(tag)
+		if loaded {
+			return outbound, true
+		}
+	}
+	return nil, false
+}
+
+func (r *Router) OutboundProviders() []adapter.OutboundProvider {
+	r.access.RLock()
+	defer r.access.RUnlock()
+	return r.outboundProviders
+}
+
+func (r *Router) OutboundProvider(tag string) (adapter.OutboundProvider, bool) {
+	r.access.RLock()
+	defer r.access.RUnlock()
+	provider, loaded := r.outboundProviderByTag[tag]
+	return provider, loaded
+}
+
+func (r *Router) RuleProviders() []adapter.RuleProvider {
+	r.access.RLock()
+	defer r.access.RUnlock()
+	return r.ruleProviders
+}
+
+func (r *Router) RuleProvider(tag string) (adapter.RuleProvider, bool) {
+	r.access.RLock()
+	defer r.access.RUnlock()
+	ruleProvider, loaded := r.ruleProviderByTag[tag]
+	return ruleProvider, loaded
+}
+
+func (r *Router) Script() adapter.RouteScript {
+	r.access.RLock()
+	defer r.access.RUnlock()
+	return r.script
+}
+
+func (r *Router) SetScript(routeScript adapter.RouteScript) {
+	r.access.Lock()
+	defer r.access.Unlock()
+	r.script = routeScript
 }
 
 func (r *Router) DefaultOutbound(network string) adapter.Outbound {
+	r.access.RLock()
+	defer r.access.RUnlock()
 	if network == N.NetworkTCP {
 		return r.defaultOutboundForConnection
 	} else {
@@ -588,6 +637,17 @@ func (r *Router) DefaultOutbound(network string) adapter.Outbound {
 	}
 }
 
+func (r *Router) Inbound(tag string) (adapter.Inbound, bool) {
+	inbound := r.inbound(tag)
+	return inbound, inbound != nil
+}
+
+func (r *Router) inbound(tag string) adapter.Inbound {
+	r.access.RLock()
+	defer r.access.RUnlock()
+	return r.inboundByTag[tag]
+}
+
 func (r *Router) FakeIPStore() adapter.FakeIPStore {
 	return r.fakeIPStore
 }
@@ -597,7 +657,7 @@ func (r *Router) RouteConnection(ctx context.Context, conn net.Conn, metadata ad
 		if metadata.LastInbound == metadata.InboundDetour {
 			return E.New("routing loop on detour: ", metadata.InboundDetour)
 		}
-		detour := r.inboundByTag[metadata.InboundDetour]
+		detour := r.inbound(metadata.InboundDetour)
 		if detour == nil {
 			return E.New("inbound detour not found: ", metadata.InboundDetour)
 		}
@@ -618,6 +678,7 @@ func (r *Router) RouteConnection(ctx context.Context, conn net.Conn, metadata ad
 		return nil
 	}
 	metadata.Network = N.NetworkTCP
+	ctx = log.ContextWithFields(ctx, &metadata)
 	switch metadata.Destination.Fqdn {
 	case mux.Destination.Fqdn:
 		r.logger.InfoContext(ctx, "inbound multiplex connection")
@@ -660,10 +721,15 @@ func (r *Router) RouteConnection(ctx context.Context, conn net.Conn, metadata ad
 	if metadata.InboundOptions.SniffEnabled {
 		buffer := buf.NewPacket()
 		buffer.FullReset()
-		sniffMetadata, err := sniff.PeekStream(ctx, conn, buffer, time.Duration(metadata.InboundOptions.SniffTimeout), sniff.StreamDomainNameQuery, sniff.TLSClientHello, sniff.HTTPHost)
+		sniffMetadata, err := sniff.PeekStream(ctx, conn, buffer, time.Duration(metadata.InboundOptions.SniffTimeout), sniff.StreamDomainNameQuery, sniff.TLSClientHello, sniff.HTTPHost, sniff.SSHBanner, sniff.RDPConnectionRequest, sniff.BitTorrentHandshake)
 		if sniffMetadata != nil {
 			metadata.Protocol = sniffMetadata.Protocol
 			metadata.Domain = sniffMetadata.Domain
+			metadata.ALPN = sniffMetadata.ALPN
+			metadata.TLSVersion = sniffMetadata.TLSVersion
+			metadata.ClientFingerprint = sniffMetadata.ClientFingerprint
+			metadata.Client = sniffMetadata.Client
+			metadata.UserAgent = sniffMetadata.UserAgent
 			if metadata.InboundOptions.SniffOverrideDestination && M.IsDomainName(metadata.Domain) {
 				metadata.Destination = M.Socksaddr{
 					Fqdn: metadata.Domain,
@@ -701,16 +767,17 @@ func (r *Router) RouteConnection(ctx context.Context, conn net.Conn, metadata ad
 		metadata.DestinationAddresses = addresses
 		r.dnsLogger.DebugContext(ctx, "resolved [", strings.Join(F.MapToString(metadata.DestinationAddresses), " "), "]")
 	}
-	ctx, matchedRule, detour, err := r.match(ctx, &metadata, r.defaultOutboundForConnection)
+	ctx, matchedRule, detour, err := r.match(ctx, &metadata, r.DefaultOutbound(N.NetworkTCP))
 	if err != nil {
 		return err
 	}
 	if !common.Contains(detour.Network(), N.NetworkTCP) {
 		return E.New("missing supported outbound, closing connection")
 	}
+	var trackers []adapter.Tracker
 	if r.clashServer != nil {
 		trackerConn, tracker := r.clashServer.RoutedConnection(ctx, conn, metadata, matchedRule)
-		defer tracker.Leave()
+		trackers = append(trackers, tracker)
 		conn = trackerConn
 	}
 	if r.v2rayServer != nil {
@@ -718,7 +785,20 @@ func (r *Router) RouteConnection(ctx context.Context, conn net.Conn, metadata ad
 			conn = statsService.RoutedConnection(metadata.Inbound, detour.Tag(), metadata.User, conn)
 		}
 	}
-	return detour.NewConnection(ctx, conn, metadata)
+	if matchedRule != nil && (r.clashServer != nil || r.v2rayServer != nil) {
+		statistics := matchedRule.Statistics()
+		conn = trackerconn.New(conn, []*atomic.Int64{&statistics.Upload}, []*atomic.Int64{&statistics.Download})
+	}
+	if r.accessLogger != nil {
+		accessConn, tracker := r.accessLogger.RoutedConnection(ctx, conn, metadata, matchedRule, detour)
+		trackers = append(trackers, tracker)
+		conn = accessConn
+	}
+	err = detour.NewConnection(ctx, conn, metadata)
+	for _, tracker := range trackers {
+		tracker.Leave(err)
+	}
+	return err
 }
 
 func (r *Router) RoutePacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
@@ -726,7 +806,7 @@ func (r *Router) RoutePacketConnection(ctx context.Context, conn N.PacketConn, m
 		if metadata.LastInbound == metadata.InboundDetour {
 			return E.New("routing loop on detour: ", metadata.InboundDetour)
 		}
-		detour := r.inboundByTag[metadata.InboundDetour]
+		detour := r.inbound(metadata.InboundDetour)
 		if detour == nil {
 			return E.New("inbound detour not found: ", metadata.InboundDetour)
 		}
@@ -747,6 +827,7 @@ func (r *Router) RoutePacketConnection(ctx context.Context, conn N.PacketConn, m
 		return nil
 	}
 	metadata.Network = N.NetworkUDP
+	ctx = log.ContextWithFields(ctx, &metadata)
 
 	var originAddress M.Socksaddr
 	if r.fakeIPStore != nil && r.fakeIPStore.Contains(metadata.Destination.Addr) {
@@ -770,10 +851,15 @@ func (r *Router) RoutePacketConnection(ctx context.Context, conn N.PacketConn, m
 			buffer.Release()
 			return err
 		}
-		sniffMetadata, _ := sniff.PeekPacket(ctx, buffer.Bytes(), sniff.DomainNameQuery, sniff.QUICClientHello, sniff.STUNMessage)
+		sniffMetadata, _ := sniff.PeekPacket(ctx, buffer.Bytes(), sniff.DomainNameQuery, sniff.QUICClientHello, sniff.STUNMessage, sniff.DTLSRecord, sniff.WireGuardMessage, sniff.NTPMessage, sniff.DHTMessage, sniff.UTPPacket)
 		if sniffMetadata != nil {
 			metadata.Protocol = sniffMetadata.Protocol
 			metadata.Domain = sniffMetadata.Domain
+			metadata.ALPN = sniffMetadata.ALPN
+			metadata.TLSVersion = sniffMetadata.TLSVersion
+			metadata.ClientFingerprint = sniffMetadata.ClientFingerprint
+			metadata.Client = sniffMetadata.Client
+			metadata.UserAgent = sniffMetadata.UserAgent
 			if metadata.InboundOptions.SniffOverrideDestination && M.IsDomainName(metadata.Domain) {
 				metadata.Destination = M.Socksaddr{
 					Fqdn: metadata.Domain,
@@ -803,16 +889,17 @@ func (r *Router) RoutePacketConnection(ctx context.Context, conn N.PacketConn, m
 		metadata.DestinationAddresses = addresses
 		r.dnsLogger.DebugContext(ctx, "resolved [", strings.Join(F.MapToString(metadata.DestinationAddresses), " "), "]")
 	}
-	ctx, matchedRule, detour, err := r.match(ctx, &metadata, r.defaultOutboundForPacketConnection)
+	ctx, matchedRule, detour, err := r.match(ctx, &metadata, r.DefaultOutbound(N.NetworkUDP))
 	if err != nil {
 		return err
 	}
 	if !common.Contains(detour.Network(), N.NetworkUDP) {
 		return E.New("missing supported outbound, closing packet connection")
 	}
+	var trackers []adapter.Tracker
 	if r.clashServer != nil {
 		trackerConn, tracker := r.clashServer.RoutedPacketConnection(ctx, conn, metadata, matchedRule)
-		defer tracker.Leave()
+		trackers = append(trackers, tracker)
 		conn = trackerConn
 	}
 	if r.v2rayServer != nil {
@@ -820,10 +907,23 @@ func (r *Router) RoutePacketConnection(ctx context.Context, conn N.PacketConn, m
 			conn = statsService.RoutedPacketConnection(metadata.Inbound, detour.Tag(), metadata.User, conn)
 		}
 	}
+	if matchedRule != nil && (r.clashServer != nil || r.v2rayServer != nil) {
+		statistics := matchedRule.Statistics()
+		conn = trackerconn.NewPacket(conn, []*atomic.Int64{&statistics.Upload}, []*atomic.Int64{&statistics.Download})
+	}
 	if originAddress.IsValid() {
 		conn = fakeip.NewNATPacketConn(conn, originAddress, metadata.Destination)
 	}
-	return detour.NewPacketConnection(ctx, conn, metadata)
+	if r.accessLogger != nil {
+		accessConn, tracker := r.accessLogger.RoutedPacketConnection(ctx, conn, metadata, matchedRule, detour)
+		trackers = append(trackers, tracker)
+		conn = accessConn
+	}
+	err = detour.NewPacketConnection(ctx, conn, metadata)
+	for _, tracker := range trackers {
+		tracker.Leave(err)
+	}
+	return err
 }
 
 func (r *Router) match(ctx context.Context, metadata *adapter.InboundContext, defaultOutbound adapter.Outbound) (context.Context, adapter.Rule, adapter.Outbound, error) {
@@ -834,6 +934,10 @@ func (r *Router) match(ctx context.Context, metadata *adapter.InboundContext, de
 		}
 	}
 	ctx = outbound.ContextWithTag(ctx, matchOutbound.Tag())
+	metadata.Outbound = matchOutbound.Tag()
+	if matchRule != nil {
+		metadata.Rule = matchRule.String()
+	}
 	return ctx, matchRule, matchOutbound, nil
 }
 
@@ -869,8 +973,24 @@ func (r *Router) match0(ctx context.Context, metadata *adapter.InboundContext, d
 			metadata.ProcessInfo = processInfo
 		}
 	}
-	for i, rule := range r.rules {
-		if rule.Match(metadata) {
+	if routeScript := r.Script(); routeScript != nil {
+		detour, err := routeScript.Outbound(ctx, metadata)
+		if err != nil {
+			r.logger.ErrorContext(ctx, E.Cause(err, "run route script"))
+		} else if detour != "" {
+			r.logger.DebugContext(ctx, "match script => ", detour)
+			if outbound, loaded := r.Outbound(detour); loaded {
+				r.scriptStatistics.Hit()
+				return &scriptRule{outbound: detour, statistics: &r.scriptStatistics}, outbound
+			}
+			r.logger.ErrorContext(ctx, "outbound not found: ", detour)
+		}
+	}
+	rules, ruleIndex := r.indexedRules()
+	for _, i := range ruleIndex.Candidates(metadata) {
+		rule := rules[i]
+		if matchContext(ctx, rule, metadata) {
+			rule.Statistics().Hit()
 			detour := rule.Outbound()
 			r.logger.DebugContext(ctx, "match[", i, "] ", rule.String(), " => ", detour)
 			if outbound, loaded := r.Outbound(detour); loaded {
@@ -914,13 +1034,38 @@ func (r *Router) DefaultMark() int {
 }
 
 func (r *Router) Rules() []adapter.Rule {
+	r.access.RLock()
+	defer r.access.RUnlock()
 	return r.rules
 }
 
+func (r *Router) indexedRules() ([]adapter.Rule, *ruleIndex) {
+	r.access.RLock()
+	defer r.access.RUnlock()
+	return r.rules, r.ruleIndex
+}
+
 func (r *Router) IPRules() []adapter.IPRule {
+	r.access.RLock()
+	defer r.access.RUnlock()
 	return r.ipRules
 }
 
+func (r *Router) updateRuleStatistics(rules []adapter.Rule, dnsRules []adapter.DNSRule) {
+	if r.v2rayServer == nil {
+		return
+	}
+	if statsService := r.v2rayServer.StatsService(); statsService != nil {
+		statsService.UpdateRules(rules, dnsRules)
+	}
+}
+
+func (r *Router) DNSRules() []adapter.DNSRule {
+	r.access.RLock()
+	defer r.access.RUnlock()
+	return r.dnsRules
+}
+
 func (r *Router) NetworkMonitor() tun.NetworkUpdateMonitor {
 	return r.networkMonitor
 }
@@ -956,6 +1101,22 @@ func (r *Router) SetV2RayServer(server adapter.V2RayServer) {
 	r.v2rayServer = server
 }
 
+func (r *Router) MetricsServer() adapter.MetricsServer {
+	return r.metricsServer
+}
+
+func (r *Router) SetMetricsServer(server adapter.MetricsServer) {
+	r.metricsServer = server
+}
+
+func (r *Router) AccessLogger() adapter.AccessLogger {
+	return r.accessLogger
+}
+
+func (r *Router) SetAccessLogger(logger adapter.AccessLogger) {
+	r.accessLogger = logger
+}
+
 func (r *Router) OnPackagesUpdated(packages int, sharedUsers int) {
 	r.logger.Info("updated packages list: ", packages, " packages, ", sharedUsers, " shared users")
 }
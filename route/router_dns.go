@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/dialer"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-dns"
@@ -42,8 +43,16 @@ func (r *Router) matchDNS(ctx context.Context) (context.Context, dns.Transport,
 	if metadata == nil {
 		panic("no context")
 	}
-	for i, rule := range r.dnsRules {
-		if rule.Match(metadata) {
+	r.access.RLock()
+	dnsRules := r.dnsRules
+	transportMap := r.transportMap
+	transportDomainStrategy := r.transportDomainStrategy
+	defaultTransport := r.defaultTransport
+	defaultDomainStrategy := r.defaultDomainStrategy
+	r.access.RUnlock()
+	for i, rule := range dnsRules {
+		if matchContext(ctx, rule, metadata) {
+			rule.Statistics().Hit()
 			if rule.DisableCache() {
 				ctx = dns.ContextWithDisableCache(ctx, true)
 			}
@@ -52,27 +61,118 @@ func (r *Router) matchDNS(ctx context.Context) (context.Context, dns.Transport,
 			}
 			detour := rule.Outbound()
 			r.dnsLogger.DebugContext(ctx, "match[", i, "] ", rule.String(), " => ", detour)
-			if transport, loaded := r.transportMap[detour]; loaded {
-				if domainStrategy, dsLoaded := r.transportDomainStrategy[transport]; dsLoaded {
+			if transport, loaded := transportMap[detour]; loaded {
+				if domainStrategy, dsLoaded := transportDomainStrategy[transport]; dsLoaded {
 					return ctx, transport, domainStrategy
 				} else {
-					return ctx, transport, r.defaultDomainStrategy
+					return ctx, transport, defaultDomainStrategy
 				}
 			}
 			r.dnsLogger.ErrorContext(ctx, "transport not found: ", detour)
 		}
 	}
-	if domainStrategy, dsLoaded := r.transportDomainStrategy[r.defaultTransport]; dsLoaded {
-		return ctx, r.defaultTransport, domainStrategy
+	if domainStrategy, dsLoaded := transportDomainStrategy[defaultTransport]; dsLoaded {
+		return ctx, defaultTransport, domainStrategy
 	} else {
-		return ctx, r.defaultTransport, r.defaultDomainStrategy
+		return ctx, defaultTransport, defaultDomainStrategy
 	}
 }
 
+func (r *Router) matchDNSResponse(ctx context.Context, transport dns.Transport, addresses []netip.Addr) (string, dns.Transport) {
+	r.access.RLock()
+	dnsResponseRules := r.dnsResponseRules
+	transportMap := r.transportMap
+	r.access.RUnlock()
+	for i, rule := range dnsResponseRules {
+		if rule.Match(transport.Name(), addresses) {
+			r.dnsLogger.DebugContext(ctx, "match response[", i, "] ", rule.String(), " => ", rule.Action())
+			if rule.Action() == C.DNSResponseActionRetry {
+				return rule.Action(), transportMap[rule.RetryServer()]
+			}
+			return rule.Action(), nil
+		}
+	}
+	return C.DNSResponseActionAccept, nil
+}
+
+// filterExchange applies response rules to an exchanged message.
+// Retried queries bypass the cache, which stores the final response instead.
+func (r *Router) filterExchange(ctx context.Context, transport dns.Transport, message *mDNS.Msg, strategy dns.DomainStrategy, response *mDNS.Msg) (*mDNS.Msg, error) {
+	triedTransports := map[dns.Transport]bool{transport: true}
+	for {
+		action, retryTransport := r.matchDNSResponse(ctx, transport, responseAddresses(response))
+		switch action {
+		case C.DNSResponseActionReject:
+			return &mDNS.Msg{
+				MsgHdr: mDNS.MsgHdr{
+					Id:       message.Id,
+					Response: true,
+					Rcode:    mDNS.RcodeRefused,
+				},
+				Question: message.Question,
+			}, nil
+		case C.DNSResponseActionRetry:
+			if retryTransport == nil || triedTransports[retryTransport] {
+				return response, nil
+			}
+			triedTransports[retryTransport] = true
+			transport = retryTransport
+			r.dnsLogger.DebugContext(ctx, "retry exchange on ", transport.Name())
+			var err error
+			response, err = r.dnsClient.Exchange(dns.ContextWithDisableCache(ctx, true), transport, message, strategy)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return response, nil
+		}
+	}
+}
+
+func (r *Router) filterLookup(ctx context.Context, transport dns.Transport, domain string, strategy dns.DomainStrategy, addrs []netip.Addr) ([]netip.Addr, error) {
+	triedTransports := map[dns.Transport]bool{transport: true}
+	for {
+		action, retryTransport := r.matchDNSResponse(ctx, transport, addrs)
+		switch action {
+		case C.DNSResponseActionReject:
+			return nil, dns.RCodeRefused
+		case C.DNSResponseActionRetry:
+			if retryTransport == nil || triedTransports[retryTransport] {
+				return addrs, nil
+			}
+			triedTransports[retryTransport] = true
+			transport = retryTransport
+			r.dnsLogger.DebugContext(ctx, "retry lookup on ", transport.Name())
+			var err error
+			addrs, err = r.dnsClient.Lookup(dns.ContextWithDisableCache(ctx, true), transport, domain, strategy)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return addrs, nil
+		}
+	}
+}
+
+func responseAddresses(response *mDNS.Msg) []netip.Addr {
+	var addresses []netip.Addr
+	for _, answer := range response.Answer {
+		switch record := answer.(type) {
+		case *mDNS.A:
+			addresses = append(addresses, M.AddrFromIP(record.A))
+		case *mDNS.AAAA:
+			addresses = append(addresses, M.AddrFromIP(record.AAAA))
+		}
+	}
+	return addresses
+}
+
 func (r *Router) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
 	if len(message.Question) > 0 {
 		r.dnsLogger.DebugContext(ctx, "exchange ", formatQuestion(message.Question[0].String()))
 	}
+	// DNS transports dial through their own detour, not through the chain of the outbound looking up its server.
+	ctx = dialer.ContextWithOverride(ctx, nil)
 	ctx, metadata := adapter.AppendContext(ctx)
 	if len(message.Question) > 0 {
 		metadata.QueryType = message.Question[0].Qtype
@@ -87,7 +187,11 @@ func (r *Router) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, er
 	ctx, transport, strategy := r.matchDNS(ctx)
 	ctx, cancel := context.WithTimeout(ctx, C.DNSTimeout)
 	defer cancel()
-	response, err := r.dnsClient.Exchange(ctx, transport, message, strategy)
+	start := time.Now()
+	response, err := r.exchangeCached(ctx, transport, message, strategy)
+	if r.metricsServer != nil {
+		r.metricsServer.DNSQuery(transport.Name(), time.Since(start), err)
+	}
 	if err != nil && len(message.Question) > 0 {
 		r.dnsLogger.ErrorContext(ctx, E.Cause(err, "exchange failed for ", formatQuestion(message.Question[0].String())))
 	}
@@ -109,6 +213,7 @@ func (r *Router) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, er
 
 func (r *Router) Lookup(ctx context.Context, domain string, strategy dns.DomainStrategy) ([]netip.Addr, error) {
 	r.dnsLogger.DebugContext(ctx, "lookup domain ", domain)
+	ctx = dialer.ContextWithOverride(ctx, nil)
 	ctx, metadata := adapter.AppendContext(ctx)
 	metadata.Domain = domain
 	ctx, transport, transportStrategy := r.matchDNS(ctx)
@@ -117,7 +222,11 @@ func (r *Router) Lookup(ctx context.Context, domain string, strategy dns.DomainS
 	}
 	ctx, cancel := context.WithTimeout(ctx, C.DNSTimeout)
 	defer cancel()
-	addrs, err := r.dnsClient.Lookup(ctx, transport, domain, strategy)
+	start := time.Now()
+	addrs, err := r.lookupCached(ctx, transport, domain, strategy)
+	if r.metricsServer != nil {
+		r.metricsServer.DNSQuery(transport.Name(), time.Since(start), err)
+	}
 	if len(addrs) > 0 {
 		r.dnsLogger.InfoContext(ctx, "lookup succeed for ", domain, ": ", strings.Join(F.MapToString(addrs), " "))
 	} else {
@@ -0,0 +1,232 @@
+package route
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/transport/fakeip"
+	"github.com/sagernet/sing-dns"
+	"github.com/sagernet/sing/common/cache"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/task"
+
+	mDNS "github.com/miekg/dns"
+)
+
+// dnsCachePurgeInterval is the interval to purge the responses stored in the cache file.
+const dnsCachePurgeInterval = 10 * time.Minute
+
+// dnsCache is the in-memory cache of exchanged responses, in front of the cache file if enabled.
+type dnsCache struct {
+	access sync.RWMutex
+	cache  *cache.LruCache[mDNS.Question, *mDNS.Msg]
+}
+
+func newDNSCache() *dnsCache {
+	return &dnsCache{
+		cache: cache.New[mDNS.Question, *mDNS.Msg](),
+	}
+}
+
+func (c *dnsCache) load(question mDNS.Question) (*mDNS.Msg, time.Time, bool) {
+	c.access.RLock()
+	defer c.access.RUnlock()
+	return c.cache.LoadWithExpire(question)
+}
+
+func (c *dnsCache) store(question mDNS.Question, message *mDNS.Msg, expireAt time.Time) {
+	c.access.RLock()
+	defer c.access.RUnlock()
+	c.cache.StoreWithExpire(question, message, expireAt)
+}
+
+func (c *dnsCache) reset() {
+	c.access.Lock()
+	defer c.access.Unlock()
+	c.cache = cache.New[mDNS.Question, *mDNS.Msg]()
+}
+
+// ClearDNSCache removes all cached responses, from memory and from the cache file.
+func (r *Router) ClearDNSCache() error {
+	if r.dnsCache != nil {
+		r.dnsCache.reset()
+	}
+	if r.dnsCacheStorage != nil {
+		return r.dnsCacheStorage.DNSCacheReset()
+	}
+	return nil
+}
+
+func (r *Router) loopPurgeDNSCache() {
+	ticker := time.NewTicker(dnsCachePurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.purgeDNSCache()
+		case <-r.dnsCachePurgeDone:
+			return
+		}
+	}
+}
+
+// purgeDNSCache drops expired responses from the cache file unless they are served
+// anyway, and the responses expiring first once the file holds too many.
+func (r *Router) purgeDNSCache() {
+	err := r.dnsCacheStorage.DNSCachePurge(!r.dnsDisableExpire)
+	if err != nil {
+		r.dnsLogger.Warn(E.Cause(err, "purge dns cache"))
+	}
+}
+
+func (r *Router) cacheEnabled(ctx context.Context, transport dns.Transport) bool {
+	if r.dnsCache == nil || dns.DisableCacheFromContext(ctx) {
+		return false
+	}
+	// fake addresses are allocated by the store, they must not outlive it in the cache file
+	_, isFakeIP := transport.(*fakeip.Server)
+	return !isFakeIP
+}
+
+// exchangeCached looks up the response in memory, then in the cache file, and exchanges it on a miss.
+// Response rules are applied to exchanged responses, so the cache holds the final answer.
+func (r *Router) exchangeCached(ctx context.Context, transport dns.Transport, message *mDNS.Msg, strategy dns.DomainStrategy) (*mDNS.Msg, error) {
+	if len(message.Question) != 1 || !r.cacheEnabled(ctx, transport) {
+		return r.exchangeFiltered(ctx, transport, message, strategy)
+	}
+	question := message.Question[0]
+	if question.Qtype == mDNS.TypeA && strategy == dns.DomainStrategyUseIPv6 || question.Qtype == mDNS.TypeAAAA && strategy == dns.DomainStrategyUseIPv4 {
+		return r.dnsClient.Exchange(dns.ContextWithDisableCache(ctx, true), transport, message, strategy)
+	}
+	response, expireAt, loaded := r.dnsCache.load(question)
+	if loaded && (r.dnsDisableExpire || time.Now().Before(expireAt)) {
+		r.dnsLogger.DebugContext(ctx, "cached response for ", formatQuestion(question.String()))
+		return r.cachedResponse(ctx, message, response, expireAt), nil
+	}
+	if r.dnsCacheStorage != nil {
+		response, expireAt, loaded = r.dnsCacheStorage.LoadDNSCache(question)
+		if loaded && (r.dnsDisableExpire || time.Now().Before(expireAt)) {
+			r.dnsCache.store(question, response, expireAt)
+			r.dnsLogger.DebugContext(ctx, "stored response for ", formatQuestion(question.String()))
+			return r.cachedResponse(ctx, message, response, expireAt), nil
+		}
+	}
+	response, err := r.exchangeFiltered(ctx, transport, message, strategy)
+	if err != nil {
+		return nil, err
+	}
+	if response.Rcode == mDNS.RcodeSuccess {
+		var timeToLive int
+		for _, recordList := range [][]mDNS.RR{response.Answer, response.Ns, response.Extra} {
+			for _, record := range recordList {
+				if timeToLive == 0 || record.Header().Ttl > 0 && int(record.Header().Ttl) < timeToLive {
+					timeToLive = int(record.Header().Ttl)
+				}
+			}
+		}
+		if timeToLive == 0 {
+			timeToLive = dns.DefaultTTL
+		}
+		expireAt = time.Now().Add(time.Duration(timeToLive) * time.Second)
+		r.dnsCache.store(question, response.Copy(), expireAt)
+		if r.dnsCacheStorage != nil {
+			err = r.dnsCacheStorage.StoreDNSCache(question, response, expireAt)
+			if err != nil {
+				r.dnsLogger.WarnContext(ctx, E.Cause(err, "store dns cache"))
+			}
+		}
+	}
+	return response, nil
+}
+
+func (r *Router) exchangeFiltered(ctx context.Context, transport dns.Transport, message *mDNS.Msg, strategy dns.DomainStrategy) (*mDNS.Msg, error) {
+	response, err := r.dnsClient.Exchange(dns.ContextWithDisableCache(ctx, true), transport, message, strategy)
+	if err != nil || len(message.Question) == 0 {
+		return response, err
+	}
+	return r.filterExchange(ctx, transport, message, strategy, response)
+}
+
+func (r *Router) cachedResponse(ctx context.Context, message *mDNS.Msg, cached *mDNS.Msg, expireAt time.Time) *mDNS.Msg {
+	response := cached.Copy()
+	timeToLive := uint32(time.Until(expireAt) / time.Second)
+	rewriteTTL, rewrite := dns.RewriteTTLFromContext(ctx)
+	for _, recordList := range [][]mDNS.RR{response.Answer, response.Ns, response.Extra} {
+		for _, record := range recordList {
+			if rewrite {
+				record.Header().Ttl = rewriteTTL
+			} else if !r.dnsDisableExpire {
+				record.Header().Ttl = timeToLive
+			}
+		}
+	}
+	response.Id = message.Id
+	return response
+}
+
+func (r *Router) lookupCached(ctx context.Context, transport dns.Transport, domain string, strategy dns.DomainStrategy) ([]netip.Addr, error) {
+	if !r.cacheEnabled(ctx, transport) {
+		addrs, err := r.dnsClient.Lookup(dns.ContextWithDisableCache(ctx, true), transport, domain, strategy)
+		if err != nil {
+			return nil, err
+		}
+		return r.filterLookup(ctx, transport, domain, strategy, addrs)
+	}
+	dnsName := mDNS.Fqdn(domain)
+	if strategy == dns.DomainStrategyUseIPv4 {
+		return r.lookupTypeCached(ctx, transport, dnsName, mDNS.TypeA, strategy)
+	} else if strategy == dns.DomainStrategyUseIPv6 {
+		return r.lookupTypeCached(ctx, transport, dnsName, mDNS.TypeAAAA, strategy)
+	}
+	var response4 []netip.Addr
+	var response6 []netip.Addr
+	var group task.Group
+	group.Append("exchange4", func(ctx context.Context) error {
+		response, err := r.lookupTypeCached(ctx, transport, dnsName, mDNS.TypeA, strategy)
+		if err != nil {
+			return err
+		}
+		response4 = response
+		return nil
+	})
+	group.Append("exchange6", func(ctx context.Context) error {
+		response, err := r.lookupTypeCached(ctx, transport, dnsName, mDNS.TypeAAAA, strategy)
+		if err != nil {
+			return err
+		}
+		response6 = response
+		return nil
+	})
+	err := group.Run(ctx)
+	if len(response4) == 0 && len(response6) == 0 {
+		return nil, err
+	}
+	if strategy == dns.DomainStrategyPreferIPv6 {
+		return append(response6, response4...), nil
+	} else {
+		return append(response4, response6...), nil
+	}
+}
+
+func (r *Router) lookupTypeCached(ctx context.Context, transport dns.Transport, dnsName string, qType uint16, strategy dns.DomainStrategy) ([]netip.Addr, error) {
+	message := mDNS.Msg{
+		MsgHdr: mDNS.MsgHdr{
+			RecursionDesired: true,
+		},
+		Question: []mDNS.Question{{
+			Name:   dnsName,
+			Qtype:  qType,
+			Qclass: mDNS.ClassINET,
+		}},
+	}
+	response, err := r.exchangeCached(ctx, transport, &message, strategy)
+	if err != nil {
+		return nil, err
+	}
+	if response.Rcode != mDNS.RcodeSuccess {
+		return nil, dns.RCodeError(response.Rcode)
+	}
+	return responseAddresses(response), nil
+}
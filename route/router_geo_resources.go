@@ -7,11 +7,13 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/common/geoip"
 	"github.com/sagernet/sing-box/common/geosite"
+	"github.com/sagernet/sing-box/common/script"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing/common"
@@ -255,13 +257,19 @@ func hasDNSRule(rules []option.DNSRule, cond func(rule option.DefaultDNSRule) bo
 }
 
 func isGeoIPRule(rule option.DefaultRule) bool {
-	return len(rule.SourceGeoIP) > 0 && common.Any(rule.SourceGeoIP, notPrivateNode) || len(rule.GeoIP) > 0 && common.Any(rule.GeoIP, notPrivateNode)
+	return len(rule.SourceGeoIP) > 0 && common.Any(rule.SourceGeoIP, notPrivateNode) || len(rule.GeoIP) > 0 && common.Any(rule.GeoIP, notPrivateNode) || isGeoIPScript(rule.Script)
 }
 
 func isGeoIPDNSRule(rule option.DefaultDNSRule) bool {
 	return len(rule.SourceGeoIP) > 0 && common.Any(rule.SourceGeoIP, notPrivateNode)
 }
 
+func hasGeoIPDNSResponseRule(rules []option.DNSResponseRule) bool {
+	return common.Any(rules, func(rule option.DNSResponseRule) bool {
+		return len(rule.GeoIP) > 0 && common.Any(rule.GeoIP, notPrivateNode)
+	})
+}
+
 func isGeositeRule(rule option.DefaultRule) bool {
 	return len(rule.Geosite) > 0
 }
@@ -271,13 +279,21 @@ func isGeositeDNSRule(rule option.DefaultDNSRule) bool {
 }
 
 func isProcessRule(rule option.DefaultRule) bool {
-	return len(rule.ProcessName) > 0 || len(rule.ProcessPath) > 0 || len(rule.PackageName) > 0 || len(rule.User) > 0 || len(rule.UserID) > 0
+	return len(rule.ProcessName) > 0 || len(rule.ProcessPath) > 0 || len(rule.PackageName) > 0 || len(rule.User) > 0 || len(rule.UserID) > 0 || isProcessScript(rule.Script)
 }
 
 func isProcessDNSRule(rule option.DefaultDNSRule) bool {
 	return len(rule.ProcessName) > 0 || len(rule.ProcessPath) > 0 || len(rule.PackageName) > 0 || len(rule.User) > 0 || len(rule.UserID) > 0
 }
 
+func isGeoIPScript(code []string) bool {
+	return len(code) > 0 && script.References(strings.Join(code, "\n"), "geoip")
+}
+
+func isProcessScript(code []string) bool {
+	return len(code) > 0 && script.References(strings.Join(code, "\n"), "process_name", "process_path", "package_name")
+}
+
 func notPrivateNode(code string) bool {
 	return code != "private"
 }
@@ -40,7 +40,7 @@ func (r *Router) RouteIPConnection(ctx context.Context, conn tun.RouteContext, m
 		metadata.DestinationAddresses = addresses
 		r.dnsLogger.DebugContext(ctx, "resolved [", strings.Join(F.MapToString(metadata.DestinationAddresses), " "), "]")
 	}
-	for i, rule := range r.ipRules {
+	for i, rule := range r.IPRules() {
 		if rule.Match(&metadata) {
 			if rule.Action() == tun.ActionTypeBlock {
 				r.logger.InfoContext(ctx, "match[", i, "] ", rule.String(), " => block")
@@ -70,7 +70,7 @@ func (r *Router) RouteIPConnection(ctx context.Context, conn tun.RouteContext, m
 }
 
 func (r *Router) NatRequired(outbound string) bool {
-	for _, ipRule := range r.ipRules {
+	for _, ipRule := range r.IPRules() {
 		if ipRule.Outbound() == outbound {
 			return true
 		}
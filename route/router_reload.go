@@ -0,0 +1,635 @@
+package route
+
+import (
+	"net/netip"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/dialer"
+	"github.com/sagernet/sing-box/common/script"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	dns "github.com/sagernet/sing-dns"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	N "github.com/sagernet/sing/common/network"
+)
+
+// ReloadRouter is the router used by the rules, rule providers, outbounds and outbound providers created by a reload.
+// Until the reload is committed, outbounds, outbound providers and rule providers are resolved from the new
+// configuration, so that everything can be created and started before replacing the running objects.
+type ReloadRouter struct {
+	*Router
+	access            sync.RWMutex
+	committed         bool
+	outboundState     *outboundState
+	ruleProviders     []adapter.RuleProvider
+	ruleProviderByTag map[string]adapter.RuleProvider
+}
+
+func (r *Router) NewReloadRouter() *ReloadRouter {
+	return &ReloadRouter{Router: r}
+}
+
+func (r *ReloadRouter) stageRuleProviders(ruleProviders []adapter.RuleProvider, ruleProviderByTag map[string]adapter.RuleProvider) {
+	r.access.Lock()
+	defer r.access.Unlock()
+	r.ruleProviders = ruleProviders
+	r.ruleProviderByTag = ruleProviderByTag
+}
+
+func (r *ReloadRouter) stageOutbounds(state *outboundState) {
+	r.access.Lock()
+	defer r.access.Unlock()
+	r.outboundState = state
+}
+
+func (r *ReloadRouter) commit() {
+	r.access.Lock()
+	defer r.access.Unlock()
+	r.committed = true
+	r.outboundState = nil
+	r.ruleProviders = nil
+	r.ruleProviderByTag = nil
+}
+
+func (r *ReloadRouter) stagedOutbounds() *outboundState {
+	r.access.RLock()
+	defer r.access.RUnlock()
+	if r.committed {
+		return nil
+	}
+	return r.outboundState
+}
+
+func (r *ReloadRouter) Outbounds() []adapter.Outbound {
+	if state := r.stagedOutbounds(); state != nil {
+		return state.outbounds
+	}
+	return r.Router.Outbounds()
+}
+
+func (r *ReloadRouter) Outbound(tag string) (adapter.Outbound, bool) {
+	if state := r.stagedOutbounds(); state != nil {
+		return lookupOutbound(state.outboundByTag, state.outboundProviders, tag)
+	}
+	return r.Router.Outbound(tag)
+}
+
+func (r *ReloadRouter) OutboundProviders() []adapter.OutboundProvider {
+	if state := r.stagedOutbounds(); state != nil {
+		return state.outboundProviders
+	}
+	return r.Router.OutboundProviders()
+}
+
+func (r *ReloadRouter) OutboundProvider(tag string) (adapter.OutboundProvider, bool) {
+	if state := r.stagedOutbounds(); state != nil {
+		provider, loaded := state.outboundProviderByTag[tag]
+		return provider, loaded
+	}
+	return r.Router.OutboundProvider(tag)
+}
+
+func (r *ReloadRouter) DefaultOutbound(network string) adapter.Outbound {
+	if state := r.stagedOutbounds(); state != nil {
+		if network == N.NetworkTCP {
+			return state.defaultOutboundForConnection
+		} else {
+			return state.defaultOutboundForPacketConnection
+		}
+	}
+	return r.Router.DefaultOutbound(network)
+}
+
+func (r *ReloadRouter) RuleProviders() []adapter.RuleProvider {
+	r.access.RLock()
+	if !r.committed && r.ruleProviderByTag != nil {
+		defer r.access.RUnlock()
+		return r.ruleProviders
+	}
+	r.access.RUnlock()
+	return r.Router.RuleProviders()
+}
+
+func (r *ReloadRouter) RuleProvider(tag string) (adapter.RuleProvider, bool) {
+	r.access.RLock()
+	if !r.committed && r.ruleProviderByTag != nil {
+		defer r.access.RUnlock()
+		ruleProvider, loaded := r.ruleProviderByTag[tag]
+		return ruleProvider, loaded
+	}
+	r.access.RUnlock()
+	return r.Router.RuleProvider(tag)
+}
+
+// UpdateInbounds replaces the inbounds looked up by tag, as recreated after a failed reload.
+func (r *Router) UpdateInbounds(inbounds []adapter.Inbound) {
+	inboundByTag := make(map[string]adapter.Inbound)
+	for _, inbound := range inbounds {
+		inboundByTag[inbound.Tag()] = inbound
+	}
+	r.access.Lock()
+	r.inboundByTag = inboundByTag
+	r.access.Unlock()
+}
+
+// Reload replaces rules, rule providers, DNS servers and outbounds of a started router.
+// Rule providers and DNS servers with unchanged options are kept, unless a DNS server depends on a replaced outbound or server.
+// Outbounds and outbound providers must be created with reloadRouter. Everything new is created and started first,
+// startOutbounds being called before anything depending on the new outbounds is started, and startInbounds being
+// called last, then the running state is replaced at once. On failure, the running state is untouched and the objects
+// created by the router are closed.
+func (r *Router) Reload(reloadRouter *ReloadRouter, options option.RouteOptions, dnsOptions option.DNSOptions, inbounds []adapter.Inbound, outbounds []adapter.Outbound, outboundProviders []adapter.OutboundProvider, defaultOutbound func() adapter.Outbound, startOutbounds func() error, startInbounds func() error) (err error) {
+	r.access.RLock()
+	oldRouteOptions := r.routeOptions
+	oldDNSOptions := r.dnsOptions
+	oldOutboundByTag := r.outboundByTag
+	oldRuleProviders := r.ruleProviders
+	oldRuleProviderByTag := r.ruleProviderByTag
+	oldScript := r.script
+	oldRules := r.rules
+	oldIPRules := r.ipRules
+	oldDNSRules := r.dnsRules
+	oldTransports := r.transports
+	r.access.RUnlock()
+
+	var (
+		newRuleProviders []adapter.RuleProvider
+		rules            []adapter.Rule
+		ipRules          []adapter.IPRule
+		dnsRules         []adapter.DNSRule
+		newTransports    []dns.Transport
+	)
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, rule := range rules {
+			rule.Close()
+		}
+		for _, rule := range ipRules {
+			rule.Close()
+		}
+		for _, rule := range dnsRules {
+			rule.Close()
+		}
+		for _, ruleProvider := range newRuleProviders {
+			ruleProvider.Close()
+		}
+		for _, transport := range newTransports {
+			transport.Close()
+		}
+	}()
+
+	reuseRuleProviders := make(map[string]adapter.RuleProvider)
+	for _, providerOptions := range options.RuleProviders {
+		for _, oldProviderOptions := range oldRouteOptions.RuleProviders {
+			if oldProviderOptions.Tag == providerOptions.Tag && reflect.DeepEqual(oldProviderOptions, providerOptions) {
+				reuseRuleProviders[providerOptions.Tag] = oldRuleProviderByTag[providerOptions.Tag]
+			}
+		}
+	}
+	ruleProviders, ruleProviderByTag, err := r.newRuleProviders(reloadRouter, options.RuleProviders, reuseRuleProviders)
+	if err != nil {
+		return err
+	}
+	for _, ruleProvider := range ruleProviders {
+		if _, reused := reuseRuleProviders[ruleProvider.Tag()]; !reused {
+			newRuleProviders = append(newRuleProviders, ruleProvider)
+		}
+	}
+	reloadRouter.stageRuleProviders(ruleProviders, ruleProviderByTag)
+
+	state, err := r.newOutboundState(options.Final, inbounds, outbounds, outboundProviders, defaultOutbound)
+	if err != nil {
+		return err
+	}
+	reloadRouter.stageOutbounds(state)
+
+	routeScript, rules, ipRules, dnsRules, err := r.newRules(reloadRouter, options, dnsOptions)
+	if err != nil {
+		return err
+	}
+	if oldScript != nil && len(options.Script) == 0 && reflect.DeepEqual(options.Script, oldRouteOptions.Script) {
+		// keep script updated via the Clash API
+		routeScript = oldScript
+	}
+	for i, rule := range rules {
+		if _, loaded := reloadRouter.Outbound(rule.Outbound()); !loaded {
+			return E.New("outbound not found for rule[", i, "]: ", rule.Outbound())
+		}
+	}
+	if r.geoIPReader == nil && (hasRule(options.Rules, isGeoIPRule) || hasDNSRule(dnsOptions.Rules, isGeoIPDNSRule) || hasGeoIPDNSResponseRule(dnsOptions.ResponseRules) || isGeoIPScript(options.Script)) {
+		err = r.prepareGeoIPDatabase()
+		if err != nil {
+			return err
+		}
+	}
+	if hasRule(options.Rules, isGeositeRule) || hasDNSRule(dnsOptions.Rules, isGeositeDNSRule) {
+		r.geositeCache = make(map[string]adapter.Rule)
+		err = r.prepareGeositeDatabase()
+		if err != nil {
+			return err
+		}
+		for _, rule := range rules {
+			err = rule.UpdateGeosite()
+			if err != nil {
+				r.logger.Error("failed to initialize geosite: ", err)
+			}
+		}
+		for _, rule := range dnsRules {
+			err = rule.UpdateGeosite()
+			if err != nil {
+				r.logger.Error("failed to initialize geosite: ", err)
+			}
+		}
+		err = common.Close(r.geositeReader)
+		if err != nil {
+			return err
+		}
+		r.geositeCache = nil
+		r.geositeReader = nil
+	}
+	for i, rule := range rules {
+		err = rule.Start()
+		if err != nil {
+			return E.Cause(err, "initialize rule[", i, "]")
+		}
+	}
+	for i, rule := range dnsRules {
+		err = rule.Start()
+		if err != nil {
+			return E.Cause(err, "initialize DNS rule[", i, "]")
+		}
+	}
+
+	if startOutbounds != nil {
+		err = startOutbounds()
+		if err != nil {
+			return err
+		}
+	}
+	for _, ruleProvider := range newRuleProviders {
+		r.logger.Trace("initializing provider/rule[", ruleProvider.Tag(), "]")
+		err = ruleProvider.Start()
+		if err != nil {
+			return E.Cause(err, "initialize provider/rule[", ruleProvider.Tag(), "]")
+		}
+	}
+
+	reuseTransports := r.reusableTransports(oldDNSOptions, dnsOptions, oldTransports, oldOutboundByTag, state.outboundByTag)
+	transports, transportMap, transportDomainStrategy, defaultTransport, err := r.newTransports(reloadRouter, dnsOptions, reuseTransports)
+	if err != nil {
+		return err
+	}
+	for _, transport := range transports {
+		if _, reused := reuseTransports[transport.Name()]; !reused {
+			newTransports = append(newTransports, transport)
+		}
+	}
+	dnsResponseRules, err := r.newDNSResponseRules(reloadRouter, dnsOptions, transportMap)
+	if err != nil {
+		return err
+	}
+	for i, transport := range transports {
+		if _, reused := reuseTransports[transport.Name()]; reused {
+			continue
+		}
+		err = transport.Start()
+		if err != nil {
+			return E.Cause(err, "initialize DNS server[", i, "]")
+		}
+	}
+
+	if startInbounds != nil {
+		err = startInbounds()
+		if err != nil {
+			return err
+		}
+	}
+
+	inheritRuleStatistics(oldRules, rules)
+	inheritRuleStatistics(oldDNSRules, dnsRules)
+	r.access.Lock()
+	r.setOutboundState(state)
+	r.ruleProviders = ruleProviders
+	r.ruleProviderByTag = ruleProviderByTag
+	r.routeOptions = options
+	r.dnsOptions = dnsOptions
+	r.script = routeScript
+	r.rules = rules
+	r.ruleIndex = newRuleIndex(rules)
+	r.ipRules = ipRules
+	r.dnsRules = dnsRules
+	r.dnsResponseRules = dnsResponseRules
+	r.transports = transports
+	r.transportMap = transportMap
+	r.transportDomainStrategy = transportDomainStrategy
+	r.defaultTransport = defaultTransport
+	r.defaultDomainStrategy = dns.DomainStrategy(dnsOptions.Strategy)
+	r.access.Unlock()
+	reloadRouter.commit()
+	r.updateRuleStatistics(rules, dnsRules)
+
+	var closeErr error
+	if !reflect.DeepEqual(oldDNSOptions, dnsOptions) {
+		// cached responses are answers of the previous servers and response rules
+		closeErr = E.Append(closeErr, r.ClearDNSCache(), func(err error) error {
+			return E.Cause(err, "clear dns cache")
+		})
+	}
+	for i, rule := range oldRules {
+		closeErr = E.Append(closeErr, rule.Close(), func(err error) error {
+			return E.Cause(err, "close rule[", i, "]")
+		})
+	}
+	for i, rule := range oldIPRules {
+		closeErr = E.Append(closeErr, rule.Close(), func(err error) error {
+			return E.Cause(err, "close ip rule[", i, "]")
+		})
+	}
+	for i, rule := range oldDNSRules {
+		closeErr = E.Append(closeErr, rule.Close(), func(err error) error {
+			return E.Cause(err, "close dns rule[", i, "]")
+		})
+	}
+	for _, ruleProvider := range oldRuleProviders {
+		if ruleProviderByTag[ruleProvider.Tag()] == ruleProvider {
+			continue
+		}
+		closeErr = E.Append(closeErr, ruleProvider.Close(), func(err error) error {
+			return E.Cause(err, "close provider/rule[", ruleProvider.Tag(), "]")
+		})
+	}
+	for i, transport := range oldTransports {
+		if reuseTransports[transport.Name()] == transport {
+			continue
+		}
+		closeErr = E.Append(closeErr, transport.Close(), func(err error) error {
+			return E.Cause(err, "close dns transport[", i, "]")
+		})
+	}
+	if closeErr != nil {
+		// the new state is committed, do not let the caller close it
+		r.logger.Error(closeErr)
+	}
+	return nil
+}
+
+func (r *Router) reusableTransports(oldDNSOptions option.DNSOptions, dnsOptions option.DNSOptions, oldTransports []dns.Transport, oldOutboundByTag map[string]adapter.Outbound, outboundByTag map[string]adapter.Outbound) map[string]dns.Transport {
+	oldTransportByTag := make(map[string]dns.Transport)
+	for _, transport := range oldTransports {
+		oldTransportByTag[transport.Name()] = transport
+	}
+	oldServerByTag := make(map[string]option.DNSServerOptions)
+	for i, server := range oldDNSOptions.Servers {
+		oldServerByTag[dnsServerTag(i, server)] = server
+	}
+	reuse := make(map[string]dns.Transport)
+	for i, server := range dnsOptions.Servers {
+		tag := dnsServerTag(i, server)
+		oldServer, loaded := oldServerByTag[tag]
+		if !loaded || !reflect.DeepEqual(oldServer, server) {
+			continue
+		}
+		if server.Detour != "" {
+			if outboundByTag[server.Detour] != oldOutboundByTag[server.Detour] {
+				continue
+			}
+		}
+		if transport, loaded := oldTransportByTag[tag]; loaded {
+			reuse[tag] = transport
+		}
+	}
+	for {
+		var changed bool
+		for i, server := range dnsOptions.Servers {
+			tag := dnsServerTag(i, server)
+			if _, loaded := reuse[tag]; !loaded || server.AddressResolver == "" {
+				continue
+			}
+			if _, loaded := reuse[server.AddressResolver]; !loaded {
+				delete(reuse, tag)
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	return reuse
+}
+
+func dnsServerTag(index int, server option.DNSServerOptions) string {
+	if server.Tag != "" {
+		return server.Tag
+	}
+	return F.ToString(index)
+}
+
+func (r *Router) newRuleProviders(router adapter.Router, options []option.RuleProvider, reuse map[string]adapter.RuleProvider) ([]adapter.RuleProvider, map[string]adapter.RuleProvider, error) {
+	ruleProviders := make([]adapter.RuleProvider, 0, len(options))
+	ruleProviderByTag := make(map[string]adapter.RuleProvider)
+	for i, providerOptions := range options {
+		if _, exists := ruleProviderByTag[providerOptions.Tag]; exists {
+			return nil, nil, E.New("duplicate rule provider tag: ", providerOptions.Tag)
+		}
+		ruleProvider, loaded := reuse[providerOptions.Tag]
+		if !loaded {
+			var err error
+			ruleProvider, err = NewRuleProvider(r.ctx, router, r.logFactory.NewLogger(F.ToString("provider/rule[", providerOptions.Tag, "]")), providerOptions)
+			if err != nil {
+				return nil, nil, E.Cause(err, "parse rule provider[", i, "]")
+			}
+		}
+		ruleProviders = append(ruleProviders, ruleProvider)
+		ruleProviderByTag[providerOptions.Tag] = ruleProvider
+	}
+	return ruleProviders, ruleProviderByTag, nil
+}
+
+func (r *Router) newRules(router adapter.Router, options option.RouteOptions, dnsOptions option.DNSOptions) (adapter.RouteScript, []adapter.Rule, []adapter.IPRule, []adapter.DNSRule, error) {
+	var routeScript adapter.RouteScript
+	if len(options.Script) > 0 {
+		parsedScript, err := script.Parse(router, r.logger, "route", strings.Join(options.Script, "\n"))
+		if err != nil {
+			return nil, nil, nil, nil, E.Cause(err, "parse route script")
+		}
+		routeScript = parsedScript
+	}
+	rules := make([]adapter.Rule, 0, len(options.Rules))
+	for i, ruleOptions := range options.Rules {
+		routeRule, err := NewRule(router, r.logger, ruleOptions)
+		if err != nil {
+			return nil, nil, nil, nil, E.Cause(err, "parse rule[", i, "]")
+		}
+		rules = append(rules, routeRule)
+	}
+	ipRules := make([]adapter.IPRule, 0, len(options.IPRules))
+	for i, ipRuleOptions := range options.IPRules {
+		ipRule, err := NewIPRule(router, r.logger, ipRuleOptions)
+		if err != nil {
+			return nil, nil, nil, nil, E.Cause(err, "parse ip rule[", i, "]")
+		}
+		ipRules = append(ipRules, ipRule)
+	}
+	dnsRules := make([]adapter.DNSRule, 0, len(dnsOptions.Rules))
+	for i, dnsRuleOptions := range dnsOptions.Rules {
+		dnsRule, err := NewDNSRule(router, r.logger, dnsRuleOptions)
+		if err != nil {
+			return nil, nil, nil, nil, E.Cause(err, "parse dns rule[", i, "]")
+		}
+		dnsRules = append(dnsRules, dnsRule)
+	}
+	return routeScript, rules, ipRules, dnsRules, nil
+}
+
+func (r *Router) newDNSResponseRules(router adapter.Router, dnsOptions option.DNSOptions, transportMap map[string]dns.Transport) ([]*DNSResponseRule, error) {
+	dnsResponseRules := make([]*DNSResponseRule, 0, len(dnsOptions.ResponseRules))
+	for i, ruleOptions := range dnsOptions.ResponseRules {
+		dnsResponseRule, err := NewDNSResponseRule(router, r.logger, ruleOptions)
+		if err != nil {
+			return nil, E.Cause(err, "parse dns response rule[", i, "]")
+		}
+		if dnsResponseRule.Action() == C.DNSResponseActionRetry {
+			if _, loaded := transportMap[dnsResponseRule.RetryServer()]; !loaded {
+				return nil, E.New("parse dns response rule[", i, "]: retry server not found: ", dnsResponseRule.RetryServer())
+			}
+		}
+		dnsResponseRules = append(dnsResponseRules, dnsResponseRule)
+	}
+	return dnsResponseRules, nil
+}
+
+func (r *Router) newTransports(router adapter.Router, dnsOptions option.DNSOptions, reuse map[string]dns.Transport) ([]dns.Transport, map[string]dns.Transport, map[dns.Transport]dns.DomainStrategy, dns.Transport, error) {
+	transports := make([]dns.Transport, len(dnsOptions.Servers))
+	dummyTransportMap := make(map[string]dns.Transport)
+	transportMap := make(map[string]dns.Transport)
+	transportTags := make([]string, len(dnsOptions.Servers))
+	transportTagMap := make(map[string]bool)
+	transportDomainStrategy := make(map[dns.Transport]dns.DomainStrategy)
+	for i, server := range dnsOptions.Servers {
+		tag := dnsServerTag(i, server)
+		if transportTagMap[tag] {
+			return nil, nil, nil, nil, E.New("duplicate dns server tag: ", tag)
+		}
+		transportTags[i] = tag
+		transportTagMap[tag] = true
+	}
+	ctx := adapter.ContextWithRouter(r.ctx, router)
+	for {
+		lastLen := len(dummyTransportMap)
+		for i, server := range dnsOptions.Servers {
+			tag := transportTags[i]
+			if _, exists := dummyTransportMap[tag]; exists {
+				continue
+			}
+			transport, reused := reuse[tag]
+			if !reused {
+				var detour N.Dialer
+				if server.Detour == "" {
+					detour = dialer.NewRouter(router)
+				} else {
+					detour = dialer.NewDetour(router, server.Detour)
+				}
+				switch server.Address {
+				case "local":
+				default:
+					serverURL, _ := url.Parse(server.Address)
+					var serverAddress string
+					if serverURL != nil {
+						serverAddress = serverURL.Hostname()
+					}
+					if serverAddress == "" {
+						serverAddress = server.Address
+					}
+					_, notIpAddress := netip.ParseAddr(serverAddress)
+					if server.AddressResolver != "" {
+						if !transportTagMap[server.AddressResolver] {
+							return nil, nil, nil, nil, E.New("parse dns server[", tag, "]: address resolver not found: ", server.AddressResolver)
+						}
+						if upstream, exists := dummyTransportMap[server.AddressResolver]; exists {
+							detour = dns.NewDialerWrapper(detour, r.dnsClient, upstream, dns.DomainStrategy(server.AddressStrategy), time.Duration(server.AddressFallbackDelay))
+						} else {
+							continue
+						}
+					} else if notIpAddress != nil && strings.Contains(server.Address, ".") {
+						return nil, nil, nil, nil, E.New("parse dns server[", tag, "]: missing address_resolver")
+					}
+				}
+				var err error
+				transport, err = dns.CreateTransport(tag, ctx, r.logFactory.NewLogger(F.ToString("dns/transport[", tag, "]")), detour, server.Address)
+				if err != nil {
+					return nil, nil, nil, nil, E.Cause(err, "parse dns server[", tag, "]")
+				}
+			}
+			transports[i] = transport
+			dummyTransportMap[tag] = transport
+			if server.Tag != "" {
+				transportMap[server.Tag] = transport
+			}
+			strategy := dns.DomainStrategy(server.Strategy)
+			if strategy != dns.DomainStrategyAsIS {
+				transportDomainStrategy[transport] = strategy
+			}
+		}
+		if len(transports) == len(dummyTransportMap) {
+			break
+		}
+		if lastLen != len(dummyTransportMap) {
+			continue
+		}
+		unresolvedTags := common.MapIndexed(common.FilterIndexed(dnsOptions.Servers, func(index int, server option.DNSServerOptions) bool {
+			_, exists := dummyTransportMap[transportTags[index]]
+			return !exists
+		}), func(index int, server option.DNSServerOptions) string {
+			return transportTags[index]
+		})
+		if len(unresolvedTags) == 0 {
+			panic(F.ToString("unexpected unresolved dns servers: ", len(transports), " ", len(dummyTransportMap), " ", len(transportMap)))
+		}
+		return nil, nil, nil, nil, E.New("found circular reference in dns servers: ", strings.Join(unresolvedTags, " "))
+	}
+	var defaultTransport dns.Transport
+	if dnsOptions.Final != "" {
+		defaultTransport = dummyTransportMap[dnsOptions.Final]
+		if defaultTransport == nil {
+			return nil, nil, nil, nil, E.New("default dns server not found: ", dnsOptions.Final)
+		}
+	}
+	if defaultTransport == nil {
+		if len(transports) == 0 {
+			transports = append(transports, dns.NewLocalTransport("local", N.SystemDialer))
+		}
+		defaultTransport = transports[0]
+	}
+	return transports, transportMap, transportDomainStrategy, defaultTransport, nil
+}
+
+// inheritRuleStatistics keeps statistics of rules that are unchanged by the reload.
+func inheritRuleStatistics[T adapter.Rule](oldRules []T, rules []T) {
+	oldStatistics := make(map[string][]*adapter.RuleStatistics)
+	for _, rule := range oldRules {
+		description := rule.String() + " => " + rule.Outbound()
+		oldStatistics[description] = append(oldStatistics[description], rule.Statistics())
+	}
+	for _, rule := range rules {
+		description := rule.String() + " => " + rule.Outbound()
+		statisticsList := oldStatistics[description]
+		if len(statisticsList) == 0 {
+			continue
+		}
+		oldStatistics[description] = statisticsList[1:]
+		statistics := rule.Statistics()
+		statistics.Hits.Store(statisticsList[0].Hits.Load())
+		statistics.LastHit.Store(statisticsList[0].LastHit.Load())
+		statistics.Upload.Store(statisticsList[0].Upload.Load())
+		statistics.Download.Store(statisticsList[0].Download.Load())
+	}
+}
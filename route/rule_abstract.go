@@ -1,6 +1,7 @@
 package route
 
 import (
+	"context"
 	"strings"
 
 	"github.com/sagernet/sing-box/adapter"
@@ -18,6 +19,7 @@ type abstractDefaultRule struct {
 	allItems                []RuleItem
 	invert                  bool
 	outbound                string
+	statistics              adapter.RuleStatistics
 }
 
 func (r *abstractDefaultRule) Type() string {
@@ -56,13 +58,32 @@ func (r *abstractDefaultRule) UpdateGeosite() error {
 	return nil
 }
 
+// contextMatcher is implemented by rules and rule items that use the connection context while matching.
+type contextMatcher interface {
+	MatchContext(ctx context.Context, metadata *adapter.InboundContext) bool
+}
+
+func matchContext(ctx context.Context, matcher interface {
+	Match(metadata *adapter.InboundContext) bool
+}, metadata *adapter.InboundContext,
+) bool {
+	if ctxMatcher, isCtxMatcher := matcher.(contextMatcher); isCtxMatcher {
+		return ctxMatcher.MatchContext(ctx, metadata)
+	}
+	return matcher.Match(metadata)
+}
+
 func (r *abstractDefaultRule) Match(metadata *adapter.InboundContext) bool {
+	return r.MatchContext(context.Background(), metadata)
+}
+
+func (r *abstractDefaultRule) MatchContext(ctx context.Context, metadata *adapter.InboundContext) bool {
 	if len(r.allItems) == 0 {
 		return true
 	}
 
 	for _, item := range r.items {
-		if !item.Match(metadata) {
+		if !matchContext(ctx, item, metadata) {
 			return r.invert
 		}
 	}
@@ -70,7 +91,7 @@ func (r *abstractDefaultRule) Match(metadata *adapter.InboundContext) bool {
 	if len(r.sourceAddressItems) > 0 {
 		var sourceAddressMatch bool
 		for _, item := range r.sourceAddressItems {
-			if item.Match(metadata) {
+			if matchContext(ctx, item, metadata) {
 				sourceAddressMatch = true
 				break
 			}
@@ -83,7 +104,7 @@ func (r *abstractDefaultRule) Match(metadata *adapter.InboundContext) bool {
 	if len(r.sourcePortItems) > 0 {
 		var sourcePortMatch bool
 		for _, item := range r.sourcePortItems {
-			if item.Match(metadata) {
+			if matchContext(ctx, item, metadata) {
 				sourcePortMatch = true
 				break
 			}
@@ -96,7 +117,7 @@ func (r *abstractDefaultRule) Match(metadata *adapter.InboundContext) bool {
 	if len(r.destinationAddressItems) > 0 {
 		var destinationAddressMatch bool
 		for _, item := range r.destinationAddressItems {
-			if item.Match(metadata) {
+			if matchContext(ctx, item, metadata) {
 				destinationAddressMatch = true
 				break
 			}
@@ -109,7 +130,7 @@ func (r *abstractDefaultRule) Match(metadata *adapter.InboundContext) bool {
 	if len(r.destinationPortItems) > 0 {
 		var destinationPortMatch bool
 		for _, item := range r.destinationPortItems {
-			if item.Match(metadata) {
+			if matchContext(ctx, item, metadata) {
 				destinationPortMatch = true
 				break
 			}
@@ -126,6 +147,10 @@ func (r *abstractDefaultRule) Outbound() string {
 	return r.outbound
 }
 
+func (r *abstractDefaultRule) Statistics() *adapter.RuleStatistics {
+	return &r.statistics
+}
+
 func (r *abstractDefaultRule) String() string {
 	if !r.invert {
 		return strings.Join(F.MapToString(r.allItems), " ")
@@ -135,10 +160,11 @@ func (r *abstractDefaultRule) String() string {
 }
 
 type abstractLogicalRule struct {
-	rules    []adapter.Rule
-	mode     string
-	invert   bool
-	outbound string
+	rules      []adapter.Rule
+	mode       string
+	invert     bool
+	outbound   string
+	statistics adapter.RuleStatistics
 }
 
 func (r *abstractLogicalRule) Type() string {
@@ -176,13 +202,17 @@ func (r *abstractLogicalRule) Close() error {
 }
 
 func (r *abstractLogicalRule) Match(metadata *adapter.InboundContext) bool {
+	return r.MatchContext(context.Background(), metadata)
+}
+
+func (r *abstractLogicalRule) MatchContext(ctx context.Context, metadata *adapter.InboundContext) bool {
 	if r.mode == C.LogicalTypeAnd {
 		return common.All(r.rules, func(it adapter.Rule) bool {
-			return it.Match(metadata)
+			return matchContext(ctx, it, metadata)
 		}) != r.invert
 	} else {
 		return common.Any(r.rules, func(it adapter.Rule) bool {
-			return it.Match(metadata)
+			return matchContext(ctx, it, metadata)
 		}) != r.invert
 	}
 }
@@ -191,6 +221,10 @@ func (r *abstractLogicalRule) Outbound() string {
 	return r.outbound
 }
 
+func (r *abstractLogicalRule) Statistics() *adapter.RuleStatistics {
+	return &r.statistics
+}
+
 func (r *abstractLogicalRule) String() string {
 	var op string
 	switch r.mode {
@@ -79,6 +79,24 @@ func NewDefaultRule(router adapter.Router, logger log.ContextLogger, options opt
 		rule.items = append(rule.items, item)
 		rule.allItems = append(rule.allItems, item)
 	}
+	if len(options.SniffALPN) > 0 {
+		item := NewSniffALPNItem(options.SniffALPN)
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.ClientFingerprint) > 0 {
+		item := NewClientFingerprintItem(options.ClientFingerprint)
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.UserAgentRegex) > 0 {
+		item, err := NewUserAgentRegexItem(options.UserAgentRegex)
+		if err != nil {
+			return nil, E.Cause(err, "user_agent_regex")
+		}
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
 	if len(options.Domain) > 0 || len(options.DomainSuffix) > 0 {
 		item := NewDomainItem(options.Domain, options.DomainSuffix)
 		rule.destinationAddressItems = append(rule.destinationAddressItems, item)
@@ -179,6 +197,30 @@ func NewDefaultRule(router adapter.Router, logger log.ContextLogger, options opt
 		rule.items = append(rule.items, item)
 		rule.allItems = append(rule.allItems, item)
 	}
+	if len(options.RuleProvider) > 0 {
+		item, err := NewRuleProviderItem(router, options.RuleProvider)
+		if err != nil {
+			return nil, E.Cause(err, "rule_provider")
+		}
+		rule.destinationAddressItems = append(rule.destinationAddressItems, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.Script) > 0 {
+		item, err := NewScriptItem(router, logger, options.Script)
+		if err != nil {
+			return nil, E.Cause(err, "script")
+		}
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.TimeRange) > 0 || len(options.Weekday) > 0 || options.Timezone != "" {
+		item, err := NewTimeItem(router, options.TimeRange, options.Weekday, options.Timezone)
+		if err != nil {
+			return nil, E.Cause(err, "time")
+		}
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
 	if options.ClashMode != "" {
 		item := NewClashModeItem(router, options.ClashMode)
 		rule.items = append(rule.items, item)
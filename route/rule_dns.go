@@ -175,6 +175,22 @@ func NewDefaultDNSRule(router adapter.Router, logger log.ContextLogger, options
 		rule.items = append(rule.items, item)
 		rule.allItems = append(rule.allItems, item)
 	}
+	if len(options.RuleProvider) > 0 {
+		item, err := NewRuleProviderItem(router, options.RuleProvider)
+		if err != nil {
+			return nil, E.Cause(err, "rule_provider")
+		}
+		rule.destinationAddressItems = append(rule.destinationAddressItems, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.TimeRange) > 0 || len(options.Weekday) > 0 || options.Timezone != "" {
+		item, err := NewTimeItem(router, options.TimeRange, options.Weekday, options.Timezone)
+		if err != nil {
+			return nil, E.Cause(err, "time")
+		}
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
 	if options.ClashMode != "" {
 		item := NewClashModeItem(router, options.ClashMode)
 		rule.items = append(rule.items, item)
@@ -0,0 +1,108 @@
+package route
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+type DNSResponseRule struct {
+	servers     []string
+	items       []RuleItem
+	invert      bool
+	action      string
+	retryServer string
+}
+
+func NewDNSResponseRule(router adapter.Router, logger log.ContextLogger, options option.DNSResponseRule) (*DNSResponseRule, error) {
+	rule := &DNSResponseRule{
+		servers:     options.Server,
+		invert:      options.Invert,
+		retryServer: options.RetryServer,
+	}
+	switch options.Action {
+	case "", C.DNSResponseActionAccept:
+		rule.action = C.DNSResponseActionAccept
+	case C.DNSResponseActionReject:
+		rule.action = C.DNSResponseActionReject
+	case C.DNSResponseActionRetry:
+		if options.RetryServer == "" {
+			return nil, E.New("missing retry_server field")
+		}
+		rule.action = C.DNSResponseActionRetry
+	default:
+		return nil, E.New("unknown action: ", options.Action)
+	}
+	if len(options.IPCIDR) > 0 {
+		item, err := NewIPCIDRItem(false, options.IPCIDR)
+		if err != nil {
+			return nil, E.Cause(err, "ipcidr")
+		}
+		rule.items = append(rule.items, item)
+	}
+	if len(options.GeoIP) > 0 {
+		item := NewGeoIPItem(router, logger, false, options.GeoIP)
+		rule.items = append(rule.items, item)
+	}
+	return rule, nil
+}
+
+// Match reports whether the rule applies to the A/AAAA answers returned by the server.
+// Rules with address conditions never match responses without addresses.
+func (r *DNSResponseRule) Match(server string, addresses []netip.Addr) bool {
+	if len(r.servers) > 0 && !common.Contains(r.servers, server) {
+		return false
+	}
+	if len(r.items) == 0 {
+		return true
+	}
+	if len(addresses) == 0 {
+		return false
+	}
+	metadata := &adapter.InboundContext{
+		DestinationAddresses: addresses,
+	}
+	for _, item := range r.items {
+		if item.Match(metadata) {
+			return !r.invert
+		}
+	}
+	return r.invert
+}
+
+func (r *DNSResponseRule) Action() string {
+	return r.action
+}
+
+func (r *DNSResponseRule) RetryServer() string {
+	return r.retryServer
+}
+
+func (r *DNSResponseRule) String() string {
+	var description string
+	if len(r.servers) == 1 {
+		description = "server=" + r.servers[0]
+	} else if len(r.servers) > 1 {
+		description = "server=[" + strings.Join(r.servers, " ") + "]"
+	}
+	if len(r.items) > 0 {
+		itemDescription := strings.Join(common.Map(r.items, RuleItem.String), " ")
+		if description != "" {
+			description += " "
+		}
+		if r.invert {
+			description += "!"
+		}
+		description += itemDescription
+	}
+	if description == "" {
+		description = "any"
+	}
+	return description
+}
@@ -0,0 +1,220 @@
+package route
+
+import (
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+)
+
+// ruleIndex narrows down the route rules to evaluate for a connection.
+//
+// Each default rule is indexed by one condition group that every match must
+// satisfy: its destination domains and IP CIDRs, its destination ports, or its
+// inbounds. Rules that are not returned as candidates can not match, so
+// evaluating the candidates in order keeps first-match semantics. Inverted,
+// logical and other rules are always candidates.
+type ruleIndex struct {
+	always        []int
+	inbound       map[string][]int
+	port          map[uint16][]int
+	domain        map[string][]int
+	domainSuffix  map[string][]int
+	suffixLengths []int
+	ipPrefix      map[netip.Prefix][]int
+	prefixBits4   []int
+	prefixBits6   []int
+}
+
+func newRuleIndex(rules []adapter.Rule) *ruleIndex {
+	index := &ruleIndex{
+		inbound:      make(map[string][]int),
+		port:         make(map[uint16][]int),
+		domain:       make(map[string][]int),
+		domainSuffix: make(map[string][]int),
+		ipPrefix:     make(map[netip.Prefix][]int),
+	}
+	suffixLengths := make(map[int]bool)
+	for i, rule := range rules {
+		defaultRule, isDefault := rule.(*DefaultRule)
+		if !isDefault || defaultRule.invert || len(defaultRule.allItems) == 0 {
+			index.always = append(index.always, i)
+			continue
+		}
+		if domainItems, ipItems, indexable := indexableAddressItems(defaultRule.destinationAddressItems); indexable {
+			for _, item := range domainItems {
+				for _, domain := range item.domains {
+					index.domain[domain] = appendIndex(index.domain[domain], i)
+				}
+				for _, suffix := range item.domainSuffixes {
+					index.domainSuffix[suffix] = appendIndex(index.domainSuffix[suffix], i)
+					suffixLengths[len(suffix)] = true
+				}
+			}
+			for _, item := range ipItems {
+				for _, prefix := range item.ipSet.Prefixes() {
+					index.ipPrefix[prefix] = appendIndex(index.ipPrefix[prefix], i)
+				}
+			}
+			continue
+		}
+		if portItems, indexable := indexablePortItems(defaultRule.destinationPortItems); indexable {
+			for _, item := range portItems {
+				for _, port := range item.ports {
+					index.port[port] = appendIndex(index.port[port], i)
+				}
+			}
+			continue
+		}
+		if inboundItem := findInboundItem(defaultRule.items); inboundItem != nil {
+			for _, inbound := range inboundItem.inbounds {
+				index.inbound[inbound] = appendIndex(index.inbound[inbound], i)
+			}
+			continue
+		}
+		index.always = append(index.always, i)
+	}
+	for length := range suffixLengths {
+		index.suffixLengths = append(index.suffixLengths, length)
+	}
+	sort.Ints(index.suffixLengths)
+	bits4 := make(map[int]bool)
+	bits6 := make(map[int]bool)
+	for prefix := range index.ipPrefix {
+		if prefix.Addr().Is4() {
+			bits4[prefix.Bits()] = true
+		} else {
+			bits6[prefix.Bits()] = true
+		}
+	}
+	for bits := range bits4 {
+		index.prefixBits4 = append(index.prefixBits4, bits)
+	}
+	for bits := range bits6 {
+		index.prefixBits6 = append(index.prefixBits6, bits)
+	}
+	sort.Ints(index.prefixBits4)
+	sort.Ints(index.prefixBits6)
+	return index
+}
+
+func appendIndex(indexes []int, index int) []int {
+	if len(indexes) > 0 && indexes[len(indexes)-1] == index {
+		return indexes
+	}
+	return append(indexes, index)
+}
+
+func indexableAddressItems(items []RuleItem) ([]*DomainItem, []*IPCIDRItem, bool) {
+	if len(items) == 0 {
+		return nil, nil, false
+	}
+	var (
+		domainItems []*DomainItem
+		ipItems     []*IPCIDRItem
+	)
+	for _, item := range items {
+		switch addressItem := item.(type) {
+		case *DomainItem:
+			domainItems = append(domainItems, addressItem)
+		case *IPCIDRItem:
+			if addressItem.isSource {
+				return nil, nil, false
+			}
+			ipItems = append(ipItems, addressItem)
+		default:
+			return nil, nil, false
+		}
+	}
+	return domainItems, ipItems, true
+}
+
+func indexablePortItems(items []RuleItem) ([]*PortItem, bool) {
+	if len(items) == 0 {
+		return nil, false
+	}
+	portItems := make([]*PortItem, 0, len(items))
+	for _, item := range items {
+		portItem, isPort := item.(*PortItem)
+		if !isPort || portItem.isSource {
+			return nil, false
+		}
+		portItems = append(portItems, portItem)
+	}
+	return portItems, true
+}
+
+func findInboundItem(items []RuleItem) *InboundItem {
+	for _, item := range items {
+		if inboundItem, isInbound := item.(*InboundItem); isInbound {
+			return inboundItem
+		}
+	}
+	return nil
+}
+
+// Candidates returns the indexes of rules that may match, in ascending order.
+func (i *ruleIndex) Candidates(metadata *adapter.InboundContext) []int {
+	var matched []int
+	matched = append(matched, i.inbound[metadata.Inbound]...)
+	matched = append(matched, i.port[metadata.Destination.Port]...)
+	var domainHost string
+	if metadata.Domain != "" {
+		domainHost = metadata.Domain
+	} else {
+		domainHost = metadata.Destination.Fqdn
+	}
+	if domainHost != "" {
+		domainHost = strings.ToLower(domainHost)
+		matched = append(matched, i.domain[domainHost]...)
+		for _, length := range i.suffixLengths {
+			if length > len(domainHost) {
+				break
+			}
+			matched = append(matched, i.domainSuffix[domainHost[len(domainHost)-length:]]...)
+		}
+	}
+	if len(i.ipPrefix) > 0 {
+		if metadata.Destination.IsIP() {
+			matched = i.appendAddress(matched, metadata.Destination.Addr)
+		} else {
+			for _, address := range metadata.DestinationAddresses {
+				matched = i.appendAddress(matched, address)
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return i.always
+	}
+	sort.Ints(matched)
+	candidates := make([]int, 0, len(matched)+len(i.always))
+	var alwaysIndex int
+	for _, index := range matched {
+		for alwaysIndex < len(i.always) && i.always[alwaysIndex] < index {
+			candidates = append(candidates, i.always[alwaysIndex])
+			alwaysIndex++
+		}
+		if len(candidates) > 0 && candidates[len(candidates)-1] == index {
+			continue
+		}
+		candidates = append(candidates, index)
+	}
+	return append(candidates, i.always[alwaysIndex:]...)
+}
+
+func (i *ruleIndex) appendAddress(matched []int, address netip.Addr) []int {
+	address = address.WithZone("")
+	prefixBits := i.prefixBits6
+	if address.Is4() {
+		prefixBits = i.prefixBits4
+	}
+	for _, bits := range prefixBits {
+		prefix, err := address.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		matched = append(matched, i.ipPrefix[prefix]...)
+	}
+	return matched
+}
@@ -0,0 +1,140 @@
+package route
+
+import (
+	"math/rand"
+	"net/netip"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	F "github.com/sagernet/sing/common/format"
+	M "github.com/sagernet/sing/common/metadata"
+
+	"github.com/stretchr/testify/require"
+)
+
+func syntheticRules(t testing.TB, count int) []adapter.Rule {
+	random := rand.New(rand.NewSource(1))
+	logger := log.NewNOPFactory().Logger()
+	rules := make([]adapter.Rule, 0, count)
+	for i := 0; i < count; i++ {
+		var options option.Rule
+		options.DefaultOptions.Outbound = "direct"
+		switch i % 50 {
+		case 0:
+			options.DefaultOptions.Port = []uint16{uint16(1000 + random.Intn(100))}
+		case 1:
+			options.DefaultOptions.Inbound = []string{F.ToString("in-", random.Intn(10))}
+			options.DefaultOptions.Network = []string{"udp"}
+		case 2:
+			options.DefaultOptions.DomainKeyword = []string{F.ToString("keyword", random.Intn(1000))}
+		case 3:
+			options.Type = C.RuleTypeLogical
+			options.LogicalOptions.Mode = C.LogicalTypeOr
+			options.LogicalOptions.Outbound = "direct"
+			options.LogicalOptions.Rules = []option.DefaultRule{
+				{Domain: []string{F.ToString("logical", i, ".com")}},
+				{Port: []uint16{uint16(random.Intn(100))}},
+			}
+		case 4:
+			options.DefaultOptions.SourceIPCIDR = []string{F.ToString("10.", random.Intn(256), ".0.0/16")}
+			options.DefaultOptions.Invert = i >= count-50
+		case 5, 6, 7, 8, 9, 10, 11, 12, 13, 14:
+			options.DefaultOptions.IPCIDR = []string{F.ToString(random.Intn(224), ".", random.Intn(256), ".0.0/16")}
+		default:
+			options.DefaultOptions.Domain = []string{F.ToString("www.site", random.Intn(count), ".com")}
+			options.DefaultOptions.DomainSuffix = []string{F.ToString("site", random.Intn(count), ".net")}
+		}
+		rule, err := NewRule(nil, logger, options)
+		require.NoError(t, err)
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func syntheticMetadata(count int) []adapter.InboundContext {
+	random := rand.New(rand.NewSource(2))
+	metadataList := make([]adapter.InboundContext, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		metadata := adapter.InboundContext{
+			Inbound: F.ToString("in-", random.Intn(12)),
+			Network: "tcp",
+		}
+		if random.Intn(4) == 0 {
+			metadata.Network = "udp"
+		}
+		port := uint16(990 + random.Intn(120))
+		switch random.Intn(4) {
+		case 0:
+			metadata.Destination = M.Socksaddr{Fqdn: F.ToString("www.site", random.Intn(count), ".com"), Port: port}
+		case 1:
+			metadata.Destination = M.Socksaddr{Fqdn: F.ToString("cdn.site", random.Intn(count), ".net"), Port: port}
+		case 2:
+			metadata.Destination = M.Socksaddr{Fqdn: F.ToString("keyword", random.Intn(1000), ".org"), Port: port}
+			metadata.DestinationAddresses = []netip.Addr{netip.AddrFrom4([4]byte{byte(random.Intn(224)), byte(random.Intn(256)), 1, 1})}
+		default:
+			metadata.Destination = M.Socksaddr{Addr: netip.AddrFrom4([4]byte{byte(random.Intn(224)), byte(random.Intn(256)), 1, 1}), Port: port}
+		}
+		metadataList = append(metadataList, metadata)
+	}
+	return metadataList
+}
+
+func linearMatch(rules []adapter.Rule, metadata *adapter.InboundContext) int {
+	for i, rule := range rules {
+		if rule.Match(metadata) {
+			return i
+		}
+	}
+	return -1
+}
+
+func indexedMatch(rules []adapter.Rule, index *ruleIndex, metadata *adapter.InboundContext) int {
+	for _, i := range index.Candidates(metadata) {
+		if rules[i].Match(metadata) {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestRuleIndex(t *testing.T) {
+	t.Parallel()
+	const ruleCount = 2000
+	rules := syntheticRules(t, ruleCount)
+	index := newRuleIndex(rules)
+	var matched int
+	for _, metadata := range syntheticMetadata(ruleCount) {
+		expected := linearMatch(rules, &metadata)
+		require.Equal(t, expected, indexedMatch(rules, index, &metadata), metadata.Destination.String())
+		if expected != -1 {
+			matched++
+		}
+	}
+	require.NotZero(t, matched)
+}
+
+const benchmarkRuleCount = 10000
+
+func BenchmarkRuleMatchLinear(b *testing.B) {
+	rules := syntheticRules(b, benchmarkRuleCount)
+	metadataList := syntheticMetadata(benchmarkRuleCount)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		linearMatch(rules, &metadataList[i%len(metadataList)])
+	}
+}
+
+func BenchmarkRuleMatchIndexed(b *testing.B) {
+	rules := syntheticRules(b, benchmarkRuleCount)
+	index := newRuleIndex(rules)
+	metadataList := syntheticMetadata(benchmarkRuleCount)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		indexedMatch(rules, index, &metadataList[i%len(metadataList)])
+	}
+}
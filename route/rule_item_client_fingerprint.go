@@ -0,0 +1,42 @@
+package route
+
+import (
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	F "github.com/sagernet/sing/common/format"
+)
+
+var _ RuleItem = (*ClientFingerprintItem)(nil)
+
+type ClientFingerprintItem struct {
+	fingerprints   []string
+	fingerprintMap map[string]bool
+}
+
+// NewClientFingerprintItem matches the JA4 fingerprint or the guessed client
+// of sniffed TLS and QUIC connections.
+func NewClientFingerprintItem(fingerprints []string) *ClientFingerprintItem {
+	fingerprintMap := make(map[string]bool)
+	for _, fingerprint := range fingerprints {
+		fingerprintMap[fingerprint] = true
+	}
+	return &ClientFingerprintItem{
+		fingerprints:   fingerprints,
+		fingerprintMap: fingerprintMap,
+	}
+}
+
+func (r *ClientFingerprintItem) Match(metadata *adapter.InboundContext) bool {
+	if metadata.ClientFingerprint != "" && r.fingerprintMap[metadata.ClientFingerprint] {
+		return true
+	}
+	return metadata.Client != "" && r.fingerprintMap[metadata.Client]
+}
+
+func (r *ClientFingerprintItem) String() string {
+	if len(r.fingerprints) == 1 {
+		return F.ToString("client_fingerprint=", r.fingerprints[0])
+	}
+	return F.ToString("client_fingerprint=[", strings.Join(r.fingerprints, " "), "]")
+}
@@ -10,8 +10,10 @@ import (
 var _ RuleItem = (*DomainItem)(nil)
 
 type DomainItem struct {
-	matcher     *domain.Matcher
-	description string
+	matcher        *domain.Matcher
+	domains        []string
+	domainSuffixes []string
+	description    string
 }
 
 func NewDomainItem(domains []string, domainSuffixes []string) *DomainItem {
@@ -38,8 +40,10 @@ func NewDomainItem(domains []string, domainSuffixes []string) *DomainItem {
 		}
 	}
 	return &DomainItem{
-		domain.NewMatcher(domains, domainSuffixes),
-		description,
+		matcher:        domain.NewMatcher(domains, domainSuffixes),
+		domains:        domains,
+		domainSuffixes: domainSuffixes,
+		description:    description,
 	}
 }
 
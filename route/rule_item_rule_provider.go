@@ -0,0 +1,48 @@
+package route
+
+import (
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+)
+
+var _ RuleItem = (*RuleProviderItem)(nil)
+
+type RuleProviderItem struct {
+	tags      []string
+	providers []adapter.RuleProvider
+}
+
+func NewRuleProviderItem(router adapter.Router, tags []string) (*RuleProviderItem, error) {
+	providers := make([]adapter.RuleProvider, 0, len(tags))
+	for _, tag := range tags {
+		provider, loaded := router.RuleProvider(tag)
+		if !loaded {
+			return nil, E.New("rule provider not found: ", tag)
+		}
+		providers = append(providers, provider)
+	}
+	return &RuleProviderItem{
+		tags:      tags,
+		providers: providers,
+	}, nil
+}
+
+func (r *RuleProviderItem) Match(metadata *adapter.InboundContext) bool {
+	for _, provider := range r.providers {
+		if provider.Match(metadata) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RuleProviderItem) String() string {
+	if len(r.tags) == 1 {
+		return F.ToString("rule_provider=", r.tags[0])
+	} else {
+		return F.ToString("rule_provider=[", strings.Join(r.tags, " "), "]")
+	}
+}
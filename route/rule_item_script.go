@@ -0,0 +1,49 @@
+package route
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/script"
+	"github.com/sagernet/sing-box/log"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+var (
+	_ RuleItem       = (*ScriptItem)(nil)
+	_ contextMatcher = (*ScriptItem)(nil)
+)
+
+type ScriptItem struct {
+	logger log.ContextLogger
+	script *script.Script
+}
+
+func NewScriptItem(router adapter.Router, logger log.ContextLogger, code []string) (*ScriptItem, error) {
+	ruleScript, err := script.Parse(router, logger, "rule", strings.Join(code, "\n"))
+	if err != nil {
+		return nil, err
+	}
+	return &ScriptItem{
+		logger: logger,
+		script: ruleScript,
+	}, nil
+}
+
+func (r *ScriptItem) Match(metadata *adapter.InboundContext) bool {
+	return r.MatchContext(context.Background(), metadata)
+}
+
+func (r *ScriptItem) MatchContext(ctx context.Context, metadata *adapter.InboundContext) bool {
+	matched, err := r.script.Match(ctx, metadata)
+	if err != nil {
+		r.logger.ErrorContext(ctx, E.Cause(err, "run rule script"))
+		return false
+	}
+	return matched
+}
+
+func (r *ScriptItem) String() string {
+	return "script"
+}
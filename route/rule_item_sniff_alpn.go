@@ -0,0 +1,42 @@
+package route
+
+import (
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	F "github.com/sagernet/sing/common/format"
+)
+
+var _ RuleItem = (*SniffALPNItem)(nil)
+
+type SniffALPNItem struct {
+	alpn    []string
+	alpnMap map[string]bool
+}
+
+func NewSniffALPNItem(alpn []string) *SniffALPNItem {
+	alpnMap := make(map[string]bool)
+	for _, protocol := range alpn {
+		alpnMap[protocol] = true
+	}
+	return &SniffALPNItem{
+		alpn:    alpn,
+		alpnMap: alpnMap,
+	}
+}
+
+func (r *SniffALPNItem) Match(metadata *adapter.InboundContext) bool {
+	for _, protocol := range metadata.ALPN {
+		if r.alpnMap[protocol] {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *SniffALPNItem) String() string {
+	if len(r.alpn) == 1 {
+		return F.ToString("sniff_alpn=", r.alpn[0])
+	}
+	return F.ToString("sniff_alpn=[", strings.Join(r.alpn, " "), "]")
+}
@@ -0,0 +1,37 @@
+package route
+
+import (
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/timerange"
+)
+
+var _ RuleItem = (*TimeItem)(nil)
+
+type TimeItem struct {
+	router  adapter.Router
+	matcher *timerange.Matcher
+}
+
+func NewTimeItem(router adapter.Router, timeRanges []string, weekdays []string, timezone string) (*TimeItem, error) {
+	matcher, err := timerange.New(timeRanges, weekdays, timezone)
+	if err != nil {
+		return nil, err
+	}
+	return &TimeItem{
+		router:  router,
+		matcher: matcher,
+	}, nil
+}
+
+func (r *TimeItem) Match(metadata *adapter.InboundContext) bool {
+	if timeFunc := r.router.TimeFunc(); timeFunc != nil {
+		return r.matcher.Match(timeFunc())
+	}
+	return r.matcher.Match(time.Now())
+}
+
+func (r *TimeItem) String() string {
+	return r.matcher.String()
+}
@@ -0,0 +1,54 @@
+package route
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+)
+
+var _ RuleItem = (*UserAgentRegexItem)(nil)
+
+type UserAgentRegexItem struct {
+	matchers    []*regexp.Regexp
+	description string
+}
+
+func NewUserAgentRegexItem(expressions []string) (*UserAgentRegexItem, error) {
+	matchers := make([]*regexp.Regexp, 0, len(expressions))
+	for i, regex := range expressions {
+		matcher, err := regexp.Compile(regex)
+		if err != nil {
+			return nil, E.Cause(err, "parse expression ", i)
+		}
+		matchers = append(matchers, matcher)
+	}
+	description := "user_agent_regex="
+	eLen := len(expressions)
+	if eLen == 1 {
+		description += expressions[0]
+	} else if eLen > 3 {
+		description += F.ToString("[", strings.Join(expressions[:3], " "), "]")
+	} else {
+		description += F.ToString("[", strings.Join(expressions, " "), "]")
+	}
+	return &UserAgentRegexItem{matchers, description}, nil
+}
+
+func (r *UserAgentRegexItem) Match(metadata *adapter.InboundContext) bool {
+	if metadata.UserAgent == "" {
+		return false
+	}
+	for _, matcher := range r.matchers {
+		if matcher.MatchString(metadata.UserAgent) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *UserAgentRegexItem) String() string {
+	return r.description
+}
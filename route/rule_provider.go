@@ -0,0 +1,447 @@
+package route
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/provider"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/rw"
+
+	"gopkg.in/yaml.v3"
+)
+
+var _ adapter.RuleProvider = (*RuleProvider)(nil)
+
+type RuleProvider struct {
+	ctx            context.Context
+	router         adapter.Router
+	logger         log.ContextLogger
+	providerType   string
+	tag            string
+	format         string
+	fileFormat     string
+	path           string
+	downloadURL    string
+	downloadDetour string
+	updateInterval time.Duration
+
+	access       sync.RWMutex
+	items        []RuleItem
+	ruleCount    int
+	updatedAt    time.Time
+	updateAccess sync.Mutex
+
+	ticker    *time.Ticker
+	close     chan struct{}
+	closeOnce sync.Once
+}
+
+func NewRuleProvider(ctx context.Context, router adapter.Router, logger log.ContextLogger, options option.RuleProvider) (*RuleProvider, error) {
+	if options.Tag == "" {
+		return nil, E.New("missing tag")
+	}
+	switch options.Format {
+	case C.RuleProviderFormatDomain, C.RuleProviderFormatIPCIDR, C.RuleProviderFormatClassical:
+	case "":
+		return nil, E.New("missing format")
+	default:
+		return nil, E.New("unknown rule provider format: ", options.Format)
+	}
+	fileFormat := options.FileFormat
+	switch fileFormat {
+	case C.RuleProviderFileFormatYAML, C.RuleProviderFileFormatText:
+	case "":
+		fileFormat = ruleProviderFileFormat(options.Path, options.DownloadURL)
+	default:
+		return nil, E.New("unknown rule provider file format: ", options.FileFormat)
+	}
+	ruleProvider := &RuleProvider{
+		ctx:            ctx,
+		router:         router,
+		logger:         logger,
+		tag:            options.Tag,
+		format:         options.Format,
+		fileFormat:     fileFormat,
+		downloadURL:    options.DownloadURL,
+		downloadDetour: options.DownloadDetour,
+		updateInterval: time.Duration(options.UpdateInterval),
+		close:          make(chan struct{}),
+	}
+	if options.DownloadURL != "" {
+		ruleProvider.providerType = C.ProviderTypeRemote
+	} else {
+		ruleProvider.providerType = C.ProviderTypeLocal
+		if options.Path == "" {
+			return nil, E.New("missing path or download_url")
+		}
+	}
+	path := options.Path
+	if path == "" {
+		path = filepath.Join("rules", options.Tag)
+	}
+	if foundPath, loaded := C.FindPath(path); loaded {
+		path = foundPath
+	}
+	ruleProvider.path = C.BasePath(path)
+	return ruleProvider, nil
+}
+
+func (p *RuleProvider) Type() string {
+	return p.providerType
+}
+
+func (p *RuleProvider) Tag() string {
+	return p.tag
+}
+
+func (p *RuleProvider) Format() string {
+	return p.format
+}
+
+func (p *RuleProvider) Start() error {
+	var needUpdate bool
+	if rw.FileExists(p.path) {
+		err := p.loadFile()
+		if err != nil {
+			if p.providerType == C.ProviderTypeLocal {
+				return err
+			}
+			p.logger.Error(E.Cause(err, "load cached rule provider"))
+			needUpdate = true
+		} else if p.providerType == C.ProviderTypeRemote && p.updateInterval > 0 {
+			if fileInfo, err := os.Stat(p.path); err == nil && time.Since(fileInfo.ModTime()) > p.updateInterval {
+				go p.updateOnce()
+			}
+		}
+	} else if p.providerType == C.ProviderTypeLocal {
+		return E.New("rule provider file not exists: ", p.path)
+	} else {
+		needUpdate = true
+	}
+	if needUpdate {
+		err := p.Update()
+		if err != nil {
+			p.logger.Error(err)
+		}
+	}
+	if p.updateInterval > 0 {
+		p.ticker = time.NewTicker(p.updateInterval)
+		go p.loopUpdate()
+	}
+	return nil
+}
+
+func (p *RuleProvider) Close() error {
+	if p.ticker != nil {
+		p.ticker.Stop()
+	}
+	p.closeOnce.Do(func() {
+		close(p.close)
+	})
+	return nil
+}
+
+func (p *RuleProvider) RuleCount() int {
+	p.access.RLock()
+	defer p.access.RUnlock()
+	return p.ruleCount
+}
+
+func (p *RuleProvider) UpdatedAt() time.Time {
+	p.access.RLock()
+	defer p.access.RUnlock()
+	return p.updatedAt
+}
+
+func (p *RuleProvider) Match(metadata *adapter.InboundContext) bool {
+	p.access.RLock()
+	items := p.items
+	p.access.RUnlock()
+	for _, item := range items {
+		if item.Match(metadata) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RuleProvider) Update() error {
+	p.updateAccess.Lock()
+	defer p.updateAccess.Unlock()
+	if p.providerType == C.ProviderTypeLocal {
+		return p.loadFile()
+	}
+	p.logger.Info("downloading rule provider")
+	detour, err := provider.Detour(p.router, p.downloadDetour)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(p.ctx, C.ProviderDownloadTimeout)
+	defer cancel()
+	content, err := provider.Download(ctx, detour, p.downloadURL)
+	if err != nil {
+		return E.Cause(err, "download rule provider")
+	}
+	err = p.load(content)
+	if err != nil {
+		return err
+	}
+	err = provider.SaveFile(p.path, content)
+	if err != nil {
+		p.logger.Warn(E.Cause(err, "save rule provider"))
+	}
+	return nil
+}
+
+func (p *RuleProvider) loopUpdate() {
+	for {
+		select {
+		case <-p.close:
+			return
+		case <-p.ticker.C:
+			p.updateOnce()
+		}
+	}
+}
+
+func (p *RuleProvider) updateOnce() {
+	err := p.Update()
+	if err != nil {
+		p.logger.Error(err)
+	}
+}
+
+func (p *RuleProvider) loadFile() error {
+	content, err := os.ReadFile(p.path)
+	if err != nil {
+		return E.Cause(err, "read rule provider file")
+	}
+	return p.load(content)
+}
+
+func (p *RuleProvider) load(content []byte) error {
+	payload, err := parseRuleProviderPayload(content, p.fileFormat)
+	if err != nil {
+		return E.Cause(err, "parse rule provider")
+	}
+	var items []RuleItem
+	switch p.format {
+	case C.RuleProviderFormatDomain:
+		items, err = newDomainProviderItems(payload)
+	case C.RuleProviderFormatIPCIDR:
+		items, err = newIPCIDRProviderItems(payload)
+	case C.RuleProviderFormatClassical:
+		items, err = p.newClassicalProviderItems(payload)
+	}
+	if err != nil {
+		return E.Cause(err, "parse rule provider")
+	}
+	p.access.Lock()
+	p.items = items
+	p.ruleCount = len(payload)
+	p.updatedAt = time.Now()
+	p.access.Unlock()
+	p.logger.Info("loaded ", len(payload), " rules")
+	return nil
+}
+
+// ruleProviderFileFormat detects the file format from the extension of the path,
+// or of the download URL if the path is not set. Clash rule providers are YAML by default.
+func ruleProviderFileFormat(path string, downloadURL string) string {
+	if path == "" && downloadURL != "" {
+		if parsedURL, err := url.Parse(downloadURL); err == nil {
+			path = parsedURL.Path
+		}
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".txt", ".list":
+		return C.RuleProviderFileFormatText
+	default:
+		return C.RuleProviderFileFormatYAML
+	}
+}
+
+func parseRuleProviderPayload(content []byte, fileFormat string) ([]string, error) {
+	if fileFormat == C.RuleProviderFileFormatYAML {
+		var ruleSet struct {
+			Payload []string `yaml:"payload"`
+		}
+		err := yaml.Unmarshal(content, &ruleSet)
+		if err != nil {
+			return nil, err
+		}
+		if ruleSet.Payload == nil {
+			return nil, E.New("missing payload")
+		}
+		return ruleSet.Payload, nil
+	}
+	var payload []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		payload = append(payload, line)
+	}
+	return payload, nil
+}
+
+func newDomainProviderItems(payload []string) ([]RuleItem, error) {
+	var (
+		domains        []string
+		domainSuffixes []string
+		domainRegexes  []string
+	)
+	for _, rule := range payload {
+		rule = strings.ToLower(rule)
+		switch {
+		case strings.HasPrefix(rule, "+."):
+			domains = append(domains, rule[2:])
+			domainSuffixes = append(domainSuffixes, rule[1:])
+		case strings.HasPrefix(rule, "*."):
+			domainRegexes = append(domainRegexes, "^[^.]+"+strings.ReplaceAll(rule[1:], ".", "\\.")+"$")
+		case strings.HasPrefix(rule, "."):
+			domainSuffixes = append(domainSuffixes, rule)
+		default:
+			domains = append(domains, rule)
+		}
+	}
+	var items []RuleItem
+	if len(domains) > 0 || len(domainSuffixes) > 0 {
+		items = append(items, NewDomainItem(domains, domainSuffixes))
+	}
+	if len(domainRegexes) > 0 {
+		item, err := NewDomainRegexItem(domainRegexes)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func newIPCIDRProviderItems(payload []string) ([]RuleItem, error) {
+	if len(payload) == 0 {
+		return nil, nil
+	}
+	item, err := NewIPCIDRItem(false, payload)
+	if err != nil {
+		return nil, err
+	}
+	return []RuleItem{item}, nil
+}
+
+func (p *RuleProvider) newClassicalProviderItems(payload []string) ([]RuleItem, error) {
+	var (
+		domains          []string
+		domainSuffixes   []string
+		domainKeywords   []string
+		domainRegexes    []string
+		ipCIDRs          []string
+		sourceIPCIDRs    []string
+		ports            []uint16
+		sourcePorts      []uint16
+		processNames     []string
+		processPaths     []string
+		networks         []string
+		unsupportedCount int
+	)
+	for i, rule := range payload {
+		ruleType, ruleValue, _ := strings.Cut(rule, ",")
+		ruleValue, _, _ = strings.Cut(ruleValue, ",")
+		ruleValue = strings.TrimSpace(ruleValue)
+		ruleType = strings.ToUpper(strings.TrimSpace(ruleType))
+		switch ruleType {
+		case "DOMAIN":
+			domains = append(domains, strings.ToLower(ruleValue))
+		case "DOMAIN-SUFFIX":
+			ruleValue = strings.ToLower(ruleValue)
+			domains = append(domains, ruleValue)
+			domainSuffixes = append(domainSuffixes, "."+ruleValue)
+		case "DOMAIN-KEYWORD":
+			domainKeywords = append(domainKeywords, strings.ToLower(ruleValue))
+		case "DOMAIN-REGEX":
+			domainRegexes = append(domainRegexes, ruleValue)
+		case "IP-CIDR", "IP-CIDR6":
+			ipCIDRs = append(ipCIDRs, ruleValue)
+		case "SRC-IP-CIDR":
+			sourceIPCIDRs = append(sourceIPCIDRs, ruleValue)
+		case "DST-PORT", "SRC-PORT":
+			port, err := strconv.ParseUint(ruleValue, 10, 16)
+			if err != nil {
+				return nil, E.Cause(err, "parse rule[", i, "]: ", rule)
+			}
+			if ruleType == "SRC-PORT" {
+				sourcePorts = append(sourcePorts, uint16(port))
+			} else {
+				ports = append(ports, uint16(port))
+			}
+		case "PROCESS-NAME":
+			processNames = append(processNames, ruleValue)
+		case "PROCESS-PATH":
+			processPaths = append(processPaths, ruleValue)
+		case "NETWORK":
+			networks = append(networks, strings.ToLower(ruleValue))
+		default:
+			unsupportedCount++
+		}
+	}
+	if unsupportedCount > 0 {
+		p.logger.Warn("ignoring ", unsupportedCount, " unsupported rules")
+	}
+	var items []RuleItem
+	if len(domains) > 0 || len(domainSuffixes) > 0 {
+		items = append(items, NewDomainItem(domains, domainSuffixes))
+	}
+	if len(domainKeywords) > 0 {
+		items = append(items, NewDomainKeywordItem(domainKeywords))
+	}
+	if len(domainRegexes) > 0 {
+		item, err := NewDomainRegexItem(domainRegexes)
+		if err != nil {
+			return nil, E.Cause(err, "domain_regex")
+		}
+		items = append(items, item)
+	}
+	if len(ipCIDRs) > 0 {
+		item, err := NewIPCIDRItem(false, ipCIDRs)
+		if err != nil {
+			return nil, E.Cause(err, "ipcidr")
+		}
+		items = append(items, item)
+	}
+	if len(sourceIPCIDRs) > 0 {
+		item, err := NewIPCIDRItem(true, sourceIPCIDRs)
+		if err != nil {
+			return nil, E.Cause(err, "source_ipcidr")
+		}
+		items = append(items, item)
+	}
+	if len(ports) > 0 {
+		items = append(items, NewPortItem(false, ports))
+	}
+	if len(sourcePorts) > 0 {
+		items = append(items, NewPortItem(true, sourcePorts))
+	}
+	if len(processNames) > 0 {
+		items = append(items, NewProcessItem(processNames))
+	}
+	if len(processPaths) > 0 {
+		items = append(items, NewProcessPathItem(processPaths))
+	}
+	if len(networks) > 0 {
+		items = append(items, NewNetworkItem(networks))
+	}
+	return items, nil
+}
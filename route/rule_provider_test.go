@@ -0,0 +1,97 @@
+package route
+
+import (
+	"testing"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing/common"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRuleProviderPayload(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name       string
+		fileFormat string
+		content    string
+		payload    []string
+	}{
+		{
+			name:       "yaml",
+			fileFormat: C.RuleProviderFileFormatYAML,
+			content:    "# comment\npayload:\n  - example.com\n  - '+.example.org'\n",
+			payload:    []string{"example.com", "+.example.org"},
+		},
+		{
+			name:       "yaml empty",
+			fileFormat: C.RuleProviderFileFormatYAML,
+			content:    "payload: []\n",
+			payload:    []string{},
+		},
+		{
+			name:       "text",
+			fileFormat: C.RuleProviderFileFormatText,
+			content:    "# comment\nexample.com\r\n\n  // comment\n+.example.org\n",
+			payload:    []string{"example.com", "+.example.org"},
+		},
+		{
+			name:       "text containing payload",
+			fileFormat: C.RuleProviderFileFormatText,
+			content:    "DOMAIN-KEYWORD,payload:\n",
+			payload:    []string{"DOMAIN-KEYWORD,payload:"},
+		},
+	}
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			payload, err := parseRuleProviderPayload([]byte(testCase.content), testCase.fileFormat)
+			require.NoError(t, err)
+			require.Equal(t, testCase.payload, payload)
+		})
+	}
+}
+
+func TestParseRuleProviderPayloadMalformed(t *testing.T) {
+	t.Parallel()
+	for _, content := range []string{
+		"payload: [",
+		"payload: example.com",
+		"example.com\nexample.org\n",
+		"rules:\n  - example.com\n",
+	} {
+		_, err := parseRuleProviderPayload([]byte(content), C.RuleProviderFileFormatYAML)
+		require.Error(t, err, content)
+	}
+}
+
+func TestRuleProviderFileFormat(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, C.RuleProviderFileFormatYAML, ruleProviderFileFormat("rules/ads.yaml", ""))
+	require.Equal(t, C.RuleProviderFileFormatText, ruleProviderFileFormat("rules/ads.TXT", ""))
+	require.Equal(t, C.RuleProviderFileFormatText, ruleProviderFileFormat("", "https://example.com/ads.list?token=yaml"))
+	require.Equal(t, C.RuleProviderFileFormatYAML, ruleProviderFileFormat("rules/ads.yml", "https://example.com/ads.list"))
+	require.Equal(t, C.RuleProviderFileFormatYAML, ruleProviderFileFormat("", "https://example.com/ads"))
+}
+
+func TestClassicalProviderItems(t *testing.T) {
+	t.Parallel()
+	provider := &RuleProvider{logger: log.NewNOPFactory().Logger()}
+	items, err := provider.newClassicalProviderItems([]string{
+		" src-port,80",
+		"DST-PORT , 443",
+		"DOMAIN,Example.com,no-resolve",
+		"GEOIP,CN",
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"domain=example.com", "port=443", "source_port=80"}, common.Map(items, RuleItem.String))
+}
+
+func TestRuleProviderCloseTwice(t *testing.T) {
+	t.Parallel()
+	provider := &RuleProvider{close: make(chan struct{})}
+	require.NoError(t, provider.Close())
+	require.NoError(t, provider.Close())
+}
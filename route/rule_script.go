@@ -0,0 +1,44 @@
+package route
+
+import "github.com/sagernet/sing-box/adapter"
+
+var _ adapter.Rule = (*scriptRule)(nil)
+
+// scriptRule stands in for a route script decision, so that connections routed
+// by the script are tracked against the chosen outbound.
+type scriptRule struct {
+	outbound   string
+	statistics *adapter.RuleStatistics
+}
+
+func (r *scriptRule) Type() string {
+	return "script"
+}
+
+func (r *scriptRule) Start() error {
+	return nil
+}
+
+func (r *scriptRule) Close() error {
+	return nil
+}
+
+func (r *scriptRule) UpdateGeosite() error {
+	return nil
+}
+
+func (r *scriptRule) Match(metadata *adapter.InboundContext) bool {
+	return false
+}
+
+func (r *scriptRule) Outbound() string {
+	return r.outbound
+}
+
+func (r *scriptRule) String() string {
+	return "script"
+}
+
+func (r *scriptRule) Statistics() *adapter.RuleStatistics {
+	return r.statistics
+}
@@ -15,6 +15,7 @@ require (
 	github.com/spyzhov/ajson v0.8.0
 	github.com/stretchr/testify v1.8.2
 	go.uber.org/goleak v1.2.1
+	golang.org/x/crypto v0.8.0
 	golang.org/x/net v0.9.0
 )
 
@@ -86,7 +87,6 @@ require (
 	go.uber.org/multierr v1.6.0 // indirect
 	go.uber.org/zap v1.24.0 // indirect
 	go4.org/netipx v0.0.0-20230303233057-f1b76eb4bb35 // indirect
-	golang.org/x/crypto v0.8.0 // indirect
 	golang.org/x/exp v0.0.0-20230321023759-10a507213a29 // indirect
 	golang.org/x/mod v0.8.0 // indirect
 	golang.org/x/sys v0.7.0 // indirect
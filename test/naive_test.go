@@ -21,10 +21,12 @@ func TestNaiveInboundWithNginx(t *testing.T) {
 						Listen:     option.NewListenAddress(netip.IPv4Unspecified()),
 						ListenPort: otherPort,
 					},
-					Users: []auth.User{
+					Users: []option.NaiveUser{
 						{
-							Username: "sekai",
-							Password: "password",
+							User: auth.User{
+								Username: "sekai",
+								Password: "password",
+							},
 						},
 					},
 					Network: network.NetworkTCP,
@@ -67,10 +69,12 @@ func TestNaiveInbound(t *testing.T) {
 						Listen:     option.NewListenAddress(netip.IPv4Unspecified()),
 						ListenPort: serverPort,
 					},
-					Users: []auth.User{
+					Users: []option.NaiveUser{
 						{
-							Username: "sekai",
-							Password: "password",
+							User: auth.User{
+								Username: "sekai",
+								Password: "password",
+							},
 						},
 					},
 					Network: network.NetworkTCP,
@@ -109,10 +113,12 @@ func TestNaiveHTTP3Inbound(t *testing.T) {
 						Listen:     option.NewListenAddress(netip.IPv4Unspecified()),
 						ListenPort: serverPort,
 					},
-					Users: []auth.User{
+					Users: []option.NaiveUser{
 						{
-							Username: "sekai",
-							Password: "password",
+							User: auth.User{
+								Username: "sekai",
+								Password: "password",
+							},
 						},
 					},
 					Network: network.NetworkUDP,
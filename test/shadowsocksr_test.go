@@ -46,3 +46,83 @@ func TestShadowsocksR(t *testing.T) {
 	})
 	testSuit(t, clientPort, testPort)
 }
+
+func TestShadowsocksRSelf(t *testing.T) {
+	t.Run("origin", func(t *testing.T) {
+		testShadowsocksRSelf(t, "aes-256-cfb", "plain", "origin", "", nil)
+	})
+	t.Run("auth_aes128_md5-http_simple", func(t *testing.T) {
+		testShadowsocksRSelf(t, "aes-128-ctr", "http_simple", "auth_aes128_md5", "", nil)
+	})
+	t.Run("auth_aes128_sha1-http_post-users", func(t *testing.T) {
+		testShadowsocksRSelf(t, "chacha20-ietf", "http_post", "auth_aes128_sha1", "1024:password1", []option.ShadowsocksRUser{
+			{
+				Name:     "sekai",
+				ID:       1024,
+				Password: "password1",
+			},
+		})
+	})
+}
+
+func testShadowsocksRSelf(t *testing.T, method string, obfs string, protocol string, protocolParam string, users []option.ShadowsocksRUser) {
+	startInstance(t, option.Options{
+		Inbounds: []option.Inbound{
+			{
+				Type: C.TypeMixed,
+				Tag:  "mixed-in",
+				MixedOptions: option.HTTPMixedInboundOptions{
+					ListenOptions: option.ListenOptions{
+						Listen:     option.NewListenAddress(netip.IPv4Unspecified()),
+						ListenPort: clientPort,
+					},
+				},
+			},
+			{
+				Type: C.TypeShadowsocksR,
+				ShadowsocksROptions: option.ShadowsocksRInboundOptions{
+					ListenOptions: option.ListenOptions{
+						Listen:     option.NewListenAddress(netip.IPv4Unspecified()),
+						ListenPort: serverPort,
+					},
+					Method:   method,
+					Password: "password0",
+					Obfs:     obfs,
+					Protocol: protocol,
+					Users:    users,
+				},
+			},
+		},
+		Outbounds: []option.Outbound{
+			{
+				Type: C.TypeDirect,
+			},
+			{
+				Type: C.TypeShadowsocksR,
+				Tag:  "ssr-out",
+				ShadowsocksROptions: option.ShadowsocksROutboundOptions{
+					ServerOptions: option.ServerOptions{
+						Server:     "127.0.0.1",
+						ServerPort: serverPort,
+					},
+					Method:        method,
+					Password:      "password0",
+					Obfs:          obfs,
+					Protocol:      protocol,
+					ProtocolParam: protocolParam,
+				},
+			},
+		},
+		Route: &option.RouteOptions{
+			Rules: []option.Rule{
+				{
+					DefaultOptions: option.DefaultRule{
+						Inbound:  []string{"mixed-in"},
+						Outbound: "ssr-out",
+					},
+				},
+			},
+		},
+	})
+	testSuit(t, clientPort, testPort)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/netip"
+	"testing"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSSHSelf(t *testing.T) {
+	hostKey, hostPublicKey := createSSHKey(t)
+	userKey, userPublicKey := createSSHKey(t)
+	t.Run("password", func(t *testing.T) {
+		testSSHSelf(t, hostKey, hostPublicKey, userPublicKey, option.SSHOutboundOptions{
+			User:     "sekai",
+			Password: "password",
+		})
+	})
+	t.Run("public-key", func(t *testing.T) {
+		testSSHSelf(t, hostKey, hostPublicKey, userPublicKey, option.SSHOutboundOptions{
+			User:       "nya",
+			PrivateKey: userKey,
+		})
+	})
+}
+
+func testSSHSelf(t *testing.T, hostKey string, hostPublicKey string, userPublicKey string, outboundOptions option.SSHOutboundOptions) {
+	outboundOptions.ServerOptions = option.ServerOptions{
+		Server:     "127.0.0.1",
+		ServerPort: serverPort,
+	}
+	outboundOptions.HostKey = []string{hostPublicKey}
+	startInstance(t, option.Options{
+		Inbounds: []option.Inbound{
+			{
+				Type: C.TypeMixed,
+				Tag:  "mixed-in",
+				MixedOptions: option.HTTPMixedInboundOptions{
+					ListenOptions: option.ListenOptions{
+						Listen:     option.NewListenAddress(netip.IPv4Unspecified()),
+						ListenPort: clientPort,
+					},
+				},
+			},
+			{
+				Type: C.TypeSSH,
+				SSHOptions: option.SSHInboundOptions{
+					ListenOptions: option.ListenOptions{
+						Listen:     option.NewListenAddress(netip.IPv4Unspecified()),
+						ListenPort: serverPort,
+					},
+					HostKey: []string{hostKey},
+					Users: []option.SSHUser{
+						{
+							Name:     "sekai",
+							Password: "password",
+						},
+						{
+							Name:           "nya",
+							AuthorizedKeys: []string{userPublicKey},
+						},
+					},
+				},
+			},
+		},
+		Outbounds: []option.Outbound{
+			{
+				Type: C.TypeDirect,
+			},
+			{
+				Type:       C.TypeSSH,
+				Tag:        "ssh-out",
+				SSHOptions: outboundOptions,
+			},
+		},
+		Route: &option.RouteOptions{
+			Rules: []option.Rule{
+				{
+					DefaultOptions: option.DefaultRule{
+						Inbound:  []string{"mixed-in"},
+						Outbound: "ssh-out",
+					},
+				},
+			},
+		},
+	})
+	testTCP(t, clientPort, testPort)
+}
+
+func createSSHKey(t *testing.T) (privateKey string, publicKey string) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+	privateKey = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes}))
+	publicKey = string(ssh.MarshalAuthorizedKey(signer.PublicKey()))
+	return
+}
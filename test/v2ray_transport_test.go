@@ -26,6 +26,39 @@ func TestV2RayHTTPPlainSelf(t *testing.T) {
 	})
 }
 
+func TestV2RayHTTPUpgradeSelf(t *testing.T) {
+	testV2RayTransportSelf(t, &option.V2RayTransportOptions{
+		Type: C.V2RayTransportTypeHTTPUpgrade,
+		HTTPUpgradeOptions: option.V2RayWebsocketOptions{
+			Path: "/upgrade",
+		},
+	})
+}
+
+func TestV2RayHTTPUpgradePlainSelf(t *testing.T) {
+	testV2RayTransportNOTLSSelf(t, &option.V2RayTransportOptions{
+		Type: C.V2RayTransportTypeHTTPUpgrade,
+	})
+}
+
+func TestV2RaySplitHTTPSelf(t *testing.T) {
+	testV2RayTransportSelf(t, &option.V2RayTransportOptions{
+		Type: C.V2RayTransportTypeSplitHTTP,
+		SplitHTTPOptions: option.V2RaySplitHTTPOptions{
+			Path: "/split",
+		},
+	})
+}
+
+func TestV2RaySplitHTTPPlainSelf(t *testing.T) {
+	testV2RayTransportNOTLSSelf(t, &option.V2RayTransportOptions{
+		Type: C.V2RayTransportTypeSplitHTTP,
+		SplitHTTPOptions: option.V2RaySplitHTTPOptions{
+			MaxUploadSize: 4096,
+		},
+	})
+}
+
 func testV2RayTransportSelf(t *testing.T, transport *option.V2RayTransportOptions) {
 	testV2RayTransportSelfWith(t, transport, transport)
 }
@@ -37,6 +70,9 @@ func testV2RayTransportSelfWith(t *testing.T, server, client *option.V2RayTransp
 	t.Run("trojan", func(t *testing.T) {
 		testTrojanTransportSelf(t, server, client)
 	})
+	t.Run("vless", func(t *testing.T) {
+		testVLESSTransportSelf(t, server, client)
+	})
 }
 
 func testVMessTransportSelf(t *testing.T, server *option.V2RayTransportOptions, client *option.V2RayTransportOptions) {
@@ -190,6 +226,81 @@ func testTrojanTransportSelf(t *testing.T, server *option.V2RayTransportOptions,
 	testSuit(t, clientPort, testPort)
 }
 
+func testVLESSTransportSelf(t *testing.T, server *option.V2RayTransportOptions, client *option.V2RayTransportOptions) {
+	user, err := uuid.DefaultGenerator.NewV4()
+	require.NoError(t, err)
+	_, certPem, keyPem := createSelfSignedCertificate(t, "example.org")
+	startInstance(t, option.Options{
+		Inbounds: []option.Inbound{
+			{
+				Type: C.TypeMixed,
+				Tag:  "mixed-in",
+				MixedOptions: option.HTTPMixedInboundOptions{
+					ListenOptions: option.ListenOptions{
+						Listen:     option.NewListenAddress(netip.IPv4Unspecified()),
+						ListenPort: clientPort,
+					},
+				},
+			},
+			{
+				Type: C.TypeVLESS,
+				VLESSOptions: option.VLESSInboundOptions{
+					ListenOptions: option.ListenOptions{
+						Listen:     option.NewListenAddress(netip.IPv4Unspecified()),
+						ListenPort: serverPort,
+					},
+					Users: []option.VLESSUser{
+						{
+							Name: "sekai",
+							UUID: user.String(),
+						},
+					},
+					TLS: &option.InboundTLSOptions{
+						Enabled:         true,
+						ServerName:      "example.org",
+						CertificatePath: certPem,
+						KeyPath:         keyPem,
+					},
+					Transport: server,
+				},
+			},
+		},
+		Outbounds: []option.Outbound{
+			{
+				Type: C.TypeDirect,
+			},
+			{
+				Type: C.TypeVLESS,
+				Tag:  "vless-out",
+				VLESSOptions: option.VLESSOutboundOptions{
+					ServerOptions: option.ServerOptions{
+						Server:     "127.0.0.1",
+						ServerPort: serverPort,
+					},
+					UUID: user.String(),
+					TLS: &option.OutboundTLSOptions{
+						Enabled:         true,
+						ServerName:      "example.org",
+						CertificatePath: certPem,
+					},
+					Transport: client,
+				},
+			},
+		},
+		Route: &option.RouteOptions{
+			Rules: []option.Rule{
+				{
+					DefaultOptions: option.DefaultRule{
+						Inbound:  []string{"mixed-in"},
+						Outbound: "vless-out",
+					},
+				},
+			},
+		},
+	})
+	testSuit(t, clientPort, testPort)
+}
+
 func TestVMessQUICSelf(t *testing.T) {
 	transport := &option.V2RayTransportOptions{
 		Type: C.V2RayTransportTypeQUIC,
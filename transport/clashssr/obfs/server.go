@@ -0,0 +1,137 @@
+package obfs
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Dreamacro/clash/common/pool"
+)
+
+var (
+	errHTTPHeaderTooLong = errors.New("http obfs header too long")
+	errHTTPBadRequest    = errors.New("http obfs bad request")
+)
+
+// ServerObfs is the server side of an obfs plugin.
+type ServerObfs interface {
+	ServerConn(net.Conn) net.Conn
+}
+
+func PickServerObfs(name string) (ServerObfs, error) {
+	switch name {
+	case "", "plain":
+		return &plain{}, nil
+	case "http_simple", "http_post":
+		return &httpServerObfs{}, nil
+	default:
+		return nil, fmt.Errorf("Obfs %s not supported in server", name)
+	}
+}
+
+func (p *plain) ServerConn(c net.Conn) net.Conn { return c }
+
+type httpServerObfs struct{}
+
+func (h *httpServerObfs) ServerConn(c net.Conn) net.Conn {
+	return &httpServerConn{Conn: c}
+}
+
+// httpServerConn strips the fake HTTP request sent by http_simple and http_post clients, whose path carries
+// the URL encoded head of the payload, and answers with a fake HTTP response.
+type httpServerConn struct {
+	net.Conn
+	hasSentHeader bool
+	hasRecvHeader bool
+	buf           []byte
+}
+
+func (c *httpServerConn) Read(b []byte) (int, error) {
+	if !c.hasRecvHeader {
+		err := c.readHeader()
+		if err != nil {
+			return 0, err
+		}
+		c.hasRecvHeader = true
+	}
+	if len(c.buf) > 0 {
+		n := copy(b, c.buf)
+		c.buf = c.buf[n:]
+		return n, nil
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *httpServerConn) readHeader() error {
+	buf := pool.Get(pool.RelayBufferSize)
+	defer pool.Put(buf)
+	var headerLen int
+	for {
+		n, err := c.Conn.Read(buf[headerLen:])
+		if err != nil {
+			return err
+		}
+		headerLen += n
+		pos := bytes.Index(buf[:headerLen], []byte("\r\n\r\n"))
+		if pos != -1 {
+			headData, err := parseURLEncodedHeadData(buf[:pos])
+			if err != nil {
+				return err
+			}
+			c.buf = append(headData, buf[pos+4:headerLen]...)
+			return nil
+		}
+		if headerLen == len(buf) {
+			return errHTTPHeaderTooLong
+		}
+	}
+}
+
+func parseURLEncodedHeadData(header []byte) ([]byte, error) {
+	// GET /%xx%xx... HTTP/1.1
+	requestLine := header
+	if pos := bytes.Index(header, []byte("\r\n")); pos != -1 {
+		requestLine = header[:pos]
+	}
+	fields := bytes.Fields(requestLine)
+	if len(fields) != 3 || len(fields[1]) == 0 || fields[1][0] != '/' {
+		return nil, errHTTPBadRequest
+	}
+	path := fields[1][1:]
+	if pos := bytes.IndexByte(path, '?'); pos != -1 {
+		path = path[:pos]
+	}
+	headData := make([]byte, 0, len(path)/3)
+	for len(path) >= 3 && path[0] == '%' {
+		var value [1]byte
+		_, err := hex.Decode(value[:], path[1:3])
+		if err != nil {
+			return nil, errHTTPBadRequest
+		}
+		headData = append(headData, value[0])
+		path = path[3:]
+	}
+	return headData, nil
+}
+
+func (c *httpServerConn) Write(b []byte) (int, error) {
+	if c.hasSentHeader {
+		return c.Conn.Write(b)
+	}
+	buf := pool.GetBuffer()
+	defer pool.PutBuffer(buf)
+	buf.WriteString("HTTP/1.1 200 OK\r\nConnection: keep-alive\r\nContent-Encoding: gzip\r\nContent-Type: text/html\r\nDate: ")
+	buf.WriteString(time.Now().UTC().Format(http.TimeFormat))
+	buf.WriteString("\r\nServer: nginx\r\nVary: Accept-Encoding\r\n\r\n")
+	buf.Write(b)
+	_, err := c.Conn.Write(buf.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	c.hasSentHeader = true
+	return len(b), nil
+}
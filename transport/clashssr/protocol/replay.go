@@ -0,0 +1,96 @@
+package protocol
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// authClientTimeout is the time after which an idle client starts a new connection ID window.
+	authClientTimeout = 3 * time.Minute
+	authMaxClients    = 1024
+)
+
+// authDataFilter rejects replayed auth data, keeping a window of the
+// connection IDs used by each client as the original SSR server does.
+type authDataFilter struct {
+	access  sync.Mutex
+	clients map[authClientKey]*authClient
+}
+
+type authClientKey struct {
+	userID   uint32
+	clientID [4]byte
+}
+
+type authClient struct {
+	front      uint32
+	back       uint32
+	used       map[uint32]bool
+	lastUpdate time.Time
+}
+
+func newAuthDataFilter() *authDataFilter {
+	return &authDataFilter{
+		clients: make(map[authClientKey]*authClient),
+	}
+}
+
+// Check records the connection ID of the client, returning false if it was used before.
+func (f *authDataFilter) Check(userID uint32, clientID [4]byte, connectionID uint32) bool {
+	f.access.Lock()
+	defer f.access.Unlock()
+	now := time.Now()
+	key := authClientKey{userID, clientID}
+	client, loaded := f.clients[key]
+	if !loaded || now.Sub(client.lastUpdate) > authClientTimeout {
+		if !loaded {
+			f.evict(now)
+		}
+		client = &authClient{
+			back: connectionID + 1,
+			used: make(map[uint32]bool),
+		}
+		if connectionID > 64 {
+			client.front = connectionID - 64
+		}
+		f.clients[key] = client
+	}
+	client.lastUpdate = now
+	if connectionID < client.front || connectionID > client.front+0x4000 || client.used[connectionID] {
+		return false
+	}
+	if client.back <= connectionID {
+		client.back = connectionID + 1
+	}
+	client.used[connectionID] = true
+	for client.used[client.front] || client.front+0x1000 < client.back {
+		delete(client.used, client.front)
+		client.front++
+	}
+	return true
+}
+
+// evict makes room for a new client, dropping idle clients first and the least recently updated one if none is idle.
+func (f *authDataFilter) evict(now time.Time) {
+	if len(f.clients) < authMaxClients {
+		return
+	}
+	var (
+		oldestKey    authClientKey
+		oldestUpdate time.Time
+	)
+	for key, client := range f.clients {
+		if now.Sub(client.lastUpdate) > authClientTimeout {
+			delete(f.clients, key)
+			continue
+		}
+		if oldestUpdate.IsZero() || client.lastUpdate.Before(oldestUpdate) {
+			oldestKey = key
+			oldestUpdate = client.lastUpdate
+		}
+	}
+	if len(f.clients) >= authMaxClients {
+		delete(f.clients, oldestKey)
+	}
+}
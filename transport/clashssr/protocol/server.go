@@ -0,0 +1,191 @@
+package protocol
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/Dreamacro/clash/transport/shadowsocks/core"
+	"github.com/Dreamacro/clash/transport/ssr/tools"
+)
+
+var (
+	errAuthAES128AuthError      = errors.New("auth_aes128 decode auth data wrong mac")
+	errAuthAES128UserError      = errors.New("auth_aes128 unknown user")
+	errAuthAES128TimeError      = errors.New("auth_aes128 auth data timestamp out of range")
+	errAuthAES128PacketMACError = errors.New("auth_aes128 decode packet wrong mac")
+	errAuthAES128ReplayError    = errors.New("auth_aes128 replayed auth data")
+)
+
+// authAES128MaxTimeDiff is the max clock difference between client and server accepted by auth_aes128.
+const authAES128MaxTimeDiff = 24 * time.Hour
+
+type ServerBase struct {
+	Key []byte
+	// LoadUser returns the password of the user ID sent by the client, the key is used if the password is empty.
+	// All user IDs are accepted with the key if nil.
+	LoadUser func(userID uint32) (password string, loaded bool)
+}
+
+// ServerProtocol is the server side of a protocol plugin.
+type ServerProtocol interface {
+	// StreamConn reads the protocol header sent by the client, iv is the IV of the client stream.
+	StreamConn(c net.Conn, iv []byte) (conn net.Conn, userID uint32, err error)
+	DecodeServerPacket(b []byte) (payload []byte, userID uint32, err error)
+	EncodeServerPacket(buf *bytes.Buffer, b []byte) error
+}
+
+func PickServerProtocol(name string, b *ServerBase) (ServerProtocol, error) {
+	switch name {
+	case "", "origin":
+		return &originServer{}, nil
+	case "auth_aes128_md5":
+		return &authAES128Server{
+			ServerBase:         b,
+			authAES128Function: &authAES128Function{salt: "auth_aes128_md5", hmac: tools.HmacMD5, hashDigest: tools.MD5Sum},
+			overhead:           protocolList[name].overhead,
+			filter:             newAuthDataFilter(),
+		}, nil
+	case "auth_aes128_sha1":
+		return &authAES128Server{
+			ServerBase:         b,
+			authAES128Function: &authAES128Function{salt: "auth_aes128_sha1", hmac: tools.HmacSHA1, hashDigest: tools.SHA1Sum},
+			overhead:           protocolList[name].overhead,
+			filter:             newAuthDataFilter(),
+		}, nil
+	default:
+		return nil, fmt.Errorf("protocol %s not supported in server", name)
+	}
+}
+
+type originServer struct{}
+
+func (o *originServer) StreamConn(c net.Conn, iv []byte) (net.Conn, uint32, error) {
+	return c, 0, nil
+}
+
+func (o *originServer) DecodeServerPacket(b []byte) ([]byte, uint32, error) {
+	return b, 0, nil
+}
+
+func (o *originServer) EncodeServerPacket(buf *bytes.Buffer, b []byte) error {
+	buf.Write(b)
+	return nil
+}
+
+type authAES128Server struct {
+	*ServerBase
+	*authAES128Function
+	overhead int
+	filter   *authDataFilter
+}
+
+func (a *authAES128Server) loadUserKey(userID uint32) ([]byte, error) {
+	if a.LoadUser == nil {
+		return a.Key, nil
+	}
+	password, loaded := a.LoadUser(userID)
+	if !loaded {
+		return nil, errAuthAES128UserError
+	}
+	if password == "" {
+		return a.Key, nil
+	}
+	return a.hashDigest([]byte(password)), nil
+}
+
+func (a *authAES128Server) StreamConn(c net.Conn, iv []byte) (net.Conn, uint32, error) {
+	/*
+		7:	checkHead(1) and hmac of checkHead(6)
+		4:	userID
+		16:	encrypted data of authdata(12), uint16 LittleEndian packedAuthDataLength(2) and uint16 LittleEndian randDataLength(2)
+		4:	hmac of userID and encrypted data
+		4:	hmac of packedAuthData except the last 4 bytes
+	*/
+	header := make([]byte, 7+4+16+4)
+	_, err := io.ReadFull(c, header)
+	if err != nil {
+		return nil, 0, err
+	}
+	macKey := make([]byte, len(iv)+len(a.Key))
+	copy(macKey, iv)
+	copy(macKey[len(iv):], a.Key)
+	if !bytes.Equal(a.hmac(macKey, header[:1])[:6], header[1:7]) ||
+		!bytes.Equal(a.hmac(macKey, header[7:27])[:4], header[27:31]) {
+		return nil, 0, errAuthAES128AuthError
+	}
+	userID := binary.LittleEndian.Uint32(header[7:11])
+	userKey, err := a.loadUserKey(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	block, err := aes.NewCipher(core.Kdf(base64.StdEncoding.EncodeToString(userKey)+a.salt, 16))
+	if err != nil {
+		return nil, 0, err
+	}
+	authData := make([]byte, 16)
+	cipher.NewCBCDecrypter(block, make([]byte, 16)).CryptBlocks(authData, header[11:27])
+	timeDiff := time.Since(time.Unix(int64(binary.LittleEndian.Uint32(authData)), 0))
+	if timeDiff > authAES128MaxTimeDiff || timeDiff < -authAES128MaxTimeDiff {
+		return nil, 0, errAuthAES128TimeError
+	}
+	packedAuthDataLength := int(binary.LittleEndian.Uint16(authData[12:]))
+	randDataLength := int(binary.LittleEndian.Uint16(authData[14:]))
+	if packedAuthDataLength >= 4096 || packedAuthDataLength < len(header)+randDataLength+4 {
+		return nil, 0, errAuthAES128LengthError
+	}
+	packedAuthData := make([]byte, packedAuthDataLength)
+	copy(packedAuthData, header)
+	_, err = io.ReadFull(c, packedAuthData[len(header):])
+	if err != nil {
+		return nil, 0, err
+	}
+	if !bytes.Equal(a.hmac(userKey, packedAuthData[:packedAuthDataLength-4])[:4], packedAuthData[packedAuthDataLength-4:]) {
+		return nil, 0, errAuthAES128ChksumError
+	}
+	var clientID [4]byte
+	copy(clientID[:], authData[4:8])
+	if !a.filter.Check(userID, clientID, binary.LittleEndian.Uint32(authData[8:12])) {
+		return nil, 0, errAuthAES128ReplayError
+	}
+	p := &authAES128{
+		Base:               &Base{Key: a.Key, Overhead: a.overhead},
+		authAES128Function: a.authAES128Function,
+		userData:           &userData{userKey: userKey},
+		// the client stream starts with the auth data, while the server stream does not
+		hasSentHeader: true,
+		packID:        1,
+		recvID:        1,
+	}
+	conn := &Conn{Conn: c, Protocol: p}
+	conn.decoded.Write(packedAuthData[len(header)+randDataLength : packedAuthDataLength-4])
+	return conn, userID, nil
+}
+
+func (a *authAES128Server) DecodeServerPacket(b []byte) ([]byte, uint32, error) {
+	if len(b) < 8 {
+		return nil, 0, errAuthAES128LengthError
+	}
+	userID := binary.LittleEndian.Uint32(b[len(b)-8 : len(b)-4])
+	userKey, err := a.loadUserKey(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !bytes.Equal(a.hmac(userKey, b[:len(b)-4])[:4], b[len(b)-4:]) {
+		return nil, 0, errAuthAES128PacketMACError
+	}
+	return b[:len(b)-8], userID, nil
+}
+
+func (a *authAES128Server) EncodeServerPacket(buf *bytes.Buffer, b []byte) error {
+	buf.Write(b)
+	buf.Write(a.hmac(a.Key, b)[:4])
+	return nil
+}
@@ -1,6 +1,8 @@
 package fakeip
 
 import (
+	"math"
+	"math/big"
 	"net/netip"
 
 	"github.com/sagernet/sing-box/adapter"
@@ -17,6 +19,8 @@ type Store struct {
 	storage      adapter.FakeIPStorage
 	inet4Current netip.Addr
 	inet6Current netip.Addr
+	inet4Wrapped bool
+	inet6Wrapped bool
 }
 
 func NewStore(router adapter.Router, inet4Range netip.Prefix, inet6Range netip.Prefix) *Store {
@@ -78,6 +82,7 @@ func (s *Store) Create(domain string, strategy dns.DomainStrategy) (netip.Addr,
 		nextAddress := s.inet4Current.Next()
 		if !s.inet4Range.Contains(nextAddress) {
 			nextAddress = s.inet4Range.Addr().Next().Next()
+			s.inet4Wrapped = true
 		}
 		s.inet4Current = nextAddress
 		address = nextAddress
@@ -88,6 +93,7 @@ func (s *Store) Create(domain string, strategy dns.DomainStrategy) (netip.Addr,
 		nextAddress := s.inet6Current.Next()
 		if !s.inet6Range.Contains(nextAddress) {
 			nextAddress = s.inet6Range.Addr().Next().Next()
+			s.inet6Wrapped = true
 		}
 		s.inet6Current = nextAddress
 		address = nextAddress
@@ -106,3 +112,27 @@ func (s *Store) Lookup(address netip.Addr) (string, bool) {
 func (s *Store) Reset() error {
 	return s.storage.FakeIPReset()
 }
+
+func (s *Store) Usage() adapter.FakeIPUsage {
+	var usage adapter.FakeIPUsage
+	if s.inet4Current.IsValid() {
+		usage.Inet4Allocated, usage.Inet4Size = rangeUsage(s.inet4Range, s.inet4Current, s.inet4Wrapped)
+	}
+	if s.inet6Current.IsValid() {
+		usage.Inet6Allocated, usage.Inet6Size = rangeUsage(s.inet6Range, s.inet6Current, s.inet6Wrapped)
+	}
+	return usage
+}
+
+// rangeUsage counts addresses from the third address of the range, which is
+// where allocation starts, to the current address.
+func rangeUsage(prefix netip.Prefix, current netip.Addr, wrapped bool) (allocated float64, size float64) {
+	size = math.Ldexp(1, current.BitLen()-prefix.Bits()) - 2
+	if wrapped {
+		return size, size
+	}
+	start := prefix.Addr().AsSlice()
+	offset := new(big.Int).Sub(new(big.Int).SetBytes(current.AsSlice()), new(big.Int).SetBytes(start))
+	allocated, _ = new(big.Float).SetInt(offset).Float64()
+	return allocated - 2, size
+}
@@ -0,0 +1,302 @@
+package hysteria2
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/quic-go/http3"
+	"github.com/sagernet/sing-box/common/baderror"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/transport/hysteria"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+type ClientOptions struct {
+	Context            context.Context
+	Dialer             N.Dialer
+	ServerAddress      M.Socksaddr
+	SendBPS            uint64
+	ReceiveBPS         uint64
+	SalamanderPassword string
+	Password           string
+	TLSConfig          *tls.Config
+}
+
+type Client struct {
+	ctx                context.Context
+	dialer             N.Dialer
+	serverAddr         M.Socksaddr
+	sendBPS            uint64
+	receiveBPS         uint64
+	salamanderPassword string
+	password           string
+	tlsConfig          *tls.Config
+	quicConfig         *quic.Config
+
+	connAccess sync.Mutex
+	conn       *clientQUICConnection
+}
+
+func NewClient(options ClientOptions) (*Client, error) {
+	if options.SalamanderPassword != "" && len(options.SalamanderPassword) < salamanderMinPasswordLen {
+		return nil, E.New("salamander password is too short")
+	}
+	tlsConfig := options.TLSConfig
+	tlsConfig.MinVersion = tls.VersionTLS13
+	if len(tlsConfig.NextProtos) == 0 {
+		tlsConfig.NextProtos = []string{DefaultALPN}
+	}
+	quicConfig := &quic.Config{
+		InitialStreamReceiveWindow:     hysteria.DefaultStreamReceiveWindow,
+		MaxStreamReceiveWindow:         hysteria.DefaultStreamReceiveWindow,
+		InitialConnectionReceiveWindow: hysteria.DefaultConnectionReceiveWindow,
+		MaxConnectionReceiveWindow:     hysteria.DefaultConnectionReceiveWindow,
+		KeepAlivePeriod:                hysteria.KeepAlivePeriod,
+		DisablePathMTUDiscovery:        !(C.IsLinux || C.IsWindows),
+		EnableDatagrams:                true,
+	}
+	return &Client{
+		ctx:                options.Context,
+		dialer:             options.Dialer,
+		serverAddr:         options.ServerAddress,
+		sendBPS:            options.SendBPS,
+		receiveBPS:         options.ReceiveBPS,
+		salamanderPassword: options.SalamanderPassword,
+		password:           options.Password,
+		tlsConfig:          tlsConfig,
+		quicConfig:         quicConfig,
+	}, nil
+}
+
+func (c *Client) offer(ctx context.Context) (*clientQUICConnection, error) {
+	conn := c.conn
+	if conn != nil && conn.active() {
+		return conn, nil
+	}
+	c.connAccess.Lock()
+	defer c.connAccess.Unlock()
+	conn = c.conn
+	if conn != nil && conn.active() {
+		return conn, nil
+	}
+	conn, err := c.offerNew(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (c *Client) offerNew(ctx context.Context) (*clientQUICConnection, error) {
+	udpConn, err := c.dialer.DialContext(c.ctx, "udp", c.serverAddr)
+	if err != nil {
+		return nil, err
+	}
+	var packetConn net.PacketConn
+	packetConn = bufio.NewUnbindPacketConn(udpConn)
+	if c.salamanderPassword != "" {
+		packetConn = NewSalamanderConn(packetConn, []byte(c.salamanderPassword))
+		packetConn = &hysteria.PacketConnWrapper{PacketConn: packetConn}
+	}
+	var quicConn quic.EarlyConnection
+	http3Transport := &http3.RoundTripper{
+		Dial: func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
+			// the http3 transport overrides the datagram option, use our own configs instead
+			connection, dErr := quic.DialEarlyContext(ctx, packetConn, udpConn.RemoteAddr(), c.serverAddr.AddrString(), c.tlsConfig, c.quicConfig)
+			if dErr != nil {
+				return nil, dErr
+			}
+			quicConn = connection
+			return connection, nil
+		},
+	}
+	request := &http.Request{
+		Method: http.MethodPost,
+		URL: &url.URL{
+			Scheme: "https",
+			Host:   URLHost,
+			Path:   URLPath,
+		},
+		Header: make(http.Header),
+	}
+	AuthRequestToHeader(request.Header, AuthRequest{Auth: c.password, Rx: c.receiveBPS})
+	response, err := http3Transport.RoundTrip(request.WithContext(ctx))
+	if err != nil {
+		if quicConn != nil {
+			quicConn.CloseWithError(0, "")
+		}
+		udpConn.Close()
+		return nil, E.Cause(err, "authenticate")
+	}
+	response.Body.Close()
+	if response.StatusCode != StatusAuthOK {
+		quicConn.CloseWithError(0, "")
+		udpConn.Close()
+		return nil, E.New("authentication failed, status code: ", response.StatusCode)
+	}
+	authResponse := AuthResponseFromHeader(response.Header)
+	var actualTx uint64
+	if !authResponse.RxAuto {
+		actualTx = authResponse.Rx
+		if actualTx == 0 || actualTx > c.sendBPS {
+			actualTx = c.sendBPS
+		}
+	}
+	setCongestion(quicConn, actualTx)
+	conn := &clientQUICConnection{
+		quicConn:    quicConn,
+		rawConn:     udpConn,
+		connDone:    make(chan struct{}),
+		udpDisabled: !authResponse.UDPEnabled,
+		udpConnMap:  make(map[uint32]*udpPacketConn),
+	}
+	if !conn.udpDisabled {
+		go c.loopMessages(conn)
+	}
+	c.conn = conn
+	return conn, nil
+}
+
+func (c *Client) loopMessages(conn *clientQUICConnection) {
+	for {
+		data, err := conn.quicConn.ReceiveMessage()
+		if err != nil {
+			conn.closeWithError(E.Cause(err, "receive message"))
+			return
+		}
+		message, err := parseUDPMessage(data)
+		if err != nil {
+			conn.closeWithError(E.Cause(err, "parse udp message"))
+			return
+		}
+		conn.handleUDPMessage(message)
+	}
+}
+
+func (c *Client) DialConn(ctx context.Context, destination M.Socksaddr) (net.Conn, error) {
+	conn, err := c.offer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := conn.quicConn.OpenStream()
+	if err != nil {
+		return nil, err
+	}
+	err = WriteTCPRequest(stream, destination.String())
+	if err != nil {
+		stream.Close()
+		return nil, err
+	}
+	return &clientConn{
+		StreamWrapper: hysteria.StreamWrapper{Conn: conn.quicConn, Stream: stream},
+	}, nil
+}
+
+func (c *Client) ListenPacket(ctx context.Context) (net.PacketConn, error) {
+	conn, err := c.offer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if conn.udpDisabled {
+		return nil, E.New("UDP disabled by server")
+	}
+	conn.udpAccess.Lock()
+	conn.udpSessionID++
+	sessionID := conn.udpSessionID
+	packetConn := newUDPPacketConn(c.ctx, conn.quicConn, sessionID, func() {
+		conn.udpAccess.Lock()
+		delete(conn.udpConnMap, sessionID)
+		conn.udpAccess.Unlock()
+	})
+	conn.udpConnMap[sessionID] = packetConn
+	conn.udpAccess.Unlock()
+	return packetConn, nil
+}
+
+func (c *Client) CloseWithError(err error) error {
+	c.connAccess.Lock()
+	defer c.connAccess.Unlock()
+	conn := c.conn
+	if conn != nil {
+		conn.closeWithError(err)
+	}
+	return nil
+}
+
+type clientQUICConnection struct {
+	quicConn     quic.Connection
+	rawConn      io.Closer
+	closeOnce    sync.Once
+	connDone     chan struct{}
+	connErr      error
+	udpDisabled  bool
+	udpAccess    sync.RWMutex
+	udpSessionID uint32
+	udpConnMap   map[uint32]*udpPacketConn
+}
+
+func (c *clientQUICConnection) active() bool {
+	select {
+	case <-c.quicConn.Context().Done():
+		return false
+	case <-c.connDone:
+		return false
+	default:
+		return true
+	}
+}
+
+func (c *clientQUICConnection) handleUDPMessage(message *udpMessage) {
+	c.udpAccess.RLock()
+	udpConn, loaded := c.udpConnMap[message.sessionID]
+	c.udpAccess.RUnlock()
+	if !loaded {
+		message.release()
+		return
+	}
+	udpConn.inputPacket(message)
+}
+
+func (c *clientQUICConnection) closeWithError(err error) {
+	c.closeOnce.Do(func() {
+		c.connErr = err
+		close(c.connDone)
+		c.quicConn.CloseWithError(0, "")
+		c.rawConn.Close()
+		c.udpAccess.Lock()
+		udpConnMap := c.udpConnMap
+		c.udpConnMap = make(map[uint32]*udpPacketConn)
+		c.udpAccess.Unlock()
+		for _, udpConn := range udpConnMap {
+			udpConn.Close()
+		}
+	})
+}
+
+// clientConn reads the TCP response lazily, so the request can be sent without waiting for a round trip.
+type clientConn struct {
+	hysteria.StreamWrapper
+	responseRead bool
+}
+
+func (c *clientConn) Read(p []byte) (n int, err error) {
+	if !c.responseRead {
+		ok, message, err := ReadTCPResponse(c.Stream)
+		if err != nil {
+			return 0, baderror.WrapQUIC(err)
+		}
+		if !ok {
+			return 0, E.New("remote error: ", message)
+		}
+		c.responseRead = true
+	}
+	return c.StreamWrapper.Read(p)
+}
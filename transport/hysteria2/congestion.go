@@ -0,0 +1,18 @@
+package hysteria2
+
+import (
+	"github.com/sagernet/quic-go"
+	congestion_meta "github.com/sagernet/quic-go/congestion"
+	"github.com/sagernet/sing-box/common/congestion"
+	"github.com/sagernet/sing-box/transport/hysteria"
+)
+
+// setCongestion uses the brutal sender with the negotiated rate in bytes per second,
+// or falls back to bandwidth detection with BBR when no rate is available.
+func setCongestion(connection quic.Connection, sendBPS uint64) {
+	if sendBPS > 0 {
+		connection.SetCongestionControl(hysteria.NewBrutalSender(congestion_meta.ByteCount(sendBPS)))
+	} else {
+		connection.SetCongestionControl(congestion.NewBBRSender())
+	}
+}
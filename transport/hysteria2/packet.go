@@ -0,0 +1,323 @@
+package hysteria2
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/quic-go/quicvarint"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/atomic"
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+const udpMessageHeaderLength = 4 + 2 + 1 + 1
+
+type udpMessage struct {
+	sessionID     uint32
+	packetID      uint16
+	fragmentID    uint8
+	fragmentCount uint8
+	destination   string
+	data          *buf.Buffer
+}
+
+func (m *udpMessage) release() {
+	m.data.Release()
+}
+
+func (m *udpMessage) headerSize() int {
+	return udpMessageHeaderLength + int(quicvarint.Len(uint64(len(m.destination)))) + len(m.destination)
+}
+
+func (m *udpMessage) pack() *buf.Buffer {
+	buffer := buf.NewSize(m.headerSize() + m.data.Len())
+	common.Must(
+		binary.Write(buffer, binary.BigEndian, m.sessionID),
+		binary.Write(buffer, binary.BigEndian, m.packetID),
+		buffer.WriteByte(m.fragmentID),
+		buffer.WriteByte(m.fragmentCount),
+	)
+	common.Must1(buffer.Write(quicvarint.Append(nil, uint64(len(m.destination)))))
+	common.Must1(buffer.WriteString(m.destination))
+	common.Must1(buffer.Write(m.data.Bytes()))
+	return buffer
+}
+
+func parseUDPMessage(data []byte) (*udpMessage, error) {
+	reader := bytes.NewReader(data)
+	var message udpMessage
+	err := binary.Read(reader, binary.BigEndian, &message.sessionID)
+	if err != nil {
+		return nil, err
+	}
+	err = binary.Read(reader, binary.BigEndian, &message.packetID)
+	if err != nil {
+		return nil, err
+	}
+	message.fragmentID, err = reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	message.fragmentCount, err = reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if message.fragmentCount == 0 || message.fragmentID >= message.fragmentCount {
+		return nil, E.New("invalid fragment ", message.fragmentID, "/", message.fragmentCount)
+	}
+	addressLength, err := quicvarint.Read(reader)
+	if err != nil {
+		return nil, err
+	}
+	if addressLength == 0 || addressLength > maxAddressLength {
+		return nil, E.New("invalid address length: ", addressLength)
+	}
+	address := make([]byte, addressLength)
+	_, err = io.ReadFull(reader, address)
+	if err != nil {
+		return nil, err
+	}
+	message.destination = string(address)
+	message.data = buf.As(data[len(data)-reader.Len():]).ToOwned()
+	return &message, nil
+}
+
+// fragUDPMessage splits the message into fragments fitting maxPacketSize,
+// every fragment carries the destination address.
+func fragUDPMessage(message *udpMessage, maxPacketSize int) []*udpMessage {
+	payload := message.data.Bytes()
+	maxPayloadSize := maxPacketSize - message.headerSize()
+	if maxPayloadSize <= 0 {
+		return nil
+	}
+	fragmentCount := (len(payload) + maxPayloadSize - 1) / maxPayloadSize
+	if fragmentCount > 255 {
+		return nil
+	}
+	fragments := make([]*udpMessage, 0, fragmentCount)
+	for offset := 0; offset < len(payload); offset += maxPayloadSize {
+		end := offset + maxPayloadSize
+		if end > len(payload) {
+			end = len(payload)
+		}
+		fragments = append(fragments, &udpMessage{
+			sessionID:     message.sessionID,
+			packetID:      message.packetID,
+			fragmentID:    uint8(len(fragments)),
+			fragmentCount: uint8(fragmentCount),
+			destination:   message.destination,
+			data:          buf.As(payload[offset:end]),
+		})
+	}
+	return fragments
+}
+
+// udpDefragger only keeps fragments of the latest packet, as the reference implementation does.
+type udpDefragger struct {
+	packetID  uint16
+	fragments []*udpMessage
+	count     uint8
+}
+
+func (d *udpDefragger) feed(message *udpMessage) *udpMessage {
+	if message.fragmentCount <= 1 {
+		return message
+	}
+	if message.packetID != d.packetID || int(message.fragmentCount) != len(d.fragments) {
+		d.reset()
+		d.packetID = message.packetID
+		d.fragments = make([]*udpMessage, message.fragmentCount)
+	}
+	if d.fragments[message.fragmentID] != nil {
+		message.release()
+		return nil
+	}
+	d.fragments[message.fragmentID] = message
+	d.count++
+	if int(d.count) != len(d.fragments) {
+		return nil
+	}
+	var dataLength int
+	for _, fragment := range d.fragments {
+		dataLength += fragment.data.Len()
+	}
+	data := buf.NewSize(dataLength)
+	for _, fragment := range d.fragments {
+		common.Must1(data.Write(fragment.data.Bytes()))
+	}
+	d.reset()
+	return &udpMessage{
+		sessionID:     message.sessionID,
+		packetID:      message.packetID,
+		fragmentCount: 1,
+		destination:   message.destination,
+		data:          data,
+	}
+}
+
+func (d *udpDefragger) reset() {
+	for _, fragment := range d.fragments {
+		if fragment != nil {
+			fragment.release()
+		}
+	}
+	d.fragments = nil
+	d.count = 0
+}
+
+var _ N.NetPacketConn = (*udpPacketConn)(nil)
+
+type udpPacketConn struct {
+	ctx         context.Context
+	cancel      context.CancelFunc
+	quicConn    quic.Connection
+	sessionID   uint32
+	packetID    atomic.Uint32
+	data        chan *udpMessage
+	defragger   udpDefragger
+	defragMutex sync.Mutex
+	closeOnce   sync.Once
+	onDestroy   func()
+}
+
+func newUDPPacketConn(ctx context.Context, quicConn quic.Connection, sessionID uint32, onDestroy func()) *udpPacketConn {
+	ctx, cancel := context.WithCancel(ctx)
+	return &udpPacketConn{
+		ctx:       ctx,
+		cancel:    cancel,
+		quicConn:  quicConn,
+		sessionID: sessionID,
+		data:      make(chan *udpMessage, 64),
+		onDestroy: onDestroy,
+	}
+}
+
+func (c *udpPacketConn) inputPacket(message *udpMessage) {
+	c.defragMutex.Lock()
+	message = c.defragger.feed(message)
+	c.defragMutex.Unlock()
+	if message == nil {
+		return
+	}
+	select {
+	case c.data <- message:
+	default:
+		// drop the packet when the receive queue is full
+		message.release()
+	}
+}
+
+func (c *udpPacketConn) ReadPacket(buffer *buf.Buffer) (destination M.Socksaddr, err error) {
+	select {
+	case message := <-c.data:
+		destination = M.ParseSocksaddr(message.destination)
+		_, err = buffer.Write(message.data.Bytes())
+		message.release()
+		return
+	case <-c.ctx.Done():
+		return M.Socksaddr{}, io.ErrClosedPipe
+	}
+}
+
+func (c *udpPacketConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	select {
+	case message := <-c.data:
+		n = copy(p, message.data.Bytes())
+		destination := M.ParseSocksaddr(message.destination)
+		if destination.IsFqdn() {
+			addr = destination
+		} else {
+			addr = destination.UDPAddr()
+		}
+		message.release()
+		return
+	case <-c.ctx.Done():
+		return 0, nil, io.ErrClosedPipe
+	}
+}
+
+func (c *udpPacketConn) WritePacket(buffer *buf.Buffer, destination M.Socksaddr) error {
+	defer buffer.Release()
+	select {
+	case <-c.ctx.Done():
+		return io.ErrClosedPipe
+	default:
+	}
+	if buffer.Len() > 0xffff {
+		return quic.ErrMessageToLarge(0xffff)
+	}
+	message := &udpMessage{
+		sessionID:     c.sessionID,
+		packetID:      uint16(c.packetID.Add(1)),
+		fragmentCount: 1,
+		destination:   destination.String(),
+		data:          buffer,
+	}
+	packet := message.pack()
+	err := c.quicConn.SendMessage(packet.Bytes())
+	packet.Release()
+	if errSize, ok := err.(quic.ErrMessageToLarge); ok {
+		fragments := fragUDPMessage(message, int(errSize))
+		if len(fragments) == 0 {
+			return err
+		}
+		for _, fragment := range fragments {
+			packet = fragment.pack()
+			err = c.quicConn.SendMessage(packet.Bytes())
+			packet.Release()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return err
+}
+
+func (c *udpPacketConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	buffer := buf.NewSize(len(p))
+	common.Must1(buffer.Write(p))
+	err = c.WritePacket(buffer, M.SocksaddrFromNet(addr))
+	if err == nil {
+		n = len(p)
+	}
+	return
+}
+
+func (c *udpPacketConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.cancel()
+		c.onDestroy()
+	})
+	return nil
+}
+
+func (c *udpPacketConn) LocalAddr() net.Addr {
+	return c.quicConn.LocalAddr()
+}
+
+func (c *udpPacketConn) RemoteAddr() net.Addr {
+	return c.quicConn.RemoteAddr()
+}
+
+func (c *udpPacketConn) SetDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *udpPacketConn) SetReadDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *udpPacketConn) SetWriteDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
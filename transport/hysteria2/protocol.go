@@ -0,0 +1,197 @@
+package hysteria2
+
+import (
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+
+	"github.com/sagernet/quic-go/quicvarint"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+const (
+	DefaultALPN = "h3"
+
+	URLHost      = "hysteria"
+	URLPath      = "/auth"
+	StatusAuthOK = 233
+
+	RequestHeaderAuth        = "Hysteria-Auth"
+	ResponseHeaderUDPEnabled = "Hysteria-UDP"
+	CommonHeaderCCRX         = "Hysteria-CC-RX"
+	CommonHeaderPadding      = "Hysteria-Padding"
+
+	FrameTypeTCPRequest = 0x401
+
+	ObfsTypeSalamander = "salamander"
+)
+
+const (
+	maxAddressLength = 2048
+	maxMessageLength = 2048
+	maxPaddingLength = 4096
+
+	tcpResponseStatusOK    = 0x00
+	tcpResponseStatusError = 0x01
+)
+
+type paddingRange struct {
+	min int
+	max int
+}
+
+var (
+	authRequestPadding  = paddingRange{min: 256, max: 2048}
+	authResponsePadding = paddingRange{min: 256, max: 2048}
+	tcpRequestPadding   = paddingRange{min: 64, max: 512}
+	tcpResponsePadding  = paddingRange{min: 128, max: 1024}
+)
+
+const paddingChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+func (r paddingRange) String() string {
+	padding := make([]byte, r.min+rand.Intn(r.max-r.min))
+	for i := range padding {
+		padding[i] = paddingChars[rand.Intn(len(paddingChars))]
+	}
+	return string(padding)
+}
+
+type AuthRequest struct {
+	Auth string
+	// Rx is the receive rate of the client in bytes per second, 0 means unknown.
+	Rx uint64
+}
+
+type AuthResponse struct {
+	UDPEnabled bool
+	// Rx is the receive rate of the server in bytes per second, 0 means unlimited.
+	Rx uint64
+	// RxAuto asks the client to use bandwidth detection instead of Rx.
+	RxAuto bool
+}
+
+func AuthRequestToHeader(header http.Header, request AuthRequest) {
+	header.Set(RequestHeaderAuth, request.Auth)
+	header.Set(CommonHeaderCCRX, strconv.FormatUint(request.Rx, 10))
+	header.Set(CommonHeaderPadding, authRequestPadding.String())
+}
+
+func AuthRequestFromHeader(header http.Header) AuthRequest {
+	rx, _ := strconv.ParseUint(header.Get(CommonHeaderCCRX), 10, 64)
+	return AuthRequest{
+		Auth: header.Get(RequestHeaderAuth),
+		Rx:   rx,
+	}
+}
+
+func AuthResponseToHeader(header http.Header, response AuthResponse) {
+	header.Set(ResponseHeaderUDPEnabled, strconv.FormatBool(response.UDPEnabled))
+	if response.RxAuto {
+		header.Set(CommonHeaderCCRX, "auto")
+	} else {
+		header.Set(CommonHeaderCCRX, strconv.FormatUint(response.Rx, 10))
+	}
+	header.Set(CommonHeaderPadding, authResponsePadding.String())
+}
+
+func AuthResponseFromHeader(header http.Header) AuthResponse {
+	var response AuthResponse
+	response.UDPEnabled, _ = strconv.ParseBool(header.Get(ResponseHeaderUDPEnabled))
+	rxString := header.Get(CommonHeaderCCRX)
+	if rxString == "auto" {
+		response.RxAuto = true
+	} else {
+		response.Rx, _ = strconv.ParseUint(rxString, 10, 64)
+	}
+	return response
+}
+
+// WriteTCPRequest writes the frame type, the destination address and random padding in one write.
+func WriteTCPRequest(writer io.Writer, address string) error {
+	padding := tcpRequestPadding.String()
+	request := quicvarint.Append(nil, FrameTypeTCPRequest)
+	request = quicvarint.Append(request, uint64(len(address)))
+	request = append(request, address...)
+	request = quicvarint.Append(request, uint64(len(padding)))
+	request = append(request, padding...)
+	return common.Error(writer.Write(request))
+}
+
+// ReadTCPRequest reads the request body, the frame type must be already read.
+func ReadTCPRequest(reader io.Reader) (string, error) {
+	varintReader := quicvarint.NewReader(reader)
+	addressLength, err := quicvarint.Read(varintReader)
+	if err != nil {
+		return "", err
+	}
+	if addressLength == 0 || addressLength > maxAddressLength {
+		return "", E.New("invalid address length: ", addressLength)
+	}
+	address := make([]byte, addressLength)
+	_, err = io.ReadFull(varintReader, address)
+	if err != nil {
+		return "", err
+	}
+	err = discardPadding(varintReader)
+	if err != nil {
+		return "", err
+	}
+	return string(address), nil
+}
+
+func WriteTCPResponse(writer io.Writer, ok bool, message string) error {
+	padding := tcpResponsePadding.String()
+	var response []byte
+	if ok {
+		response = append(response, tcpResponseStatusOK)
+	} else {
+		response = append(response, tcpResponseStatusError)
+	}
+	response = quicvarint.Append(response, uint64(len(message)))
+	response = append(response, message...)
+	response = quicvarint.Append(response, uint64(len(padding)))
+	response = append(response, padding...)
+	return common.Error(writer.Write(response))
+}
+
+func ReadTCPResponse(reader io.Reader) (bool, string, error) {
+	varintReader := quicvarint.NewReader(reader)
+	status, err := varintReader.ReadByte()
+	if err != nil {
+		return false, "", err
+	}
+	messageLength, err := quicvarint.Read(varintReader)
+	if err != nil {
+		return false, "", err
+	}
+	if messageLength > maxMessageLength {
+		return false, "", E.New("invalid message length: ", messageLength)
+	}
+	message := make([]byte, messageLength)
+	_, err = io.ReadFull(varintReader, message)
+	if err != nil {
+		return false, "", err
+	}
+	err = discardPadding(varintReader)
+	if err != nil {
+		return false, "", err
+	}
+	return status == tcpResponseStatusOK, string(message), nil
+}
+
+func discardPadding(reader quicvarint.Reader) error {
+	paddingLength, err := quicvarint.Read(reader)
+	if err != nil {
+		return err
+	}
+	if paddingLength > maxPaddingLength {
+		return E.New("invalid padding length: ", paddingLength)
+	}
+	if paddingLength > 0 {
+		_, err = io.CopyN(io.Discard, reader, int64(paddingLength))
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+package hysteria2
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	salamanderSaltLen = 8
+	// salamanderMinPasswordLen follows the requirement of the reference implementation.
+	salamanderMinPasswordLen = 4
+)
+
+// SalamanderPacketConn obfuscates every packet as salt || payload XOR BLAKE2b-256(password || salt).
+type SalamanderPacketConn struct {
+	net.PacketConn
+	password   []byte
+	randAccess sync.Mutex
+	rand       *rand.Rand
+}
+
+func NewSalamanderConn(conn net.PacketConn, password []byte) net.PacketConn {
+	return &SalamanderPacketConn{
+		PacketConn: conn,
+		password:   password,
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (c *SalamanderPacketConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	n, addr, err = c.PacketConn.ReadFrom(p)
+	if err != nil {
+		return
+	} else if n <= salamanderSaltLen {
+		n = 0
+		return
+	}
+	key := c.key(p[:salamanderSaltLen])
+	for i := range p[salamanderSaltLen:n] {
+		p[i] = p[salamanderSaltLen+i] ^ key[i%blake2b.Size256]
+	}
+	n -= salamanderSaltLen
+	return
+}
+
+func (c *SalamanderPacketConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	buffer := buf.NewSize(len(p) + salamanderSaltLen)
+	defer buffer.Release()
+	salt := buffer.Extend(salamanderSaltLen)
+	c.randAccess.Lock()
+	_, _ = c.rand.Read(salt)
+	c.randAccess.Unlock()
+	key := c.key(salt)
+	for i := range p {
+		common.Must(buffer.WriteByte(p[i] ^ key[i%blake2b.Size256]))
+	}
+	_, err = c.PacketConn.WriteTo(buffer.Bytes(), addr)
+	if err != nil {
+		return
+	}
+	return len(p), nil
+}
+
+func (c *SalamanderPacketConn) key(salt []byte) [blake2b.Size256]byte {
+	keyMaterial := make([]byte, 0, len(c.password)+salamanderSaltLen)
+	keyMaterial = append(keyMaterial, c.password...)
+	keyMaterial = append(keyMaterial, salt...)
+	return blake2b.Sum256(keyMaterial)
+}
+
+func (c *SalamanderPacketConn) Upstream() any {
+	return c.PacketConn
+}
@@ -0,0 +1,326 @@
+package hysteria2
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/quic-go/http3"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/transport/hysteria"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/auth"
+	"github.com/sagernet/sing/common/canceler"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	aTLS "github.com/sagernet/sing/common/tls"
+)
+
+type Handler interface {
+	N.TCPConnectionHandler
+	N.UDPConnectionHandler
+	E.Handler
+}
+
+type ServiceOptions struct {
+	Context               context.Context
+	SendBPS               uint64
+	ReceiveBPS            uint64
+	IgnoreClientBandwidth bool
+	SalamanderPassword    string
+	TLSConfig             aTLS.ServerConfig
+	UDPDisabled           bool
+	Handler               Handler
+	// MasqueradeHandler serves all requests that fail to authenticate, 404 is returned if nil.
+	MasqueradeHandler http.Handler
+}
+
+type Service[U comparable] struct {
+	ctx                   context.Context
+	sendBPS               uint64
+	receiveBPS            uint64
+	ignoreClientBandwidth bool
+	salamanderPassword    string
+	tlsConfig             aTLS.ServerConfig
+	quicConfig            *quic.Config
+	udpDisabled           bool
+	handler               Handler
+	masqueradeHandler     http.Handler
+
+	userAccess sync.RWMutex
+	userMap    map[string]U
+	listener   io.Closer
+}
+
+func NewService[U comparable](options ServiceOptions) (*Service[U], error) {
+	if options.SalamanderPassword != "" && len(options.SalamanderPassword) < salamanderMinPasswordLen {
+		return nil, E.New("salamander password is too short")
+	}
+	if options.MasqueradeHandler == nil {
+		options.MasqueradeHandler = http.NotFoundHandler()
+	}
+	quicConfig := &quic.Config{
+		InitialStreamReceiveWindow:     hysteria.DefaultStreamReceiveWindow,
+		MaxStreamReceiveWindow:         hysteria.DefaultStreamReceiveWindow,
+		InitialConnectionReceiveWindow: hysteria.DefaultConnectionReceiveWindow,
+		MaxConnectionReceiveWindow:     hysteria.DefaultConnectionReceiveWindow,
+		MaxIncomingStreams:             hysteria.DefaultMaxIncomingStreams,
+		KeepAlivePeriod:                hysteria.KeepAlivePeriod,
+		DisablePathMTUDiscovery:        !(C.IsLinux || C.IsWindows),
+		EnableDatagrams:                !options.UDPDisabled,
+	}
+	return &Service[U]{
+		ctx:                   options.Context,
+		sendBPS:               options.SendBPS,
+		receiveBPS:            options.ReceiveBPS,
+		ignoreClientBandwidth: options.IgnoreClientBandwidth,
+		salamanderPassword:    options.SalamanderPassword,
+		tlsConfig:             options.TLSConfig,
+		quicConfig:            quicConfig,
+		udpDisabled:           options.UDPDisabled,
+		handler:               options.Handler,
+		masqueradeHandler:     options.MasqueradeHandler,
+		userMap:               make(map[string]U),
+	}, nil
+}
+
+func (s *Service[U]) UpdateUsers(userList []U, passwordList []string) {
+	userMap := make(map[string]U)
+	for index, user := range userList {
+		if _, loaded := userMap[passwordList[index]]; !loaded {
+			userMap[passwordList[index]] = user
+		}
+	}
+	s.userAccess.Lock()
+	s.userMap = userMap
+	s.userAccess.Unlock()
+}
+
+func (s *Service[U]) loadUser(password string) (U, bool) {
+	s.userAccess.RLock()
+	defer s.userAccess.RUnlock()
+	user, loaded := s.userMap[password]
+	return user, loaded
+}
+
+func (s *Service[U]) Start(conn net.PacketConn) error {
+	if s.salamanderPassword != "" {
+		conn = NewSalamanderConn(conn, []byte(s.salamanderPassword))
+		conn = &hysteria.PacketConnWrapper{PacketConn: conn}
+	}
+	tlsConfig, err := s.tlsConfig.Config()
+	if err != nil {
+		return err
+	}
+	listener, err := quic.Listen(conn, tlsConfig, s.quicConfig)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	go func() {
+		for {
+			connection, hErr := listener.Accept(s.ctx)
+			if hErr != nil {
+				return
+			}
+			go s.handleConnection(connection)
+		}
+	}()
+	return nil
+}
+
+func (s *Service[U]) Close() error {
+	return common.Close(s.listener)
+}
+
+func (s *Service[U]) handleConnection(connection quic.Connection) {
+	session := &serverSession[U]{
+		Service:    s,
+		ctx:        log.ContextWithNewID(s.ctx),
+		quicConn:   connection,
+		source:     M.SocksaddrFromNet(connection.RemoteAddr()).Unwrap(),
+		authDone:   make(chan struct{}),
+		udpConnMap: make(map[uint32]*udpPacketConn),
+	}
+	httpServer := http3.Server{
+		Handler:        session,
+		StreamHijacker: session.handleStream,
+	}
+	err := httpServer.ServeQUICConn(connection)
+	if err == nil {
+		err = net.ErrClosed
+	}
+	session.closeWithError(err)
+}
+
+type serverSession[U comparable] struct {
+	*Service[U]
+	ctx        context.Context
+	quicConn   quic.Connection
+	source     M.Socksaddr
+	closeOnce  sync.Once
+	authAccess sync.Mutex
+	authDone   chan struct{}
+	authUser   U
+	udpAccess  sync.RWMutex
+	udpConnMap map[uint32]*udpPacketConn
+}
+
+func (s *serverSession[U]) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost || request.Host != URLHost || request.URL.Path != URLPath {
+		s.masqueradeHandler.ServeHTTP(writer, request)
+		return
+	}
+	s.authAccess.Lock()
+	defer s.authAccess.Unlock()
+	select {
+	case <-s.authDone:
+		// repeated authentication on an authenticated connection
+		s.writeAuthResponse(writer)
+		return
+	default:
+	}
+	authRequest := AuthRequestFromHeader(request.Header)
+	user, loaded := s.loadUser(authRequest.Auth)
+	if !loaded {
+		s.masqueradeHandler.ServeHTTP(writer, request)
+		return
+	}
+	s.writeAuthResponse(writer)
+	var actualTx uint64
+	if !s.ignoreClientBandwidth {
+		actualTx = authRequest.Rx
+		if s.sendBPS > 0 && (actualTx == 0 || actualTx > s.sendBPS) {
+			actualTx = s.sendBPS
+		}
+	}
+	setCongestion(s.quicConn, actualTx)
+	s.authUser = user
+	close(s.authDone)
+	if !s.udpDisabled {
+		go s.loopMessages()
+	}
+}
+
+func (s *serverSession[U]) writeAuthResponse(writer http.ResponseWriter) {
+	AuthResponseToHeader(writer.Header(), AuthResponse{
+		UDPEnabled: !s.udpDisabled,
+		Rx:         s.receiveBPS,
+		RxAuto:     s.ignoreClientBandwidth,
+	})
+	writer.WriteHeader(StatusAuthOK)
+}
+
+func (s *serverSession[U]) authenticated() bool {
+	select {
+	case <-s.authDone:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *serverSession[U]) handleStream(frameType http3.FrameType, connection quic.Connection, stream quic.Stream, err error) (bool, error) {
+	if err != nil || frameType != FrameTypeTCPRequest || !s.authenticated() {
+		return false, nil
+	}
+	hErr := s.handleTCPStream(stream)
+	if hErr != nil {
+		stream.CancelRead(0)
+		stream.Close()
+		s.handler.NewError(s.ctx, E.Cause(hErr, "handle stream request"))
+	}
+	return true, nil
+}
+
+func (s *serverSession[U]) handleTCPStream(stream quic.Stream) error {
+	address, err := ReadTCPRequest(stream)
+	if err != nil {
+		return E.Cause(err, "read request")
+	}
+	destination := M.ParseSocksaddr(address)
+	if !destination.IsValid() {
+		return E.Errors(E.New("invalid destination: ", address), WriteTCPResponse(stream, false, "invalid destination"))
+	}
+	// the result of the outbound connection is unknown here, so always report success
+	err = WriteTCPResponse(stream, true, "")
+	if err != nil {
+		return E.Cause(err, "write response")
+	}
+	ctx := auth.ContextWithUser(s.ctx, s.authUser)
+	conn := &hysteria.StreamWrapper{Conn: s.quicConn, Stream: stream}
+	return s.handler.NewConnection(ctx, conn, M.Metadata{
+		Source:      s.source,
+		Destination: destination,
+	})
+}
+
+func (s *serverSession[U]) loopMessages() {
+	for {
+		data, err := s.quicConn.ReceiveMessage()
+		if err != nil {
+			s.closeWithError(E.Cause(err, "receive message"))
+			return
+		}
+		message, err := parseUDPMessage(data)
+		if err != nil {
+			s.handler.NewError(s.ctx, E.Cause(err, "parse udp message"))
+			continue
+		}
+		s.handleUDPMessage(message)
+	}
+}
+
+func (s *serverSession[U]) handleUDPMessage(message *udpMessage) {
+	s.udpAccess.Lock()
+	udpConn, loaded := s.udpConnMap[message.sessionID]
+	if !loaded {
+		sessionID := message.sessionID
+		udpConn = newUDPPacketConn(s.ctx, s.quicConn, sessionID, func() {
+			s.udpAccess.Lock()
+			delete(s.udpConnMap, sessionID)
+			s.udpAccess.Unlock()
+		})
+		s.udpConnMap[sessionID] = udpConn
+	}
+	s.udpAccess.Unlock()
+	if !loaded {
+		destination := M.ParseSocksaddr(message.destination)
+		go func() {
+			// the protocol has no message to release a session, so close it once idle
+			ctx, conn := canceler.NewPacketConn(auth.ContextWithUser(s.ctx, s.authUser), udpConn, C.UDPTimeout)
+			go func() {
+				<-ctx.Done()
+				udpConn.Close()
+			}()
+			err := s.handler.NewPacketConnection(ctx, conn, M.Metadata{
+				Source:      s.source,
+				Destination: destination,
+			})
+			conn.Close()
+			if err != nil {
+				s.handler.NewError(ctx, E.Cause(err, "handle packet connection"))
+			}
+		}()
+	}
+	udpConn.inputPacket(message)
+}
+
+func (s *serverSession[U]) closeWithError(err error) {
+	s.closeOnce.Do(func() {
+		s.handler.NewError(s.ctx, E.Cause(err, "process connection from ", s.source))
+		s.quicConn.CloseWithError(0, "")
+		s.udpAccess.Lock()
+		udpConnMap := s.udpConnMap
+		s.udpConnMap = make(map[uint32]*udpPacketConn)
+		s.udpAccess.Unlock()
+		for _, udpConn := range udpConnMap {
+			udpConn.Close()
+		}
+	})
+}
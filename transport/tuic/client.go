@@ -0,0 +1,334 @@
+package tuic
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sagernet/quic-go"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/transport/hysteria"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+type ClientOptions struct {
+	Context           context.Context
+	Dialer            N.Dialer
+	ServerAddress     M.Socksaddr
+	TLSConfig         *tls.Config
+	UUID              [16]byte
+	Password          string
+	CongestionControl string
+	UDPStream         bool
+	ZeroRTTHandshake  bool
+	Heartbeat         time.Duration
+}
+
+type Client struct {
+	ctx               context.Context
+	dialer            N.Dialer
+	serverAddr        M.Socksaddr
+	tlsConfig         *tls.Config
+	quicConfig        *quic.Config
+	uuid              [16]byte
+	password          string
+	congestionControl string
+	udpStream         bool
+	zeroRTTHandshake  bool
+	heartbeat         time.Duration
+
+	connAccess sync.Mutex
+	conn       *clientQUICConnection
+}
+
+func NewClient(options ClientOptions) (*Client, error) {
+	if options.Heartbeat == 0 {
+		options.Heartbeat = DefaultHeartbeat
+	}
+	if options.CongestionControl == "" {
+		options.CongestionControl = "cubic"
+	}
+	err := checkCongestionControl(options.CongestionControl)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := options.TLSConfig
+	tlsConfig.MinVersion = tls.VersionTLS13
+	if len(tlsConfig.NextProtos) == 0 {
+		tlsConfig.NextProtos = []string{DefaultALPN}
+	}
+	if options.ZeroRTTHandshake && tlsConfig.ClientSessionCache == nil {
+		tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+	quicConfig := &quic.Config{
+		DisablePathMTUDiscovery: !(C.IsLinux || C.IsWindows),
+		EnableDatagrams:         true,
+		MaxIncomingUniStreams:   1 << 60,
+	}
+	return &Client{
+		ctx:               options.Context,
+		dialer:            options.Dialer,
+		serverAddr:        options.ServerAddress,
+		tlsConfig:         tlsConfig,
+		quicConfig:        quicConfig,
+		uuid:              options.UUID,
+		password:          options.Password,
+		congestionControl: options.CongestionControl,
+		udpStream:         options.UDPStream,
+		zeroRTTHandshake:  options.ZeroRTTHandshake,
+		heartbeat:         options.Heartbeat,
+	}, nil
+}
+
+func (c *Client) offer(ctx context.Context) (*clientQUICConnection, error) {
+	conn := c.conn
+	if conn != nil && conn.active() {
+		return conn, nil
+	}
+	c.connAccess.Lock()
+	defer c.connAccess.Unlock()
+	conn = c.conn
+	if conn != nil && conn.active() {
+		return conn, nil
+	}
+	conn, err := c.offerNew(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (c *Client) offerNew(ctx context.Context) (*clientQUICConnection, error) {
+	udpConn, err := c.dialer.DialContext(c.ctx, "udp", c.serverAddr)
+	if err != nil {
+		return nil, err
+	}
+	packetConn := bufio.NewUnbindPacketConn(udpConn)
+	var quicConn quic.Connection
+	if c.zeroRTTHandshake {
+		quicConn, err = quic.DialEarly(packetConn, udpConn.RemoteAddr(), c.serverAddr.AddrString(), c.tlsConfig, c.quicConfig)
+	} else {
+		quicConn, err = quic.Dial(packetConn, udpConn.RemoteAddr(), c.serverAddr.AddrString(), c.tlsConfig, c.quicConfig)
+	}
+	if err != nil {
+		udpConn.Close()
+		return nil, E.Cause(err, "open connection")
+	}
+	setCongestion(quicConn, c.congestionControl)
+	conn := &clientQUICConnection{
+		quicConn:   quicConn,
+		rawConn:    udpConn,
+		connDone:   make(chan struct{}),
+		udpConnMap: make(map[uint16]*udpPacketConn),
+	}
+	go func() {
+		hErr := c.clientHandshake(quicConn)
+		if hErr != nil {
+			conn.closeWithError(hErr)
+		}
+	}()
+	go c.loopHeartbeats(conn)
+	go c.loopUniStreams(conn)
+	go c.loopMessages(conn)
+	c.conn = conn
+	return conn, nil
+}
+
+func (c *Client) clientHandshake(conn quic.Connection) error {
+	if c.zeroRTTHandshake {
+		earlyConn := conn.(quic.EarlyConnection)
+		select {
+		case <-earlyConn.HandshakeComplete().Done():
+		case <-conn.Context().Done():
+			return conn.Context().Err()
+		}
+	}
+	tlsState := conn.ConnectionState().TLS
+	token, err := tlsState.ExportKeyingMaterial(string(c.uuid[:]), []byte(c.password), AuthenticationTokenLength)
+	if err != nil {
+		return E.Cause(err, "export keying material")
+	}
+	authStream, err := conn.OpenUniStream()
+	if err != nil {
+		return E.Cause(err, "open handshake stream")
+	}
+	defer authStream.Close()
+	err = WriteAuthenticate(authStream, c.uuid, token)
+	if err != nil {
+		return E.Cause(err, "write auth request")
+	}
+	return nil
+}
+
+func (c *Client) loopHeartbeats(conn *clientQUICConnection) {
+	ticker := time.NewTicker(c.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-conn.connDone:
+			return
+		case <-ticker.C:
+			err := conn.quicConn.SendMessage(HeartbeatMessage())
+			if err != nil {
+				conn.closeWithError(E.Cause(err, "send heartbeat"))
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) loopUniStreams(conn *clientQUICConnection) {
+	for {
+		stream, err := conn.quicConn.AcceptUniStream(c.ctx)
+		if err != nil {
+			conn.closeWithError(E.Cause(err, "accept uni stream"))
+			return
+		}
+		go func() {
+			hErr := conn.handleUniStream(stream)
+			stream.CancelRead(0)
+			if hErr != nil {
+				conn.closeWithError(hErr)
+			}
+		}()
+	}
+}
+
+func (c *Client) loopMessages(conn *clientQUICConnection) {
+	for {
+		data, err := conn.quicConn.ReceiveMessage()
+		if err != nil {
+			conn.closeWithError(E.Cause(err, "receive message"))
+			return
+		}
+		message, err := handleDatagram(data)
+		if err != nil {
+			conn.closeWithError(E.Cause(err, "handle datagram"))
+			return
+		}
+		if message != nil {
+			conn.handleUDPMessage(message)
+		}
+	}
+}
+
+func (c *Client) DialConn(ctx context.Context, destination M.Socksaddr) (net.Conn, error) {
+	conn, err := c.offer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := conn.quicConn.OpenStream()
+	if err != nil {
+		return nil, err
+	}
+	err = WriteConnect(stream, destination)
+	if err != nil {
+		stream.Close()
+		return nil, err
+	}
+	return &hysteria.StreamWrapper{Conn: conn.quicConn, Stream: stream}, nil
+}
+
+func (c *Client) ListenPacket(ctx context.Context) (net.PacketConn, error) {
+	conn, err := c.offer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var sessionID uint16
+	conn.udpAccess.Lock()
+	for {
+		sessionID = uint16(rand.Intn(0xffff) + 1)
+		if _, loaded := conn.udpConnMap[sessionID]; !loaded {
+			break
+		}
+	}
+	packetConn := newUDPPacketConn(c.ctx, conn.quicConn, c.udpStream, false, sessionID, func() {
+		conn.udpAccess.Lock()
+		delete(conn.udpConnMap, sessionID)
+		conn.udpAccess.Unlock()
+	})
+	conn.udpConnMap[sessionID] = packetConn
+	conn.udpAccess.Unlock()
+	return packetConn, nil
+}
+
+func (c *Client) CloseWithError(err error) error {
+	c.connAccess.Lock()
+	defer c.connAccess.Unlock()
+	conn := c.conn
+	if conn != nil {
+		conn.closeWithError(err)
+	}
+	return nil
+}
+
+type clientQUICConnection struct {
+	quicConn   quic.Connection
+	rawConn    io.Closer
+	closeOnce  sync.Once
+	connDone   chan struct{}
+	connErr    error
+	udpAccess  sync.RWMutex
+	udpConnMap map[uint16]*udpPacketConn
+}
+
+func (c *clientQUICConnection) active() bool {
+	select {
+	case <-c.quicConn.Context().Done():
+		return false
+	case <-c.connDone:
+		return false
+	default:
+		return true
+	}
+}
+
+func (c *clientQUICConnection) handleUniStream(stream quic.ReceiveStream) error {
+	command, err := ReadCommand(stream)
+	if err != nil {
+		return err
+	}
+	if command != CommandPacket {
+		return E.New("unknown stream command: ", command)
+	}
+	message, err := readUDPMessage(stream)
+	if err != nil {
+		return err
+	}
+	c.handleUDPMessage(message)
+	return nil
+}
+
+func (c *clientQUICConnection) handleUDPMessage(message *udpMessage) {
+	c.udpAccess.RLock()
+	udpConn, loaded := c.udpConnMap[message.sessionID]
+	c.udpAccess.RUnlock()
+	if !loaded {
+		message.release()
+		return
+	}
+	udpConn.inputPacket(message)
+}
+
+func (c *clientQUICConnection) closeWithError(err error) {
+	c.closeOnce.Do(func() {
+		c.connErr = err
+		close(c.connDone)
+		c.quicConn.CloseWithError(0, "")
+		c.rawConn.Close()
+		c.udpAccess.Lock()
+		udpConnMap := c.udpConnMap
+		c.udpConnMap = make(map[uint16]*udpPacketConn)
+		c.udpAccess.Unlock()
+		for _, udpConn := range udpConnMap {
+			udpConn.closeWithError()
+		}
+	})
+}
@@ -0,0 +1,27 @@
+package tuic
+
+import (
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/sing-box/common/congestion"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+func checkCongestionControl(name string) error {
+	switch name {
+	case "cubic", "new_reno", "bbr":
+		return nil
+	default:
+		return E.New("unknown congestion control algorithm: ", name)
+	}
+}
+
+func setCongestion(connection quic.Connection, name string) {
+	switch name {
+	case "cubic":
+		connection.SetCongestionControl(congestion.NewCubicSender(false))
+	case "new_reno":
+		connection.SetCongestionControl(congestion.NewCubicSender(true))
+	case "bbr":
+		connection.SetCongestionControl(congestion.NewBBRSender())
+	}
+}
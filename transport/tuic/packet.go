@@ -0,0 +1,414 @@
+package tuic
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/atomic"
+	"github.com/sagernet/sing/common/buf"
+	"github.com/sagernet/sing/common/cache"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+type udpMessage struct {
+	sessionID     uint16
+	packetID      uint16
+	fragmentTotal uint8
+	fragmentID    uint8
+	destination   M.Socksaddr
+	data          *buf.Buffer
+}
+
+func (m *udpMessage) release() {
+	m.data.Release()
+}
+
+func (m *udpMessage) headerSize() int {
+	return packetHeaderLength + AddressLength(m.destination)
+}
+
+func (m *udpMessage) pack() (*buf.Buffer, error) {
+	buffer := buf.NewSize(m.headerSize() + m.data.Len())
+	common.Must(
+		buffer.WriteByte(Version),
+		buffer.WriteByte(CommandPacket),
+		binary.Write(buffer, binary.BigEndian, m.sessionID),
+		binary.Write(buffer, binary.BigEndian, m.packetID),
+		buffer.WriteByte(m.fragmentTotal),
+		buffer.WriteByte(m.fragmentID),
+		binary.Write(buffer, binary.BigEndian, uint16(m.data.Len())),
+	)
+	err := WriteAddress(buffer, m.destination)
+	if err != nil {
+		buffer.Release()
+		return nil, err
+	}
+	common.Must1(buffer.Write(m.data.Bytes()))
+	return buffer, nil
+}
+
+// readUDPMessage reads the packet command body, the command header must be already read.
+func readUDPMessage(reader io.Reader) (*udpMessage, error) {
+	var message udpMessage
+	var dataLength uint16
+	err := binary.Read(reader, binary.BigEndian, &message.sessionID)
+	if err != nil {
+		return nil, err
+	}
+	err = binary.Read(reader, binary.BigEndian, &message.packetID)
+	if err != nil {
+		return nil, err
+	}
+	err = binary.Read(reader, binary.BigEndian, &message.fragmentTotal)
+	if err != nil {
+		return nil, err
+	}
+	err = binary.Read(reader, binary.BigEndian, &message.fragmentID)
+	if err != nil {
+		return nil, err
+	}
+	err = binary.Read(reader, binary.BigEndian, &dataLength)
+	if err != nil {
+		return nil, err
+	}
+	message.destination, err = ReadAddress(reader)
+	if err != nil {
+		return nil, err
+	}
+	message.data = buf.NewSize(int(dataLength))
+	_, err = message.data.ReadFullFrom(reader, int(dataLength))
+	if err != nil {
+		message.release()
+		return nil, err
+	}
+	return &message, nil
+}
+
+// fragUDPMessage splits the message into fragments fitting maxPacketSize,
+// only the first fragment carries the destination address.
+func fragUDPMessage(message *udpMessage, maxPacketSize int) []*udpMessage {
+	payload := message.data.Bytes()
+	maxPayloadSize := maxPacketSize - message.headerSize()
+	if maxPayloadSize <= 0 {
+		return nil
+	}
+	fragmentTotal := (len(payload) + maxPayloadSize - 1) / maxPayloadSize
+	if fragmentTotal > 255 {
+		return nil
+	}
+	fragments := make([]*udpMessage, 0, fragmentTotal)
+	for offset := 0; offset < len(payload); offset += maxPayloadSize {
+		end := offset + maxPayloadSize
+		if end > len(payload) {
+			end = len(payload)
+		}
+		fragment := &udpMessage{
+			sessionID:     message.sessionID,
+			packetID:      message.packetID,
+			fragmentTotal: uint8(fragmentTotal),
+			fragmentID:    uint8(len(fragments)),
+			data:          buf.As(payload[offset:end]),
+		}
+		if len(fragments) == 0 {
+			fragment.destination = message.destination
+		}
+		fragments = append(fragments, fragment)
+	}
+	return fragments
+}
+
+type packetItem struct {
+	access      sync.Mutex
+	messages    []*udpMessage
+	count       uint8
+	destination M.Socksaddr
+}
+
+type udpDefragger struct {
+	packetMap *cache.LruCache[uint16, *packetItem]
+}
+
+func newUDPDefragger() *udpDefragger {
+	return &udpDefragger{
+		packetMap: cache.New(
+			cache.WithAge[uint16, *packetItem](10),
+			cache.WithUpdateAgeOnGet[uint16, *packetItem](),
+			cache.WithEvict[uint16, *packetItem](func(key uint16, value *packetItem) {
+				value.access.Lock()
+				defer value.access.Unlock()
+				for _, message := range value.messages {
+					if message != nil {
+						message.release()
+					}
+				}
+				value.messages = nil
+			}),
+		),
+	}
+}
+
+func (d *udpDefragger) feed(message *udpMessage) *udpMessage {
+	if message.fragmentTotal <= 1 {
+		return message
+	}
+	if message.fragmentID >= message.fragmentTotal {
+		message.release()
+		return nil
+	}
+	item, _ := d.packetMap.LoadOrStore(message.packetID, func() *packetItem {
+		return &packetItem{messages: make([]*udpMessage, message.fragmentTotal)}
+	})
+	item.access.Lock()
+	defer item.access.Unlock()
+	if int(message.fragmentTotal) != len(item.messages) || item.messages[message.fragmentID] != nil {
+		message.release()
+		return nil
+	}
+	item.messages[message.fragmentID] = message
+	item.count++
+	if message.destination.IsValid() {
+		item.destination = message.destination
+	}
+	if int(item.count) != len(item.messages) {
+		return nil
+	}
+	var dataLength int
+	for _, fragment := range item.messages {
+		dataLength += fragment.data.Len()
+	}
+	data := buf.NewSize(dataLength)
+	for _, fragment := range item.messages {
+		common.Must1(data.Write(fragment.data.Bytes()))
+		fragment.release()
+	}
+	item.messages = nil
+	d.packetMap.Delete(message.packetID)
+	return &udpMessage{
+		sessionID:     message.sessionID,
+		packetID:      message.packetID,
+		fragmentTotal: 1,
+		destination:   item.destination,
+		data:          data,
+	}
+}
+
+var _ N.NetPacketConn = (*udpPacketConn)(nil)
+
+type udpPacketConn struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	quicConn  quic.Connection
+	udpStream bool
+	isServer  bool
+	sessionID uint16
+	packetID  atomic.Uint32
+	data      chan *udpMessage
+	defragger *udpDefragger
+	closeOnce sync.Once
+	onDestroy func()
+}
+
+func newUDPPacketConn(ctx context.Context, quicConn quic.Connection, udpStream bool, isServer bool, sessionID uint16, onDestroy func()) *udpPacketConn {
+	ctx, cancel := context.WithCancel(ctx)
+	return &udpPacketConn{
+		ctx:       ctx,
+		cancel:    cancel,
+		quicConn:  quicConn,
+		udpStream: udpStream,
+		isServer:  isServer,
+		sessionID: sessionID,
+		data:      make(chan *udpMessage, 64),
+		defragger: newUDPDefragger(),
+		onDestroy: onDestroy,
+	}
+}
+
+func (c *udpPacketConn) inputPacket(message *udpMessage) {
+	message = c.defragger.feed(message)
+	if message == nil {
+		return
+	}
+	select {
+	case c.data <- message:
+	default:
+		// drop the packet when the receive queue is full
+		message.release()
+	}
+}
+
+func (c *udpPacketConn) ReadPacket(buffer *buf.Buffer) (destination M.Socksaddr, err error) {
+	select {
+	case message := <-c.data:
+		destination = message.destination
+		_, err = buffer.Write(message.data.Bytes())
+		message.release()
+		return
+	case <-c.ctx.Done():
+		return M.Socksaddr{}, io.ErrClosedPipe
+	}
+}
+
+func (c *udpPacketConn) ReadFrom(p []byte) (n int, addr net.Addr, err error) {
+	select {
+	case message := <-c.data:
+		n = copy(p, message.data.Bytes())
+		if message.destination.IsFqdn() {
+			addr = message.destination
+		} else {
+			addr = message.destination.UDPAddr()
+		}
+		message.release()
+		return
+	case <-c.ctx.Done():
+		return 0, nil, io.ErrClosedPipe
+	}
+}
+
+func (c *udpPacketConn) WritePacket(buffer *buf.Buffer, destination M.Socksaddr) error {
+	defer buffer.Release()
+	select {
+	case <-c.ctx.Done():
+		return io.ErrClosedPipe
+	default:
+	}
+	if buffer.Len() > 0xffff {
+		return quic.ErrMessageToLarge(0xffff)
+	}
+	message := &udpMessage{
+		sessionID:     c.sessionID,
+		packetID:      uint16(c.packetID.Add(1)),
+		fragmentTotal: 1,
+		destination:   destination,
+		data:          buffer,
+	}
+	if c.udpStream {
+		return c.writeStream(message)
+	}
+	return c.writeDatagram(message)
+}
+
+func (c *udpPacketConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	buffer := buf.NewSize(len(p))
+	common.Must1(buffer.Write(p))
+	err = c.WritePacket(buffer, M.SocksaddrFromNet(addr))
+	if err == nil {
+		n = len(p)
+	}
+	return
+}
+
+func (c *udpPacketConn) writeDatagram(message *udpMessage) error {
+	packet, err := message.pack()
+	if err != nil {
+		return err
+	}
+	err = c.quicConn.SendMessage(packet.Bytes())
+	packet.Release()
+	if errSize, ok := err.(quic.ErrMessageToLarge); ok {
+		fragments := fragUDPMessage(message, int(errSize))
+		if len(fragments) == 0 {
+			return err
+		}
+		for _, fragment := range fragments {
+			packet, err = fragment.pack()
+			if err != nil {
+				return err
+			}
+			err = c.quicConn.SendMessage(packet.Bytes())
+			packet.Release()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return err
+}
+
+func (c *udpPacketConn) writeStream(message *udpMessage) error {
+	packet, err := message.pack()
+	if err != nil {
+		return err
+	}
+	defer packet.Release()
+	stream, err := c.quicConn.OpenUniStream()
+	if err != nil {
+		return err
+	}
+	_, err = stream.Write(packet.Bytes())
+	if err != nil {
+		stream.CancelWrite(0)
+		return err
+	}
+	return stream.Close()
+}
+
+func (c *udpPacketConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.cancel()
+		if !c.isServer {
+			// tell the server to release the association
+			stream, err := c.quicConn.OpenUniStream()
+			if err == nil {
+				WriteDissociate(stream, c.sessionID)
+				stream.Close()
+			}
+		}
+		c.onDestroy()
+	})
+	return nil
+}
+
+// closeWithError closes the association without notifying the peer.
+func (c *udpPacketConn) closeWithError() {
+	c.closeOnce.Do(func() {
+		c.cancel()
+		c.onDestroy()
+	})
+}
+
+func (c *udpPacketConn) LocalAddr() net.Addr {
+	return c.quicConn.LocalAddr()
+}
+
+func (c *udpPacketConn) RemoteAddr() net.Addr {
+	return c.quicConn.RemoteAddr()
+}
+
+func (c *udpPacketConn) SetDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *udpPacketConn) SetReadDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *udpPacketConn) SetWriteDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func handleDatagram(data []byte) (*udpMessage, error) {
+	reader := bytes.NewReader(data)
+	command, err := ReadCommand(reader)
+	if err != nil {
+		return nil, err
+	}
+	switch command {
+	case CommandPacket:
+		return readUDPMessage(reader)
+	case CommandHeartbeat:
+		return nil, nil
+	default:
+		return nil, E.New("unknown datagram command: ", command)
+	}
+}
@@ -0,0 +1,192 @@
+package tuic
+
+import (
+	"encoding/binary"
+	"io"
+	"net/netip"
+	"time"
+
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	"github.com/sagernet/sing/common/rw"
+)
+
+const (
+	Version            = 5
+	DefaultALPN        = "h3"
+	DefaultAuthTimeout = 3 * time.Second
+	DefaultHeartbeat   = 10 * time.Second
+)
+
+const (
+	UDPRelayModeNative = "native"
+	UDPRelayModeQUIC   = "quic"
+)
+
+const (
+	CommandAuthenticate = iota
+	CommandConnect
+	CommandPacket
+	CommandDissociate
+	CommandHeartbeat
+)
+
+const (
+	AddressTypeDomain = 0x00
+	AddressTypeIPv4   = 0x01
+	AddressTypeIPv6   = 0x02
+	AddressTypeNone   = 0xff
+)
+
+const (
+	AuthenticationTokenLength = 32
+	authenticateLength        = 2 + 16 + AuthenticationTokenLength
+	packetHeaderLength        = 2 + 2 + 2 + 1 + 1 + 2
+)
+
+func ReadCommand(reader io.Reader) (byte, error) {
+	var header [2]byte
+	_, err := io.ReadFull(reader, header[:])
+	if err != nil {
+		return 0, err
+	}
+	if header[0] != Version {
+		return 0, E.New("unknown version: ", header[0])
+	}
+	return header[1], nil
+}
+
+func WriteAuthenticate(writer io.Writer, uuid [16]byte, token []byte) error {
+	_request := buf.StackNewSize(authenticateLength)
+	defer common.KeepAlive(_request)
+	request := common.Dup(_request)
+	defer request.Release()
+	common.Must(
+		request.WriteByte(Version),
+		request.WriteByte(CommandAuthenticate),
+		common.Error(request.Write(uuid[:])),
+		common.Error(request.Write(token)),
+	)
+	return common.Error(writer.Write(request.Bytes()))
+}
+
+func ReadAuthenticate(reader io.Reader) (uuid [16]byte, token [AuthenticationTokenLength]byte, err error) {
+	_, err = io.ReadFull(reader, uuid[:])
+	if err != nil {
+		return
+	}
+	_, err = io.ReadFull(reader, token[:])
+	return
+}
+
+func WriteConnect(writer io.Writer, destination M.Socksaddr) error {
+	_request := buf.StackNewSize(2 + AddressLength(destination))
+	defer common.KeepAlive(_request)
+	request := common.Dup(_request)
+	defer request.Release()
+	common.Must(
+		request.WriteByte(Version),
+		request.WriteByte(CommandConnect),
+		WriteAddress(request, destination),
+	)
+	return common.Error(writer.Write(request.Bytes()))
+}
+
+func WriteDissociate(writer io.Writer, sessionID uint16) error {
+	var request [4]byte
+	request[0] = Version
+	request[1] = CommandDissociate
+	binary.BigEndian.PutUint16(request[2:], sessionID)
+	return common.Error(writer.Write(request[:]))
+}
+
+func HeartbeatMessage() []byte {
+	return []byte{Version, CommandHeartbeat}
+}
+
+func AddressLength(address M.Socksaddr) int {
+	switch {
+	case !address.IsValid():
+		return 1
+	case address.IsFqdn():
+		return 1 + 1 + len(address.Fqdn) + 2
+	case address.Addr.Is4():
+		return 1 + 4 + 2
+	default:
+		return 1 + 16 + 2
+	}
+}
+
+// WriteAddress writes the TUIC address, an invalid address is written as None.
+func WriteAddress(buffer *buf.Buffer, address M.Socksaddr) error {
+	address = address.Unwrap()
+	switch {
+	case !address.IsValid():
+		return buffer.WriteByte(AddressTypeNone)
+	case address.IsFqdn():
+		if len(address.Fqdn) > 255 {
+			return E.New("domain name too long: ", address.Fqdn)
+		}
+		common.Must(
+			buffer.WriteByte(AddressTypeDomain),
+			buffer.WriteByte(byte(len(address.Fqdn))),
+			common.Error(buffer.WriteString(address.Fqdn)),
+		)
+	case address.Addr.Is4():
+		common.Must(
+			buffer.WriteByte(AddressTypeIPv4),
+			common.Error(buffer.Write(address.Addr.AsSlice())),
+		)
+	default:
+		common.Must(
+			buffer.WriteByte(AddressTypeIPv6),
+			common.Error(buffer.Write(address.Addr.AsSlice())),
+		)
+	}
+	return binary.Write(buffer, binary.BigEndian, address.Port)
+}
+
+func ReadAddress(reader io.Reader) (M.Socksaddr, error) {
+	addressType, err := rw.ReadByte(reader)
+	if err != nil {
+		return M.Socksaddr{}, err
+	}
+	var address M.Socksaddr
+	switch addressType {
+	case AddressTypeNone:
+		return M.Socksaddr{}, nil
+	case AddressTypeDomain:
+		domainLength, err := rw.ReadByte(reader)
+		if err != nil {
+			return M.Socksaddr{}, err
+		}
+		domain, err := rw.ReadBytes(reader, int(domainLength))
+		if err != nil {
+			return M.Socksaddr{}, err
+		}
+		address.Fqdn = string(domain)
+	case AddressTypeIPv4:
+		var addr [4]byte
+		_, err = io.ReadFull(reader, addr[:])
+		if err != nil {
+			return M.Socksaddr{}, err
+		}
+		address.Addr = netip.AddrFrom4(addr)
+	case AddressTypeIPv6:
+		var addr [16]byte
+		_, err = io.ReadFull(reader, addr[:])
+		if err != nil {
+			return M.Socksaddr{}, err
+		}
+		address.Addr = netip.AddrFrom16(addr).Unmap()
+	default:
+		return M.Socksaddr{}, E.New("unknown address type: ", addressType)
+	}
+	err = binary.Read(reader, binary.BigEndian, &address.Port)
+	if err != nil {
+		return M.Socksaddr{}, err
+	}
+	return address.Unwrap(), nil
+}
@@ -0,0 +1,426 @@
+package tuic
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sagernet/quic-go"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/transport/hysteria"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/auth"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	aTLS "github.com/sagernet/sing/common/tls"
+)
+
+type Handler interface {
+	N.TCPConnectionHandler
+	N.UDPConnectionHandler
+	E.Handler
+}
+
+type ServiceOptions struct {
+	Context           context.Context
+	TLSConfig         aTLS.ServerConfig
+	CongestionControl string
+	AuthTimeout       time.Duration
+	ZeroRTTHandshake  bool
+	Heartbeat         time.Duration
+	Handler           Handler
+}
+
+type Service[U comparable] struct {
+	ctx               context.Context
+	tlsConfig         aTLS.ServerConfig
+	quicConfig        *quic.Config
+	congestionControl string
+	authTimeout       time.Duration
+	heartbeat         time.Duration
+	zeroRTTHandshake  bool
+	handler           Handler
+
+	userAccess  sync.RWMutex
+	userMap     map[[16]byte]U
+	passwordMap map[U]string
+	listener    io.Closer
+}
+
+func NewService[U comparable](options ServiceOptions) (*Service[U], error) {
+	if options.AuthTimeout == 0 {
+		options.AuthTimeout = DefaultAuthTimeout
+	}
+	if options.Heartbeat == 0 {
+		options.Heartbeat = DefaultHeartbeat
+	}
+	if options.CongestionControl == "" {
+		options.CongestionControl = "cubic"
+	}
+	err := checkCongestionControl(options.CongestionControl)
+	if err != nil {
+		return nil, err
+	}
+	quicConfig := &quic.Config{
+		DisablePathMTUDiscovery: !(C.IsLinux || C.IsWindows),
+		EnableDatagrams:         true,
+		MaxIncomingStreams:      1 << 60,
+		MaxIncomingUniStreams:   1 << 60,
+	}
+	if options.ZeroRTTHandshake {
+		quicConfig.Allow0RTT = func(net.Addr) bool {
+			return true
+		}
+	}
+	return &Service[U]{
+		ctx:               options.Context,
+		tlsConfig:         options.TLSConfig,
+		quicConfig:        quicConfig,
+		congestionControl: options.CongestionControl,
+		authTimeout:       options.AuthTimeout,
+		heartbeat:         options.Heartbeat,
+		zeroRTTHandshake:  options.ZeroRTTHandshake,
+		handler:           options.Handler,
+		userMap:           make(map[[16]byte]U),
+		passwordMap:       make(map[U]string),
+	}, nil
+}
+
+func (s *Service[U]) UpdateUsers(userList []U, uuidList [][16]byte, passwordList []string) {
+	userMap := make(map[[16]byte]U)
+	passwordMap := make(map[U]string)
+	for index, user := range userList {
+		userMap[uuidList[index]] = user
+		passwordMap[user] = passwordList[index]
+	}
+	s.userAccess.Lock()
+	s.userMap = userMap
+	s.passwordMap = passwordMap
+	s.userAccess.Unlock()
+}
+
+func (s *Service[U]) loadUser(uuid [16]byte) (U, string, bool) {
+	s.userAccess.RLock()
+	defer s.userAccess.RUnlock()
+	user, loaded := s.userMap[uuid]
+	if !loaded {
+		return user, "", false
+	}
+	return user, s.passwordMap[user], true
+}
+
+func (s *Service[U]) Start(conn net.PacketConn) error {
+	tlsConfig, err := s.tlsConfig.Config()
+	if err != nil {
+		return err
+	}
+	if !s.zeroRTTHandshake {
+		listener, err := quic.Listen(conn, tlsConfig, s.quicConfig)
+		if err != nil {
+			return err
+		}
+		s.listener = listener
+		go func() {
+			for {
+				connection, hErr := listener.Accept(s.ctx)
+				if hErr != nil {
+					return
+				}
+				go s.handleConnection(connection)
+			}
+		}()
+	} else {
+		listener, err := quic.ListenEarly(conn, tlsConfig, s.quicConfig)
+		if err != nil {
+			return err
+		}
+		s.listener = listener
+		go func() {
+			for {
+				connection, hErr := listener.Accept(s.ctx)
+				if hErr != nil {
+					return
+				}
+				go s.handleConnection(connection)
+			}
+		}()
+	}
+	return nil
+}
+
+func (s *Service[U]) Close() error {
+	return common.Close(s.listener)
+}
+
+func (s *Service[U]) handleConnection(connection quic.Connection) {
+	setCongestion(connection, s.congestionControl)
+	session := &serverSession[U]{
+		Service:    s,
+		ctx:        log.ContextWithNewID(s.ctx),
+		quicConn:   connection,
+		source:     M.SocksaddrFromNet(connection.RemoteAddr()).Unwrap(),
+		connDone:   make(chan struct{}),
+		authDone:   make(chan struct{}),
+		udpConnMap: make(map[uint16]*udpPacketConn),
+	}
+	session.handle()
+}
+
+type serverSession[U comparable] struct {
+	*Service[U]
+	ctx        context.Context
+	quicConn   quic.Connection
+	source     M.Socksaddr
+	closeOnce  sync.Once
+	connDone   chan struct{}
+	connErr    error
+	authAccess sync.Mutex
+	authDone   chan struct{}
+	authUser   U
+	udpAccess  sync.RWMutex
+	udpConnMap map[uint16]*udpPacketConn
+}
+
+func (s *serverSession[U]) handle() {
+	go s.loopUniStreams()
+	go s.loopStreams()
+	go s.loopMessages()
+	go s.handleAuthTimeout()
+	go s.loopHeartbeats()
+}
+
+func (s *serverSession[U]) loopUniStreams() {
+	for {
+		uniStream, err := s.quicConn.AcceptUniStream(s.ctx)
+		if err != nil {
+			s.closeWithError(E.Cause(err, "accept uni stream"))
+			return
+		}
+		go func() {
+			err := s.handleUniStream(uniStream)
+			uniStream.CancelRead(0)
+			if err != nil {
+				s.closeWithError(E.Cause(err, "handle uni stream"))
+			}
+		}()
+	}
+}
+
+func (s *serverSession[U]) handleUniStream(stream quic.ReceiveStream) error {
+	command, err := ReadCommand(stream)
+	if err != nil {
+		return err
+	}
+	switch command {
+	case CommandAuthenticate:
+		s.authAccess.Lock()
+		defer s.authAccess.Unlock()
+		select {
+		case <-s.authDone:
+			return E.New("authentication: multiple authentication requests")
+		default:
+		}
+		uuid, token, err := ReadAuthenticate(stream)
+		if err != nil {
+			return E.Cause(err, "authentication: read request")
+		}
+		user, password, loaded := s.loadUser(uuid)
+		if !loaded {
+			return E.New("authentication: unknown user ", uuid)
+		}
+		tlsState := s.quicConn.ConnectionState().TLS
+		expectedToken, err := tlsState.ExportKeyingMaterial(string(uuid[:]), []byte(password), AuthenticationTokenLength)
+		if err != nil {
+			return E.Cause(err, "authentication: export keying material")
+		}
+		if subtle.ConstantTimeCompare(expectedToken, token[:]) != 1 {
+			return E.New("authentication: token mismatch")
+		}
+		s.authUser = user
+		close(s.authDone)
+		return nil
+	case CommandPacket:
+		err = s.waitAuthentication()
+		if err != nil {
+			return err
+		}
+		message, err := readUDPMessage(stream)
+		if err != nil {
+			return err
+		}
+		s.handleUDPMessage(message, true)
+		return nil
+	case CommandDissociate:
+		err = s.waitAuthentication()
+		if err != nil {
+			return err
+		}
+		var sessionID uint16
+		err = binary.Read(stream, binary.BigEndian, &sessionID)
+		if err != nil {
+			return err
+		}
+		s.udpAccess.RLock()
+		udpConn, loaded := s.udpConnMap[sessionID]
+		s.udpAccess.RUnlock()
+		if loaded {
+			udpConn.closeWithError()
+		}
+		return nil
+	default:
+		return E.New("unknown uni stream command: ", command)
+	}
+}
+
+func (s *serverSession[U]) loopStreams() {
+	for {
+		stream, err := s.quicConn.AcceptStream(s.ctx)
+		if err != nil {
+			s.closeWithError(E.Cause(err, "accept stream"))
+			return
+		}
+		go func() {
+			err := s.handleStream(stream)
+			if err != nil {
+				stream.CancelRead(0)
+				stream.Close()
+				s.handler.NewError(s.ctx, E.Cause(err, "handle stream request"))
+			}
+		}()
+	}
+}
+
+func (s *serverSession[U]) handleStream(stream quic.Stream) error {
+	command, err := ReadCommand(stream)
+	if err != nil {
+		return E.Cause(err, "read request")
+	}
+	if command != CommandConnect {
+		return E.New("unknown stream command: ", command)
+	}
+	destination, err := ReadAddress(stream)
+	if err != nil {
+		return E.Cause(err, "read request destination")
+	}
+	err = s.waitAuthentication()
+	if err != nil {
+		return err
+	}
+	ctx := auth.ContextWithUser(s.ctx, s.authUser)
+	conn := &hysteria.StreamWrapper{Conn: s.quicConn, Stream: stream}
+	return s.handler.NewConnection(ctx, conn, M.Metadata{
+		Source:      s.source,
+		Destination: destination,
+	})
+}
+
+func (s *serverSession[U]) loopMessages() {
+	for {
+		data, err := s.quicConn.ReceiveMessage()
+		if err != nil {
+			s.closeWithError(E.Cause(err, "receive message"))
+			return
+		}
+		message, err := handleDatagram(data)
+		if err != nil {
+			s.closeWithError(E.Cause(err, "handle datagram"))
+			return
+		}
+		if message == nil {
+			continue
+		}
+		err = s.waitAuthentication()
+		if err != nil {
+			message.release()
+			return
+		}
+		s.handleUDPMessage(message, false)
+	}
+}
+
+func (s *serverSession[U]) handleUDPMessage(message *udpMessage, udpStream bool) {
+	s.udpAccess.Lock()
+	udpConn, loaded := s.udpConnMap[message.sessionID]
+	if !loaded {
+		sessionID := message.sessionID
+		udpConn = newUDPPacketConn(s.ctx, s.quicConn, udpStream, true, sessionID, func() {
+			s.udpAccess.Lock()
+			delete(s.udpConnMap, sessionID)
+			s.udpAccess.Unlock()
+		})
+		s.udpConnMap[sessionID] = udpConn
+	}
+	s.udpAccess.Unlock()
+	if !loaded {
+		destination := message.destination
+		go func() {
+			ctx := auth.ContextWithUser(s.ctx, s.authUser)
+			err := s.handler.NewPacketConnection(ctx, udpConn, M.Metadata{
+				Source:      s.source,
+				Destination: destination,
+			})
+			udpConn.closeWithError()
+			if err != nil {
+				s.handler.NewError(ctx, E.Cause(err, "handle packet connection"))
+			}
+		}()
+	}
+	udpConn.inputPacket(message)
+}
+
+func (s *serverSession[U]) waitAuthentication() error {
+	select {
+	case <-s.authDone:
+		return nil
+	case <-s.connDone:
+		return s.connErr
+	}
+}
+
+func (s *serverSession[U]) handleAuthTimeout() {
+	select {
+	case <-s.connDone:
+	case <-s.authDone:
+	case <-time.After(s.authTimeout):
+		s.closeWithError(E.New("authentication timeout"))
+	}
+}
+
+func (s *serverSession[U]) loopHeartbeats() {
+	ticker := time.NewTicker(s.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.connDone:
+			return
+		case <-ticker.C:
+			err := s.quicConn.SendMessage(HeartbeatMessage())
+			if err != nil {
+				s.closeWithError(E.Cause(err, "send heartbeat"))
+				return
+			}
+		}
+	}
+}
+
+func (s *serverSession[U]) closeWithError(err error) {
+	s.closeOnce.Do(func() {
+		s.connErr = err
+		close(s.connDone)
+		s.handler.NewError(s.ctx, E.Cause(err, "process connection from ", s.source))
+		s.quicConn.CloseWithError(0, "")
+		s.udpAccess.Lock()
+		udpConnMap := s.udpConnMap
+		s.udpConnMap = make(map[uint16]*udpPacketConn)
+		s.udpAccess.Unlock()
+		for _, udpConn := range udpConnMap {
+			udpConn.closeWithError()
+		}
+	})
+}
@@ -0,0 +1,203 @@
+package v2raysplithttp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/tls"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/v2rayhttp"
+	"github.com/sagernet/sing/common/bufio/deadline"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/gofrs/uuid/v5"
+	"golang.org/x/net/http2"
+)
+
+const (
+	DefaultMaxUploadSize        = 1024 * 1024
+	DefaultMaxConcurrentUploads = 10
+)
+
+var _ adapter.V2RayClientTransport = (*Client)(nil)
+
+type Client struct {
+	ctx                  context.Context
+	transport            http.RoundTripper
+	url                  url.URL
+	host                 string
+	headers              http.Header
+	maxUploadSize        int
+	maxConcurrentUploads int
+}
+
+func NewClient(ctx context.Context, dialer N.Dialer, serverAddr M.Socksaddr, options option.V2RaySplitHTTPOptions, tlsConfig tls.Config) (adapter.V2RayClientTransport, error) {
+	var transport http.RoundTripper
+	if tlsConfig == nil {
+		transport = &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, M.ParseSocksaddr(addr))
+			},
+		}
+	} else {
+		if len(tlsConfig.NextProtos()) == 0 {
+			tlsConfig.SetNextProtos([]string{http2.NextProtoTLS})
+		}
+		transport = &http2.Transport{
+			DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.STDConfig) (net.Conn, error) {
+				conn, err := dialer.DialContext(ctx, network, M.ParseSocksaddr(addr))
+				if err != nil {
+					return nil, err
+				}
+				return tls.ClientHandshake(ctx, conn, tlsConfig)
+			},
+		}
+	}
+	client := &Client{
+		ctx:                  ctx,
+		transport:            transport,
+		host:                 options.Host,
+		headers:              make(http.Header),
+		maxUploadSize:        int(options.MaxUploadSize),
+		maxConcurrentUploads: int(options.MaxConcurrentUploads),
+	}
+	if client.host == "" {
+		client.host = serverAddr.AddrString()
+	}
+	if client.maxUploadSize == 0 {
+		client.maxUploadSize = DefaultMaxUploadSize
+	}
+	if client.maxConcurrentUploads == 0 {
+		client.maxConcurrentUploads = DefaultMaxConcurrentUploads
+	}
+	if tlsConfig == nil {
+		client.url.Scheme = "http"
+	} else {
+		client.url.Scheme = "https"
+	}
+	client.url.Host = serverAddr.String()
+	client.url.Path = normalizePath(options.Path)
+	for key, value := range options.Headers {
+		client.headers[key] = value
+	}
+	return client, nil
+}
+
+func (c *Client) DialContext(ctx context.Context) (net.Conn, error) {
+	sessionID, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+	sessionURL := c.url
+	sessionURL.Path += sessionID.String()
+	// the connection outlives the dial context, so bind the requests to the client context
+	connCtx, cancel := context.WithCancel(c.ctx)
+	uploadReader, uploadWriter := io.Pipe()
+	conn := &clientConn{
+		cancel:       cancel,
+		uploadWriter: uploadWriter,
+		create:       make(chan struct{}),
+	}
+	go c.loopDownload(connCtx, conn, sessionURL)
+	go c.loopUpload(connCtx, conn, sessionURL, uploadReader)
+	return deadline.NewConn(conn), nil
+}
+
+func (c *Client) newRequest(ctx context.Context, method string, requestURL url.URL, body io.Reader) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(ctx, method, requestURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	request.Host = c.host
+	for key, values := range c.headers {
+		request.Header[key] = values
+	}
+	return request, nil
+}
+
+func (c *Client) loopDownload(ctx context.Context, conn *clientConn, sessionURL url.URL) {
+	request, err := c.newRequest(ctx, http.MethodGet, sessionURL, nil)
+	if err != nil {
+		conn.setup(nil, err)
+		return
+	}
+	response, err := c.transport.RoundTrip(request)
+	if err != nil {
+		conn.setup(nil, E.Cause(err, "download"))
+	} else if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		conn.setup(nil, E.New("download: unexpected status: ", response.Status))
+	} else {
+		conn.setup(response.Body, nil)
+	}
+}
+
+func (c *Client) loopUpload(ctx context.Context, conn *clientConn, sessionURL url.URL, uploadReader *io.PipeReader) {
+	defer uploadReader.Close()
+	uploadLimit := make(chan struct{}, c.maxConcurrentUploads)
+	buffer := make([]byte, c.maxUploadSize)
+	for seq := uint64(0); ; seq++ {
+		n, err := uploadReader.Read(buffer)
+		if err != nil {
+			return
+		}
+		payload := make([]byte, n)
+		copy(payload, buffer[:n])
+		select {
+		case uploadLimit <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		go func(seq uint64) {
+			defer func() {
+				<-uploadLimit
+			}()
+			uErr := c.upload(ctx, sessionURL, seq, payload)
+			if uErr != nil {
+				conn.closeWithError(uErr)
+			}
+		}(seq)
+	}
+}
+
+func (c *Client) upload(ctx context.Context, sessionURL url.URL, seq uint64, payload []byte) error {
+	uploadURL := sessionURL
+	uploadURL.Path += "/" + strconv.FormatUint(seq, 10)
+	request, err := c.newRequest(ctx, http.MethodPost, uploadURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	response, err := c.transport.RoundTrip(request)
+	if err != nil {
+		return E.Cause(err, "upload")
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return E.New("upload: unexpected status: ", response.Status)
+	}
+	return nil
+}
+
+func (c *Client) Close() error {
+	v2rayhttp.CloseIdleConnections(c.transport)
+	return nil
+}
+
+func normalizePath(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	return path
+}
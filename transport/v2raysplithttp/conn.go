@@ -0,0 +1,125 @@
+package v2raysplithttp
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/common/baderror"
+	"github.com/sagernet/sing/common"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+type serverConn struct {
+	session    *serverSession
+	writer     io.Writer
+	flusher    http.Flusher
+	remoteAddr net.Addr
+}
+
+func (c *serverConn) Read(b []byte) (n int, err error) {
+	return c.session.uploadQueue.Read(b)
+}
+
+func (c *serverConn) Write(b []byte) (n int, err error) {
+	n, err = c.writer.Write(b)
+	if err == nil {
+		c.flusher.Flush()
+	}
+	return n, baderror.WrapH2(err)
+}
+
+func (c *serverConn) Close() error {
+	c.session.close()
+	return nil
+}
+
+func (c *serverConn) LocalAddr() net.Addr {
+	return M.Socksaddr{}
+}
+
+func (c *serverConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+func (c *serverConn) SetDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *serverConn) SetReadDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *serverConn) SetWriteDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+// clientConn writes to a pipe drained by the upload loop and reads from the download response.
+type clientConn struct {
+	cancel       context.CancelFunc
+	uploadWriter *io.PipeWriter
+	create       chan struct{}
+	reader       io.ReadCloser
+	err          error
+	closeOnce    sync.Once
+}
+
+func (c *clientConn) setup(reader io.ReadCloser, err error) {
+	c.reader = reader
+	c.err = err
+	close(c.create)
+}
+
+func (c *clientConn) Read(b []byte) (n int, err error) {
+	<-c.create
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err = c.reader.Read(b)
+	return n, baderror.WrapH2(err)
+}
+
+func (c *clientConn) Write(b []byte) (n int, err error) {
+	return c.uploadWriter.Write(b)
+}
+
+func (c *clientConn) closeWithError(err error) {
+	c.closeOnce.Do(func() {
+		c.uploadWriter.CloseWithError(err)
+		c.cancel()
+	})
+}
+
+func (c *clientConn) Close() error {
+	c.closeWithError(net.ErrClosed)
+	select {
+	case <-c.create:
+		return common.Close(c.reader)
+	default:
+		return nil
+	}
+}
+
+func (c *clientConn) LocalAddr() net.Addr {
+	return M.Socksaddr{}
+}
+
+func (c *clientConn) RemoteAddr() net.Addr {
+	return M.Socksaddr{}
+}
+
+func (c *clientConn) SetDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *clientConn) SetReadDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
+
+func (c *clientConn) SetWriteDeadline(t time.Time) error {
+	return os.ErrInvalid
+}
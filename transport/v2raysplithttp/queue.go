@@ -0,0 +1,125 @@
+package v2raysplithttp
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/sagernet/sing/common/atomic"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// uploadQueue reorders the uplink payloads, which may arrive out of order over concurrent requests.
+type uploadQueue struct {
+	access      sync.Mutex
+	cond        *sync.Cond
+	packets     map[uint64]*uploadPacket
+	nextSeq     uint64
+	current     []byte
+	maxPackets  int
+	buffered    *atomic.Int64
+	maxBuffered int64
+	closed      bool
+	done        chan struct{}
+}
+
+type uploadPacket struct {
+	payload []byte
+	read    chan struct{}
+}
+
+// newUploadQueue creates a queue accepting at most maxPackets payloads after the next expected one.
+// Buffered bytes are counted in buffered, which is shared by all queues of the server.
+func newUploadQueue(maxPackets int, buffered *atomic.Int64, maxBuffered int64) *uploadQueue {
+	queue := &uploadQueue{
+		packets:     make(map[uint64]*uploadPacket),
+		maxPackets:  maxPackets,
+		buffered:    buffered,
+		maxBuffered: maxBuffered,
+		done:        make(chan struct{}),
+	}
+	queue.cond = sync.NewCond(&queue.access)
+	return queue
+}
+
+// Push queues the payload and waits until the reader takes it, so a client
+// never gets more than its concurrent uploads ahead of the reader.
+func (q *uploadQueue) Push(ctx context.Context, seq uint64, payload []byte) error {
+	q.access.Lock()
+	if q.closed {
+		q.access.Unlock()
+		return net.ErrClosed
+	}
+	if _, loaded := q.packets[seq]; loaded || seq < q.nextSeq {
+		q.access.Unlock()
+		return E.New("duplicate upload: ", seq)
+	}
+	if seq-q.nextSeq >= uint64(q.maxPackets) {
+		q.access.Unlock()
+		return E.New("upload out of window: ", seq, ", expected ", q.nextSeq)
+	}
+	if q.buffered.Add(int64(len(payload))) > q.maxBuffered {
+		q.buffered.Add(-int64(len(payload)))
+		q.access.Unlock()
+		return E.New("too many buffered uploads")
+	}
+	packet := &uploadPacket{
+		payload: payload,
+		read:    make(chan struct{}),
+	}
+	q.packets[seq] = packet
+	q.cond.Broadcast()
+	q.access.Unlock()
+	select {
+	case <-packet.read:
+		return nil
+	case <-q.done:
+		return net.ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *uploadQueue) Read(p []byte) (n int, err error) {
+	q.access.Lock()
+	defer q.access.Unlock()
+	for {
+		for len(q.current) == 0 {
+			packet, loaded := q.packets[q.nextSeq]
+			if !loaded {
+				break
+			}
+			delete(q.packets, q.nextSeq)
+			q.nextSeq++
+			q.buffered.Add(-int64(len(packet.payload)))
+			close(packet.read)
+			q.current = packet.payload
+		}
+		if len(q.current) > 0 {
+			n = copy(p, q.current)
+			q.current = q.current[n:]
+			return
+		}
+		if q.closed {
+			return 0, io.EOF
+		}
+		q.cond.Wait()
+	}
+}
+
+func (q *uploadQueue) Close() error {
+	q.access.Lock()
+	defer q.access.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	for seq, packet := range q.packets {
+		q.buffered.Add(-int64(len(packet.payload)))
+		delete(q.packets, seq)
+	}
+	close(q.done)
+	q.cond.Broadcast()
+	return nil
+}
@@ -0,0 +1,279 @@
+package v2raysplithttp
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/tls"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/v2rayhttp"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/atomic"
+	"github.com/sagernet/sing/common/bufio/deadline"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	aTLS "github.com/sagernet/sing/common/tls"
+	sHttp "github.com/sagernet/sing/protocol/http"
+
+	"golang.org/x/net/http2"
+)
+
+var _ adapter.V2RayServerTransport = (*Server)(nil)
+
+const (
+	// maxPendingSessions limits sessions created by uploads whose download request has not arrived.
+	maxPendingSessions = 1024
+	// maxBufferedBytes limits upload payloads buffered across all sessions.
+	maxBufferedBytes = 64 * 1024 * 1024
+)
+
+type Server struct {
+	ctx                  context.Context
+	tlsConfig            tls.ServerConfig
+	handler              adapter.V2RayServerTransportHandler
+	httpServer           *http.Server
+	host                 string
+	path                 string
+	headers              http.Header
+	maxUploadSize        int
+	maxConcurrentUploads int
+
+	sessionAccess   sync.Mutex
+	sessions        map[string]*serverSession
+	pendingSessions int
+	bufferedBytes   atomic.Int64
+}
+
+type serverSession struct {
+	server      *Server
+	id          string
+	uploadQueue *uploadQueue
+	connected   bool
+	closeOnce   sync.Once
+	reaper      *time.Timer
+}
+
+func NewServer(ctx context.Context, options option.V2RaySplitHTTPOptions, tlsConfig tls.ServerConfig, handler adapter.V2RayServerTransportHandler) (*Server, error) {
+	server := &Server{
+		ctx:                  ctx,
+		tlsConfig:            tlsConfig,
+		handler:              handler,
+		host:                 options.Host,
+		path:                 normalizePath(options.Path),
+		headers:              make(http.Header),
+		maxUploadSize:        int(options.MaxUploadSize),
+		maxConcurrentUploads: int(options.MaxConcurrentUploads),
+		sessions:             make(map[string]*serverSession),
+	}
+	if server.maxUploadSize == 0 {
+		server.maxUploadSize = DefaultMaxUploadSize
+	}
+	if server.maxConcurrentUploads == 0 {
+		server.maxConcurrentUploads = DefaultMaxConcurrentUploads
+	}
+	for key, value := range options.Headers {
+		server.headers[key] = value
+	}
+	server.httpServer = &http.Server{
+		Handler:           server,
+		ReadHeaderTimeout: C.TCPTimeout,
+		MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+	}
+	return server, nil
+}
+
+func (s *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if s.host != "" && request.Host != s.host {
+		s.fallbackRequest(request.Context(), writer, request, http.StatusBadRequest, E.New("bad host: ", request.Host))
+		return
+	}
+	if !strings.HasPrefix(request.URL.Path, s.path) {
+		s.fallbackRequest(request.Context(), writer, request, http.StatusNotFound, E.New("bad path: ", request.URL.Path))
+		return
+	}
+	parts := strings.Split(request.URL.Path[len(s.path):], "/")
+	switch {
+	case request.Method == http.MethodGet && len(parts) == 1 && parts[0] != "":
+		s.serveDownload(writer, request, parts[0])
+	case request.Method == http.MethodPost && len(parts) == 2 && parts[0] != "":
+		seq, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			s.handler.NewError(request.Context(), E.Cause(err, "process upload from ", request.RemoteAddr, ": bad sequence"))
+			return
+		}
+		s.serveUpload(writer, request, parts[0], seq)
+	default:
+		s.fallbackRequest(request.Context(), writer, request, http.StatusNotFound, E.New("bad request: ", request.Method, " ", request.URL.Path))
+	}
+}
+
+func (s *Server) serveDownload(writer http.ResponseWriter, request *http.Request, sessionID string) {
+	flusher, isFlusher := writer.(http.Flusher)
+	if !isFlusher {
+		writer.WriteHeader(http.StatusInternalServerError)
+		s.handler.NewError(request.Context(), E.New("process download from ", request.RemoteAddr, ": streaming not supported"))
+		return
+	}
+	session, err := s.loadSession(sessionID)
+	if err != nil {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		s.handler.NewError(request.Context(), E.Cause(err, "process download from ", request.RemoteAddr))
+		return
+	}
+	s.sessionAccess.Lock()
+	// a session closed after loading is no longer counted as pending
+	connected := session.connected || s.sessions[sessionID] != session
+	if !connected {
+		session.connected = true
+		s.pendingSessions--
+	}
+	s.sessionAccess.Unlock()
+	if connected {
+		writer.WriteHeader(http.StatusConflict)
+		s.handler.NewError(request.Context(), E.New("process download from ", request.RemoteAddr, ": duplicate session ", sessionID))
+		return
+	}
+	session.reaper.Stop()
+	defer session.close()
+	go func() {
+		<-request.Context().Done()
+		session.close()
+	}()
+	for key, values := range s.headers {
+		for _, value := range values {
+			writer.Header().Add(key, value)
+		}
+	}
+	writer.Header().Set("Cache-Control", "no-store")
+	writer.Header().Set("X-Accel-Buffering", "no")
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	var metadata M.Metadata
+	metadata.Source = sHttp.SourceAddress(request)
+	conn := v2rayhttp.NewHTTP2Wrapper(&serverConn{
+		session:    session,
+		writer:     writer,
+		flusher:    flusher,
+		remoteAddr: metadata.Source.TCPAddr(),
+	})
+	s.handler.NewConnection(request.Context(), deadline.NewConn(conn), metadata)
+	conn.CloseWrapper()
+}
+
+func (s *Server) serveUpload(writer http.ResponseWriter, request *http.Request, sessionID string, seq uint64) {
+	payload, err := io.ReadAll(io.LimitReader(request.Body, int64(s.maxUploadSize)+1))
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		s.handler.NewError(request.Context(), E.Cause(err, "process upload from ", request.RemoteAddr, ": read payload"))
+		return
+	}
+	if len(payload) > s.maxUploadSize {
+		writer.WriteHeader(http.StatusRequestEntityTooLarge)
+		s.handler.NewError(request.Context(), E.New("process upload from ", request.RemoteAddr, ": payload too large"))
+		return
+	}
+	session, err := s.loadSession(sessionID)
+	if err != nil {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		s.handler.NewError(request.Context(), E.Cause(err, "process upload from ", request.RemoteAddr))
+		return
+	}
+	err = session.uploadQueue.Push(request.Context(), seq, payload)
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		s.handler.NewError(request.Context(), E.Cause(err, "process upload from ", request.RemoteAddr))
+		return
+	}
+	writer.WriteHeader(http.StatusOK)
+}
+
+// loadSession returns the session with the ID, creating it if not exists, since uploads may arrive before the download.
+func (s *Server) loadSession(sessionID string) (*serverSession, error) {
+	s.sessionAccess.Lock()
+	defer s.sessionAccess.Unlock()
+	session, loaded := s.sessions[sessionID]
+	if loaded {
+		return session, nil
+	}
+	if s.pendingSessions >= maxPendingSessions {
+		return nil, E.New("too many pending sessions")
+	}
+	session = &serverSession{
+		server:      s,
+		id:          sessionID,
+		uploadQueue: newUploadQueue(s.maxConcurrentUploads, &s.bufferedBytes, maxBufferedBytes),
+	}
+	// drop sessions whose download request never arrives
+	session.reaper = time.AfterFunc(C.TCPTimeout, session.close)
+	s.sessions[sessionID] = session
+	s.pendingSessions++
+	return session, nil
+}
+
+func (s *serverSession) close() {
+	s.closeOnce.Do(func() {
+		s.reaper.Stop()
+		s.server.sessionAccess.Lock()
+		if s.server.sessions[s.id] == s {
+			delete(s.server.sessions, s.id)
+		}
+		if !s.connected {
+			s.server.pendingSessions--
+		}
+		s.server.sessionAccess.Unlock()
+		s.uploadQueue.Close()
+	})
+}
+
+func (s *Server) fallbackRequest(ctx context.Context, writer http.ResponseWriter, request *http.Request, statusCode int, err error) {
+	conn := v2rayhttp.NewHTTPConn(request.Body, writer)
+	fErr := s.handler.FallbackConnection(ctx, &conn, M.Metadata{})
+	if fErr == nil {
+		return
+	} else if fErr == os.ErrInvalid {
+		fErr = nil
+	}
+	if statusCode > 0 {
+		writer.WriteHeader(statusCode)
+	}
+	s.handler.NewError(request.Context(), E.Cause(E.Errors(err, E.Cause(fErr, "fallback connection")), "process connection from ", request.RemoteAddr))
+}
+
+func (s *Server) Network() []string {
+	return []string{N.NetworkTCP}
+}
+
+func (s *Server) Serve(listener net.Listener) error {
+	if s.tlsConfig != nil {
+		if len(s.tlsConfig.NextProtos()) == 0 {
+			s.tlsConfig.SetNextProtos([]string{http2.NextProtoTLS, "http/1.1"})
+		} else if !common.Contains(s.tlsConfig.NextProtos(), http2.NextProtoTLS) {
+			s.tlsConfig.SetNextProtos(append([]string{http2.NextProtoTLS}, s.tlsConfig.NextProtos()...))
+		}
+		listener = aTLS.NewListener(listener, s.tlsConfig)
+	}
+	return s.httpServer.Serve(listener)
+}
+
+func (s *Server) ServePacket(listener net.PacketConn) error {
+	return os.ErrInvalid
+}
+
+func (s *Server) Close() error {
+	return common.Close(common.PtrOrNil(s.httpServer))
+}
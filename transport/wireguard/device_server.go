@@ -0,0 +1,201 @@
+//go:build with_gvisor
+
+package wireguard
+
+import (
+	"context"
+	"os"
+
+	"github.com/sagernet/sing-tun"
+	"github.com/sagernet/sing/common/logger"
+	wgTun "github.com/sagernet/wireguard-go/tun"
+
+	"gvisor.dev/gvisor/pkg/bufferv2"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+)
+
+var _ wgTun.Device = (*ServerDevice)(nil)
+
+// ServerDevice terminates packets from WireGuard peers on a gVisor stack and
+// passes accepted TCP and UDP flows to the handler.
+type ServerDevice struct {
+	mtu        uint32
+	events     chan wgTun.Event
+	outbound   chan *stack.PacketBuffer
+	done       chan struct{}
+	dispatcher stack.NetworkDispatcher
+	stack      tun.Stack
+}
+
+func NewServerDevice(ctx context.Context, mtu uint32, udpTimeout int64, handler tun.Handler, logger logger.Logger) (*ServerDevice, error) {
+	tunDevice := &ServerDevice{
+		mtu:      mtu,
+		events:   make(chan wgTun.Event, 1),
+		outbound: make(chan *stack.PacketBuffer, 256),
+		done:     make(chan struct{}),
+	}
+	tunStack, err := tun.NewGVisor(tun.StackOptions{
+		Context:    ctx,
+		Tun:        (*serverTun)(tunDevice),
+		MTU:        mtu,
+		UDPTimeout: udpTimeout,
+		Handler:    handler,
+		Logger:     logger,
+	})
+	if err != nil {
+		return nil, err
+	}
+	tunDevice.stack = tunStack
+	return tunDevice, nil
+}
+
+func (w *ServerDevice) Start() error {
+	err := w.stack.Start()
+	if err != nil {
+		return err
+	}
+	w.events <- wgTun.EventUp
+	return nil
+}
+
+func (w *ServerDevice) File() *os.File {
+	return nil
+}
+
+func (w *ServerDevice) Read(p []byte, offset int) (n int, err error) {
+	select {
+	case packetBuffer, ok := <-w.outbound:
+		if !ok {
+			return 0, os.ErrClosed
+		}
+		defer packetBuffer.DecRef()
+		p = p[offset:]
+		for _, slice := range packetBuffer.AsSlices() {
+			n += copy(p[n:], slice)
+		}
+		return
+	case <-w.done:
+		return 0, os.ErrClosed
+	}
+}
+
+func (w *ServerDevice) Write(p []byte, offset int) (n int, err error) {
+	p = p[offset:]
+	if len(p) == 0 || w.dispatcher == nil {
+		return
+	}
+	var networkProtocol tcpip.NetworkProtocolNumber
+	switch header.IPVersion(p) {
+	case header.IPv4Version:
+		networkProtocol = header.IPv4ProtocolNumber
+	case header.IPv6Version:
+		networkProtocol = header.IPv6ProtocolNumber
+	}
+	packetBuffer := stack.NewPacketBuffer(stack.PacketBufferOptions{
+		Payload: bufferv2.MakeWithData(p),
+	})
+	defer packetBuffer.DecRef()
+	w.dispatcher.DeliverNetworkPacket(networkProtocol, packetBuffer)
+	n = len(p)
+	return
+}
+
+func (w *ServerDevice) Flush() error {
+	return nil
+}
+
+func (w *ServerDevice) MTU() (int, error) {
+	return int(w.mtu), nil
+}
+
+func (w *ServerDevice) Name() (string, error) {
+	return "sing-box", nil
+}
+
+func (w *ServerDevice) Events() chan wgTun.Event {
+	return w.events
+}
+
+func (w *ServerDevice) Close() error {
+	select {
+	case <-w.done:
+		return os.ErrClosed
+	default:
+	}
+	w.stack.Close()
+	close(w.done)
+	return nil
+}
+
+// serverTun exposes the device to the sing-tun gVisor stack, which only
+// needs its link endpoint.
+type serverTun ServerDevice
+
+func (t *serverTun) Read(p []byte) (n int, err error) {
+	return 0, os.ErrInvalid
+}
+
+func (t *serverTun) Write(p []byte) (n int, err error) {
+	return 0, os.ErrInvalid
+}
+
+func (t *serverTun) Close() error {
+	return nil
+}
+
+func (t *serverTun) NewEndpoint() (stack.LinkEndpoint, error) {
+	return (*serverEndpoint)(t), nil
+}
+
+var _ stack.LinkEndpoint = (*serverEndpoint)(nil)
+
+type serverEndpoint ServerDevice
+
+func (ep *serverEndpoint) MTU() uint32 {
+	return ep.mtu
+}
+
+func (ep *serverEndpoint) MaxHeaderLength() uint16 {
+	return 0
+}
+
+func (ep *serverEndpoint) LinkAddress() tcpip.LinkAddress {
+	return ""
+}
+
+func (ep *serverEndpoint) Capabilities() stack.LinkEndpointCapabilities {
+	return stack.CapabilityNone
+}
+
+func (ep *serverEndpoint) Attach(dispatcher stack.NetworkDispatcher) {
+	ep.dispatcher = dispatcher
+}
+
+func (ep *serverEndpoint) IsAttached() bool {
+	return ep.dispatcher != nil
+}
+
+func (ep *serverEndpoint) Wait() {
+}
+
+func (ep *serverEndpoint) ARPHardwareType() header.ARPHardwareType {
+	return header.ARPHardwareNone
+}
+
+func (ep *serverEndpoint) AddHeader(buffer *stack.PacketBuffer) {
+}
+
+func (ep *serverEndpoint) WritePackets(list stack.PacketBufferList) (int, tcpip.Error) {
+	for _, packetBuffer := range list.AsSlice() {
+		packetBuffer.IncRef()
+		select {
+		case <-ep.done:
+			packetBuffer.DecRef()
+			return 0, &tcpip.ErrClosedForSend{}
+		case ep.outbound <- packetBuffer:
+		}
+	}
+	return list.Len(), nil
+}
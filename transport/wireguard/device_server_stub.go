@@ -0,0 +1,23 @@
+//go:build !with_gvisor
+
+package wireguard
+
+import (
+	"context"
+
+	"github.com/sagernet/sing-tun"
+	"github.com/sagernet/sing/common/logger"
+	wgTun "github.com/sagernet/wireguard-go/tun"
+)
+
+type ServerDevice struct {
+	wgTun.Device
+}
+
+func NewServerDevice(ctx context.Context, mtu uint32, udpTimeout int64, handler tun.Handler, logger logger.Logger) (*ServerDevice, error) {
+	return nil, tun.ErrGVisorNotIncluded
+}
+
+func (w *ServerDevice) Start() error {
+	return tun.ErrGVisorNotIncluded
+}